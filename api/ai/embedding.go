@@ -2,10 +2,12 @@ package ai
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"go_audio_search_api_server/globalUtils"
+	"go_audio_search_api_server/tracing"
 	"io"
 	"log/slog"
 	"net/http"
@@ -15,6 +17,7 @@ import (
 
 type EmbeddingWorker struct {
 	model      string
+	ollamaURL  string
 	requestURL string
 	lock       sync.Mutex
 }
@@ -26,11 +29,33 @@ func NewEmbeddingsWorker() *EmbeddingWorker {
 
 	return &EmbeddingWorker{
 		model:      model,
+		ollamaURL:  ollama,
 		requestURL: ollama + "/api/embed",
 	}
 }
 
-func (h *EmbeddingWorker) CreateEmbedding(text string) ([]float32, error) {
+// Model returns the name of the embedding model
+func (h *EmbeddingWorker) Model() string {
+	return h.model
+}
+
+// Dimension checks that the embedding model is available in Ollama and returns the length of its vectors
+func (h *EmbeddingWorker) Dimension(ctx context.Context) (uint64, error) {
+	info, err := showModel(ctx, h.ollamaURL, h.model)
+	if err != nil {
+		return 0, err
+	}
+
+	length, ok := info.embeddingLength()
+	if !ok {
+		return 0, fmt.Errorf("ollama reports no embedding length for model %s", h.model)
+	}
+
+	return length, nil
+}
+
+// CreateEmbedding embeds text with the embedding model, the call is traced as child of the span in ctx
+func (h *EmbeddingWorker) CreateEmbedding(ctx context.Context, text string) (embedding []float32, err error) {
 	client := &http.Client{Timeout: 1200 * time.Second}
 
 	reqBody := ollamaEmbedReq{
@@ -43,10 +68,21 @@ func (h *EmbeddingWorker) CreateEmbedding(text string) ([]float32, error) {
 		return nil, err
 	}
 
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.requestURL, bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
 	h.lock.Lock()
-	resp, err := client.Post(h.requestURL, "application/json", bytes.NewReader(b))
+	callCtx, done := tracing.StartCall(ctx, "ollama", "embed")
+	tracing.InjectHeaders(callCtx, req.Header)
+	resp, err := client.Do(req)
 	h.lock.Unlock()
 
+	// waiting for the lock is not part of the span, errors of the response below count as failed calls
+	defer func() { done(err) }()
+
 	if err != nil {
 		return nil, err
 	}
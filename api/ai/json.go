@@ -29,3 +29,11 @@ type ChatResp struct {
 	} `json:"message"`
 	Done bool `json:"done"`
 }
+
+type ollamaShowReq struct {
+	Model string `json:"model"`
+}
+
+type ollamaShowResp struct {
+	ModelInfo map[string]any `json:"model_info"`
+}
@@ -2,10 +2,12 @@ package ai
 
 import (
 	"bytes"
+	"context"
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"go_audio_search_api_server/globalUtils"
+	"go_audio_search_api_server/tracing"
 	"io"
 	"log/slog"
 	"net/http"
@@ -16,6 +18,7 @@ import (
 
 type LlmWorker struct {
 	model      string
+	ollamaUrl  string
 	requestUrl string
 	lock       sync.Mutex
 }
@@ -27,19 +30,31 @@ func NewLlmWorker() *LlmWorker {
 
 	return &LlmWorker{
 		model:      model,
+		ollamaUrl:  ollama,
 		requestUrl: ollama + "/api/chat",
 	}
 }
 
-func (w *LlmWorker) Summary(audioType string, input string) (string, error) {
+// Model returns the name of the chat model
+func (w *LlmWorker) Model() string {
+	return w.model
+}
+
+// CheckModel checks that the chat model is available in Ollama
+func (w *LlmWorker) CheckModel(ctx context.Context) error {
+	_, err := showModel(ctx, w.ollamaUrl, w.model)
+	return err
+}
+
+func (w *LlmWorker) Summary(ctx context.Context, audioType string, input string) (string, error) {
 	_, summarySysPrompt := w.getSysPrompts(audioType)
-	return w.ollamaRequest(summarySysPrompt, input)
+	return w.ollamaRequest(ctx, summarySysPrompt, input)
 }
 
-func (w *LlmWorker) Keywords(audioType string, input string) ([]string, error) {
+func (w *LlmWorker) Keywords(ctx context.Context, audioType string, input string) ([]string, error) {
 	keywordSysPrompt, _ := w.getSysPrompts(audioType)
 
-	req, err := w.ollamaRequest(keywordSysPrompt, input)
+	req, err := w.ollamaRequest(ctx, keywordSysPrompt, input)
 	if err != nil {
 		return nil, err
 	}
@@ -57,7 +72,7 @@ func (w *LlmWorker) Keywords(audioType string, input string) ([]string, error) {
 	return keywords, nil
 }
 
-func (w *LlmWorker) ollamaRequest(sysPrompt string, userPrompt string) (string, error) {
+func (w *LlmWorker) ollamaRequest(ctx context.Context, sysPrompt string, userPrompt string) (content string, err error) {
 	slog.Info("Requesting Ollama model", "model", w.model)
 
 	client := &http.Client{Timeout: 1200 * time.Second}
@@ -73,10 +88,20 @@ func (w *LlmWorker) ollamaRequest(sysPrompt string, userPrompt string) (string,
 
 	b, _ := json.Marshal(reqBody)
 
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.requestUrl, bytes.NewReader(b))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
 	w.lock.Lock()
-	resp, err := client.Post(w.requestUrl, "application/json", bytes.NewReader(b))
+	callCtx, done := tracing.StartCall(ctx, "ollama", "chat")
+	tracing.InjectHeaders(callCtx, req.Header)
+	resp, err := client.Do(req)
 	w.lock.Unlock()
 
+	defer func() { done(err) }()
+
 	if err != nil {
 		slog.Error("OllamaRequest error", "error", err)
 		return "", err
@@ -86,7 +111,7 @@ func (w *LlmWorker) ollamaRequest(sysPrompt string, userPrompt string) (string,
 	if resp.StatusCode != 200 {
 		body, _ := io.ReadAll(resp.Body)
 		slog.Error("OllamaRequest non-200 status", "status", resp.StatusCode, "body", string(body))
-		return fmt.Sprintf("status=%d body=%s", resp.StatusCode, string(body)), err
+		return "", fmt.Errorf("ollama chat failed: status=%d body=%s", resp.StatusCode, string(body))
 	}
 
 	var out ChatResp
@@ -0,0 +1,66 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"go_audio_search_api_server/tracing"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// showModel asks Ollama for the details of a model, it fails if the model is not pulled on the server
+func showModel(ctx context.Context, ollamaURL string, model string) (info *ollamaShowResp, err error) {
+	b, err := json.Marshal(ollamaShowReq{Model: model})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ollamaURL+"/api/show", bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	callCtx, done := tracing.StartCall(ctx, "ollama", "show")
+	defer func() { done(err) }()
+	tracing.InjectHeaders(callCtx, req.Header)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("model %s is not available in ollama", model)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ollama show failed: status=%d body=%s", resp.StatusCode, string(body))
+	}
+
+	var out ollamaShowResp
+	if err := json.Unmarshal(body, &out); err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// embeddingLength reads the vector size from the model info, the key is prefixed with the architecture of the
+// model like "nomic-bert.embedding_length"
+func (info *ollamaShowResp) embeddingLength() (uint64, bool) {
+	for key, value := range info.ModelInfo {
+		if !strings.HasSuffix(key, ".embedding_length") {
+			continue
+		}
+		if length, ok := value.(float64); ok && length > 0 {
+			return uint64(length), true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,122 @@
+package ai
+
+import (
+	"strings"
+)
+
+// timedSpan maps a character range of the joined whisper text onto its time range
+type timedSpan struct {
+	from     int
+	to       int
+	startSec float64
+	endSec   float64
+}
+
+// assignSegmentTimestamps sets StartSec and EndSec of the segments from the timed whisper segments.
+// Every sentence is located in the joined whisper text and its position is interpolated inside the
+// whisper segment containing it. Returns the number of segments that received timestamps.
+func assignSegmentTimestamps(segments []Segment, sentences []string, whisperSegments []whisperSegment) int {
+	if len(segments) == 0 || len(sentences) == 0 || len(whisperSegments) == 0 {
+		return 0
+	}
+
+	var full strings.Builder
+	spans := make([]timedSpan, 0, len(whisperSegments))
+	var lastEnd float64
+
+	for _, ws := range whisperSegments {
+		text := normalizeWhitespace(ws.Text)
+		if text == "" || ws.EndSec < ws.StartSec {
+			continue
+		}
+
+		if full.Len() > 0 {
+			full.WriteString(" ")
+		}
+
+		// the text order is the order of speech, a segment overlapping its predecessor starts where that one ended
+		startSec := max(ws.StartSec, lastEnd)
+		endSec := max(ws.EndSec, startSec)
+		lastEnd = endSec
+
+		spans = append(spans, timedSpan{
+			from:     full.Len(),
+			to:       full.Len() + len(text),
+			startSec: startSec,
+			endSec:   endSec,
+		})
+		full.WriteString(text)
+	}
+
+	if len(spans) == 0 {
+		return 0
+	}
+
+	joined := full.String()
+	sentenceStart := make([]*float64, len(sentences))
+	sentenceEnd := make([]*float64, len(sentences))
+
+	cursor := 0
+	for idx, sentence := range sentences {
+		needle := normalizeWhitespace(sentence)
+		if needle == "" {
+			continue
+		}
+
+		pos := strings.Index(joined[cursor:], needle)
+		if pos < 0 {
+			continue
+		}
+
+		from := cursor + pos
+		to := from + len(needle)
+		cursor = to
+
+		start := timeAtChar(spans, from)
+		end := timeAtChar(spans, to)
+		sentenceStart[idx] = &start
+		sentenceEnd[idx] = &end
+	}
+
+	timed := 0
+	for i := range segments {
+		first := segments[i].SentenceIndex
+		last := first + len(SplitIntoSentences(segments[i].Transcript)) - 1
+
+		if first < 0 || last < first || last >= len(sentences) {
+			continue
+		}
+		if sentenceStart[first] == nil || sentenceEnd[last] == nil {
+			continue
+		}
+
+		segments[i].StartSec = sentenceStart[first]
+		segments[i].EndSec = sentenceEnd[last]
+		timed++
+	}
+
+	return timed
+}
+
+// timeAtChar interpolates the time of a character offset in the joined whisper text
+func timeAtChar(spans []timedSpan, pos int) float64 {
+	for _, span := range spans {
+		if pos > span.to {
+			continue
+		}
+
+		// the separator between two spans belongs to the start of the next one
+		if pos <= span.from {
+			return span.startSec
+		}
+
+		ratio := float64(pos-span.from) / float64(span.to-span.from)
+		return span.startSec + (span.endSec-span.startSec)*ratio
+	}
+
+	return spans[len(spans)-1].endSec
+}
+
+func normalizeWhitespace(v string) string {
+	return strings.Join(strings.Fields(v), " ")
+}
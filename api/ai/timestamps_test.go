@@ -0,0 +1,135 @@
+package ai
+
+import (
+	"math"
+	"testing"
+)
+
+const fourSentences = "Eins. Zwei. Drei. Vier."
+
+// timedSegments splits the text like the importer, three sentences per segment overlapping by two
+func timedSegments(t *testing.T, text string) []Segment {
+	t.Helper()
+
+	segments, err := SplitSentences(text, 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return segments
+}
+
+func TestAssignSegmentTimestamps(t *testing.T) {
+	// "Eins. Zwei." spans the characters 0-11 of the joined text, "Drei. Vier." 12-23
+	tests := []struct {
+		name      string
+		whisper   []whisperSegment
+		wantTimed int
+		// want holds start and end of the first segments, the remaining ones have no timestamps
+		want [][2]float64
+	}{
+		{
+			name: "aligned segments",
+			whisper: []whisperSegment{
+				{Text: " Eins. Zwei.", StartSec: 0, EndSec: 4},
+				{Text: " Drei. Vier.", StartSec: 4, EndSec: 8},
+			},
+			wantTimed: 2,
+			want:      [][2]float64{{0, 4 + 4*5.0/11}, {4 * 6.0 / 11, 8}},
+		},
+		{
+			name: "overlapping segments start where the previous one ended",
+			whisper: []whisperSegment{
+				{Text: "Eins. Zwei.", StartSec: 0, EndSec: 4},
+				{Text: "Drei. Vier.", StartSec: 3, EndSec: 8},
+			},
+			wantTimed: 2,
+			want:      [][2]float64{{0, 4 + 4*5.0/11}, {4 * 6.0 / 11, 8}},
+		},
+		{
+			name: "segments out of time order collapse instead of running backwards",
+			whisper: []whisperSegment{
+				{Text: "Eins. Zwei.", StartSec: 4, EndSec: 8},
+				{Text: "Drei. Vier.", StartSec: 0, EndSec: 4},
+			},
+			wantTimed: 2,
+			want:      [][2]float64{{4, 8}, {4 + 4*6.0/11, 8}},
+		},
+		{
+			name: "whitespace differences are ignored",
+			whisper: []whisperSegment{
+				{Text: "Eins.\n  Zwei. ", StartSec: 0, EndSec: 4},
+				{Text: "Drei.   Vier.", StartSec: 4, EndSec: 8},
+			},
+			wantTimed: 2,
+			want:      [][2]float64{{0, 4 + 4*5.0/11}, {4 * 6.0 / 11, 8}},
+		},
+		{
+			name:      "no whisper segments",
+			whisper:   nil,
+			wantTimed: 0,
+		},
+		{
+			name: "a segment with its end before its start is skipped",
+			whisper: []whisperSegment{
+				{Text: "Eins. Zwei.", StartSec: 0, EndSec: 4},
+				{Text: "Drei. Vier.", StartSec: 9, EndSec: 5},
+			},
+			wantTimed: 0,
+		},
+		{
+			name: "sentences whisper did not return stay untimed",
+			whisper: []whisperSegment{
+				{Text: "Eins. Zwei. Drei.", StartSec: 0, EndSec: 6},
+				{Text: "Fünf.", StartSec: 6, EndSec: 8},
+			},
+			wantTimed: 1,
+			want:      [][2]float64{{0, 6}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			segments := timedSegments(t, fourSentences)
+
+			timed := assignSegmentTimestamps(segments, SplitIntoSentences(fourSentences), tt.whisper)
+			if timed != tt.wantTimed {
+				t.Fatalf("timed %d segments, want %d", timed, tt.wantTimed)
+			}
+
+			for idx, segment := range segments {
+				if idx >= len(tt.want) {
+					if segment.StartSec != nil || segment.EndSec != nil {
+						t.Errorf("segment %d got timestamps, want none", idx)
+					}
+					continue
+				}
+
+				if segment.StartSec == nil || segment.EndSec == nil {
+					t.Fatalf("segment %d has no timestamps", idx)
+				}
+				if !near(*segment.StartSec, tt.want[idx][0]) || !near(*segment.EndSec, tt.want[idx][1]) {
+					t.Errorf("segment %d is %.3f-%.3f, want %.3f-%.3f",
+						idx, *segment.StartSec, *segment.EndSec, tt.want[idx][0], tt.want[idx][1])
+				}
+				if *segment.EndSec < *segment.StartSec {
+					t.Errorf("segment %d ends before it starts", idx)
+				}
+			}
+		})
+	}
+}
+
+func TestAssignSegmentTimestampsWithoutSegments(t *testing.T) {
+	whisper := []whisperSegment{{Text: fourSentences, StartSec: 0, EndSec: 8}}
+
+	if timed := assignSegmentTimestamps(nil, SplitIntoSentences(fourSentences), whisper); timed != 0 {
+		t.Fatalf("timed %d segments without segments", timed)
+	}
+	if timed := assignSegmentTimestamps(timedSegments(t, fourSentences), nil, whisper); timed != 0 {
+		t.Fatalf("timed %d segments without sentences", timed)
+	}
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
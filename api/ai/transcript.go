@@ -6,12 +6,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"go_audio_search_api_server/globalUtils"
+	"go_audio_search_api_server/tracing"
 	"io"
 	"log/slog"
 	"mime/multipart"
 	"net/http"
-	"os"
-	"path/filepath"
 	"regexp"
 	"strings"
 	"time"
@@ -33,29 +32,59 @@ type WhisperWorker struct {
 type Segment struct {
 	SentenceIndex int
 	Transcript    string `json:"text"`
+	StartSec      *float64
+	EndSec        *float64
 }
 
 type TranscriptionResult struct {
-	Transcript string `json:"text"`
-	Segments   []Segment
+	Transcript      string           `json:"text"`
+	DurationSec     float64          `json:"duration"`
+	Segments        []Segment        `json:"-"`
+	WhisperSegments []whisperSegment `json:"segments"`
 }
 
+// Duration is the length of the audio in seconds. Servers that leave out duration are covered by the end of the
+// last timed segment, 0 means unknown.
+func (r *TranscriptionResult) Duration() float64 {
+	if r.DurationSec > 0 {
+		return r.DurationSec
+	}
+
+	var end float64
+	for _, ws := range r.WhisperSegments {
+		end = max(end, ws.EndSec)
+	}
+	return end
+}
+
+// whisperSegment is one timed piece of the verbose_json response of the whisper server. Servers that answer with
+// the plain json format have no segments, the timestamps of the transcript are interpolated then.
+type whisperSegment struct {
+	Text     string  `json:"text"`
+	StartSec float64 `json:"start"`
+	EndSec   float64 `json:"end"`
+}
+
+// TranscriptionLanguage is the language Whisper transcribes in, the sparse vectors of the segments are stemmed in it
+const TranscriptionLanguage = "de"
+
 func New(minSegSec float32) *WhisperWorker {
 	whisperReplicas := globalUtils.LoadEnvInt("WHISPER_REPLICAS")
 
 	return &WhisperWorker{
-		BaseURL:   globalUtils.LoadEnvStr("WHISPER_API_URL"),
+		BaseURL:   globalUtils.LoadEnvStrOr("WHISPER_API_URL", "http://127.0.0.1:9001"),
 		Timeout:   30 * time.Minute,
 		Temp:      "0.0",
 		TempInc:   "0.2",
-		Format:    "json",
-		Language:  "de",
+		Format:    globalUtils.LoadEnvStrOr("WHISPER_RESPONSE_FORMAT", "verbose_json"),
+		Language:  TranscriptionLanguage,
 		MinSegSec: minSegSec,
 		sem:       semaphore.NewWeighted(int64(whisperReplicas)),
 	}
 }
 
-func (wa *WhisperWorker) Transcribe(ctx context.Context, filePath string) (*TranscriptionResult, error) {
+// Transcribe uploads the audio to the whisper server, name is only used as the file name of the upload
+func (wa *WhisperWorker) Transcribe(ctx context.Context, name string, audio io.Reader) (*TranscriptionResult, error) {
 	if wa.BaseURL == "" {
 		wa.BaseURL = "http://127.0.0.1:9001"
 	}
@@ -63,7 +92,7 @@ func (wa *WhisperWorker) Transcribe(ctx context.Context, filePath string) (*Tran
 		wa.Timeout = 5 * time.Minute
 	}
 
-	raw, err := wa.transcribeRaw(ctx, filePath)
+	raw, err := wa.transcribeRaw(ctx, name, audio)
 	if err != nil {
 		return nil, err
 	}
@@ -77,32 +106,75 @@ func (wa *WhisperWorker) Transcribe(ctx context.Context, filePath string) (*Tran
 		return nil, fmt.Errorf("unmarshal whisper response failed: %w (snippet: %q)", err, snippet)
 	}
 
+	if out.Transcript == "" {
+		out.Transcript = joinSegmentTexts(out.WhisperSegments)
+	}
+	if len(out.WhisperSegments) == 0 {
+		slog.Debug("whisper response has no timed segments, timestamps are interpolated", "file", name)
+	}
+
 	out.Segments, err = SplitSentences(out.Transcript, 3, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	timed := assignSegmentTimestamps(out.Segments, SplitIntoSentences(out.Transcript), out.WhisperSegments)
 
 	slog.Info("whisper transcription completed",
-		"file", filePath,
+		"file", name,
 		"transcript_len", len(out.Transcript),
 		"segments", len(out.Segments),
+		"timed_segments", timed,
 	)
 
 	return &out, nil
 }
 
-func (wa *WhisperWorker) transcribeRaw(ctx context.Context, filePath string) ([]byte, error) {
-	f, err := os.Open(filePath)
+// joinSegmentTexts is the transcript of servers that only fill the segments of the verbose_json response
+func joinSegmentTexts(segments []whisperSegment) string {
+	texts := make([]string, 0, len(segments))
+	for _, ws := range segments {
+		if text := strings.TrimSpace(ws.Text); text != "" {
+			texts = append(texts, text)
+		}
+	}
+	return strings.Join(texts, " ")
+}
+
+// Ping checks that the whisper server answers and has loaded its model, the server answers 503 while it loads
+func (wa *WhisperWorker) Ping(ctx context.Context) (err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, wa.BaseURL+"/health", nil)
 	if err != nil {
-		return nil, fmt.Errorf("open file: %w", err)
+		return fmt.Errorf("new request: %w", err)
+	}
+
+	callCtx, done := tracing.StartCall(ctx, "whisper", "health")
+	defer func() { done(err) }()
+	tracing.InjectHeaders(callCtx, req.Header)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("whisper health failed: %s", resp.Status)
 	}
-	defer f.Close()
 
+	return nil
+}
+
+func (wa *WhisperWorker) transcribeRaw(ctx context.Context, name string, audio io.Reader) (raw []byte, err error) {
 	var body bytes.Buffer
 	w := multipart.NewWriter(&body)
 
-	part, err := w.CreateFormFile("file", filepath.Base(filePath))
+	part, err := w.CreateFormFile("file", name)
 	if err != nil {
 		return nil, fmt.Errorf("create form file: %w", err)
 	}
-	if _, err := io.Copy(part, f); err != nil {
+	if _, err := io.Copy(part, audio); err != nil {
 		return nil, fmt.Errorf("copy file to multipart: %w", err)
 	}
 
@@ -135,9 +207,13 @@ func (wa *WhisperWorker) transcribeRaw(ctx context.Context, filePath string) ([]
 	if err := wa.sem.Acquire(ctx, 1); err != nil {
 		return nil, err
 	}
+	callCtx, done := tracing.StartCall(ctx, "whisper", "transcribe")
+	tracing.InjectHeaders(callCtx, req.Header)
 	resp, err := client.Do(req)
 	wa.sem.Release(1)
 
+	defer func() { done(err) }()
+
 	if err != nil {
 		return nil, fmt.Errorf("do request: %w", err)
 	}
@@ -167,17 +243,7 @@ func SplitSentences(text string, chunkSize int, overlap int) ([]Segment, error)
 		return nil, fmt.Errorf("overlap must be smaller than chunk size")
 	}
 
-	re := regexp.MustCompile(`(?s).*?[.!?](?:\s+|$)`)
-	sentences := re.FindAllString(text, -1)
-
-	var cleanedSentences []string
-	for _, sentence := range sentences {
-		cleaned := strings.TrimSpace(sentence)
-		cleaned = strings.ReplaceAll(cleaned, "\n", " ")
-		if cleaned != "" {
-			cleanedSentences = append(cleanedSentences, cleaned)
-		}
-	}
+	cleanedSentences := SplitIntoSentences(text)
 
 	if len(cleanedSentences) == 0 {
 		return nil, nil
@@ -206,3 +272,21 @@ func SplitSentences(text string, chunkSize int, overlap int) ([]Segment, error)
 
 	return segments, nil
 }
+
+var sentenceRe = regexp.MustCompile(`(?s).*?[.!?](?:\s+|$)`)
+
+// SplitIntoSentences splits a text into cleaned sentences, the segments are built from these sentences.
+func SplitIntoSentences(text string) []string {
+	sentences := sentenceRe.FindAllString(text, -1)
+
+	var cleanedSentences []string
+	for _, sentence := range sentences {
+		cleaned := strings.TrimSpace(sentence)
+		cleaned = strings.ReplaceAll(cleaned, "\n", " ")
+		if cleaned != "" {
+			cleanedSentences = append(cleanedSentences, cleaned)
+		}
+	}
+
+	return cleanedSentences
+}
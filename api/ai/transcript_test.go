@@ -0,0 +1,112 @@
+package ai
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/sync/semaphore"
+)
+
+// newTestWhisper answers every transcription with body and reports the requested response_format
+func newTestWhisper(t *testing.T, body string) (*WhisperWorker, *string) {
+	t.Helper()
+
+	var format string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/inference" {
+			http.NotFound(w, r)
+			return
+		}
+		format = r.FormValue("response_format")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+
+	return &WhisperWorker{
+		BaseURL:  srv.URL,
+		Timeout:  10 * time.Second,
+		Format:   "verbose_json",
+		Language: TranscriptionLanguage,
+		sem:      semaphore.NewWeighted(1),
+	}, &format
+}
+
+func TestTranscribe(t *testing.T) {
+	tests := []struct {
+		name         string
+		body         string
+		wantDuration float64
+		wantTimed    bool
+	}{
+		{
+			name:         "verbose_json with segments",
+			body:         `{"text": "Eins. Zwei. Drei. Vier.", "duration": 8.5, "segments": [{"text": "Eins. Zwei.", "start": 0, "end": 4}, {"text": "Drei. Vier.", "start": 4, "end": 8}]}`,
+			wantDuration: 8.5,
+			wantTimed:    true,
+		},
+		{
+			name:         "verbose_json without duration",
+			body:         `{"text": "Eins. Zwei. Drei. Vier.", "segments": [{"text": "Eins. Zwei.", "start": 0, "end": 4}, {"text": "Drei. Vier.", "start": 4, "end": 8}]}`,
+			wantDuration: 8,
+			wantTimed:    true,
+		},
+		{
+			name:         "segments without text",
+			body:         `{"segments": [{"text": " Eins. Zwei.", "start": 0, "end": 4}, {"text": " Drei. Vier.", "start": 4, "end": 8}]}`,
+			wantDuration: 8,
+			wantTimed:    true,
+		},
+		{
+			name: "plain json without segments",
+			body: `{"text": "Eins. Zwei. Drei. Vier."}`,
+		},
+		{
+			name: "empty segments",
+			body: `{"text": "Eins. Zwei. Drei. Vier.", "segments": []}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			whisper, format := newTestWhisper(t, tt.body)
+
+			result, err := whisper.Transcribe(context.Background(), "test.mp3", strings.NewReader("audio"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if *format != "verbose_json" {
+				t.Errorf("requested response_format %q, want verbose_json", *format)
+			}
+			if result.Transcript != fourSentences {
+				t.Errorf("transcript %q, want %q", result.Transcript, fourSentences)
+			}
+			if result.Duration() != tt.wantDuration {
+				t.Errorf("duration %v, want %v", result.Duration(), tt.wantDuration)
+			}
+			if len(result.Segments) != 2 {
+				t.Fatalf("got %d segments, want 2", len(result.Segments))
+			}
+
+			for idx, segment := range result.Segments {
+				timed := segment.StartSec != nil && segment.EndSec != nil
+				if timed != tt.wantTimed {
+					t.Errorf("segment %d timed = %v, want %v", idx, timed, tt.wantTimed)
+				}
+			}
+		})
+	}
+}
+
+func TestTranscribeRejectsInvalidJson(t *testing.T) {
+	whisper, _ := newTestWhisper(t, `Eins. Zwei.`)
+
+	if _, err := whisper.Transcribe(context.Background(), "test.mp3", strings.NewReader("audio")); err == nil {
+		t.Fatal("a plain text answer was accepted")
+	}
+}
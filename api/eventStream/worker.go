@@ -0,0 +1,110 @@
+package eventStream
+
+import (
+	"context"
+	"go_audio_search_api_server/globalTypes"
+	"go_audio_search_api_server/postgres"
+	"log/slog"
+	"sync"
+)
+
+// subscriberBuffer is the number of events a slow client may fall behind before events are dropped for it
+const subscriberBuffer = 64
+
+// Filter selects the events of a subscriber, empty fields match everything.
+// A Viewer with workspace only receives the events of that workspace it may read.
+type Filter struct {
+	Viewer   globalTypes.Viewer
+	ImportID string
+	Category string
+}
+
+func (f Filter) Matches(event globalTypes.AudioEvent) bool {
+	if f.Viewer.WorkspaceID != "" {
+		if f.Viewer.WorkspaceID != event.WorkspaceID || !f.Viewer.CanRead(event.Readers) {
+			return false
+		}
+	}
+	if f.ImportID != "" && f.ImportID != event.ImportID {
+		return false
+	}
+	if f.Category != "" && f.Category != event.Category {
+		return false
+	}
+	return true
+}
+
+type subscriber struct {
+	filter Filter
+	events chan globalTypes.AudioEvent
+}
+
+// Worker receives the AudioEvents of all instances through Postgres notifications and fans them out to the
+// subscribed event stream clients of this instance.
+type Worker struct {
+	workerWG *sync.WaitGroup
+	stopCtx  context.Context
+	postgres *postgres.Worker
+
+	mu          sync.Mutex
+	subscribers map[*subscriber]struct{}
+}
+
+func NewWorker(ctx context.Context, wg *sync.WaitGroup, postgres *postgres.Worker) *Worker {
+	worker := &Worker{
+		workerWG:    wg,
+		stopCtx:     ctx,
+		postgres:    postgres,
+		subscribers: map[*subscriber]struct{}{},
+	}
+
+	worker.workerWG.Add(1)
+	go worker.run()
+
+	return worker
+}
+
+func (w *Worker) run() {
+	defer w.workerWG.Done()
+	slog.Debug("Started event stream listener")
+
+	w.postgres.ListenAudioEvents(w.stopCtx, w.publish)
+
+	slog.Debug("Event stream listener stopped", "reason", "stop_ctx_done")
+}
+
+// Subscribe returns the events matching filter until unsubscribe is called
+func (w *Worker) Subscribe(filter Filter) (events <-chan globalTypes.AudioEvent, unsubscribe func()) {
+	sub := &subscriber{
+		filter: filter,
+		events: make(chan globalTypes.AudioEvent, subscriberBuffer),
+	}
+
+	w.mu.Lock()
+	w.subscribers[sub] = struct{}{}
+	w.mu.Unlock()
+
+	return sub.events, func() {
+		w.mu.Lock()
+		delete(w.subscribers, sub)
+		w.mu.Unlock()
+	}
+}
+
+func (w *Worker) publish(event globalTypes.AudioEvent) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for sub := range w.subscribers {
+		if !sub.filter.Matches(event) {
+			continue
+		}
+
+		// a client that does not keep up must not block the others
+		select {
+		case sub.events <- event:
+		default:
+			slog.Warn("Dropped event for slow event stream client", "event", event.Type, "audioHash", event.AudiofileHash)
+		}
+	}
+}
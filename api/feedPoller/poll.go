@@ -0,0 +1,119 @@
+package feedPoller
+
+import (
+	"fmt"
+	"go_audio_search_api_server/feeds"
+	"go_audio_search_api_server/globalTypes"
+	"log/slog"
+)
+
+// poll imports the episodes of a subscription that were not seen before. On the first poll only the newest
+// BackfillLatest episodes are imported, the older ones are just marked as seen.
+// Episodes whose enclosure cannot be downloaded stay unseen and are retried on the next poll.
+func (w *Worker) poll(sub *globalTypes.FeedSubscription) {
+	slog.Info("Polling feed subscription", "subscriptionId", sub.ID, "feedUrl", sub.FeedUrl)
+
+	ctx, cancel := w.opCtx()
+	feed, err := feeds.Fetch(ctx, sub.FeedUrl)
+	cancel()
+
+	if err != nil {
+		w.finish(sub, "", 0, "Couldn't load RSS feed: "+err.Error())
+		return
+	}
+
+	ctx, cancel = w.opCtx()
+	seenGuids, seenUrls, err := w.postgres.GetSeenFeedEpisodes(ctx, sub.ID)
+	cancel()
+
+	if err != nil {
+		w.finish(sub, feed.Title, 0, "Couldn't load seen episodes: "+err.Error())
+		return
+	}
+
+	var unseen []feeds.Episode
+	for _, episode := range feeds.Select(feed.Episodes, feeds.Selection{}) {
+		if seenGuids[episode.Guid] || seenUrls[episode.EnclosureUrl] {
+			continue
+		}
+		unseen = append(unseen, episode)
+	}
+
+	toImport := unseen
+	var seen []globalTypes.FeedEpisodeKey
+
+	if sub.LastPolledAt == nil {
+		// unseen is sorted newest first
+		toImport = unseen[:min(sub.BackfillLatest, len(unseen))]
+		for _, episode := range unseen[len(toImport):] {
+			seen = append(seen, episodeKey(episode))
+		}
+	}
+
+	items := make([]*globalTypes.AudioDataElement, len(toImport))
+	for idx, episode := range toImport {
+		items[idx] = feeds.ToAudioData(feed, episode, feeds.Defaults{Category: sub.Category, AudioType: sub.AudioType})
+	}
+
+	var validItems []*globalTypes.AudioDataElement
+	var skippedErr error
+	importID := globalTypes.NewImportID()
+	skipped := 0
+
+	for idx, err := range feeds.ValidateItems(items) {
+		if err != nil {
+			slog.Info("Skipping feed episode until the next poll", "subscriptionId", sub.ID, "guid", toImport[idx].Guid, "err", err)
+			skipped++
+			skippedErr = err
+			continue
+		}
+
+		item := items[idx]
+		item.WorkspaceID = sub.WorkspaceID
+		item.AudiofileHash = item.GetTmpHash()
+		item.LastSuccessfulStage = globalTypes.StageQueued
+		item.ImportID = importID
+		validItems = append(validItems, item)
+		seen = append(seen, episodeKey(toImport[idx]))
+	}
+
+	ctx, cancel = w.opCtx()
+	err = w.postgres.QueueFeedEpisodes(ctx, sub.ID, validItems, seen)
+	cancel()
+
+	if err != nil {
+		slog.Error("Error while queueing feed episodes", "subscriptionId", sub.ID, "err", err)
+		w.finish(sub, feed.Title, 0, "Couldn't queue episodes: "+err.Error())
+		return
+	}
+
+	if len(validItems) > 0 {
+		w.PoolRefillSignal.Trigger()
+	}
+
+	pollErr := ""
+	if skipped > 0 {
+		pollErr = fmt.Sprintf("%d episodes skipped, last error: %v", skipped, skippedErr)
+	}
+
+	slog.Info("Polled feed subscription", "subscriptionId", sub.ID, "newEpisodes", len(validItems), "skipped", skipped)
+	w.finish(sub, feed.Title, len(validItems), pollErr)
+}
+
+func (w *Worker) finish(sub *globalTypes.FeedSubscription, title string, newEpisodes int, pollErr string) {
+	if pollErr != "" {
+		slog.Info("Feed poll finished with error", "subscriptionId", sub.ID, "err", pollErr)
+	}
+
+	ctx, cancel := w.opCtx()
+	err := w.postgres.FinishFeedPoll(ctx, sub.ID, title, newEpisodes, pollErr)
+	cancel()
+
+	if err != nil {
+		slog.Error("Error while storing feed poll result", "subscriptionId", sub.ID, "err", err)
+	}
+}
+
+func episodeKey(episode feeds.Episode) globalTypes.FeedEpisodeKey {
+	return globalTypes.FeedEpisodeKey{Guid: episode.Guid, EnclosureUrl: episode.EnclosureUrl}
+}
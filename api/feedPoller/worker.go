@@ -0,0 +1,85 @@
+package feedPoller
+
+import (
+	"context"
+	"go_audio_search_api_server/globalUtils"
+	"go_audio_search_api_server/postgres"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const opTimeout = 5 * time.Minute
+
+// checkInterval is how often the poller looks for due subscriptions without being triggered
+const checkInterval = 1 * time.Minute
+
+type Worker struct {
+	// PollSignal wakes the poller up, e.g. after a subscription was created or resumed
+	PollSignal       *globalUtils.NoneStackingEvent
+	PoolRefillSignal *globalUtils.NoneStackingEvent
+
+	workerWG *sync.WaitGroup
+	stopCtx  context.Context
+	postgres *postgres.Worker
+}
+
+func NewWorker(ctx context.Context, wg *sync.WaitGroup, postgres *postgres.Worker, poolRefillSignal *globalUtils.NoneStackingEvent) *Worker {
+	worker := Worker{
+		PollSignal:       globalUtils.NewSignal(),
+		PoolRefillSignal: poolRefillSignal,
+		workerWG:         wg,
+		stopCtx:          ctx,
+		postgres:         postgres,
+	}
+
+	worker.workerWG.Add(1)
+	go worker.run()
+
+	worker.PollSignal.Trigger()
+
+	return &worker
+}
+
+func (w *Worker) run() {
+	defer w.workerWG.Done()
+	slog.Debug("Started feed poller")
+
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stopCtx.Done():
+			slog.Debug("Feed poller stopped", "reason", "stop_ctx_done")
+			return
+		case <-ticker.C:
+			w.pollDueSubscriptions()
+		case <-w.PollSignal.Reader():
+			w.pollDueSubscriptions()
+		}
+	}
+}
+
+// pollDueSubscriptions polls until no subscription is due anymore
+func (w *Worker) pollDueSubscriptions() {
+	for w.stopCtx.Err() == nil {
+		ctx, cancel := w.opCtx()
+		sub, err := w.postgres.ClaimDueFeedSubscription(ctx)
+		cancel()
+
+		if err != nil {
+			slog.Error("Error while claiming due feed subscription", "err", err)
+			return
+		}
+		if sub == nil {
+			return
+		}
+
+		w.poll(sub)
+	}
+}
+
+func (w *Worker) opCtx() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(w.stopCtx, opTimeout)
+}
@@ -0,0 +1,421 @@
+package feeds
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html/charset"
+)
+
+const atomNs = "http://www.w3.org/2005/Atom"
+
+var ErrUnknownFormat = errors.New("document is neither an RSS 2.0 nor an Atom feed")
+
+// Feed is the format independent view of an RSS 2.0 or Atom feed
+type Feed struct {
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	Link        string    `json:"link"`
+	Author      string    `json:"author"`
+	PodcastGuid string    `json:"podcast_guid,omitempty"`
+	Episodes    []Episode `json:"episodes"`
+}
+
+// Episode is one feed item. Items without a usable audio enclosure are kept with an empty EnclosureUrl.
+type Episode struct {
+	Guid          string    `json:"guid"`
+	Title         string    `json:"title"`
+	Description   string    `json:"description"`
+	PublishedAt   time.Time `json:"published_at"`
+	EnclosureUrl  string    `json:"enclosure_url"`
+	EnclosureType string    `json:"enclosure_type"`
+	DurationInSec float32   `json:"duration_in_sec"`
+	Season        int       `json:"season,omitempty"`
+	Episode       int       `json:"episode,omitempty"`
+}
+
+type rssDocument struct {
+	XMLName xml.Name   `xml:"rss"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	ItunesSummary string    `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd summary"`
+	ItunesAuthor  string    `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd author"`
+	PodcastGuid   string    `xml:"https://podcastindex.org/namespace/1.0 guid"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title          string               `xml:"title"`
+	ItunesTitle    string               `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd title"`
+	Link           string               `xml:"link"`
+	Guid           string               `xml:"guid"`
+	PubDate        string               `xml:"pubDate"`
+	DcDate         string               `xml:"http://purl.org/dc/elements/1.1/ date"`
+	Description    string               `xml:"description"`
+	ContentEncoded string               `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	ItunesSummary  string               `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd summary"`
+	ItunesSubtitle string               `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd subtitle"`
+	ItunesDuration string               `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd duration"`
+	ItunesSeason   string               `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd season"`
+	ItunesEpisode  string               `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd episode"`
+	PodcastSeason  string               `xml:"https://podcastindex.org/namespace/1.0 season"`
+	PodcastEpisode string               `xml:"https://podcastindex.org/namespace/1.0 episode"`
+	Enclosures     []enclosure          `xml:"enclosure"`
+	Alternates     []alternateEnclosure `xml:"https://podcastindex.org/namespace/1.0 alternateEnclosure"`
+	MediaContents  []mediaContent       `xml:"http://search.yahoo.com/mrss/ content"`
+	AtomLinks      []atomLink           `xml:"http://www.w3.org/2005/Atom link"`
+}
+
+type enclosure struct {
+	Url  string `xml:"url,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type alternateEnclosure struct {
+	Type    string `xml:"type,attr"`
+	Sources []struct {
+		Uri string `xml:"uri,attr"`
+	} `xml:"https://podcastindex.org/namespace/1.0 source"`
+}
+
+type mediaContent struct {
+	Url      string `xml:"url,attr"`
+	Type     string `xml:"type,attr"`
+	Medium   string `xml:"medium,attr"`
+	Duration string `xml:"duration,attr"`
+}
+
+type atomDocument struct {
+	XMLName  xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title    string      `xml:"http://www.w3.org/2005/Atom title"`
+	Subtitle string      `xml:"http://www.w3.org/2005/Atom subtitle"`
+	Links    []atomLink  `xml:"http://www.w3.org/2005/Atom link"`
+	Author   string      `xml:"http://www.w3.org/2005/Atom author>name"`
+	Entries  []atomEntry `xml:"http://www.w3.org/2005/Atom entry"`
+}
+
+type atomEntry struct {
+	Id             string     `xml:"http://www.w3.org/2005/Atom id"`
+	Title          string     `xml:"http://www.w3.org/2005/Atom title"`
+	Published      string     `xml:"http://www.w3.org/2005/Atom published"`
+	Updated        string     `xml:"http://www.w3.org/2005/Atom updated"`
+	Summary        string     `xml:"http://www.w3.org/2005/Atom summary"`
+	Content        string     `xml:"http://www.w3.org/2005/Atom content"`
+	Links          []atomLink `xml:"http://www.w3.org/2005/Atom link"`
+	ItunesSummary  string     `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd summary"`
+	ItunesDuration string     `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd duration"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+// Parse reads an RSS 2.0 or Atom document, the format is detected from the root element
+func Parse(r io.Reader) (*Feed, error) {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return ParseBytes(raw)
+}
+
+func ParseBytes(raw []byte) (*Feed, error) {
+	root, err := rootElement(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case root.Local == "rss":
+		var doc rssDocument
+		if err := decode(raw, &doc); err != nil {
+			return nil, err
+		}
+		return doc.toFeed(), nil
+	case root.Local == "feed" && root.Space == atomNs:
+		var doc atomDocument
+		if err := decode(raw, &doc); err != nil {
+			return nil, err
+		}
+		return doc.toFeed(), nil
+	default:
+		return nil, ErrUnknownFormat
+	}
+}
+
+func decode(raw []byte, v any) error {
+	dec := xml.NewDecoder(bytes.NewReader(raw))
+	dec.CharsetReader = charset.NewReaderLabel
+	// many feeds contain html entities like &nbsp; without declaring them
+	dec.Strict = false
+	dec.Entity = xml.HTMLEntity
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("invalid feed xml: %w", err)
+	}
+	return nil
+}
+
+func rootElement(raw []byte) (xml.Name, error) {
+	dec := xml.NewDecoder(bytes.NewReader(raw))
+	dec.CharsetReader = charset.NewReaderLabel
+	dec.Strict = false
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return xml.Name{}, fmt.Errorf("invalid feed xml: %w", err)
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			return start.Name, nil
+		}
+	}
+}
+
+func (doc *rssDocument) toFeed() *Feed {
+	channel := doc.Channel
+
+	feed := &Feed{
+		Title:       cleanText(channel.Title),
+		Description: firstNonEmpty(cleanText(channel.Description), cleanText(channel.ItunesSummary)),
+		Link:        strings.TrimSpace(channel.Link),
+		Author:      cleanText(channel.ItunesAuthor),
+		PodcastGuid: strings.TrimSpace(channel.PodcastGuid),
+	}
+
+	for _, item := range channel.Items {
+		episode := Episode{
+			Guid:  firstNonEmpty(strings.TrimSpace(item.Guid), strings.TrimSpace(item.Link)),
+			Title: firstNonEmpty(cleanText(item.Title), cleanText(item.ItunesTitle)),
+			Description: firstNonEmpty(
+				cleanText(item.Description),
+				cleanText(item.ContentEncoded),
+				cleanText(item.ItunesSummary),
+				cleanText(item.ItunesSubtitle),
+			),
+			PublishedAt:   parseDate(firstNonEmpty(item.PubDate, item.DcDate)),
+			DurationInSec: parseDuration(item.ItunesDuration),
+			Season:        atoiOrZero(firstNonEmpty(item.PodcastSeason, item.ItunesSeason)),
+			Episode:       atoiOrZero(firstNonEmpty(item.PodcastEpisode, item.ItunesEpisode)),
+		}
+
+		episode.EnclosureUrl, episode.EnclosureType = item.audioUrl()
+
+		if episode.DurationInSec == 0 {
+			for _, media := range item.MediaContents {
+				if d := parseDuration(media.Duration); d > 0 {
+					episode.DurationInSec = d
+					break
+				}
+			}
+		}
+
+		if episode.EnclosureUrl == "" {
+			episode.EnclosureUrl = findMp3Url(item.Description, item.ContentEncoded, item.Link)
+		}
+		if episode.Guid == "" {
+			episode.Guid = episode.EnclosureUrl
+		}
+
+		feed.Episodes = append(feed.Episodes, episode)
+	}
+
+	return feed
+}
+
+// audioUrl picks the enclosure like the frontend did: real enclosures first, then alternates and media tags
+func (item *rssItem) audioUrl() (string, string) {
+	for _, enc := range item.Enclosures {
+		if isAudio(enc.Url, enc.Type) {
+			return strings.TrimSpace(enc.Url), enc.Type
+		}
+	}
+	for _, alt := range item.Alternates {
+		for _, source := range alt.Sources {
+			if isAudio(source.Uri, alt.Type) {
+				return strings.TrimSpace(source.Uri), alt.Type
+			}
+		}
+	}
+	for _, media := range item.MediaContents {
+		if media.Medium == "audio" || isAudio(media.Url, media.Type) {
+			return strings.TrimSpace(media.Url), media.Type
+		}
+	}
+	for _, link := range item.AtomLinks {
+		if link.Rel == "enclosure" || isAudio(link.Href, link.Type) {
+			return strings.TrimSpace(link.Href), link.Type
+		}
+	}
+	return "", ""
+}
+
+func (doc *atomDocument) toFeed() *Feed {
+	feed := &Feed{
+		Title:       cleanText(doc.Title),
+		Description: cleanText(doc.Subtitle),
+		Author:      cleanText(doc.Author),
+	}
+	for _, link := range doc.Links {
+		if link.Rel == "" || link.Rel == "alternate" {
+			feed.Link = link.Href
+			break
+		}
+	}
+
+	for _, entry := range doc.Entries {
+		episode := Episode{
+			Guid:          strings.TrimSpace(entry.Id),
+			Title:         cleanText(entry.Title),
+			Description:   firstNonEmpty(cleanText(entry.Summary), cleanText(entry.Content), cleanText(entry.ItunesSummary)),
+			PublishedAt:   parseDate(firstNonEmpty(entry.Published, entry.Updated)),
+			DurationInSec: parseDuration(entry.ItunesDuration),
+		}
+
+		for _, link := range entry.Links {
+			if link.Rel == "enclosure" || isAudio(link.Href, link.Type) {
+				episode.EnclosureUrl, episode.EnclosureType = strings.TrimSpace(link.Href), link.Type
+				break
+			}
+		}
+		if episode.EnclosureUrl == "" {
+			episode.EnclosureUrl = findMp3Url(entry.Summary, entry.Content)
+		}
+		if episode.Guid == "" {
+			episode.Guid = episode.EnclosureUrl
+		}
+
+		feed.Episodes = append(feed.Episodes, episode)
+	}
+
+	return feed
+}
+
+func isAudio(url, mimeType string) bool {
+	if strings.TrimSpace(url) == "" {
+		return false
+	}
+	return strings.HasPrefix(strings.ToLower(strings.TrimSpace(mimeType)), "audio/") ||
+		strings.Contains(strings.ToLower(url), ".mp3")
+}
+
+var mp3UrlRe = regexp.MustCompile(`(?i)https?://[^\s"'<>]+?\.mp3(?:\?[^\s"'<>]*)?`)
+
+func findMp3Url(values ...string) string {
+	for _, v := range values {
+		if match := mp3UrlRe.FindString(v); match != "" {
+			return html.UnescapeString(match)
+		}
+	}
+	return ""
+}
+
+var (
+	tagRe        = regexp.MustCompile(`<[^>]*>`)
+	blockTagRe   = regexp.MustCompile(`(?i)<\s*(br|/p|/div|/li)\s*/?>`)
+	whitespaceRe = regexp.MustCompile(`[ \t\r\f\v]+`)
+	newlinesRe   = regexp.MustCompile(`\s*\n\s*`)
+)
+
+// cleanText turns the html of descriptions into plain text
+func cleanText(v string) string {
+	v = blockTagRe.ReplaceAllString(v, "\n")
+	v = tagRe.ReplaceAllString(v, "")
+	v = html.UnescapeString(v)
+	v = strings.ReplaceAll(v, "\u00a0", " ")
+	v = whitespaceRe.ReplaceAllString(v, " ")
+	v = newlinesRe.ReplaceAllString(v, "\n")
+	return strings.TrimSpace(v)
+}
+
+var dateLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"Mon, 2 Jan 2006 15:04 -0700",
+	"Mon, 02 Jan 2006 15:04 -0700",
+	"Mon, 2 Jan 2006 15:04 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	"02 Jan 2006 15:04:05 -0700",
+	time.RFC822Z,
+	time.RFC822,
+	time.RFC3339,
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	time.DateOnly,
+}
+
+// parseDate understands the RFC 822 dates of RSS and the RFC 3339 dates of Atom, unknown dates are zero
+func parseDate(v string) time.Time {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return time.Time{}
+	}
+
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, v); err == nil {
+			return t.UTC()
+		}
+	}
+
+	// some feeds use the wrong weekday or none at all
+	if idx := strings.Index(v, ","); idx >= 0 {
+		return parseDate(v[idx+1:])
+	}
+
+	return time.Time{}
+}
+
+// parseDuration reads itunes:duration, which is either seconds or [hh:]mm:ss
+func parseDuration(v string) float32 {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0
+	}
+
+	parts := strings.Split(v, ":")
+	if len(parts) > 3 {
+		return 0
+	}
+
+	var total float64
+	for _, part := range parts {
+		n, err := strconv.ParseFloat(part, 64)
+		if err != nil || n < 0 {
+			return 0
+		}
+		total = total*60 + n
+	}
+	return float32(total)
+}
+
+func atoiOrZero(v string) int {
+	n, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return v
+		}
+	}
+	return ""
+}
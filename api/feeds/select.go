@@ -0,0 +1,100 @@
+package feeds
+
+import (
+	"go_audio_search_api_server/globalTypes"
+	"sort"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// Selection chooses the episodes to import. Start and End are inclusive, zero values disable the bound.
+// Latest keeps only the newest episodes after the date filter, 0 keeps all of them.
+type Selection struct {
+	Latest int
+	Start  time.Time
+	End    time.Time
+}
+
+// Select returns the episodes with an audio enclosure matching the selection, newest first
+func Select(episodes []Episode, sel Selection) []Episode {
+	var out []Episode
+	for _, episode := range episodes {
+		if episode.EnclosureUrl == "" {
+			continue
+		}
+		if !sel.Start.IsZero() && episode.PublishedAt.Before(sel.Start) {
+			continue
+		}
+		if !sel.End.IsZero() && episode.PublishedAt.After(sel.End) {
+			continue
+		}
+		out = append(out, episode)
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].PublishedAt.After(out[j].PublishedAt)
+	})
+
+	if sel.Latest > 0 && len(out) > sel.Latest {
+		out = out[:sel.Latest]
+	}
+
+	return out
+}
+
+// Defaults fill the import fields an episode does not carry itself
+type Defaults struct {
+	Category  string
+	AudioType string
+}
+
+// ToAudioData maps an episode onto an import item. The feed title is the default category like in the frontend.
+func ToAudioData(feed *Feed, episode Episode, defaults Defaults) *globalTypes.AudioDataElement {
+	category := defaults.Category
+	if category == "" {
+		category = firstNonEmpty(feed.Title, "Podcast")
+	}
+
+	audioType := defaults.AudioType
+	if audioType == "" {
+		audioType = "Media"
+	}
+
+	item := &globalTypes.AudioDataElement{
+		Title:         firstNonEmpty(episode.Title, "Unknown Episode"),
+		UserSummary:   firstNonEmpty(episode.Description, episode.Title, feed.Title),
+		Category:      category,
+		AudioType:     audioType,
+		FileUrl:       episode.EnclosureUrl,
+		DurationInSec: episode.DurationInSec,
+	}
+
+	// recording_date is a date, it is the day in the zone of the publisher, converting to UTC first would move
+	// episodes published close to midnight to the neighbouring day
+	if !episode.PublishedAt.IsZero() {
+		item.RecordingDate = episode.PublishedAt.Format(time.DateOnly)
+	}
+
+	return item
+}
+
+// enclosures are checked in parallel, every check is a request to the podcast host
+const validationConcurrency = 8
+
+// ValidateItems runs the import validation for every item, the errors are in the order of the items
+func ValidateItems(items []*globalTypes.AudioDataElement) []error {
+	errs := make([]error, len(items))
+
+	var g errgroup.Group
+	g.SetLimit(validationConcurrency)
+	for idx, item := range items {
+		g.Go(func() error {
+			errs[idx] = item.ValidateApiInput()
+			return nil
+		})
+	}
+	_ = g.Wait()
+
+	return errs
+}
@@ -0,0 +1,99 @@
+package globalTypes
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const (
+	// AclUserPrefix marks an access list entry that names a user, e.g. "user:alice"
+	AclUserPrefix = "user:"
+	// AclGroupPrefix marks an access list entry that names a group, e.g. "group:hr"
+	AclGroupPrefix = "group:"
+	// PublicReader is the reader of recordings without an access list, every viewer of the workspace matches it
+	PublicReader = "*"
+)
+
+// Viewer is the identity a read is made for. Recordings with an access list are only visible to their owner and
+// the users and groups on the list, SeesAll viewers (admin keys) see every recording of their workspace.
+type Viewer struct {
+	WorkspaceID string
+	User        string
+	Groups      []string
+	SeesAll     bool
+}
+
+// Principals returns the access list entries the viewer matches, including PublicReader
+func (v Viewer) Principals() []string {
+	principals := []string{PublicReader}
+	if v.User != "" {
+		principals = append(principals, AclUserPrefix+v.User)
+	}
+	for _, group := range v.Groups {
+		principals = append(principals, AclGroupPrefix+group)
+	}
+	return principals
+}
+
+// CanRead reports whether the viewer may read a recording with the given readers, see AudioReaders
+func (v Viewer) CanRead(readers []string) bool {
+	if v.SeesAll || len(readers) == 0 {
+		return true
+	}
+	for _, principal := range v.Principals() {
+		if slices.Contains(readers, principal) {
+			return true
+		}
+	}
+	return false
+}
+
+// AudioReaders flattens owner and access list into the entries that may read a recording,
+// a recording without an access list is readable by PublicReader
+func AudioReaders(owner string, acl []string) []string {
+	if len(acl) == 0 {
+		return []string{PublicReader}
+	}
+
+	readers := slices.Clone(acl)
+	if owner != "" && !slices.Contains(readers, AclUserPrefix+owner) {
+		readers = append(readers, AclUserPrefix+owner)
+	}
+	return readers
+}
+
+// NormalizeAcl trims and deduplicates an access list and checks that every entry names a user or a group
+func NormalizeAcl(acl []string) ([]string, error) {
+	out := []string{}
+	for _, entry := range acl {
+		entry = strings.TrimSpace(entry)
+
+		name, isUser := strings.CutPrefix(entry, AclUserPrefix)
+		if !isUser {
+			var isGroup bool
+			name, isGroup = strings.CutPrefix(entry, AclGroupPrefix)
+			if !isGroup {
+				return nil, fmt.Errorf("acl entry %q must start with %s or %s", entry, AclUserPrefix, AclGroupPrefix)
+			}
+		}
+		if err := validatePrincipalName(name); err != nil {
+			return nil, fmt.Errorf("acl entry %q: %w", entry, err)
+		}
+
+		if !slices.Contains(out, entry) {
+			out = append(out, entry)
+		}
+	}
+	return out, nil
+}
+
+func validatePrincipalName(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return fmt.Errorf("name is empty")
+	}
+	if name != strings.TrimSpace(name) || strings.ContainsAny(name, ":,*") {
+		return fmt.Errorf("name must not contain surrounding spaces, ':', ',' or '*'")
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package globalTypes
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// ScopeRead allows browsing audio files, transcripts, feeds and the event stream
+	ScopeRead = "read"
+	// ScopeSearch allows searching
+	ScopeSearch = "search"
+	// ScopeImport allows imports, uploads, feed subscriptions and metadata changes
+	ScopeImport = "import"
+	// ScopeAdmin allows everything within the workspace of the key, including deletes, webhooks and its api keys
+	ScopeAdmin = "admin"
+	// ScopeSystem manages the deployment: workspaces and the api keys of every workspace. It grants every scope.
+	ScopeSystem = "system"
+	// ScopeMetrics allows scraping the Prometheus metrics of the instance, a key for the scraper needs nothing else
+	ScopeMetrics = "metrics"
+)
+
+var ApiKeyScopes = []string{ScopeRead, ScopeSearch, ScopeImport, ScopeAdmin, ScopeSystem, ScopeMetrics}
+
+// deploymentScopes cover all workspaces, so the admin scope of a single workspace does not grant them
+var deploymentScopes = []string{ScopeSystem, ScopeMetrics}
+
+// ApiKey is a stored api key, the key itself is only known once on creation or rotation
+type ApiKey struct {
+	ID          int64      `json:"id"`
+	Name        string     `json:"name"`
+	Prefix      string     `json:"prefix"`
+	Scopes      []string   `json:"scopes"`
+	WorkspaceID string     `json:"workspace_id"`
+	User        string     `json:"user"`
+	Groups      []string   `json:"groups"`
+	Bootstrap   bool       `json:"bootstrap"`
+	Key         string     `json:"key,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	LastUsedAt  *time.Time `json:"last_used_at"`
+	RevokedAt   *time.Time `json:"revoked_at"`
+}
+
+// Viewer returns the identity reads with this key are made for, admin keys bypass the access lists
+func (k *ApiKey) Viewer() Viewer {
+	return Viewer{
+		WorkspaceID: k.WorkspaceID,
+		User:        k.User,
+		Groups:      k.Groups,
+		SeesAll:     k.HasScope(ScopeAdmin),
+	}
+}
+
+// HasScope reports whether the key grants the scope. System keys grant every scope, admin keys every scope of their
+// workspace.
+func (k *ApiKey) HasScope(scope string) bool {
+	if slices.Contains(k.Scopes, ScopeSystem) || slices.Contains(k.Scopes, scope) {
+		return true
+	}
+	return !slices.Contains(deploymentScopes, scope) && slices.Contains(k.Scopes, ScopeAdmin)
+}
+
+// ApiKeyRequest is the body of POST /auth/keys, the key belongs to the workspace of the creating key if none is given.
+// User and Groups are matched against the access lists of the recordings.
+type ApiKeyRequest struct {
+	Name        string   `json:"name"`
+	Scopes      []string `json:"scopes"`
+	WorkspaceID string   `json:"workspace_id"`
+	User        string   `json:"user"`
+	Groups      []string `json:"groups"`
+}
+
+func (s *ApiKeyRequest) ValidateApiInput() error {
+	s.Name = strings.TrimSpace(s.Name)
+	if s.Name == "" {
+		return fmt.Errorf("name is empty")
+	}
+
+	if len(s.Scopes) == 0 {
+		return fmt.Errorf("scopes is empty, allowed are %s", strings.Join(ApiKeyScopes, ", "))
+	}
+
+	scopes := []string{}
+	for _, scope := range s.Scopes {
+		scope = strings.TrimSpace(scope)
+		if !slices.Contains(ApiKeyScopes, scope) {
+			return fmt.Errorf("unknown scope %q, allowed are %s", scope, strings.Join(ApiKeyScopes, ", "))
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	s.Scopes = scopes
+	s.WorkspaceID = strings.TrimSpace(s.WorkspaceID)
+
+	if s.User != "" {
+		if err := validatePrincipalName(s.User); err != nil {
+			return fmt.Errorf("user: %w", err)
+		}
+	}
+
+	groups := []string{}
+	for _, group := range s.Groups {
+		if err := validatePrincipalName(group); err != nil {
+			return fmt.Errorf("group %q: %w", group, err)
+		}
+		if !slices.Contains(groups, group) {
+			groups = append(groups, group)
+		}
+	}
+	s.Groups = groups
+
+	return nil
+}
@@ -0,0 +1,120 @@
+package globalTypes
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// AudioFilter selects audio files by their metadata, used by the listing and bulk endpoints.
+// Viewer is set from the api key and not part of IsEmpty, a Viewer without workspace matches every audio file.
+type AudioFilter struct {
+	Viewer        Viewer
+	Category      string
+	AudioType     string
+	Stage         *ProcessingStage
+	StartDateIso  string
+	EndDateIso    string
+	TitleContains string
+}
+
+// IsEmpty reports whether no filter field is set, so the filter would match every audio file
+func (f *AudioFilter) IsEmpty() bool {
+	return f.Category == "" &&
+		f.AudioType == "" &&
+		f.Stage == nil &&
+		f.StartDateIso == "" &&
+		f.EndDateIso == "" &&
+		f.TitleContains == ""
+}
+
+// AudioMetadataPatch holds the user editable metadata of an imported audio file, nil fields stay unchanged
+type AudioMetadataPatch struct {
+	Title         *string   `json:"title,omitempty"`
+	Category      *string   `json:"category,omitempty"`
+	RecordingDate *string   `json:"recording_date,omitempty"`
+	UserSummary   *string   `json:"user_summary,omitempty"`
+	Acl           *[]string `json:"acl,omitempty"`
+}
+
+// IsEmpty reports whether the patch changes nothing
+func (p *AudioMetadataPatch) IsEmpty() bool {
+	return p.Title == nil && p.Category == nil && p.RecordingDate == nil && p.UserSummary == nil && p.Acl == nil
+}
+
+// ValidateApiInput validates the input data for the AudioMetadataPatch
+func (p *AudioMetadataPatch) ValidateApiInput() error {
+	if p.IsEmpty() {
+		return fmt.Errorf("no field to update, allowed are title, category, recording_date, user_summary and acl")
+	}
+
+	if p.Title != nil && strings.TrimSpace(*p.Title) == "" {
+		return fmt.Errorf("title is empty")
+	}
+
+	if p.UserSummary != nil && strings.TrimSpace(*p.UserSummary) == "" {
+		return fmt.Errorf("user_summary is empty")
+	}
+
+	// an empty recording_date clears the date
+	if p.RecordingDate != nil && *p.RecordingDate != "" {
+		_, errDate := time.Parse(time.DateOnly, *p.RecordingDate)
+		_, errTime := time.Parse(time.RFC3339, *p.RecordingDate)
+		if errDate != nil && errTime != nil {
+			return fmt.Errorf("recording_date %q is neither a date like 2026-03-01 nor a RFC3339 timestamp", *p.RecordingDate)
+		}
+	}
+
+	// an empty acl makes the audio file visible to the whole workspace again
+	if p.Acl != nil {
+		acl, err := NormalizeAcl(*p.Acl)
+		if err != nil {
+			return err
+		}
+		p.Acl = &acl
+	}
+
+	return nil
+}
+
+// AudioListEntry is the overview of one audio file returned by the listing endpoint
+type AudioListEntry struct {
+	AudiofileHash string  `json:"audiofile_hash"`
+	Title         string  `json:"title"`
+	RecordingDate string  `json:"recording_date"`
+	Category      string  `json:"category"`
+	AudioType     string  `json:"audio_type"`
+	DurationInSec float32 `json:"duration_in_sec"`
+	UserSummary   string  `json:"user_summary"`
+	Stage         string  `json:"stage"`
+	RetryCounter  int     `json:"retry_counter"`
+	CreatedAt     string  `json:"created_at"`
+	UpdatedAt     string  `json:"updated_at"`
+	SortValue     string  `json:"-"`
+}
+
+// AudioListSort names the columns the listing can be sorted by
+type AudioListSort string
+
+const (
+	SortByCreatedAt     AudioListSort = "created_at"
+	SortByRecordingDate AudioListSort = "recording_date"
+)
+
+// AudioListQuery describes one page of the audio listing, After continues behind the last entry of the previous page
+type AudioListQuery struct {
+	Filter     AudioFilter
+	SortBy     AudioListSort
+	Descending bool
+	Limit      int
+	After      *AudioListCursor
+}
+
+// AudioListCursor points at the last entry of a page by its sort value and hash. It records the sort key and
+// direction of the page, the next page is only valid in the same order.
+type AudioListCursor struct {
+	SortBy     AudioListSort `json:"s"`
+	Descending bool          `json:"d"`
+	SortValue  string        `json:"v"`
+	Hash       string        `json:"h"`
+}
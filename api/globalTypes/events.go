@@ -0,0 +1,64 @@
+package globalTypes
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// AudioEventStageChanged fires whenever an audio file finished a pipeline stage
+	AudioEventStageChanged = "audio.stage_changed"
+	// AudioEventRetried fires when a stage failed and will be retried
+	AudioEventRetried = "audio.retried"
+	// AudioEventCompleted fires once the last pipeline stage is done and the audio file is searchable
+	AudioEventCompleted = "audio.completed"
+	// AudioEventFailed fires when an audio file gave up after the maximum number of retries
+	AudioEventFailed = "audio.failed"
+	// AudioEventDeleted fires after an audio file was deleted
+	AudioEventDeleted = "audio.deleted"
+)
+
+// AudioEvent describes a change in the lifecycle of an audio file, it is sent to webhooks and event stream clients
+type AudioEvent struct {
+	Type          string    `json:"type"`
+	AudiofileHash string    `json:"audiofile_hash"`
+	WorkspaceID   string    `json:"workspace_id"`
+	ImportID      string    `json:"import_id,omitempty"`
+	Title         string    `json:"title"`
+	Category      string    `json:"category"`
+	AudioType     string    `json:"audio_type"`
+	Stage         string    `json:"stage"`
+	RetryCounter  int       `json:"retry_counter"`
+	Error         string    `json:"error,omitempty"`
+	Readers       []string  `json:"readers,omitempty"`
+	OccurredAt    time.Time `json:"occurred_at"`
+}
+
+// NewImportID returns the id shared by all audio files of one import, event stream clients can filter by it
+func NewImportID() string {
+	return uuid.NewString()
+}
+
+// NewAudioEvent describes the current state of an audio file as an event of the given type.
+// Readers is only set for audio files with an access list, so event stream clients can be filtered.
+func NewAudioEvent(eventType string, a *AudioDataElement) AudioEvent {
+	var readers []string
+	if len(a.Acl) > 0 {
+		readers = a.Readers()
+	}
+
+	return AudioEvent{
+		Type:          eventType,
+		AudiofileHash: a.AudiofileHash,
+		WorkspaceID:   a.WorkspaceID,
+		ImportID:      a.ImportID,
+		Title:         a.Title,
+		Category:      a.Category,
+		AudioType:     a.AudioType,
+		Stage:         a.LastSuccessfulStage.Name(),
+		RetryCounter:  a.RetryCounter,
+		Readers:       readers,
+		OccurredAt:    time.Now().UTC(),
+	}
+}
@@ -0,0 +1,126 @@
+package globalTypes
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// FeedImportRequest is the body of POST /import/rss
+type FeedImportRequest struct {
+	FeedUrl   string `json:"feed_url"`
+	Category  string `json:"category"`
+	AudioType string `json:"audio_type"`
+	Latest    int    `json:"latest"`
+	StartDate string `json:"start_date"`
+	EndDate   string `json:"end_date"`
+}
+
+// ValidateApiInput checks the request and returns the parsed date range, an end date without time covers the whole day
+func (s *FeedImportRequest) ValidateApiInput() (start time.Time, end time.Time, err error) {
+	u, err := url.Parse(strings.TrimSpace(s.FeedUrl))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return start, end, fmt.Errorf("feed_url must be an http or https url")
+	}
+
+	if s.Latest < 0 {
+		return start, end, fmt.Errorf("latest must not be negative")
+	}
+
+	if s.StartDate != "" {
+		start, _, err = parseIsoDate(s.StartDate)
+		if err != nil {
+			return start, end, fmt.Errorf("start_date: %w", err)
+		}
+	}
+
+	if s.EndDate != "" {
+		var dateOnly bool
+		end, dateOnly, err = parseIsoDate(s.EndDate)
+		if err != nil {
+			return start, end, fmt.Errorf("end_date: %w", err)
+		}
+		if dateOnly {
+			end = end.Add(24*time.Hour - time.Nanosecond)
+		}
+	}
+
+	if !start.IsZero() && !end.IsZero() && end.Before(start) {
+		return start, end, fmt.Errorf("end_date is before start_date")
+	}
+
+	return start, end, nil
+}
+
+func parseIsoDate(v string) (t time.Time, dateOnly bool, err error) {
+	v = strings.TrimSpace(v)
+	if t, err := time.Parse(time.DateOnly, v); err == nil {
+		return t, true, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t.UTC(), false, nil
+	}
+	return t, false, fmt.Errorf("%q is not an iso date", v)
+}
+
+const (
+	DefaultFeedPollIntervalSec = 3600
+	MinFeedPollIntervalSec     = 60
+)
+
+// FeedSubscription is a feed that is polled periodically for new episodes
+type FeedSubscription struct {
+	ID              int64      `json:"id"`
+	WorkspaceID     string     `json:"workspace_id"`
+	FeedUrl         string     `json:"feed_url"`
+	Title           string     `json:"title"`
+	Category        string     `json:"category"`
+	AudioType       string     `json:"audio_type"`
+	PollIntervalSec int        `json:"poll_interval_sec"`
+	BackfillLatest  int        `json:"backfill_latest"`
+	Paused          bool       `json:"paused"`
+	LastPolledAt    *time.Time `json:"last_polled_at"`
+	NextPollAt      time.Time  `json:"next_poll_at"`
+	LastError       string     `json:"last_error"`
+	LastNewEpisodes int        `json:"last_new_episodes"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// FeedSubscriptionRequest is the body of POST /feeds/subscriptions. BackfillLatest episodes that are already in the
+// feed are imported on the first poll, all older ones only count as seen.
+type FeedSubscriptionRequest struct {
+	FeedUrl         string `json:"feed_url"`
+	Category        string `json:"category"`
+	AudioType       string `json:"audio_type"`
+	PollIntervalSec int    `json:"poll_interval_sec"`
+	BackfillLatest  int    `json:"backfill_latest"`
+}
+
+func (s *FeedSubscriptionRequest) ValidateApiInput() error {
+	u, err := url.Parse(strings.TrimSpace(s.FeedUrl))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("feed_url must be an http or https url")
+	}
+
+	if s.PollIntervalSec == 0 {
+		s.PollIntervalSec = DefaultFeedPollIntervalSec
+	}
+	if s.PollIntervalSec < MinFeedPollIntervalSec {
+		return fmt.Errorf("poll_interval_sec must be at least %d", MinFeedPollIntervalSec)
+	}
+
+	if s.BackfillLatest < 0 {
+		return fmt.Errorf("backfill_latest must not be negative")
+	}
+
+	s.FeedUrl = strings.TrimSpace(s.FeedUrl)
+	return nil
+}
+
+// FeedEpisodeKey identifies an episode of a subscription, either field matching counts as seen
+type FeedEpisodeKey struct {
+	Guid         string
+	EnclosureUrl string
+}
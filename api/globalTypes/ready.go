@@ -0,0 +1,10 @@
+package globalTypes
+
+// DependencyCheck is the outcome of one check of GET /ready
+type DependencyCheck struct {
+	Ok        bool   `json:"ok"`
+	Skipped   bool   `json:"skipped,omitempty"`
+	Detail    string `json:"detail,omitempty"`
+	Error     string `json:"error,omitempty"`
+	LatencyMs int64  `json:"latency_ms"`
+}
@@ -2,15 +2,44 @@ package globalTypes
 
 import "fmt"
 
+// SearchMode selects how the lexical and the semantic query are combined
+type SearchMode string
+
+const (
+    // SearchModeHybrid runs the full text and the vector search independently and fuses both rankings
+    SearchModeHybrid SearchMode = "hybrid"
+    // SearchModeRerank reranks the best full text candidates by their vector score, segments without a keyword
+    // match are never found
+    SearchModeRerank   SearchMode = "rerank"
+    SearchModeLexical  SearchMode = "lexical"
+    SearchModeSemantic SearchMode = "semantic"
+)
+
+// SearchFusion selects how a hybrid search merges the two rankings
+type SearchFusion string
+
+const (
+    // FusionRrf scores a segment by the weighted reciprocal of its rank in each list
+    FusionRrf SearchFusion = "rrf"
+    // FusionWeighted scores a segment by the weighted sum of its min-max normalized scores
+    FusionWeighted SearchFusion = "weighted"
+
+    DefaultRrfK = 60
+)
+
 type SearchAudioData struct {
     AudiofileHash  string   `json:"audiofile_hash"`
     Title          string   `json:"title"`
     RecordingDate  string   `json:"recording_date"`
+    Category       string   `json:"category"`
+    AudioType      string   `json:"audio_type"`
     DurationInSec  float32  `json:"duration_in_sec"`
     TranscriptFull string   `json:"transcript_full"`
     UserSummary    string   `json:"user_summary"`
     AiKeywords     []string `json:"ai_keywords"`
     AiSummary      string   `json:"ai_summary"`
+    Owner          string   `json:"owner,omitempty"`
+    Acl            []string `json:"acl,omitempty"`
     Error          string   `json:"error,omitempty"`
 }
 
@@ -21,16 +50,25 @@ type SearchSegmentData struct {
     Transcript    string  `json:"transcript"`
     TsScore       float64 `json:"ts_score,omitempty"`
     QueryScore    float32 `json:"vector_score,omitempty"`
+    FusedScore    float64 `json:"fused_score,omitempty"`
     Error         string  `json:"error,omitempty"`
 }
 
+// SearchRequest is the body of a search. Mode defaults to rerank if both queries are set, the ranking of clients
+// from before the hybrid mode, otherwise to the mode of the query that is set. Fusion, the weights and RrfK only apply to the hybrid mode, the weights default to 1.
 type SearchRequest struct {
-    TsQuery             string `json:"ts_query,omitempty"`
-    SemanticSearchQuery string `json:"semantic_search_query,omitempty"`
-    Category            string `json:"category"`
-    StartTimePeriodIso  string `json:"start_time_period_iso"`
-    EndTimePeriodIso    string `json:"end_time_period_iso"`
-    MaxSegmentReturn    uint64 `json:"max_segment_return"`
+    TsQuery             string       `json:"ts_query,omitempty"`
+    SemanticSearchQuery string       `json:"semantic_search_query,omitempty"`
+    Category            string       `json:"category"`
+    StartTimePeriodIso  string       `json:"start_time_period_iso"`
+    EndTimePeriodIso    string       `json:"end_time_period_iso"`
+    MaxSegmentReturn    uint64       `json:"max_segment_return"`
+    Mode                SearchMode   `json:"mode,omitempty"`
+    Fusion              SearchFusion `json:"fusion,omitempty"`
+    LexicalWeight       *float64     `json:"lexical_weight,omitempty"`
+    SemanticWeight      *float64     `json:"semantic_weight,omitempty"`
+    RrfK                uint64       `json:"rrf_k,omitempty"`
+    Viewer              Viewer       `json:"-"`
 }
 
 type SearchResponse struct {
@@ -57,5 +95,64 @@ func (s *SearchRequest) ValidateApiInput() error {
         return fmt.Errorf("end_time_period_iso is empty")
     }
 
+    if s.Mode == "" {
+        switch {
+        case s.TsQuery != "" && s.SemanticSearchQuery != "":
+            s.Mode = SearchModeRerank
+        case s.TsQuery != "":
+            s.Mode = SearchModeLexical
+        default:
+            s.Mode = SearchModeSemantic
+        }
+    }
+
+    switch s.Mode {
+    case SearchModeHybrid, SearchModeRerank:
+        if s.TsQuery == "" || s.SemanticSearchQuery == "" {
+            return fmt.Errorf("mode %s needs ts_query and semantic_search_query", s.Mode)
+        }
+    case SearchModeLexical:
+        if s.TsQuery == "" {
+            return fmt.Errorf("mode lexical needs ts_query")
+        }
+    case SearchModeSemantic:
+        if s.SemanticSearchQuery == "" {
+            return fmt.Errorf("mode semantic needs semantic_search_query")
+        }
+    default:
+        return fmt.Errorf("unknown mode %q, allowed are hybrid, rerank, lexical and semantic", s.Mode)
+    }
+
+    if s.Fusion == "" {
+        s.Fusion = FusionRrf
+    }
+    if s.Fusion != FusionRrf && s.Fusion != FusionWeighted {
+        return fmt.Errorf("unknown fusion %q, allowed are rrf and weighted", s.Fusion)
+    }
+
+    if s.RrfK == 0 {
+        s.RrfK = DefaultRrfK
+    }
+
+    lexical, semantic := s.Weights()
+    if lexical < 0 || semantic < 0 {
+        return fmt.Errorf("lexical_weight and semantic_weight must not be negative")
+    }
+    if lexical == 0 && semantic == 0 {
+        return fmt.Errorf("lexical_weight and semantic_weight are both 0")
+    }
+
     return nil
 }
+
+// Weights returns the weights of the lexical and the semantic ranking of a hybrid search
+func (s *SearchRequest) Weights() (lexical float64, semantic float64) {
+    lexical, semantic = 1, 1
+    if s.LexicalWeight != nil {
+        lexical = *s.LexicalWeight
+    }
+    if s.SemanticWeight != nil {
+        semantic = *s.SemanticWeight
+    }
+    return lexical, semantic
+}
@@ -0,0 +1,38 @@
+package globalTypes
+
+import "time"
+
+// Stats is the body of GET /stats. Everything is counted in the workspace of the api key, the audio files, segments
+// and vectors only as far as the key may read them.
+type Stats struct {
+	Counters   map[string]int64 `json:"counters"`
+	AudioFiles AudioFileStats   `json:"audio_files"`
+	// Queue counts the audio files waiting for or in their next stage, keyed by the last successful stage
+	Queue      map[string]int64 `json:"queue"`
+	Categories map[string]int64 `json:"categories"`
+	AudioHours float64          `json:"audio_hours"`
+	Segments   int64            `json:"segments"`
+	Vectors    uint64           `json:"vectors"`
+}
+
+// AudioFileStats counts the audio files by their state in the pipeline
+type AudioFileStats struct {
+	Total      int64 `json:"total"`
+	Completed  int64 `json:"completed"`
+	Processing int64 `json:"processing"`
+	Waiting    int64 `json:"waiting"`
+	Failed     int64 `json:"failed"`
+}
+
+// StatsBucket is one hour of GET /stats/timeseries. Completed and failed audio files are counted in the hour the
+// pipeline finished them, the requests are the ones of the workspace.
+type StatsBucket struct {
+	Time                 time.Time `json:"time"`
+	AudioFilesImported   int64     `json:"audio_files_imported"`
+	AudioFilesCompleted  int64     `json:"audio_files_completed"`
+	AudioFilesFailed     int64     `json:"audio_files_failed"`
+	ImportRequests       int64     `json:"import_requests"`
+	ImportRequestsFailed int64     `json:"import_requests_failed"`
+	SearchRequests       int64     `json:"search_requests"`
+	SearchRequestsFailed int64     `json:"search_requests_failed"`
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/base64"
 	"fmt"
 	"go_audio_search_api_server/globalUtils"
+	"strconv"
 )
 
 // ProcessingStage represents the different stages of processing an audio file, from receiving the data to completing all processing stages
@@ -27,6 +28,45 @@ const (
 	StageFailed ProcessingStage = -1
 )
 
+var stageNames = map[ProcessingStage]string{
+	StageQueued:          "queued",
+	StageFilePersisted:   "file_persisted",
+	StageTranscribed:     "transcribed",
+	StageEmbedded:        "embedded",
+	StageAiDataGenerated: "ai_data_generated",
+	StageCompleted:       "completed",
+	StageFailed:          "failed",
+}
+
+// Name returns the api name of the stage like "transcribed"
+func (s ProcessingStage) Name() string {
+	if name, ok := stageNames[s]; ok {
+		return name
+	}
+	return "unknown_stage_" + strconv.Itoa(int(s))
+}
+
+// ParseProcessingStage parses a stage from its api name like "transcribed" or its numeric value like "3"
+func ParseProcessingStage(v string) (ProcessingStage, error) {
+	for stage, name := range stageNames {
+		if name == v {
+			return stage, nil
+		}
+	}
+
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("unknown processing stage %q", v)
+	}
+
+	stage := ProcessingStage(i)
+	if _, ok := stageNames[stage]; !ok {
+		return 0, fmt.Errorf("unknown processing stage %q", v)
+	}
+
+	return stage, nil
+}
+
 // AudioDataElement represents the structure of the audio data received from the API and used throughout the processing pipeline
 type AudioDataElement struct {
 	AudiofileHash       string           `json:"audiofile_hash"`
@@ -45,22 +85,48 @@ type AudioDataElement struct {
 	SegmentElements     []SegmentElement `json:"-"`
 	LastSuccessfulStage ProcessingStage  `json:"last_successful_stage"`
 	RetryCounter        int              `json:"retry_counter"`
+	GetsProcessed       bool             `json:"-"`
+	ImportID            string           `json:"-"`
+	WorkspaceID         string           `json:"-"`
+	Owner               string           `json:"-"`
+	ApiKeyID            int64            `json:"-"`
+	Acl                 []string         `json:"acl"`
+	TraceParent         string           `json:"-"`
+	Language            string           `json:"-"`
+}
+
+// Readers returns the access list entries that may read the audio file, see AudioReaders
+func (s *AudioDataElement) Readers() []string {
+	return AudioReaders(s.Owner, s.Acl)
 }
 
 // UpdateToNextStage updates the LastSuccessfulStage to the next stage in the processing pipeline
 func (s *AudioDataElement) UpdateToNextStage() {
-	switch s.LastSuccessfulStage {
+	s.LastSuccessfulStage = s.LastSuccessfulStage.Next()
+}
+
+// Next returns the stage the pipeline works on after s, StageFailed for stages without a successor
+func (s ProcessingStage) Next() ProcessingStage {
+	switch s {
 	case StageQueued:
-		s.LastSuccessfulStage = StageFilePersisted
+		return StageFilePersisted
 	case StageFilePersisted:
-		s.LastSuccessfulStage = StageTranscribed
+		return StageTranscribed
 	case StageTranscribed:
-		s.LastSuccessfulStage = StageEmbedded
+		return StageEmbedded
 	case StageEmbedded:
-		s.LastSuccessfulStage = StageAiDataGenerated
+		return StageAiDataGenerated
 	default:
-		s.LastSuccessfulStage = StageFailed
+		return StageFailed
+	}
+}
+
+// FinalStage is the stage after which an audio file is complete, the AI data stage is skipped with DEACTIVATE_LLM
+func FinalStage() ProcessingStage {
+	if globalUtils.LoadEnvStr("DEACTIVATE_LLM") == "true" {
+		return StageEmbedded
 	}
+	return StageAiDataGenerated
 }
 
 // SegmentElement represents a segment of the audio file with its transcript and embedding information
@@ -74,6 +140,9 @@ type SegmentElement struct {
 	TranscriptEmbeddingDone bool      `json:"-"`
 	TsScore                 float64   `json:"ts_score"`
 	QueryScore              float32   `json:"vector_score"`
+	FusedScore              float64   `json:"fused_score,omitempty"`
+	StartSec                *float64  `json:"start_sec,omitempty"`
+	EndSec                  *float64  `json:"end_sec,omitempty"`
 }
 
 // ValidateApiInput validates the input data for the AudioDataElement
@@ -82,6 +151,12 @@ func (s *AudioDataElement) ValidateApiInput() error {
 		return fmt.Errorf("title is empty")
 	}
 
+	acl, err := NormalizeAcl(s.Acl)
+	if err != nil {
+		return err
+	}
+	s.Acl = acl
+
 	if s.UserSummary == "" {
 		return fmt.Errorf("user_summary is empty")
 	}
@@ -104,6 +179,29 @@ func (s *AudioDataElement) ValidateApiInput() error {
 	return nil
 }
 
+// ValidateUploadInput validates the metadata of an uploaded file, the file itself is sent next to the metadata
+func (s *AudioDataElement) ValidateUploadInput() error {
+	if s.Title == "" {
+		return fmt.Errorf("title is empty")
+	}
+
+	acl, err := NormalizeAcl(s.Acl)
+	if err != nil {
+		return err
+	}
+	s.Acl = acl
+
+	if s.UserSummary == "" {
+		return fmt.Errorf("user_summary is empty")
+	}
+
+	if s.Base64Data != "" || s.FileUrl != "" {
+		return fmt.Errorf("file_url and base64_data must be empty for uploaded files")
+	}
+
+	return nil
+}
+
 // ToString creates a string representation of the AudioDataElement
 func (s *AudioDataElement) ToString() string {
 	return fmt.Sprint(
@@ -123,5 +221,5 @@ func (s *AudioDataElement) ToString() string {
 	)
 }
 func (s *AudioDataElement) GetTmpHash() string {
-	return "tmp_" + globalUtils.StringSha256Hex(s.ToString())
+	return "tmp_" + globalUtils.StringSha256Hex(s.WorkspaceID+"\n"+s.ToString())
 }
@@ -0,0 +1,165 @@
+package globalTypes
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// WebhookEventTypes are the AudioEvents webhooks can subscribe to
+var WebhookEventTypes = []string{
+	AudioEventStageChanged,
+	AudioEventCompleted,
+	AudioEventFailed,
+	AudioEventDeleted,
+}
+
+const MinWebhookSecretLength = 16
+
+// Webhook is a registered receiver of AudioEvents. Empty Events or Categories match everything.
+type Webhook struct {
+	ID          int64     `json:"id"`
+	WorkspaceID string    `json:"workspace_id"`
+	Url         string    `json:"url"`
+	Secret      string    `json:"secret,omitempty"`
+	Events      []string  `json:"events"`
+	Categories  []string  `json:"categories"`
+	Active      bool      `json:"active"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// WebhookRequest is the body of POST /webhooks, a missing secret is generated by the server
+type WebhookRequest struct {
+	Url        string   `json:"url"`
+	Secret     string   `json:"secret"`
+	Events     []string `json:"events"`
+	Categories []string `json:"categories"`
+}
+
+func (s *WebhookRequest) ValidateApiInput() error {
+	s.Url = strings.TrimSpace(s.Url)
+	if err := validateWebhookUrl(s.Url); err != nil {
+		return err
+	}
+
+	if s.Secret != "" && len(s.Secret) < MinWebhookSecretLength {
+		return fmt.Errorf("secret must be at least %d characters long", MinWebhookSecretLength)
+	}
+
+	var err error
+	if s.Events, err = normalizeWebhookEvents(s.Events); err != nil {
+		return err
+	}
+	s.Categories = normalizeWebhookCategories(s.Categories)
+
+	return nil
+}
+
+// WebhookPatch is the body of PATCH /webhooks/{id}, nil fields stay unchanged
+type WebhookPatch struct {
+	Url        *string   `json:"url,omitempty"`
+	Events     *[]string `json:"events,omitempty"`
+	Categories *[]string `json:"categories,omitempty"`
+	Active     *bool     `json:"active,omitempty"`
+}
+
+func (p *WebhookPatch) ValidateApiInput() error {
+	if p.Url == nil && p.Events == nil && p.Categories == nil && p.Active == nil {
+		return fmt.Errorf("no field to update, allowed are url, events, categories and active")
+	}
+
+	if p.Url != nil {
+		*p.Url = strings.TrimSpace(*p.Url)
+		if err := validateWebhookUrl(*p.Url); err != nil {
+			return err
+		}
+	}
+
+	if p.Events != nil {
+		events, err := normalizeWebhookEvents(*p.Events)
+		if err != nil {
+			return err
+		}
+		p.Events = &events
+	}
+
+	if p.Categories != nil {
+		categories := normalizeWebhookCategories(*p.Categories)
+		p.Categories = &categories
+	}
+
+	return nil
+}
+
+func validateWebhookUrl(v string) error {
+	u, err := url.Parse(v)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url must be an http or https url")
+	}
+	return nil
+}
+
+func normalizeWebhookEvents(events []string) ([]string, error) {
+	out := []string{}
+	for _, event := range events {
+		event = strings.TrimSpace(event)
+		if !slices.Contains(WebhookEventTypes, event) {
+			return nil, fmt.Errorf("unknown event %q, allowed are %s", event, strings.Join(WebhookEventTypes, ", "))
+		}
+		if !slices.Contains(out, event) {
+			out = append(out, event)
+		}
+	}
+	return out, nil
+}
+
+func normalizeWebhookCategories(categories []string) []string {
+	out := []string{}
+	for _, category := range categories {
+		category = strings.TrimSpace(category)
+		if category != "" && !slices.Contains(out, category) {
+			out = append(out, category)
+		}
+	}
+	return out
+}
+
+const (
+	WebhookOutboxPending   = "pending"
+	WebhookOutboxDelivered = "delivered"
+	WebhookOutboxFailed    = "failed"
+)
+
+// WebhookOutboxItem is one event waiting to be delivered to one webhook
+type WebhookOutboxItem struct {
+	ID            int64     `json:"id"`
+	WebhookID     int64     `json:"webhook_id"`
+	EventType     string    `json:"event_type"`
+	AudiofileHash string    `json:"audiofile_hash"`
+	Payload       []byte    `json:"-"`
+	Status        string    `json:"status"`
+	Attempts      int       `json:"attempts"`
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+	LastError     string    `json:"last_error"`
+	CreatedAt     time.Time `json:"created_at"`
+	Url           string    `json:"-"`
+	Secret        string    `json:"-"`
+}
+
+// WebhookDelivery is one delivery attempt of the delivery log
+type WebhookDelivery struct {
+	ID             int64     `json:"id"`
+	OutboxID       int64     `json:"outbox_id"`
+	WebhookID      int64     `json:"webhook_id"`
+	EventType      string    `json:"event_type"`
+	AudiofileHash  string    `json:"audiofile_hash"`
+	Attempt        int       `json:"attempt"`
+	Success        bool      `json:"success"`
+	ResponseStatus int       `json:"response_status"`
+	Error          string    `json:"error"`
+	DurationMs     int64     `json:"duration_ms"`
+	AttemptedAt    time.Time `json:"attempted_at"`
+}
@@ -0,0 +1,51 @@
+package globalTypes
+
+import (
+	"fmt"
+	"go_audio_search_api_server/globalUtils"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// DefaultWorkspaceID is the workspace of everything stored before workspaces existed and of the bootstrap admin key
+const DefaultWorkspaceID = "default"
+
+// WorkspaceAudioHash is the audiofile hash of a file content in a workspace. The default workspace keeps the content
+// hash, every other workspace gets its own, so the same recording can be imported into several workspaces and a
+// tenant can not learn from a hash which recordings another one holds.
+func WorkspaceAudioHash(workspaceID string, contentHash string) string {
+	if workspaceID == "" || workspaceID == DefaultWorkspaceID {
+		return contentHash
+	}
+	return globalUtils.StringSha256Hex(workspaceID + "\n" + contentHash)
+}
+
+var workspaceIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+// Workspace separates the recordings of one team from all others, every api key belongs to exactly one workspace
+type Workspace struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// WorkspaceRequest is the body of POST /workspaces
+type WorkspaceRequest struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+func (s *WorkspaceRequest) ValidateApiInput() error {
+	s.ID = strings.TrimSpace(s.ID)
+	if !workspaceIDPattern.MatchString(s.ID) {
+		return fmt.Errorf("id must be 1 to 63 lowercase letters, digits, '-' or '_' and start with a letter or digit")
+	}
+
+	s.Name = strings.TrimSpace(s.Name)
+	if s.Name == "" {
+		s.Name = s.ID
+	}
+
+	return nil
+}
@@ -5,27 +5,14 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"os"
-	"path/filepath"
 	"time"
 )
 
-func DownloadURLToFile(ctx context.Context, url, filename string) (err error, outpath string) {
-	filename = filepath.Base(filename)
-	if filename == "." || filename == "/" || filename == "" {
-		return fmt.Errorf("invalid filename"), ""
-	}
-
-	fullPath := filepath.Join("/app/downloaded_audios/downloaded", filename+".mp3")
-	dir := filepath.Dir(fullPath)
-
-	if err := os.MkdirAll(dir, 0o755); err != nil {
-		return err, ""
-	}
-
+// DownloadURL streams the body of url into dst
+func DownloadURL(ctx context.Context, url string, dst io.Writer) error {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		return err, ""
+		return err
 	}
 	req.Header.Set("User-Agent", "my-downloader/1.0")
 
@@ -33,36 +20,14 @@ func DownloadURLToFile(ctx context.Context, url, filename string) (err error, ou
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return err, ""
+		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode < 200 || resp.StatusCode > 299 {
-		return fmt.Errorf("bad status: %s", resp.Status), ""
-	}
-
-	tmp, err := os.CreateTemp(dir, ".tmp-*")
-	if err != nil {
-		return err, ""
-	}
-	tmpName := tmp.Name()
-
-	defer func() {
-		if err != nil {
-			_ = tmp.Close()
-			_ = os.Remove(tmpName)
-		}
-	}()
-
-	if _, err := io.Copy(tmp, resp.Body); err != nil {
-		return err, ""
-	}
-	if err := tmp.Sync(); err != nil {
-		return err, ""
-	}
-	if err := tmp.Close(); err != nil {
-		return err, ""
+		return fmt.Errorf("bad status: %s", resp.Status)
 	}
 
-	return os.Rename(tmpName, fullPath), fullPath
+	_, err = io.Copy(dst, resp.Body)
+	return err
 }
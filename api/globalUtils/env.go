@@ -41,3 +41,20 @@ func LoadEnvUInt64(key string) uint64 {
 
 	return u
 }
+
+// LoadEnvStrOr returns the env var or def if it is unset or empty
+func LoadEnvStrOr(key string, def string) string {
+	v, ok := os.LookupEnv(key)
+	if !ok || v == "" {
+		return def
+	}
+	return v
+}
+
+// LoadEnvIntOr returns the env var or def if it is unset or empty
+func LoadEnvIntOr(key string, def int) int {
+	if v, ok := os.LookupEnv(key); !ok || v == "" {
+		return def
+	}
+	return LoadEnvInt(key)
+}
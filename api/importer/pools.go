@@ -1,14 +1,21 @@
 package importer
 
 import (
+	"context"
 	"go_audio_search_api_server/globalTypes"
+	"go_audio_search_api_server/metrics"
+	"go_audio_search_api_server/tracing"
 	"log/slog"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
 )
 
 func (w *Worker) startPersistFilePool(workerAmount uint) {
 	w.startPool(
 		workerAmount,
 		"Persist file pool",
+		globalTypes.StageFilePersisted,
 		w.persistFileBuffer,
 		w.persistFile,
 		"Error persisting file",
@@ -19,6 +26,7 @@ func (w *Worker) startTranscriptAudioPool(workerAmount uint) {
 	w.startPool(
 		workerAmount,
 		"Transcript audio pool",
+		globalTypes.StageTranscribed,
 		w.transcriptAudioBuffer,
 		w.transcribeAudio,
 		"Error transcribing audio",
@@ -29,6 +37,7 @@ func (w *Worker) startCreateEmbeddingsPool(workerAmount uint) {
 	w.startPool(
 		workerAmount,
 		"Create embeddings pool",
+		globalTypes.StageEmbedded,
 		w.createEmbeddingsBuffer,
 		w.createEmbeddings,
 		"Error creating embeddings",
@@ -39,6 +48,7 @@ func (w *Worker) startGenerateAiDataPool(workerAmount uint) {
 	w.startPool(
 		workerAmount,
 		"Generate AI data pool",
+		globalTypes.StageAiDataGenerated,
 		w.genAiDataBuffer,
 		w.generateAiData,
 		"Error generating AI data",
@@ -48,8 +58,9 @@ func (w *Worker) startGenerateAiDataPool(workerAmount uint) {
 func (w *Worker) startPool(
 	workerAmount uint,
 	poolName string,
+	stage globalTypes.ProcessingStage,
 	buffer <-chan *globalTypes.AudioDataElement,
-	handler func(workerIdx uint, audioDataElement *globalTypes.AudioDataElement) error,
+	handler func(ctx context.Context, workerIdx uint, audioDataElement *globalTypes.AudioDataElement) error,
 	errorMsg string,
 ) {
 	for workerIdx := uint(0); workerIdx < workerAmount; workerIdx++ {
@@ -66,9 +77,23 @@ func (w *Worker) startPool(
 					return
 
 				case audioDataElement := <-buffer:
-					if err := handler(idx, audioDataElement); err != nil {
+					// every stage is a trace of its own that links to the request which imported the audio file
+					ctx, span := tracing.StartLinked(
+						w.StopCtx,
+						"pipeline "+stage.Name(),
+						audioDataElement.TraceParent,
+						attribute.String("audiofile_hash", audioDataElement.AudiofileHash),
+					)
+
+					start := time.Now()
+					result := "ok"
+					err := handler(ctx, idx, audioDataElement)
+					if err != nil {
+						result = "error"
 						slog.Error(errorMsg, ", worker", idx, "err", err)
 					}
+					metrics.StageDuration.Observe(time.Since(start).Seconds(), stage.Name(), result)
+					tracing.End(span, err)
 					w.PoolRefillSignal.Trigger()
 				}
 			}
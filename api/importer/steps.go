@@ -1,32 +1,39 @@
 package importer
 
 import (
+	"context"
 	"fmt"
 	"go_audio_search_api_server/globalTypes"
 	"go_audio_search_api_server/globalUtils"
+	"go_audio_search_api_server/storage"
 	"log/slog"
+	"path"
 )
 
 // persistFile saves the audio file to disk and updates the database with the new file path and hash.
-func (w *Worker) persistFile(workerIdx uint, audioDataElement *globalTypes.AudioDataElement) error {
+func (w *Worker) persistFile(ctx context.Context, workerIdx uint, audioDataElement *globalTypes.AudioDataElement) error {
 	logImport(slog.LevelDebug, "persisting file to disk", workerIdx, audioDataElement)
 
 	oldHash := audioDataElement.AudiofileHash
+	queued := *audioDataElement
 
-	ctx, cancel := w.opCtx()
-	err, updatedElement := saveAudiofileElementToDisk(ctx, audioDataElement)
+	// downloading and storing a large file takes longer than opTimeout
+	callCtx, cancel := context.WithTimeout(ctx, storage.StoreTimeout)
+	err, updatedElement := saveAudiofileElementToDisk(callCtx, w.storage, audioDataElement)
 	cancel()
 	if err != nil {
-		return w.updateRetryCounter(workerIdx, audioDataElement, err)
+		return w.updateRetryCounter(ctx, workerIdx, audioDataElement, err)
 	}
 
 	newHash := updatedElement.AudiofileHash
 
-	ctx, cancel = w.opCtx()
-	err = w.postgres.UpdateAudiofileHash(ctx, oldHash, newHash)
+	callCtx, cancel = w.opCtx(ctx)
+	err = w.postgres.UpdateAudiofileHash(callCtx, oldHash, newHash)
 	cancel()
 	if err != nil {
-		return err
+		// the row still has the temporary hash and the source of the file, the retry has to find both again
+		*audioDataElement = queued
+		return w.updateRetryCounter(ctx, workerIdx, audioDataElement, fmt.Errorf("update audio file hash: %w", err))
 	}
 
 	logImport(
@@ -38,7 +45,7 @@ func (w *Worker) persistFile(workerIdx uint, audioDataElement *globalTypes.Audio
 		"newAudioHash", newHash,
 	)
 
-	err = w.updateStage(updatedElement)
+	err = w.updateStage(ctx, updatedElement)
 	if err != nil {
 		return err
 	}
@@ -47,18 +54,31 @@ func (w *Worker) persistFile(workerIdx uint, audioDataElement *globalTypes.Audio
 }
 
 // transcribeAudio transcribes the given audio data element, creates the segments and stores them in the database.
-func (w *Worker) transcribeAudio(workerIdx uint, audioDataElement *globalTypes.AudioDataElement) error {
+func (w *Worker) transcribeAudio(ctx context.Context, workerIdx uint, audioDataElement *globalTypes.AudioDataElement) error {
 	logImport(slog.LevelDebug, "starting transcription", workerIdx, audioDataElement)
 
-	ctx, cancel := w.opCtx()
-	result, err := w.whisper.Transcribe(ctx, audioDataElement.DownloadPath)
+	callCtx, cancel := w.opCtx(ctx)
+	store, key := storage.Locate(w.storage, audioDataElement.DownloadPath)
+	audio, _, err := store.Get(callCtx, key)
+	if err != nil {
+		cancel()
+		return w.updateRetryCounter(ctx, workerIdx, audioDataElement, fmt.Errorf("open stored audio file: %w", err))
+	}
+
+	result, err := w.whisper.Transcribe(callCtx, path.Base(key), audio)
+	_ = audio.Close()
 	cancel()
 
 	if err != nil {
-		return w.updateRetryCounter(workerIdx, audioDataElement, err)
+		return w.updateRetryCounter(ctx, workerIdx, audioDataElement, err)
 	}
 
 	audioDataElement.TranscriptFull = result.Transcript
+	audioDataElement.Language = w.whisper.Language
+	if audioDataElement.DurationInSec <= 0 {
+		// the clips of the playback endpoint are cut by the duration
+		audioDataElement.DurationInSec = float32(result.Duration())
+	}
 	audioDataElement.SegmentElements = []globalTypes.SegmentElement{}
 
 	for _, segment := range result.Segments {
@@ -69,6 +89,8 @@ func (w *Worker) transcribeAudio(workerIdx uint, audioDataElement *globalTypes.A
 			SentenceIndex: segment.SentenceIndex,
 			Transcript:    segment.Transcript,
 			SegmentHash:   globalUtils.StringSha256Hex(hashInput),
+			StartSec:      segment.StartSec,
+			EndSec:        segment.EndSec,
 		}
 
 		audioDataElement.SegmentElements = append(audioDataElement.SegmentElements, builtSegment)
@@ -83,15 +105,15 @@ func (w *Worker) transcribeAudio(workerIdx uint, audioDataElement *globalTypes.A
 		"segmentCount", len(result.Segments),
 	)
 
-	ctx, cancel = w.opCtx()
-	err = w.postgres.UpsertSegments(ctx, audioDataElement.AudiofileHash, &audioDataElement.SegmentElements)
+	callCtx, cancel = w.opCtx(ctx)
+	err = w.postgres.UpsertSegments(callCtx, audioDataElement.AudiofileHash, &audioDataElement.SegmentElements)
 	cancel()
 
 	if err != nil {
-		return w.updateRetryCounter(workerIdx, audioDataElement, err)
+		return w.updateRetryCounter(ctx, workerIdx, audioDataElement, err)
 	}
 
-	err = w.updateStage(audioDataElement)
+	err = w.updateStage(ctx, audioDataElement)
 	if err != nil {
 		return err
 	}
@@ -100,18 +122,18 @@ func (w *Worker) transcribeAudio(workerIdx uint, audioDataElement *globalTypes.A
 }
 
 // createEmbeddings creates the embeddings for the segments of the given audio data element and stores them in the vector database.
-func (w *Worker) createEmbeddings(workerIdx uint, audioDataElement *globalTypes.AudioDataElement) error {
+func (w *Worker) createEmbeddings(ctx context.Context, workerIdx uint, audioDataElement *globalTypes.AudioDataElement) error {
 	logImport(slog.LevelDebug, "creating segment embeddings", workerIdx, audioDataElement)
 
 	var segments []globalTypes.SegmentElement
 
 	var err error
-	ctx, cancel := w.opCtx()
-	audioDataElement.SegmentElements, err = w.postgres.GetAllSegmentsByAudioHash(ctx, audioDataElement.AudiofileHash)
+	callCtx, cancel := w.opCtx(ctx)
+	audioDataElement.SegmentElements, err = w.postgres.GetAllSegmentsByAudioHash(callCtx, audioDataElement.AudiofileHash)
 	cancel()
 
 	if err != nil {
-		return w.updateRetryCounter(workerIdx, audioDataElement, err)
+		return w.updateRetryCounter(ctx, workerIdx, audioDataElement, err)
 	}
 
 	if len(audioDataElement.SegmentElements) == 0 {
@@ -124,9 +146,9 @@ func (w *Worker) createEmbeddings(workerIdx uint, audioDataElement *globalTypes.
 	}
 
 	for _, segment := range audioDataElement.SegmentElements {
-		embedding, err := w.embeddings.CreateEmbedding(segment.Transcript)
+		embedding, err := w.embeddings.CreateEmbedding(ctx, segment.Transcript)
 		if err != nil {
-			return w.updateRetryCounter(workerIdx, audioDataElement, err)
+			return w.updateRetryCounter(ctx, workerIdx, audioDataElement, err)
 		}
 
 		segment.TranscriptEmbedding = embedding
@@ -141,12 +163,12 @@ func (w *Worker) createEmbeddings(workerIdx uint, audioDataElement *globalTypes.
 		"segmentCount", len(segments),
 	)
 
-	ctx, cancel = w.opCtx()
-	err = w.qdrant.UpsertSegmentEmbeddings(ctx, &segments)
+	callCtx, cancel = w.opCtx(ctx)
+	err = w.qdrant.UpsertSegmentEmbeddings(callCtx, audioDataElement, &segments)
 
 	cancel()
 	if err != nil {
-		return w.updateRetryCounter(workerIdx, audioDataElement, err)
+		return w.updateRetryCounter(ctx, workerIdx, audioDataElement, err)
 	}
 
 	logImport(
@@ -157,7 +179,7 @@ func (w *Worker) createEmbeddings(workerIdx uint, audioDataElement *globalTypes.
 		"segmentCount", len(segments),
 	)
 
-	err = w.updateStage(audioDataElement)
+	err = w.updateStage(ctx, audioDataElement)
 	if err != nil {
 		return err
 	}
@@ -166,13 +188,13 @@ func (w *Worker) createEmbeddings(workerIdx uint, audioDataElement *globalTypes.
 }
 
 // generateAiData creates the ai summary and keywords for the given audio data element and stores them in the database.
-func (w *Worker) generateAiData(workerIdx uint, audioDataElement *globalTypes.AudioDataElement) error {
+func (w *Worker) generateAiData(ctx context.Context, workerIdx uint, audioDataElement *globalTypes.AudioDataElement) error {
 	var err error
 
-	audioDataElement.AiSummary, err = w.llm.Summary(audioDataElement.AudioType, audioDataElement.TranscriptFull)
+	audioDataElement.AiSummary, err = w.llm.Summary(ctx, audioDataElement.AudioType, audioDataElement.TranscriptFull)
 
 	if err != nil {
-		return w.updateRetryCounter(workerIdx, audioDataElement, err)
+		return w.updateRetryCounter(ctx, workerIdx, audioDataElement, err)
 	}
 
 	logImport(
@@ -183,10 +205,10 @@ func (w *Worker) generateAiData(workerIdx uint, audioDataElement *globalTypes.Au
 		"summaryLen", len(audioDataElement.AiSummary),
 	)
 
-	audioDataElement.AiKeywords, err = w.llm.Keywords(audioDataElement.AudioType, audioDataElement.TranscriptFull)
+	audioDataElement.AiKeywords, err = w.llm.Keywords(ctx, audioDataElement.AudioType, audioDataElement.TranscriptFull)
 
 	if err != nil {
-		return w.updateRetryCounter(workerIdx, audioDataElement, err)
+		return w.updateRetryCounter(ctx, workerIdx, audioDataElement, err)
 	}
 
 	logImport(
@@ -197,7 +219,7 @@ func (w *Worker) generateAiData(workerIdx uint, audioDataElement *globalTypes.Au
 		"keywordCount", len(audioDataElement.AiKeywords),
 	)
 
-	err = w.updateStage(audioDataElement)
+	err = w.updateStage(ctx, audioDataElement)
 	if err != nil {
 		return err
 	}
@@ -7,8 +7,12 @@ import (
 	"fmt"
 	"go_audio_search_api_server/globalTypes"
 	"go_audio_search_api_server/globalUtils"
+	"go_audio_search_api_server/metrics"
+	"go_audio_search_api_server/storage"
+	"io"
 	"log/slog"
 	"strconv"
+	"strings"
 )
 
 func stageName(stage int) string {
@@ -49,7 +53,7 @@ func logImport(level slog.Level, msg string, workerIdx uint, audioDataElement *g
 	}
 }
 
-func saveAudiofileElementToDisk(ctx context.Context, element *globalTypes.AudioDataElement) (error, *globalTypes.AudioDataElement) {
+func saveAudiofileElementToDisk(ctx context.Context, store storage.Storage, element *globalTypes.AudioDataElement) (error, *globalTypes.AudioDataElement) {
 	hasURL := element.FileUrl != ""
 	hasB64 := element.Base64Data != ""
 
@@ -57,27 +61,23 @@ func saveAudiofileElementToDisk(ctx context.Context, element *globalTypes.AudioD
 		return errors.New("tried to save a file without FileUrl or Base64Data"), nil
 	}
 
-	initSeed := element.FileUrl
-	if initSeed == "" {
-		initSeed = element.Base64Data
-	}
-	initName := globalUtils.StringSha256Hex(initSeed)
-
 	switch {
 	case hasURL:
 		slog.Info("downloading from url", "url", element.FileUrl)
 
-		err, newPath := globalUtils.DownloadURLToFile(ctx, element.FileUrl, initName)
+		spoolPath, contentHash, err := storage.Spool(func(w io.Writer) error {
+			return globalUtils.DownloadURL(ctx, element.FileUrl, w)
+		})
 		if err != nil {
 			return fmt.Errorf("error while downloading '%s': %w", element.FileUrl, err), nil
 		}
 
-		path, hash, err := globalUtils.MarkFileAtomicMP3(newPath)
+		key, hash, _, err := storage.StoreAudioFile(ctx, store, element.WorkspaceID, spoolPath, contentHash)
 		if err != nil {
-			return fmt.Errorf("error while marking file as mp3 '%s': %w", initName, err), nil
+			return fmt.Errorf("error while storing file '%s': %w", hash, err), nil
 		}
 
-		element.DownloadPath = path
+		element.DownloadPath = key
 		element.AudiofileHash = hash
 		element.FileUrl = ""
 		element.Base64Data = ""
@@ -85,22 +85,14 @@ func saveAudiofileElementToDisk(ctx context.Context, element *globalTypes.AudioD
 	case hasB64:
 		slog.Info("writing from base64")
 
-		decodedBytes, err := base64.StdEncoding.DecodeString(element.Base64Data)
-		if err != nil {
-			return fmt.Errorf("base64 decode failed: %w", err), nil
-		}
+		decoder := base64.NewDecoder(base64.StdEncoding, strings.NewReader(element.Base64Data))
 
-		filePath, err := globalUtils.WriteFileAtomicMP3(decodedBytes, initName)
+		key, hash, _, err := storage.StoreAudio(ctx, store, element.WorkspaceID, decoder)
 		if err != nil {
-			return fmt.Errorf("error while writing file '%s': %w", initName, err), nil
+			return fmt.Errorf("error while storing base64 file: %w", err), nil
 		}
 
-		path, hash, err := globalUtils.MarkFileAtomicMP3(filePath)
-		if err != nil {
-			return fmt.Errorf("error while marking file as mp3 '%s': %w", initName, err), nil
-		}
-
-		element.DownloadPath = path
+		element.DownloadPath = key
 		element.AudiofileHash = hash
 		element.Base64Data = ""
 		element.FileUrl = ""
@@ -109,21 +101,30 @@ func saveAudiofileElementToDisk(ctx context.Context, element *globalTypes.AudioD
 	return nil, element
 }
 
-func (w *Worker) opCtx() (context.Context, context.CancelFunc) {
-	return context.WithTimeout(w.StopCtx, opTimeout)
+// opCtx bounds a call of a stage by opTimeout, ctx is the stage context or StopCtx outside of a stage
+func (w *Worker) opCtx(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, opTimeout)
 }
 
-func (w *Worker) updateStage(audioDataElement *globalTypes.AudioDataElement) error {
+func (w *Worker) updateStage(ctx context.Context, audioDataElement *globalTypes.AudioDataElement) error {
 	audioDataElement.UpdateToNextStage()
 
-	ctx, cancel := w.opCtx()
-	err := w.postgres.UpsertBase(ctx, audioDataElement)
+	events := []globalTypes.AudioEvent{
+		globalTypes.NewAudioEvent(globalTypes.AudioEventStageChanged, audioDataElement),
+	}
+	if audioDataElement.LastSuccessfulStage == globalTypes.FinalStage() {
+		events = append(events, globalTypes.NewAudioEvent(globalTypes.AudioEventCompleted, audioDataElement))
+	}
+
+	callCtx, cancel := w.opCtx(ctx)
+	err := w.postgres.UpsertBaseWithEvents(callCtx, audioDataElement, events)
 	cancel()
 
 	return err
 }
 
-func (w *Worker) updateRetryCounter(workerIdx uint, audioDataElement *globalTypes.AudioDataElement, cause error) error {
+func (w *Worker) updateRetryCounter(ctx context.Context, workerIdx uint, audioDataElement *globalTypes.AudioDataElement, cause error) error {
+	failedStage := audioDataElement.LastSuccessfulStage.Next().Name()
 	audioDataElement.RetryCounter++
 
 	logImport(
@@ -134,6 +135,7 @@ func (w *Worker) updateRetryCounter(workerIdx uint, audioDataElement *globalType
 		"err", cause,
 	)
 
+	eventType := globalTypes.AudioEventRetried
 	if audioDataElement.RetryCounter >= maxRetryCount {
 		logImport(
 			slog.LevelError,
@@ -144,10 +146,17 @@ func (w *Worker) updateRetryCounter(workerIdx uint, audioDataElement *globalType
 		)
 
 		audioDataElement.LastSuccessfulStage = globalTypes.StageFailed
+		eventType = globalTypes.AudioEventFailed
+		metrics.StageFailures.Inc(failedStage)
+	} else {
+		metrics.StageRetries.Inc(failedStage)
 	}
 
-	ctx, cancel := w.opCtx()
-	err := w.postgres.UpsertBase(ctx, audioDataElement)
+	event := globalTypes.NewAudioEvent(eventType, audioDataElement)
+	event.Error = cause.Error()
+
+	callCtx, cancel := w.opCtx(ctx)
+	err := w.postgres.UpsertBaseWithEvents(callCtx, audioDataElement, []globalTypes.AudioEvent{event})
 	cancel()
 
 	if err != nil {
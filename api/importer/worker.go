@@ -7,6 +7,7 @@ import (
 	"go_audio_search_api_server/globalUtils"
 	"go_audio_search_api_server/postgres"
 	"go_audio_search_api_server/qdrant"
+	"go_audio_search_api_server/storage"
 	"log/slog"
 	"sync"
 	"time"
@@ -31,9 +32,10 @@ type Worker struct {
 	embeddings *ai.EmbeddingWorker
 	qdrant     *qdrant.Worker
 	llm        *ai.LlmWorker
+	storage    storage.Storage
 }
 
-func NewWorker(ctx context.Context, wg *sync.WaitGroup, qdrant *qdrant.Worker, postgres *postgres.Worker, embedder *ai.EmbeddingWorker, poolRefillSignal *globalUtils.NoneStackingEvent) *Worker {
+func NewWorker(ctx context.Context, wg *sync.WaitGroup, qdrant *qdrant.Worker, postgres *postgres.Worker, embedder *ai.EmbeddingWorker, store storage.Storage, poolRefillSignal *globalUtils.NoneStackingEvent) *Worker {
 
 	whisperReplicas := globalUtils.LoadEnvInt("WHISPER_REPLICAS")
 
@@ -49,10 +51,11 @@ func NewWorker(ctx context.Context, wg *sync.WaitGroup, qdrant *qdrant.Worker, p
 		embeddings:             embedder,
 		qdrant:                 qdrant,
 		llm:                    ai.NewLlmWorker(),
+		storage:                store,
 		WorkerWG:               wg,
 	}
 
-	ctx, cancel := worker.opCtx()
+	ctx, cancel := worker.opCtx(worker.StopCtx)
 	err := worker.postgres.ResetProcessingClaims(ctx)
 	cancel()
 
@@ -104,7 +107,7 @@ func (w *Worker) refillBuffer(
 		return
 	}
 
-	ctx, cancel := w.opCtx()
+	ctx, cancel := w.opCtx(w.StopCtx)
 	audioDataElements, err := w.postgres.ClaimNextAudioForProcessing(ctx, stage, uint64(space))
 	cancel()
 
@@ -4,12 +4,19 @@ import (
 	"context"
 	"errors"
 	"go_audio_search_api_server/ai"
+	"go_audio_search_api_server/eventStream"
+	"go_audio_search_api_server/feedPoller"
 	"go_audio_search_api_server/globalUtils"
 	"go_audio_search_api_server/importer"
 	"go_audio_search_api_server/postgres"
 	"go_audio_search_api_server/qdrant"
 	"go_audio_search_api_server/restApi"
 	"go_audio_search_api_server/searcher"
+	"go_audio_search_api_server/sparse"
+	"go_audio_search_api_server/storage"
+	"go_audio_search_api_server/tracing"
+	"go_audio_search_api_server/watchFolder"
+	"go_audio_search_api_server/webhooks"
 	"log/slog"
 	"net/http"
 	"os"
@@ -55,6 +62,12 @@ func main() {
 
 	var wg sync.WaitGroup
 
+	shutdownTracing, err := tracing.Init(ctx)
+	if err != nil {
+		slog.Error("failed to init tracing", "err", err)
+		os.Exit(1)
+	}
+
 	db, err := postgres.Open()
 	if err != nil {
 		slog.Error("failed to open db", "err", err)
@@ -66,19 +79,40 @@ func main() {
 		}
 	}()
 
-	qdrantWorker, err := qdrant.New("AudioSegments")
+	qdrantWorker, err := qdrant.New("AudioSegments", sparse.NewEncoder(ai.TranscriptionLanguage))
 	if err != nil {
 		slog.Error("failed to connect to qdrant", "err", err)
 		os.Exit(1)
 	}
 
+	store, err := storage.New()
+	if err != nil {
+		slog.Error("failed to open storage", "err", err)
+		os.Exit(1)
+	}
+
+	db.StartCounterFlush(ctx, &wg)
+
+	// points stored before the payload carried the audio fields are not found by the filtered semantic search
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := qdrantWorker.BackfillAudioPayload(ctx, db.GetAudioBySegmentHashes); err != nil && ctx.Err() == nil {
+			slog.Error("failed to backfill the qdrant payload", "err", err)
+		}
+	}()
+
 	poolRefillSignal := globalUtils.NewSignal()
 	embedder := ai.NewEmbeddingsWorker()
 
-	importer.NewWorker(ctx, &wg, qdrantWorker, db, embedder, poolRefillSignal)
+	importer.NewWorker(ctx, &wg, qdrantWorker, db, embedder, store, poolRefillSignal)
 	searchWorker := searcher.NewWorker(ctx, &wg, qdrantWorker, db, embedder)
+	poller := feedPoller.NewWorker(ctx, &wg, db, poolRefillSignal)
+	watchFolder.NewWorker(ctx, &wg, db, store, poolRefillSignal)
+	webhooks.NewWorker(ctx, &wg, db)
+	events := eventStream.NewWorker(ctx, &wg, db)
 
-	srv := restApi.NewRestServer(ctx, "8880", db, searchWorker, poolRefillSignal)
+	srv := restApi.NewRestServer(ctx, "8880", db, qdrantWorker, searchWorker, embedder, store, events, poolRefillSignal, poller.PollSignal)
 
 	wg.Add(1)
 	go func() {
@@ -117,4 +151,12 @@ func main() {
 	case <-time.After(30 * time.Second):
 		slog.Error("shutdown timeout")
 	}
+
+	// the spans of the last requests and stages are still in the batch of the exporter
+	tracingCtx, cancelTracing := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelTracing()
+
+	if err := shutdownTracing(tracingCtx); err != nil {
+		slog.Error("failed to flush traces", "err", err)
+	}
 }
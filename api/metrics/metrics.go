@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"time"
+)
+
+var (
+	// request durations, from fast reads up to long uploads
+	httpBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+	// pipeline stages and model calls, a transcription of a long recording takes minutes
+	workBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300, 600, 1800}
+)
+
+var (
+	HttpRequests = NewCounter(
+		"audio_search_http_requests_total",
+		"HTTP requests by route pattern, method and status. Unmatched paths have the route \"unmatched\".",
+		"route", "method", "status",
+	)
+	HttpRequestDuration = NewHistogram(
+		"audio_search_http_request_duration_seconds",
+		"Time until the handler returned, for /events the lifetime of the stream.",
+		httpBuckets,
+		"route", "method", "status",
+	)
+
+	QueueDepth = NewGauge(
+		"audio_search_queue_depth",
+		"Audio files waiting for the next stage, by the last successful stage. Read from Postgres on every scrape.",
+		"stage",
+	)
+	StageDuration = NewHistogram(
+		"audio_search_pipeline_stage_duration_seconds",
+		"Time one worker spent on an audio file, by the stage it worked towards and the result ok or error.",
+		workBuckets,
+		"stage", "result",
+	)
+	StageRetries = NewCounter(
+		"audio_search_pipeline_retries_total",
+		"Failed attempts that will be retried, by the stage that failed.",
+		"stage",
+	)
+	StageFailures = NewCounter(
+		"audio_search_pipeline_failures_total",
+		"Audio files that ran out of retries, by the stage that failed.",
+		"stage",
+	)
+
+	ExternalRequestDuration = NewHistogram(
+		"audio_search_external_request_duration_seconds",
+		"Latency of calls to Whisper, Ollama and Qdrant by service and operation, including failed calls.",
+		workBuckets,
+		"service", "operation",
+	)
+	ExternalRequestErrors = NewCounter(
+		"audio_search_external_request_errors_total",
+		"Failed calls to Whisper, Ollama and Qdrant by service and operation.",
+		"service", "operation",
+	)
+)
+
+// ObserveCall records the latency of a call to an external service started at start and counts it as failed if
+// err is not nil. It is meant to be deferred with a pointer to the named error result.
+func ObserveCall(service string, operation string, start time.Time, err *error) {
+	ExternalRequestDuration.Observe(time.Since(start).Seconds(), service, operation)
+	if err != nil && *err != nil {
+		ExternalRequestErrors.Inc(service, operation)
+	}
+}
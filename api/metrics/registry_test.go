@@ -0,0 +1,210 @@
+package metrics
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+// the families of the tests are not registered, so they neither show up in WriteText nor leak between tests
+
+func TestCounterText(t *testing.T) {
+	c := &Counter{newFamily("test_requests_total", "Requests by route.", "counter", []string{"route", "status"})}
+	c.Inc("/search", "200")
+	c.Add(2.5, "/audio", "500")
+	c.Inc("/search", "200")
+
+	want := `# HELP test_requests_total Requests by route.
+# TYPE test_requests_total counter
+test_requests_total{route="/audio",status="500"} 2.5
+test_requests_total{route="/search",status="200"} 2
+`
+	assertText(t, c, want)
+}
+
+func TestCounterWithoutLabels(t *testing.T) {
+	c := &Counter{newFamily("test_events_total", "Events.", "counter", nil)}
+	c.Inc()
+
+	want := `# HELP test_events_total Events.
+# TYPE test_events_total counter
+test_events_total 1
+`
+	assertText(t, c, want)
+}
+
+func TestEmptyFamilyWritesHeaderOnly(t *testing.T) {
+	c := &Counter{newFamily("test_unused_total", "Never incremented.", "counter", []string{"stage"})}
+
+	want := `# HELP test_unused_total Never incremented.
+# TYPE test_unused_total counter
+`
+	assertText(t, c, want)
+}
+
+func TestGaugeText(t *testing.T) {
+	g := &Gauge{newFamily("test_queue_depth", "Queued files.", "gauge", []string{"stage"})}
+	g.Set(7, "queued")
+	g.Set(3, "transcribed")
+	g.Set(1, "queued")
+	g.Set(math.Inf(1), "inf")
+	g.Set(math.Inf(-1), "minus_inf")
+	g.Set(math.NaN(), "nan")
+	g.Set(0.000001, "small")
+
+	want := `# HELP test_queue_depth Queued files.
+# TYPE test_queue_depth gauge
+test_queue_depth{stage="inf"} +Inf
+test_queue_depth{stage="minus_inf"} -Inf
+test_queue_depth{stage="nan"} NaN
+test_queue_depth{stage="queued"} 1
+test_queue_depth{stage="small"} 1e-06
+test_queue_depth{stage="transcribed"} 3
+`
+	assertText(t, g, want)
+}
+
+func TestHistogramText(t *testing.T) {
+	h := &Histogram{
+		family: newFamily("test_duration_seconds", "Durations.", "histogram", []string{"stage"}),
+		bounds: []float64{0.1, 1, 10},
+	}
+	// on a bound counts into that bucket, above the last bound only into +Inf
+	h.Observe(0.05, "embed")
+	h.Observe(0.1, "embed")
+	h.Observe(0.5, "embed")
+	h.Observe(20, "embed")
+	h.Observe(2, "transcribe")
+
+	want := `# HELP test_duration_seconds Durations.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{stage="embed",le="0.1"} 2
+test_duration_seconds_bucket{stage="embed",le="1"} 3
+test_duration_seconds_bucket{stage="embed",le="10"} 3
+test_duration_seconds_bucket{stage="embed",le="+Inf"} 4
+test_duration_seconds_sum{stage="embed"} 20.65
+test_duration_seconds_count{stage="embed"} 4
+test_duration_seconds_bucket{stage="transcribe",le="0.1"} 0
+test_duration_seconds_bucket{stage="transcribe",le="1"} 0
+test_duration_seconds_bucket{stage="transcribe",le="10"} 1
+test_duration_seconds_bucket{stage="transcribe",le="+Inf"} 1
+test_duration_seconds_sum{stage="transcribe"} 2
+test_duration_seconds_count{stage="transcribe"} 1
+`
+	assertText(t, h, want)
+}
+
+func TestHistogramWithoutLabels(t *testing.T) {
+	h := &Histogram{
+		family: newFamily("test_latency_seconds", "Latency.", "histogram", nil),
+		bounds: []float64{0.5},
+	}
+	h.Observe(0.25)
+
+	want := `# HELP test_latency_seconds Latency.
+# TYPE test_latency_seconds histogram
+test_latency_seconds_bucket{le="0.5"} 1
+test_latency_seconds_bucket{le="+Inf"} 1
+test_latency_seconds_sum 0.25
+test_latency_seconds_count 1
+`
+	assertText(t, h, want)
+}
+
+func TestLabelAndHelpEscaping(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"plain", "plain", `plain`},
+		{"quote", `say "hi"`, `say \"hi\"`},
+		{"backslash", `C:\audio`, `C:\\audio`},
+		{"newline", "two\nlines", `two\nlines`},
+		{"escaped newline", `a\nb`, `a\\nb`},
+		{"all", "\\\"\n", `\\\"\n`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := &Gauge{newFamily("test_labels", "Help with \\ and\nnewline, \"quotes\" stay.", "gauge", []string{"value"})}
+			g.Set(1, tt.value)
+
+			want := `# HELP test_labels Help with \\ and\nnewline, "quotes" stay.
+# TYPE test_labels gauge
+test_labels{value="` + tt.want + `"} 1
+`
+			assertText(t, g, want)
+		})
+	}
+}
+
+func TestHistogramLabelEscaping(t *testing.T) {
+	h := &Histogram{
+		family: newFamily("test_escaped_seconds", "Escaped.", "histogram", []string{"operation"}),
+		bounds: []float64{1},
+	}
+	h.Observe(0.5, "a\"b\\c\nd")
+
+	want := `# HELP test_escaped_seconds Escaped.
+# TYPE test_escaped_seconds histogram
+test_escaped_seconds_bucket{operation="a\"b\\c\nd",le="1"} 1
+test_escaped_seconds_bucket{operation="a\"b\\c\nd",le="+Inf"} 1
+test_escaped_seconds_sum{operation="a\"b\\c\nd"} 0.5
+test_escaped_seconds_count{operation="a\"b\\c\nd"} 1
+`
+	assertText(t, h, want)
+}
+
+func TestWrongLabelCountPanics(t *testing.T) {
+	c := &Counter{newFamily("test_panics_total", "Panics.", "counter", []string{"route"})}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Inc with a missing label value did not panic")
+		}
+	}()
+	c.Inc()
+}
+
+func TestWriteTextKeepsRegistrationOrder(t *testing.T) {
+	var buf bytes.Buffer
+	WriteText(&buf)
+	out := buf.String()
+
+	// every family of metrics.go is written with its header in the order it was registered
+	last := -1
+	for _, name := range []string{
+		"audio_search_http_requests_total",
+		"audio_search_http_request_duration_seconds",
+		"audio_search_queue_depth",
+		"audio_search_pipeline_stage_duration_seconds",
+		"audio_search_pipeline_retries_total",
+		"audio_search_pipeline_failures_total",
+		"audio_search_external_request_duration_seconds",
+		"audio_search_external_request_errors_total",
+	} {
+		help := bytes.Index([]byte(out), []byte("# HELP "+name+" "))
+		typ := bytes.Index([]byte(out), []byte("# TYPE "+name+" "))
+		if help < 0 || typ < 0 {
+			t.Fatalf("%s has no HELP or TYPE line:\n%s", name, out)
+		}
+		if help > typ {
+			t.Fatalf("%s has its TYPE line before its HELP line", name)
+		}
+		if help < last {
+			t.Fatalf("%s is written out of registration order", name)
+		}
+		last = help
+	}
+}
+
+func assertText(t *testing.T, m metric, want string) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	m.write(&buf)
+	if got := buf.String(); got != want {
+		t.Fatalf("exposition differs\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
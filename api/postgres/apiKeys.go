@@ -0,0 +1,175 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"go_audio_search_api_server/globalTypes"
+)
+
+const apiKeyColumns = `
+  id,
+  name,
+  prefix,
+  scopes::text,
+  workspace_id,
+  COALESCE(user_name, ''),
+  groups::text,
+  bootstrap,
+  created_at,
+  updated_at,
+  last_used_at,
+  revoked_at`
+
+func scanApiKey(row rowScanner) (*globalTypes.ApiKey, error) {
+	var r globalTypes.ApiKey
+	var scopes, groups string
+	var lastUsedAt, revokedAt sql.NullTime
+
+	err := row.Scan(
+		&r.ID,
+		&r.Name,
+		&r.Prefix,
+		&scopes,
+		&r.WorkspaceID,
+		&r.User,
+		&groups,
+		&r.Bootstrap,
+		&r.CreatedAt,
+		&r.UpdatedAt,
+		&lastUsedAt,
+		&revokedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	r.Scopes = nonNilStrings(stringSliceFromJSON(scopes))
+	r.Groups = nonNilStrings(stringSliceFromJSON(groups))
+	if lastUsedAt.Valid {
+		r.LastUsedAt = &lastUsedAt.Time
+	}
+	if revokedAt.Valid {
+		r.RevokedAt = &revokedAt.Time
+	}
+	return &r, nil
+}
+
+// CreateApiKey stores a new key, only its hash and display prefix are kept
+func (s *Worker) CreateApiKey(ctx context.Context, req globalTypes.ApiKeyRequest, keyHash string, prefix string) (*globalTypes.ApiKey, error) {
+	scopes, err := jsonFromStringSlice(req.Scopes)
+	if err != nil {
+		return nil, err
+	}
+	groups, err := jsonFromStringSlice(req.Groups)
+	if err != nil {
+		return nil, err
+	}
+
+	q := `
+INSERT INTO api_keys (name, prefix, key_hash, scopes, workspace_id, user_name, groups)
+VALUES ($1, $2, $3, $4::jsonb, $5, $6, $7::jsonb)
+RETURNING` + apiKeyColumns + `;`
+
+	return scanApiKey(s.db.QueryRowContext(ctx, q, req.Name, prefix, keyHash, scopes, req.WorkspaceID, nullIfEmpty(req.User), groups))
+}
+
+// EnsureBootstrapApiKey makes the configured admin key the single bootstrap key of the default workspace,
+// it holds the system scope to set up the workspaces. A revoked bootstrap key stays revoked until a different key
+// is configured.
+func (s *Worker) EnsureBootstrapApiKey(ctx context.Context, keyHash string, prefix string) error {
+	scopes, err := jsonFromStringSlice([]string{globalTypes.ScopeSystem})
+	if err != nil {
+		return err
+	}
+
+	const q = `
+WITH updated AS (
+  UPDATE api_keys
+  SET key_hash = $1,
+      prefix = $2,
+      scopes = $3::jsonb,
+      revoked_at = CASE WHEN key_hash = $1 THEN revoked_at ELSE NULL END,
+      updated_at = CASE WHEN key_hash = $1 THEN updated_at ELSE now() END
+  WHERE bootstrap = TRUE
+  RETURNING id
+)
+INSERT INTO api_keys (name, prefix, key_hash, scopes, bootstrap)
+SELECT 'bootstrap admin', $2, $1, $3::jsonb, TRUE
+WHERE NOT EXISTS (SELECT 1 FROM updated);
+`
+
+	if _, err := s.db.ExecContext(ctx, q, keyHash, prefix, scopes); err != nil {
+		return fmt.Errorf("ensure bootstrap api key: %w", err)
+	}
+	return nil
+}
+
+// ListApiKeys returns the keys of the workspace, an empty workspaceID lists the keys of all workspaces
+func (s *Worker) ListApiKeys(ctx context.Context, workspaceID string) ([]globalTypes.ApiKey, error) {
+	q := `SELECT` + apiKeyColumns + ` FROM api_keys WHERE ($1 = '' OR workspace_id = $1) ORDER BY id;`
+
+	rows, err := s.db.QueryContext(ctx, q, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []globalTypes.ApiKey{}
+	for rows.Next() {
+		key, err := scanApiKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *key)
+	}
+	return out, rows.Err()
+}
+
+// GetActiveApiKeyByHash returns the key with the hash unless it is revoked, sql.ErrNoRows otherwise
+func (s *Worker) GetActiveApiKeyByHash(ctx context.Context, keyHash string) (*globalTypes.ApiKey, error) {
+	q := `SELECT` + apiKeyColumns + ` FROM api_keys WHERE key_hash = $1 AND revoked_at IS NULL;`
+	return scanApiKey(s.db.QueryRowContext(ctx, q, keyHash))
+}
+
+// TouchApiKey sets last_used_at, at most once a minute per key to keep the writes down
+func (s *Worker) TouchApiKey(ctx context.Context, id int64) error {
+	const q = `
+UPDATE api_keys
+SET last_used_at = now()
+WHERE id = $1
+  AND (last_used_at IS NULL OR last_used_at < now() - interval '1 minute');
+`
+	_, err := s.db.ExecContext(ctx, q, id)
+	return err
+}
+
+// RotateApiKey replaces the key of an active api key of the workspace, the old key stops working immediately.
+// An empty workspaceID matches keys of all workspaces, otherwise system keys are left out, so an admin cannot take
+// one over. Returns sql.ErrNoRows for unknown or revoked keys.
+func (s *Worker) RotateApiKey(ctx context.Context, workspaceID string, id int64, keyHash string, prefix string) (*globalTypes.ApiKey, error) {
+	q := `
+UPDATE api_keys
+SET key_hash = $2,
+    prefix = $3,
+    updated_at = now()
+WHERE id = $1 AND revoked_at IS NULL AND ($4 = '' OR (workspace_id = $4 AND NOT scopes ? 'system'))
+RETURNING` + apiKeyColumns + `;`
+
+	return scanApiKey(s.db.QueryRowContext(ctx, q, id, keyHash, prefix, workspaceID))
+}
+
+// RevokeApiKey disables a key of the workspace for good, the row stays for the audit trail.
+// An empty workspaceID matches keys of all workspaces, otherwise system keys are left out.
+// Returns sql.ErrNoRows for unknown keys.
+func (s *Worker) RevokeApiKey(ctx context.Context, workspaceID string, id int64) (*globalTypes.ApiKey, error) {
+	q := `
+UPDATE api_keys
+SET revoked_at = COALESCE(revoked_at, now()),
+    updated_at = now()
+WHERE id = $1 AND ($2 = '' OR (workspace_id = $2 AND NOT scopes ? 'system'))
+RETURNING` + apiKeyColumns + `;`
+
+	return scanApiKey(s.db.QueryRowContext(ctx, q, id, workspaceID))
+}
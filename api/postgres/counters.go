@@ -1,5 +1,12 @@
 package postgres
 
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
 type Counter string
 
 const (
@@ -9,3 +16,102 @@ const (
 	SearchRequestsFailed     Counter = "search_requests_failed"
 	SearchRequestsSuccessful Counter = "search_requests_successful"
 )
+
+// counterFlushInterval is how long increments stay in memory before they are added to the counters table
+const counterFlushInterval = 10 * time.Second
+
+// counterKey is a counter of one workspace
+type counterKey struct {
+	workspaceID string
+	counter     Counter
+}
+
+// pendingCounters collects increments in memory, so requests do not wait for a database write
+type pendingCounters struct {
+	mu     sync.Mutex
+	deltas map[counterKey]int
+}
+
+// IncCounter adds one to the counter of the workspace, the table is updated by the next flush of StartCounterFlush
+func (s *Worker) IncCounter(workspaceID string, counter Counter) {
+	s.counters.mu.Lock()
+	defer s.counters.mu.Unlock()
+
+	if s.counters.deltas == nil {
+		s.counters.deltas = map[counterKey]int{}
+	}
+	s.counters.deltas[counterKey{workspaceID: workspaceOrDefault(workspaceID), counter: counter}]++
+}
+
+// FlushCounters adds the pending increments to the counters table. Increments that could not be written are kept
+// for the next flush.
+func (s *Worker) FlushCounters(ctx context.Context) error {
+	s.counters.mu.Lock()
+	deltas := s.counters.deltas
+	s.counters.deltas = nil
+	s.counters.mu.Unlock()
+
+	for key, delta := range deltas {
+		if err := s.AddToCounter(ctx, key.workspaceID, key.counter, delta); err != nil {
+			s.counters.mu.Lock()
+			if s.counters.deltas == nil {
+				s.counters.deltas = map[counterKey]int{}
+			}
+			for c, d := range deltas {
+				s.counters.deltas[c] += d
+			}
+			s.counters.mu.Unlock()
+			return err
+		}
+		delete(deltas, key)
+	}
+
+	return nil
+}
+
+// StartCounterFlush flushes the counters every counterFlushInterval and a last time when ctx is done. Once an hour
+// it removes the counter buckets that are older than counterBucketRetention.
+func (s *Worker) StartCounterFlush(ctx context.Context, wg *sync.WaitGroup) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		ticker := time.NewTicker(counterFlushInterval)
+		defer ticker.Stop()
+
+		pruneTicker := time.NewTicker(time.Hour)
+		defer pruneTicker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				err := s.FlushCounters(flushCtx)
+				cancel()
+
+				if err != nil {
+					slog.Error("Error while flushing counters on shutdown", "err", err)
+				}
+				return
+
+			case <-ticker.C:
+				flushCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+				err := s.FlushCounters(flushCtx)
+				cancel()
+
+				if err != nil {
+					slog.Error("Error while flushing counters", "err", err)
+				}
+
+			case <-pruneTicker.C:
+				pruneCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+				err := s.pruneCounterBuckets(pruneCtx)
+				cancel()
+
+				if err != nil {
+					slog.Error("Error while pruning counter buckets", "err", err)
+				}
+			}
+		}
+	}()
+}
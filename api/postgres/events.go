@@ -0,0 +1,120 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go_audio_search_api_server/globalTypes"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// audioEventsChannel is the notification channel every instance listens on for AudioEvents
+const audioEventsChannel = "audio_events"
+
+// maxEventErrorLength keeps the notification below the 8000 byte payload limit of Postgres
+const maxEventErrorLength = 2000
+
+// UpsertBaseWithEvents stores the audio file and records the events in the same transaction,
+// so an event is never lost or sent for a change that was rolled back.
+func (s *Worker) UpsertBaseWithEvents(ctx context.Context, a *globalTypes.AudioDataElement, events []globalTypes.AudioEvent) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := upsertBaseBatchTx(ctx, tx, []*globalTypes.AudioDataElement{a}); err != nil {
+		return err
+	}
+
+	for _, event := range events {
+		if err := recordAudioEventTx(ctx, tx, event); err != nil {
+			return err
+		}
+
+		// later metadata changes move updated_at, the statistics count the file in the hour the pipeline finished it
+		if event.Type == globalTypes.AudioEventCompleted || event.Type == globalTypes.AudioEventFailed {
+			if _, err := tx.ExecContext(ctx, `UPDATE audiofiles SET completed_at = now() WHERE audiofile_hash = $1;`, a.AudiofileHash); err != nil {
+				return fmt.Errorf("set completed_at: %w", err)
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+// recordAudioEventTx queues the event for the webhooks and notifies the listening instances once the transaction commits
+func recordAudioEventTx(ctx context.Context, tx *sql.Tx, event globalTypes.AudioEvent) error {
+	if len(event.Error) > maxEventErrorLength {
+		event.Error = event.Error[:maxEventErrorLength] + "..."
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("marshal audio event: %w", err)
+	}
+
+	if err := enqueueWebhookEventTx(ctx, tx, event, payload); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_notify($1, $2);`, audioEventsChannel, string(payload)); err != nil {
+		return fmt.Errorf("notify audio event: %w", err)
+	}
+	return nil
+}
+
+// ListenAudioEvents passes every AudioEvent recorded by any instance to handle until ctx is done.
+// The listening connection is reconnected after errors, events sent while it was down are missed.
+func (s *Worker) ListenAudioEvents(ctx context.Context, handle func(globalTypes.AudioEvent)) {
+	for ctx.Err() == nil {
+		err := s.listenAudioEvents(ctx, handle)
+		if ctx.Err() != nil {
+			return
+		}
+
+		slog.Warn("Audio event listener disconnected, reconnecting", "err", err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(3 * time.Second):
+		}
+	}
+}
+
+func (s *Worker) listenAudioEvents(ctx context.Context, handle func(globalTypes.AudioEvent)) error {
+	conn, err := pgx.Connect(ctx, s.dsn)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		closeCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		_ = conn.Close(closeCtx)
+		cancel()
+	}()
+
+	if _, err := conn.Exec(ctx, "LISTEN "+audioEventsChannel); err != nil {
+		return err
+	}
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+
+		var event globalTypes.AudioEvent
+		if err := json.Unmarshal([]byte(notification.Payload), &event); err != nil {
+			slog.Warn("Skipping malformed audio event", "err", err)
+			continue
+		}
+
+		handle(event)
+	}
+}
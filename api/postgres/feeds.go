@@ -0,0 +1,226 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"go_audio_search_api_server/globalTypes"
+)
+
+var ErrFeedSubscriptionExists = errors.New("feed is already subscribed")
+
+const feedSubscriptionColumns = `
+  id,
+  workspace_id,
+  feed_url,
+  COALESCE(title, ''),
+  COALESCE(category, ''),
+  COALESCE(audio_type, ''),
+  poll_interval_sec,
+  backfill_latest,
+  paused,
+  last_polled_at,
+  next_poll_at,
+  COALESCE(last_error, ''),
+  last_new_episodes,
+  created_at,
+  updated_at`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanFeedSubscription(row rowScanner) (*globalTypes.FeedSubscription, error) {
+	var r globalTypes.FeedSubscription
+	var lastPolledAt sql.NullTime
+
+	err := row.Scan(
+		&r.ID,
+		&r.WorkspaceID,
+		&r.FeedUrl,
+		&r.Title,
+		&r.Category,
+		&r.AudioType,
+		&r.PollIntervalSec,
+		&r.BackfillLatest,
+		&r.Paused,
+		&lastPolledAt,
+		&r.NextPollAt,
+		&r.LastError,
+		&r.LastNewEpisodes,
+		&r.CreatedAt,
+		&r.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if lastPolledAt.Valid {
+		r.LastPolledAt = &lastPolledAt.Time
+	}
+	return &r, nil
+}
+
+func (s *Worker) CreateFeedSubscription(ctx context.Context, workspaceID string, req globalTypes.FeedSubscriptionRequest) (*globalTypes.FeedSubscription, error) {
+	q := `
+INSERT INTO feed_subscriptions (workspace_id, feed_url, category, audio_type, poll_interval_sec, backfill_latest)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (workspace_id, feed_url) DO NOTHING
+RETURNING` + feedSubscriptionColumns + `;`
+
+	sub, err := scanFeedSubscription(s.db.QueryRowContext(ctx, q,
+		workspaceID,
+		req.FeedUrl,
+		nullIfEmpty(req.Category),
+		nullIfEmpty(req.AudioType),
+		req.PollIntervalSec,
+		req.BackfillLatest,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrFeedSubscriptionExists
+	}
+	return sub, err
+}
+
+func (s *Worker) ListFeedSubscriptions(ctx context.Context, workspaceID string) ([]globalTypes.FeedSubscription, error) {
+	q := `SELECT` + feedSubscriptionColumns + ` FROM feed_subscriptions WHERE workspace_id = $1 ORDER BY id;`
+
+	rows, err := s.db.QueryContext(ctx, q, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []globalTypes.FeedSubscription{}
+	for rows.Next() {
+		sub, err := scanFeedSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *sub)
+	}
+	return out, rows.Err()
+}
+
+// GetFeedSubscription returns sql.ErrNoRows for unknown ids and subscriptions of other workspaces
+func (s *Worker) GetFeedSubscription(ctx context.Context, workspaceID string, id int64) (*globalTypes.FeedSubscription, error) {
+	q := `SELECT` + feedSubscriptionColumns + ` FROM feed_subscriptions WHERE id = $1 AND workspace_id = $2;`
+	return scanFeedSubscription(s.db.QueryRowContext(ctx, q, id, workspaceID))
+}
+
+// SetFeedSubscriptionPaused pauses or resumes a subscription, a resumed subscription is due immediately
+func (s *Worker) SetFeedSubscriptionPaused(ctx context.Context, workspaceID string, id int64, paused bool) (*globalTypes.FeedSubscription, error) {
+	q := `
+UPDATE feed_subscriptions
+SET paused = $2,
+    next_poll_at = CASE WHEN $2 THEN next_poll_at ELSE now() END,
+    updated_at = now()
+WHERE id = $1 AND workspace_id = $3
+RETURNING` + feedSubscriptionColumns + `;`
+	return scanFeedSubscription(s.db.QueryRowContext(ctx, q, id, paused, workspaceID))
+}
+
+// DeleteFeedSubscription removes the subscription and its seen episodes, imported audio files are kept
+func (s *Worker) DeleteFeedSubscription(ctx context.Context, workspaceID string, id int64) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM feed_subscriptions WHERE id = $1 AND workspace_id = $2;`, id, workspaceID)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ClaimDueFeedSubscription moves the next poll of one due subscription forward and returns it, nil if none is due.
+// Several API instances can poll side by side because locked rows are skipped.
+func (s *Worker) ClaimDueFeedSubscription(ctx context.Context) (*globalTypes.FeedSubscription, error) {
+	q := `
+UPDATE feed_subscriptions
+SET next_poll_at = now() + make_interval(secs => poll_interval_sec)
+WHERE id = (
+  SELECT id
+  FROM feed_subscriptions
+  WHERE paused = FALSE AND next_poll_at <= now()
+  ORDER BY next_poll_at
+  LIMIT 1
+  FOR UPDATE SKIP LOCKED
+)
+RETURNING` + feedSubscriptionColumns + `;`
+
+	sub, err := scanFeedSubscription(s.db.QueryRowContext(ctx, q))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return sub, err
+}
+
+// GetSeenFeedEpisodes returns the guids and enclosure urls of all episodes the subscription has already handled
+func (s *Worker) GetSeenFeedEpisodes(ctx context.Context, id int64) (guids map[string]bool, urls map[string]bool, err error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT guid, enclosure_url FROM feed_seen_episodes WHERE subscription_id = $1;`, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	guids = map[string]bool{}
+	urls = map[string]bool{}
+	for rows.Next() {
+		var guid, url string
+		if err := rows.Scan(&guid, &url); err != nil {
+			return nil, nil, err
+		}
+		guids[guid] = true
+		urls[url] = true
+	}
+	return guids, urls, rows.Err()
+}
+
+// QueueFeedEpisodes queues the new episodes and marks the given keys as seen in one transaction,
+// so an episode is never queued twice by a poll that fails halfway.
+func (s *Worker) QueueFeedEpisodes(ctx context.Context, id int64, items []*globalTypes.AudioDataElement, seen []globalTypes.FeedEpisodeKey) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if len(items) > 0 {
+		if err := upsertBaseBatchTx(ctx, tx, items); err != nil {
+			return err
+		}
+	}
+
+	const q = `
+INSERT INTO feed_seen_episodes (subscription_id, guid, enclosure_url)
+VALUES ($1, $2, $3)
+ON CONFLICT (subscription_id, guid) DO NOTHING;`
+
+	for _, key := range seen {
+		if _, err := tx.ExecContext(ctx, q, id, key.Guid, key.EnclosureUrl); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// FinishFeedPoll stores the outcome of a poll, an empty pollErr clears the last error
+func (s *Worker) FinishFeedPoll(ctx context.Context, id int64, title string, newEpisodes int, pollErr string) error {
+	const q = `
+UPDATE feed_subscriptions
+SET last_polled_at = now(),
+    title = COALESCE($2, title),
+    last_new_episodes = $3,
+    last_error = $4,
+    updated_at = now()
+WHERE id = $1;`
+
+	_, err := s.db.ExecContext(ctx, q, id, nullIfEmpty(title), newEpisodes, nullIfEmpty(pollErr))
+	return err
+}
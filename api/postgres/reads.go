@@ -4,37 +4,51 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"strings"
+	"time"
 
 	"go_audio_search_api_server/globalTypes"
 )
 
-func (s *Worker) GetSearchAudioDataByHash(ctx context.Context, audioHash string) (*globalTypes.SearchAudioData, error) {
-	const q = `
+// GetSearchAudioDataByHash returns sql.ErrNoRows if the audio file does not exist or the viewer may not read it
+func (s *Worker) GetSearchAudioDataByHash(ctx context.Context, audioHash string, viewer globalTypes.Viewer) (*globalTypes.SearchAudioData, error) {
+	where, args := viewerWhere("", viewer, []any{audioHash})
+
+	q := `
 SELECT
   audiofile_hash,
   COALESCE(title, ''),
   COALESCE(recording_date::text, ''),
+  COALESCE(category, ''),
+  COALESCE(audio_type, ''),
   COALESCE(duration_in_sec, 0),
   COALESCE(transcript_full, ''),
   COALESCE(user_summary_text, ''),
   COALESCE(ai_keywords::text, ''),
-  COALESCE(ai_summary, '')
+  COALESCE(ai_summary, ''),
+  COALESCE(owner, ''),
+  acl::text
 FROM audiofiles
-WHERE audiofile_hash = $1;
+WHERE audiofile_hash = $1
+  AND ` + where + `;
 `
 
 	var r globalTypes.SearchAudioData
-	var aiKeywordsJSON string
-	err := s.db.QueryRowContext(ctx, q, audioHash).Scan(
+	var aiKeywordsJSON, aclJSON string
+	err := s.db.QueryRowContext(ctx, q, args...).Scan(
 		&r.AudiofileHash,
 		&r.Title,
 		&r.RecordingDate,
+		&r.Category,
+		&r.AudioType,
 		&r.DurationInSec,
 		&r.TranscriptFull,
 		&r.UserSummary,
 		&aiKeywordsJSON,
 		&r.AiSummary,
+		&r.Owner,
+		&aclJSON,
 	)
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, err
@@ -43,13 +57,14 @@ WHERE audiofile_hash = $1;
 		return nil, err
 	}
 	r.AiKeywords = stringSliceFromJSON(aiKeywordsJSON)
+	r.Acl = stringSliceFromJSON(aclJSON)
 
 	return &r, nil
 }
 
 func (s *Worker) GetAllSegmentsByAudioHash(ctx context.Context, audioHash string) ([]globalTypes.SegmentElement, error) {
 	const q = `
-SELECT segment_hash, sentence_index, transcript
+SELECT segment_hash, sentence_index, transcript, start_sec, end_sec
 FROM segments
 WHERE audiofile_hash = $1
 ORDER BY sentence_index ASC;
@@ -69,6 +84,8 @@ ORDER BY sentence_index ASC;
 			&segment.SegmentHash,
 			&segment.SentenceIndex,
 			&segment.Transcript,
+			&segment.StartSec,
+			&segment.EndSec,
 		); err != nil {
 			return nil, err
 		}
@@ -80,6 +97,16 @@ ORDER BY sentence_index ASC;
 	return out, rows.Err()
 }
 
+// GetSegmentsEndSec returns the end of the last timed segment of the audio file, 0 if no segment is timed.
+// It stands in for duration_in_sec of recordings that were transcribed before the duration was stored.
+func (s *Worker) GetSegmentsEndSec(ctx context.Context, audioHash string) (float64, error) {
+	const q = `SELECT COALESCE(max(end_sec), 0) FROM segments WHERE audiofile_hash = $1;`
+
+	var endSec float64
+	err := s.db.QueryRowContext(ctx, q, audioHash).Scan(&endSec)
+	return endSec, err
+}
+
 func (s *Worker) ClaimNextAudioForProcessing(
 	ctx context.Context,
 	lastSuccessfulStage globalTypes.ProcessingStage,
@@ -101,6 +128,7 @@ WITH next_rows AS (
     FROM audiofiles
     WHERE last_successful_stage = $1
       AND gets_processed = FALSE
+      AND delete_requested = FALSE
     ORDER BY last_successful_stage ASC, created_at ASC
     FOR UPDATE SKIP LOCKED
     LIMIT $2
@@ -124,7 +152,13 @@ RETURNING
   COALESCE(a.ai_keywords::text, '[]'),
   COALESCE(a.ai_summary, ''),
   COALESCE(a.last_successful_stage, 0),
-  COALESCE(a.retry_counter, 0);
+  COALESCE(a.retry_counter, 0),
+  COALESCE(a.import_id, ''),
+  a.workspace_id,
+  COALESCE(a.owner, ''),
+  a.acl::text,
+  COALESCE(a.trace_parent, ''),
+  COALESCE(a.language, '');
 `
 
 	rows, err := tx.QueryContext(ctx, q, int64(lastSuccessfulStage), int64(amount))
@@ -138,7 +172,7 @@ RETURNING
 	for rows.Next() {
 		var r globalTypes.AudioDataElement
 		var stage int64
-		var aiKeywordsJSON string
+		var aiKeywordsJSON, aclJSON string
 
 		if err := rows.Scan(
 			&r.AudiofileHash,
@@ -156,11 +190,18 @@ RETURNING
 			&r.AiSummary,
 			&stage,
 			&r.RetryCounter,
+			&r.ImportID,
+			&r.WorkspaceID,
+			&r.Owner,
+			&aclJSON,
+			&r.TraceParent,
+			&r.Language,
 		); err != nil {
 			return nil, err
 		}
 
 		r.AiKeywords = stringSliceFromJSON(aiKeywordsJSON)
+		r.Acl = stringSliceFromJSON(aclJSON)
 		r.LastSuccessfulStage = globalTypes.ProcessingStage(stage)
 
 		out = append(out, &r)
@@ -180,16 +221,21 @@ RETURNING
 	return out, nil
 }
 
-func (s *Worker) GetSegmentByHash(ctx context.Context, segmentHash string) (*globalTypes.SearchSegmentData, error) {
-	const q = `
-SELECT segment_hash, audiofile_hash, sentence_index, transcript
-FROM segments
-WHERE segment_hash = $1;
+// GetSegmentByHash returns nil if the segment does not exist or the viewer may not read its audio file
+func (s *Worker) GetSegmentByHash(ctx context.Context, segmentHash string, viewer globalTypes.Viewer) (*globalTypes.SearchSegmentData, error) {
+	where, args := viewerWhere("a.", viewer, []any{segmentHash})
+
+	q := `
+SELECT s.segment_hash, s.audiofile_hash, s.sentence_index, s.transcript
+FROM segments s
+JOIN audiofiles a ON a.audiofile_hash = s.audiofile_hash
+WHERE s.segment_hash = $1
+  AND ` + where + `;
 `
 
 	var r globalTypes.SearchSegmentData
 
-	err := s.db.QueryRowContext(ctx, q, segmentHash).Scan(
+	err := s.db.QueryRowContext(ctx, q, args...).Scan(
 		&r.SegmentHash,
 		&r.AudiofileHash,
 		&r.SentenceIndex,
@@ -207,15 +253,21 @@ WHERE segment_hash = $1;
 
 // GetPostgresCandidates bleibt absichtlich gleich benannt, damit dein Restcode nicht bricht.
 // Intern ist das jetzt Postgres Full Text Search.
-func (s *Worker) GetPostgresCandidates(ctx context.Context, userInput string, k int, category string, startDateISO string, endDateISO string) ([]globalTypes.SegmentElement, error) {
+func (s *Worker) GetPostgresCandidates(ctx context.Context, viewer globalTypes.Viewer, userInput string, k int, category string, startDateISO string, endDateISO string) ([]globalTypes.SegmentElement, error) {
 	if strings.TrimSpace(userInput) == "" {
 		return nil, errors.New("userInput empty")
 	}
+	if viewer.WorkspaceID == "" {
+		return nil, errors.New("viewer without workspace")
+	}
 	if k <= 0 {
 		k = 200
 	}
 
-	const q = `
+	// the acl condition is part of the query, so restricted segments never take a place in the top k
+	where, args := viewerWhere("a.", viewer, []any{userInput, startDateISO, endDateISO, category, k, viewer.WorkspaceID})
+
+	q := `
 WITH search_query AS (
   SELECT websearch_to_tsquery('simple', $1) AS query
 )
@@ -229,6 +281,9 @@ FROM segments s
 JOIN audiofiles a ON a.audiofile_hash = s.audiofile_hash
 CROSS JOIN search_query
 WHERE s.transcript_tsv @@ search_query.query
+  AND s.workspace_id = $6
+  AND ` + where + `
+  AND a.delete_requested = FALSE
   AND a.recording_date >= COALESCE(NULLIF($2, '')::date, DATE '0001-01-01')
   AND a.recording_date <  COALESCE(NULLIF($3, '')::date, DATE '9999-12-31')
   AND a.category IS NOT DISTINCT FROM $4
@@ -236,7 +291,7 @@ ORDER BY score DESC, s.sentence_index ASC
 LIMIT $5;
 `
 
-	rows, err := s.db.QueryContext(ctx, q, userInput, startDateISO, endDateISO, category, k)
+	rows, err := s.db.QueryContext(ctx, q, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -261,3 +316,318 @@ LIMIT $5;
 
 	return out, rows.Err()
 }
+
+// GetAudioDataByHash loads the stored pipeline state of one audio file, returns nil if it does not exist.
+func (s *Worker) GetAudioDataByHash(ctx context.Context, audioHash string) (*globalTypes.AudioDataElement, error) {
+	const q = `
+SELECT
+  audiofile_hash,
+  COALESCE(title, ''),
+  COALESCE(recording_date::text, ''),
+  COALESCE(category, ''),
+  COALESCE(audio_type, ''),
+  COALESCE(file_url, ''),
+  COALESCE(download_path, ''),
+  COALESCE(duration_in_sec, 0),
+  COALESCE(user_summary_text, ''),
+  COALESCE(last_successful_stage, 0),
+  COALESCE(retry_counter, 0),
+  gets_processed,
+  workspace_id,
+  COALESCE(owner, ''),
+  acl::text,
+  COALESCE(language, '')
+FROM audiofiles
+WHERE audiofile_hash = $1;
+`
+
+	var r globalTypes.AudioDataElement
+	var stage int64
+	var aclJSON string
+
+	err := s.db.QueryRowContext(ctx, q, audioHash).Scan(
+		&r.AudiofileHash,
+		&r.Title,
+		&r.RecordingDate,
+		&r.Category,
+		&r.AudioType,
+		&r.FileUrl,
+		&r.DownloadPath,
+		&r.DurationInSec,
+		&r.UserSummary,
+		&stage,
+		&r.RetryCounter,
+		&r.GetsProcessed,
+		&r.WorkspaceID,
+		&r.Owner,
+		&aclJSON,
+		&r.Language,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	r.LastSuccessfulStage = globalTypes.ProcessingStage(stage)
+	r.Acl = stringSliceFromJSON(aclJSON)
+
+	return &r, nil
+}
+
+// GetAudiofileHashesByFilter returns the hashes of all audio files matching the filter.
+func (s *Worker) GetAudiofileHashesByFilter(ctx context.Context, filter globalTypes.AudioFilter) ([]string, error) {
+	where, args := audioFilterWhere(filter, nil)
+
+	q := `
+SELECT audiofile_hash
+FROM audiofiles
+WHERE ` + where + `
+ORDER BY created_at ASC;
+`
+
+	rows, err := s.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []string
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return nil, err
+		}
+		out = append(out, hash)
+	}
+
+	return out, rows.Err()
+}
+
+// ListAudio returns one page of audio files using keyset pagination on the sort column and the hash.
+func (s *Worker) ListAudio(ctx context.Context, query globalTypes.AudioListQuery) ([]globalTypes.AudioListEntry, error) {
+	var sortExpr string
+	switch query.SortBy {
+	case globalTypes.SortByRecordingDate:
+		sortExpr = "COALESCE(recording_date, DATE '0001-01-01')"
+	case globalTypes.SortByCreatedAt, "":
+		sortExpr = "created_at"
+	default:
+		return nil, fmt.Errorf("unsupported sort column %q", query.SortBy)
+	}
+
+	direction, compare := "ASC", ">"
+	if query.Descending {
+		direction, compare = "DESC", "<"
+	}
+
+	where, args := audioFilterWhere(query.Filter, nil)
+	where += "\n  AND delete_requested = FALSE"
+
+	if query.After != nil {
+		castType := "timestamptz"
+		if query.SortBy == globalTypes.SortByRecordingDate {
+			castType = "date"
+		}
+
+		args = append(args, query.After.SortValue, query.After.Hash)
+		where += fmt.Sprintf("\n  AND (%s, audiofile_hash) %s ($%d::%s, $%d)", sortExpr, compare, len(args)-1, castType, len(args))
+	}
+
+	limit := query.Limit
+	if limit <= 0 {
+		limit = 50
+	}
+	args = append(args, limit)
+
+	q := fmt.Sprintf(`
+SELECT
+  audiofile_hash,
+  COALESCE(title, ''),
+  COALESCE(recording_date::text, ''),
+  COALESCE(category, ''),
+  COALESCE(audio_type, ''),
+  COALESCE(duration_in_sec, 0),
+  COALESCE(user_summary_text, ''),
+  COALESCE(last_successful_stage, 0),
+  COALESCE(retry_counter, 0),
+  created_at,
+  updated_at,
+  COALESCE(recording_date, DATE '0001-01-01')::text
+FROM audiofiles
+WHERE %s
+ORDER BY %s %s, audiofile_hash %s
+LIMIT $%d;
+`, where, sortExpr, direction, direction, len(args))
+
+	rows, err := s.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]globalTypes.AudioListEntry, 0, minInt(limit, 128))
+	for rows.Next() {
+		var r globalTypes.AudioListEntry
+		var stage int64
+		var createdAt, updatedAt time.Time
+		var recordingDateSort string
+
+		if err := rows.Scan(
+			&r.AudiofileHash,
+			&r.Title,
+			&r.RecordingDate,
+			&r.Category,
+			&r.AudioType,
+			&r.DurationInSec,
+			&r.UserSummary,
+			&stage,
+			&r.RetryCounter,
+			&createdAt,
+			&updatedAt,
+			&recordingDateSort,
+		); err != nil {
+			return nil, err
+		}
+
+		r.Stage = globalTypes.ProcessingStage(stage).Name()
+		r.CreatedAt = createdAt.UTC().Format(time.RFC3339Nano)
+		r.UpdatedAt = updatedAt.UTC().Format(time.RFC3339Nano)
+
+		if query.SortBy == globalTypes.SortByRecordingDate {
+			r.SortValue = recordingDateSort
+		} else {
+			r.SortValue = r.CreatedAt
+		}
+
+		out = append(out, r)
+	}
+
+	return out, rows.Err()
+}
+
+// CanViewAudio reports whether the audio file exists and the viewer may read it
+func (s *Worker) CanViewAudio(ctx context.Context, audioHash string, viewer globalTypes.Viewer) (bool, error) {
+	where, args := viewerWhere("", viewer, []any{audioHash})
+	q := `SELECT EXISTS(SELECT 1 FROM audiofiles WHERE audiofile_hash = $1 AND ` + where + `);`
+
+	var exists bool
+	err := s.db.QueryRowContext(ctx, q, args...).Scan(&exists)
+	return exists, err
+}
+
+// CountQueuedByApiKey counts the audio files queued with the api key that did not complete or fail yet
+func (s *Worker) CountQueuedByApiKey(ctx context.Context, apiKeyID int64) (int, error) {
+	const q = `
+SELECT count(*)
+FROM audiofiles
+WHERE api_key_id = $1
+  AND last_successful_stage > 0
+  AND last_successful_stage < $2
+  AND delete_requested = FALSE;
+`
+
+	var n int
+	err := s.db.QueryRowContext(ctx, q, apiKeyID, globalTypes.FinalStage()).Scan(&n)
+	return n, err
+}
+
+// CountQueuedByStage counts the audio files waiting for or in their next stage, keyed by the last successful stage.
+// Only the audio files the viewer may read are counted, a nil viewer counts all workspaces. Completed and failed
+// audio files are not part of the queue.
+func (s *Worker) CountQueuedByStage(ctx context.Context, viewer *globalTypes.Viewer) (map[globalTypes.ProcessingStage]int, error) {
+	where, args := "TRUE", []any{globalTypes.FinalStage()}
+	if viewer != nil {
+		where, args = viewerWhere("", *viewer, args)
+	}
+
+	q := `
+SELECT last_successful_stage, count(*)
+FROM audiofiles
+WHERE last_successful_stage > 0
+  AND last_successful_stage < $1
+  AND delete_requested = FALSE
+  AND ` + where + `
+GROUP BY last_successful_stage;
+`
+
+	rows, err := s.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := map[globalTypes.ProcessingStage]int{}
+	for rows.Next() {
+		var stage globalTypes.ProcessingStage
+		var n int
+		if err := rows.Scan(&stage, &n); err != nil {
+			return nil, err
+		}
+		counts[stage] = n
+	}
+
+	return counts, rows.Err()
+}
+
+// GetAudioBySegmentHashes returns the audio file of every given segment, keyed by the segment hash. Segments that do
+// not exist are missing in the result. Only the fields stored with the segment vectors in Qdrant are loaded.
+func (s *Worker) GetAudioBySegmentHashes(ctx context.Context, segmentHashes []string) (map[string]*globalTypes.AudioDataElement, error) {
+	out := map[string]*globalTypes.AudioDataElement{}
+	if len(segmentHashes) == 0 {
+		return out, nil
+	}
+
+	const q = `
+SELECT
+  s.segment_hash,
+  a.audiofile_hash,
+  COALESCE(a.recording_date::text, ''),
+  COALESCE(a.category, ''),
+  COALESCE(a.audio_type, ''),
+  a.workspace_id,
+  COALESCE(a.owner, ''),
+  a.acl::text,
+  COALESCE(a.language, '')
+FROM segments s
+JOIN audiofiles a ON a.audiofile_hash = s.audiofile_hash
+WHERE s.segment_hash = ANY($1);
+`
+
+	rows, err := s.db.QueryContext(ctx, q, segmentHashes)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// the segments of one audio file share its element
+	audios := map[string]*globalTypes.AudioDataElement{}
+	for rows.Next() {
+		var segmentHash, aclJSON string
+		var r globalTypes.AudioDataElement
+
+		if err := rows.Scan(
+			&segmentHash,
+			&r.AudiofileHash,
+			&r.RecordingDate,
+			&r.Category,
+			&r.AudioType,
+			&r.WorkspaceID,
+			&r.Owner,
+			&aclJSON,
+			&r.Language,
+		); err != nil {
+			return nil, err
+		}
+
+		audio, ok := audios[r.AudiofileHash]
+		if !ok {
+			r.Acl = stringSliceFromJSON(aclJSON)
+			audio = &r
+			audios[r.AudiofileHash] = audio
+		}
+		out[segmentHash] = audio
+	}
+
+	return out, rows.Err()
+}
@@ -0,0 +1,221 @@
+package postgres
+
+import (
+	"context"
+	"go_audio_search_api_server/globalTypes"
+)
+
+// counterBucketRetention is how long the hourly counter buckets are kept, GET /stats/timeseries reaches back as far
+const counterBucketRetention = "31 days"
+
+// GetCounters returns the request counters of a workspace, increments of the last seconds are still pending, see
+// IncCounter
+func (s *Worker) GetCounters(ctx context.Context, workspaceID string) (map[string]int64, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT counter_name, counter_value FROM counters WHERE workspace_id = $1;`, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counters := map[string]int64{}
+	for _, counter := range []Counter{ImportRequestsFailed, ImportRequestsSuccessful, SearchRequestsFailed, SearchRequestsSuccessful} {
+		counters[string(counter)] = 0
+	}
+	for rows.Next() {
+		var name string
+		var value int64
+		if err := rows.Scan(&name, &value); err != nil {
+			return nil, err
+		}
+		counters[name] = value
+	}
+
+	return counters, rows.Err()
+}
+
+// GetWorkspaceStats counts the audio files, segments and hours of audio the viewer may read. The duration of an audio
+// file without a known duration is the end of its last timed segment. embeddedSegments counts the segments of the
+// audio files that reached StageEmbedded, the vectors of a viewer that can not count the whole workspace in Qdrant.
+func (s *Worker) GetWorkspaceStats(ctx context.Context, viewer globalTypes.Viewer) (stats *globalTypes.Stats, embeddedSegments int64, err error) {
+	where, args := viewerWhere("a.", viewer, []any{
+		viewer.WorkspaceID,
+		globalTypes.FinalStage(),
+		globalTypes.StageFailed,
+		globalTypes.StageEmbedded,
+	})
+
+	q := `
+SELECT
+  count(*),
+  count(*) FILTER (WHERE a.last_successful_stage = $2),
+  count(*) FILTER (WHERE a.last_successful_stage > 0 AND a.last_successful_stage < $2 AND a.gets_processed),
+  count(*) FILTER (WHERE a.last_successful_stage > 0 AND a.last_successful_stage < $2 AND NOT a.gets_processed),
+  count(*) FILTER (WHERE a.last_successful_stage = $3),
+  COALESCE(sum(COALESCE(NULLIF(a.duration_in_sec, 0), s.end_sec, 0)), 0) / 3600.0,
+  COALESCE(sum(s.segments), 0),
+  COALESCE(sum(s.segments) FILTER (WHERE a.last_successful_stage >= $4), 0)
+FROM audiofiles a
+LEFT JOIN (
+  SELECT audiofile_hash, max(end_sec) AS end_sec, count(*) AS segments
+  FROM segments
+  WHERE workspace_id = $1
+  GROUP BY audiofile_hash
+) s ON s.audiofile_hash = a.audiofile_hash
+WHERE ` + where + `
+  AND a.delete_requested = FALSE;
+`
+
+	stats = &globalTypes.Stats{}
+	err = s.db.QueryRowContext(ctx, q, args...).Scan(
+		&stats.AudioFiles.Total,
+		&stats.AudioFiles.Completed,
+		&stats.AudioFiles.Processing,
+		&stats.AudioFiles.Waiting,
+		&stats.AudioFiles.Failed,
+		&stats.AudioHours,
+		&stats.Segments,
+		&embeddedSegments,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	stats.Categories, err = s.countCategories(ctx, viewer)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return stats, embeddedSegments, nil
+}
+
+// countCategories counts the audio files the viewer may read per category
+func (s *Worker) countCategories(ctx context.Context, viewer globalTypes.Viewer) (map[string]int64, error) {
+	where, args := viewerWhere("", viewer, nil)
+
+	q := `
+SELECT COALESCE(category, ''), count(*)
+FROM audiofiles
+WHERE ` + where + `
+  AND delete_requested = FALSE
+GROUP BY 1;
+`
+
+	rows, err := s.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := map[string]int64{}
+	for rows.Next() {
+		var category string
+		var n int64
+		if err := rows.Scan(&category, &n); err != nil {
+			return nil, err
+		}
+		categories[category] = n
+	}
+
+	return categories, rows.Err()
+}
+
+// GetStatsTimeseries returns one bucket per hour of the last hours, the current hour included. The audio files are
+// the ones the viewer may read, the requests are the ones of the workspace of the viewer.
+func (s *Worker) GetStatsTimeseries(ctx context.Context, viewer globalTypes.Viewer, hours int) ([]globalTypes.StatsBucket, error) {
+	where, args := viewerWhere("", viewer, []any{
+		viewer.WorkspaceID,
+		hours,
+		globalTypes.FinalStage(),
+		globalTypes.StageFailed,
+		ImportRequestsFailed,
+		ImportRequestsSuccessful,
+		SearchRequestsFailed,
+		SearchRequestsSuccessful,
+	})
+
+	q := `
+WITH series AS (
+  SELECT generate_series(
+    date_trunc('hour', now() - make_interval(hours => $2)),
+    date_trunc('hour', now()),
+    interval '1 hour'
+  ) AS bucket
+),
+imported AS (
+  SELECT date_trunc('hour', created_at) AS bucket, count(*) AS n
+  FROM audiofiles
+  WHERE ` + where + `
+    AND created_at >= date_trunc('hour', now() - make_interval(hours => $2))
+  GROUP BY 1
+),
+finished AS (
+  SELECT
+    date_trunc('hour', completed_at) AS bucket,
+    count(*) FILTER (WHERE last_successful_stage = $3) AS completed,
+    count(*) FILTER (WHERE last_successful_stage = $4) AS failed
+  FROM audiofiles
+  WHERE ` + where + `
+    AND completed_at >= date_trunc('hour', now() - make_interval(hours => $2))
+    AND last_successful_stage IN ($3, $4)
+  GROUP BY 1
+),
+requests AS (
+  SELECT
+    bucket,
+    sum(counter_value) FILTER (WHERE counter_name IN ($5, $6)) AS imports,
+    sum(counter_value) FILTER (WHERE counter_name = $5) AS imports_failed,
+    sum(counter_value) FILTER (WHERE counter_name IN ($7, $8)) AS searches,
+    sum(counter_value) FILTER (WHERE counter_name = $7) AS searches_failed
+  FROM counter_buckets
+  WHERE workspace_id = $1
+    AND bucket >= date_trunc('hour', now() - make_interval(hours => $2))
+  GROUP BY bucket
+)
+SELECT
+  series.bucket,
+  COALESCE(imported.n, 0),
+  COALESCE(finished.completed, 0),
+  COALESCE(finished.failed, 0),
+  COALESCE(requests.imports, 0),
+  COALESCE(requests.imports_failed, 0),
+  COALESCE(requests.searches, 0),
+  COALESCE(requests.searches_failed, 0)
+FROM series
+LEFT JOIN imported ON imported.bucket = series.bucket
+LEFT JOIN finished ON finished.bucket = series.bucket
+LEFT JOIN requests ON requests.bucket = series.bucket
+ORDER BY series.bucket;
+`
+
+	rows, err := s.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	buckets := make([]globalTypes.StatsBucket, 0, hours+1)
+	for rows.Next() {
+		var b globalTypes.StatsBucket
+		if err := rows.Scan(
+			&b.Time,
+			&b.AudioFilesImported,
+			&b.AudioFilesCompleted,
+			&b.AudioFilesFailed,
+			&b.ImportRequests,
+			&b.ImportRequestsFailed,
+			&b.SearchRequests,
+			&b.SearchRequestsFailed,
+		); err != nil {
+			return nil, err
+		}
+		buckets = append(buckets, b)
+	}
+
+	return buckets, rows.Err()
+}
+
+// pruneCounterBuckets removes the hourly buckets older than counterBucketRetention
+func (s *Worker) pruneCounterBuckets(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM counter_buckets WHERE bucket < now() - $1::interval;`, counterBucketRetention)
+	return err
+}
@@ -1,10 +1,20 @@
 package postgres
 
-import "context"
+import (
+	"context"
+	"go_audio_search_api_server/globalTypes"
+)
 
 func (s *Worker) CreateTables(ctx context.Context) error {
 	stmts := []string{
 		`
+CREATE TABLE IF NOT EXISTS workspaces (
+  id          text PRIMARY KEY,
+  name        text NOT NULL,
+  created_at  timestamptz NOT NULL DEFAULT now()
+);`,
+		`INSERT INTO workspaces (id, name) VALUES ('default', 'Default') ON CONFLICT (id) DO NOTHING;`,
+		`
 CREATE TABLE IF NOT EXISTS audiofiles (
   audiofile_hash        text PRIMARY KEY,
   title                 text,
@@ -25,6 +35,7 @@ CREATE TABLE IF NOT EXISTS audiofiles (
   created_at            timestamptz NOT NULL DEFAULT now(),
   updated_at            timestamptz NOT NULL DEFAULT now()
 );`,
+		`ALTER TABLE audiofiles ADD COLUMN IF NOT EXISTS delete_requested boolean NOT NULL DEFAULT false;`,
 		`
 CREATE OR REPLACE FUNCTION set_audiofiles_updated_at()
 RETURNS trigger AS $$
@@ -56,18 +67,154 @@ CREATE TABLE IF NOT EXISTS segments (
     ON UPDATE CASCADE,
   CONSTRAINT uq_segments_audio_range UNIQUE(audiofile_hash, sentence_index)
 );`,
+		`ALTER TABLE segments ADD COLUMN IF NOT EXISTS start_sec double precision;`,
+		`ALTER TABLE segments ADD COLUMN IF NOT EXISTS end_sec double precision;`,
+		`ALTER TABLE audiofiles ADD COLUMN IF NOT EXISTS import_id text;`,
+		`ALTER TABLE audiofiles ADD COLUMN IF NOT EXISTS workspace_id text NOT NULL DEFAULT 'default' REFERENCES workspaces(id);`,
+		`ALTER TABLE segments ADD COLUMN IF NOT EXISTS workspace_id text NOT NULL DEFAULT 'default' REFERENCES workspaces(id);`,
 		`CREATE INDEX IF NOT EXISTS idx_segments_audiofile ON segments(audiofile_hash);`,
+		`CREATE INDEX IF NOT EXISTS idx_audiofiles_workspace ON audiofiles(workspace_id, created_at);`,
+		`ALTER TABLE audiofiles ADD COLUMN IF NOT EXISTS owner text;`,
+		`ALTER TABLE audiofiles ADD COLUMN IF NOT EXISTS acl jsonb NOT NULL DEFAULT '[]'::jsonb;`,
+		`CREATE INDEX IF NOT EXISTS idx_audiofiles_acl ON audiofiles USING GIN (acl);`,
+		`ALTER TABLE audiofiles ADD COLUMN IF NOT EXISTS trace_parent text;`,
+		`ALTER TABLE audiofiles ADD COLUMN IF NOT EXISTS language text;`,
+		`CREATE INDEX IF NOT EXISTS idx_segments_workspace ON segments(workspace_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_audiofiles_import_id ON audiofiles(import_id);`,
 		`CREATE INDEX IF NOT EXISTS idx_audiofiles_recording_date ON audiofiles(recording_date);`,
 		`CREATE INDEX IF NOT EXISTS idx_audiofiles_category ON audiofiles(category);`,
 		`CREATE INDEX IF NOT EXISTS idx_segments_tsv ON segments USING GIN (transcript_tsv);`,
 		`
 CREATE INDEX IF NOT EXISTS idx_audiofiles_claim_queue
 ON audiofiles (gets_processed, last_successful_stage, created_at)
-WHERE gets_processed = false;`, `CREATE TABLE IF NOT EXISTS counters (
+WHERE gets_processed = false;`,
+		`
+CREATE TABLE IF NOT EXISTS feed_subscriptions (
+  id                 bigserial PRIMARY KEY,
+  feed_url           text NOT NULL,
+  title              text,
+  category           text,
+  audio_type         text,
+  poll_interval_sec  integer NOT NULL,
+  backfill_latest    integer NOT NULL DEFAULT 0,
+  paused             boolean NOT NULL DEFAULT false,
+  last_polled_at     timestamptz,
+  next_poll_at       timestamptz NOT NULL DEFAULT now(),
+  last_error         text,
+  last_new_episodes  integer NOT NULL DEFAULT 0,
+  created_at         timestamptz NOT NULL DEFAULT now(),
+  updated_at         timestamptz NOT NULL DEFAULT now()
+);`,
+		`
+CREATE TABLE IF NOT EXISTS feed_seen_episodes (
+  subscription_id  bigint NOT NULL REFERENCES feed_subscriptions(id) ON DELETE CASCADE,
+  guid             text NOT NULL,
+  enclosure_url    text NOT NULL,
+  seen_at          timestamptz NOT NULL DEFAULT now(),
+  PRIMARY KEY (subscription_id, guid)
+);`,
+		`ALTER TABLE feed_subscriptions ADD COLUMN IF NOT EXISTS workspace_id text NOT NULL DEFAULT 'default' REFERENCES workspaces(id) ON DELETE CASCADE;`,
+		`ALTER TABLE feed_subscriptions DROP CONSTRAINT IF EXISTS feed_subscriptions_feed_url_key;`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_feed_subscriptions_workspace_url ON feed_subscriptions(workspace_id, feed_url);`,
+		`CREATE INDEX IF NOT EXISTS idx_feed_seen_episodes_url ON feed_seen_episodes(subscription_id, enclosure_url);`,
+		`
+CREATE INDEX IF NOT EXISTS idx_feed_subscriptions_due
+ON feed_subscriptions (next_poll_at)
+WHERE paused = false;`,
+		`
+CREATE TABLE IF NOT EXISTS watched_files (
+  path            text PRIMARY KEY,
+  size            bigint NOT NULL,
+  mod_time        timestamptz NOT NULL,
+  status          text NOT NULL,
+  audiofile_hash  text,
+  error           text,
+  created_at      timestamptz NOT NULL DEFAULT now(),
+  updated_at      timestamptz NOT NULL DEFAULT now()
+);`,
+		`
+CREATE TABLE IF NOT EXISTS webhooks (
+  id          bigserial PRIMARY KEY,
+  url         text NOT NULL,
+  secret      text NOT NULL,
+  events      jsonb NOT NULL DEFAULT '[]'::jsonb,
+  categories  jsonb NOT NULL DEFAULT '[]'::jsonb,
+  active      boolean NOT NULL DEFAULT true,
+  created_at  timestamptz NOT NULL DEFAULT now(),
+  updated_at  timestamptz NOT NULL DEFAULT now()
+);`,
+		`
+CREATE TABLE IF NOT EXISTS webhook_outbox (
+  id               bigserial PRIMARY KEY,
+  webhook_id       bigint NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+  event_type       text NOT NULL,
+  audiofile_hash   text NOT NULL,
+  payload          jsonb NOT NULL,
+  status           text NOT NULL DEFAULT 'pending',
+  attempts         integer NOT NULL DEFAULT 0,
+  next_attempt_at  timestamptz NOT NULL DEFAULT now(),
+  last_error       text,
+  created_at       timestamptz NOT NULL DEFAULT now(),
+  updated_at       timestamptz NOT NULL DEFAULT now()
+);`,
+		`
+CREATE INDEX IF NOT EXISTS idx_webhook_outbox_due
+ON webhook_outbox (next_attempt_at)
+WHERE status = 'pending';`,
+		`CREATE INDEX IF NOT EXISTS idx_webhook_outbox_webhook ON webhook_outbox(webhook_id, id);`,
+		`
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+  id               bigserial PRIMARY KEY,
+  outbox_id        bigint NOT NULL REFERENCES webhook_outbox(id) ON DELETE CASCADE,
+  webhook_id       bigint NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+  attempt          integer NOT NULL,
+  success          boolean NOT NULL,
+  response_status  integer,
+  error            text,
+  duration_ms      bigint NOT NULL,
+  attempted_at     timestamptz NOT NULL DEFAULT now()
+);`,
+		`CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook ON webhook_deliveries(webhook_id, id);`,
+		`
+CREATE TABLE IF NOT EXISTS api_keys (
+  id            bigserial PRIMARY KEY,
+  name          text NOT NULL,
+  prefix        text NOT NULL,
+  key_hash      text NOT NULL UNIQUE,
+  scopes        jsonb NOT NULL,
+  bootstrap     boolean NOT NULL DEFAULT false,
+  created_at    timestamptz NOT NULL DEFAULT now(),
+  updated_at    timestamptz NOT NULL DEFAULT now(),
+  last_used_at  timestamptz,
+  revoked_at    timestamptz
+);`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_api_keys_bootstrap ON api_keys(bootstrap) WHERE bootstrap = true;`,
+		`ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS workspace_id text NOT NULL DEFAULT 'default' REFERENCES workspaces(id) ON DELETE CASCADE;`,
+		`ALTER TABLE webhooks ADD COLUMN IF NOT EXISTS workspace_id text NOT NULL DEFAULT 'default' REFERENCES workspaces(id) ON DELETE CASCADE;`,
+		`ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS user_name text;`,
+		`ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS groups jsonb NOT NULL DEFAULT '[]'::jsonb;`,
+		`ALTER TABLE audiofiles ADD COLUMN IF NOT EXISTS api_key_id bigint REFERENCES api_keys(id) ON DELETE SET NULL;`,
+		`CREATE INDEX IF NOT EXISTS idx_audiofiles_api_key_queued ON audiofiles(api_key_id) WHERE last_successful_stage > 0;`, `CREATE TABLE IF NOT EXISTS counters (
   counter_name  text PRIMARY KEY,
   counter_value bigint NOT NULL DEFAULT 0,
   updated_at    timestamptz NOT NULL DEFAULT now()
 );`,
+		`
+CREATE TABLE IF NOT EXISTS counter_buckets (
+  counter_name  text NOT NULL,
+  bucket        timestamptz NOT NULL,
+  counter_value bigint NOT NULL DEFAULT 0,
+  PRIMARY KEY (counter_name, bucket)
+);`,
+		`ALTER TABLE audiofiles ADD COLUMN IF NOT EXISTS completed_at timestamptz;`,
+		`DROP INDEX IF EXISTS idx_audiofiles_workspace_updated;`,
+		`CREATE INDEX IF NOT EXISTS idx_audiofiles_workspace_completed ON audiofiles(workspace_id, completed_at) WHERE completed_at IS NOT NULL;`,
+		`ALTER TABLE counters ADD COLUMN IF NOT EXISTS workspace_id text NOT NULL DEFAULT 'default' REFERENCES workspaces(id) ON DELETE CASCADE;`,
+		`ALTER TABLE counters DROP CONSTRAINT IF EXISTS counters_pkey;`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_counters_workspace_name ON counters(workspace_id, counter_name);`,
+		`ALTER TABLE counter_buckets ADD COLUMN IF NOT EXISTS workspace_id text NOT NULL DEFAULT 'default' REFERENCES workspaces(id) ON DELETE CASCADE;`,
+		`ALTER TABLE counter_buckets DROP CONSTRAINT IF EXISTS counter_buckets_pkey;`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_counter_buckets_workspace_name ON counter_buckets(workspace_id, counter_name, bucket);`,
 	}
 
 	for _, stmt := range stmts {
@@ -76,9 +223,27 @@ WHERE gets_processed = false;`, `CREATE TABLE IF NOT EXISTS counters (
 		}
 	}
 
+	if err := s.backfillCompletedAt(ctx); err != nil {
+		return err
+	}
+
 	return s.InitCounters(ctx)
 }
 
+// backfillCompletedAt sets completed_at of audio files that finished before the column existed, their last change
+// is the closest known time
+func (s *Worker) backfillCompletedAt(ctx context.Context) error {
+	const q = `
+UPDATE audiofiles
+SET completed_at = updated_at
+WHERE completed_at IS NULL
+  AND last_successful_stage IN ($1, $2);
+`
+
+	_, err := s.db.ExecContext(ctx, q, globalTypes.FinalStage(), globalTypes.StageFailed)
+	return err
+}
+
 func (s *Worker) InitCounters(ctx context.Context) error {
 	const q = `
 		INSERT INTO counters (counter_name, counter_value, updated_at)
@@ -87,7 +252,7 @@ func (s *Worker) InitCounters(ctx context.Context) error {
 		  ('import_requests_successful', 0, now()),
 		  ('search_requests_failed', 0, now()),
 		  ('search_requests_successful', 0, now())
-		ON CONFLICT (counter_name) DO NOTHING;
+		ON CONFLICT (workspace_id, counter_name) DO NOTHING;
 	`
 
 	_, err := s.db.ExecContext(ctx, q)
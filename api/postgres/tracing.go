@@ -0,0 +1,47 @@
+package postgres
+
+import (
+	"context"
+	"go_audio_search_api_server/tracing"
+	"strings"
+	"unicode"
+
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type querySpanKey struct{}
+
+// queryTracer traces the queries of a request or pipeline stage as client spans. Queries without a span in their
+// context, like the polls of the background workers, are not traced so they do not start traces of their own.
+type queryTracer struct{}
+
+func (queryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx
+	}
+
+	ctx, span := tracing.Start(ctx, "postgres "+queryVerb(data.SQL),
+		attribute.String("db.system", "postgresql"),
+		attribute.String("db.query.text", data.SQL),
+	)
+	return context.WithValue(ctx, querySpanKey{}, span)
+}
+
+func (queryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	span, ok := ctx.Value(querySpanKey{}).(trace.Span)
+	if !ok {
+		return
+	}
+	tracing.End(span, data.Err)
+}
+
+// queryVerb returns the first keyword of a statement like SELECT or INSERT, it keeps the span names few
+func queryVerb(sql string) string {
+	sql = strings.TrimSpace(sql)
+	if i := strings.IndexFunc(sql, unicode.IsSpace); i >= 0 {
+		sql = sql[:i]
+	}
+	return strings.ToUpper(sql)
+}
@@ -2,6 +2,8 @@ package postgres
 
 import (
 	"encoding/json"
+	"fmt"
+	"go_audio_search_api_server/globalTypes"
 	"strings"
 )
 
@@ -12,6 +14,13 @@ func nullIfEmpty(v string) any {
 	return v
 }
 
+func nullIfZero(v int64) any {
+	if v == 0 {
+		return nil
+	}
+	return v
+}
+
 func jsonOrNilFromStringSlice(v []string) (any, error) {
 	if len(v) == 0 {
 		return nil, nil
@@ -39,9 +48,77 @@ func stringSliceFromJSON(raw string) []string {
 	return out
 }
 
+// workspaceOrDefault stores audio files without a workspace, e.g. from older code paths, in the default workspace
+func workspaceOrDefault(workspaceID string) string {
+	if workspaceID == "" {
+		return globalTypes.DefaultWorkspaceID
+	}
+	return workspaceID
+}
+
 func minInt(a, b int) int {
 	if a < b {
 		return a
 	}
 	return b
 }
+
+// audioFilterWhere builds the WHERE conditions for an AudioFilter on the audiofiles table.
+// The placeholders continue after the already collected args.
+func audioFilterWhere(filter globalTypes.AudioFilter, args []any) (string, []any) {
+	conds := []string{"TRUE"}
+
+	add := func(cond string, v any) {
+		args = append(args, v)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+
+	if filter.Viewer.WorkspaceID != "" {
+		var cond string
+		cond, args = viewerWhere("", filter.Viewer, args)
+		conds = append(conds, cond)
+	}
+	if filter.Category != "" {
+		add("category = $%d", filter.Category)
+	}
+	if filter.AudioType != "" {
+		add("audio_type = $%d", filter.AudioType)
+	}
+	if filter.Stage != nil {
+		add("last_successful_stage = $%d", int64(*filter.Stage))
+	}
+	if filter.StartDateIso != "" {
+		add("recording_date >= $%d::date", filter.StartDateIso)
+	}
+	if filter.EndDateIso != "" {
+		add("recording_date < $%d::date", filter.EndDateIso)
+	}
+	if filter.TitleContains != "" {
+		add("title ILIKE '%%' || $%d || '%%'", escapeLike(filter.TitleContains))
+	}
+
+	return strings.Join(conds, "\n  AND "), args
+}
+
+// viewerWhere builds the condition that limits audiofiles to the recordings the viewer may read. prefix is the table
+// alias including the dot. A recording with an empty acl is visible to the whole workspace, otherwise only to its owner
+// and the users and groups on the acl.
+func viewerWhere(prefix string, viewer globalTypes.Viewer, args []any) (string, []any) {
+	args = append(args, viewer.WorkspaceID)
+	cond := fmt.Sprintf("%sworkspace_id = $%d", prefix, len(args))
+
+	if viewer.SeesAll {
+		return cond, args
+	}
+
+	args = append(args, nullIfEmpty(viewer.User), viewer.Principals())
+	cond += fmt.Sprintf(
+		"\n  AND (%sacl = '[]'::jsonb OR %sowner = $%d OR %sacl ?| $%d::text[])",
+		prefix, prefix, len(args)-1, prefix, len(args),
+	)
+	return cond, args
+}
+
+func escapeLike(v string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(v)
+}
@@ -0,0 +1,62 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+const (
+	WatchedFileProcessing = "processing"
+	WatchedFileQueued     = "queued"
+	WatchedFileDuplicate  = "duplicate"
+	WatchedFileFailed     = "failed"
+)
+
+// ClaimWatchedFile records a file found in a watch folder and reports whether it still has to be ingested.
+// A file at a known path is only claimed again if its size or modification time changed.
+func (s *Worker) ClaimWatchedFile(ctx context.Context, path string, size int64, modTime time.Time) (bool, error) {
+	const q = `
+INSERT INTO watched_files (path, size, mod_time, status)
+VALUES ($1, $2, $3, 'processing')
+ON CONFLICT (path) DO UPDATE SET
+  size = EXCLUDED.size,
+  mod_time = EXCLUDED.mod_time,
+  status = EXCLUDED.status,
+  audiofile_hash = NULL,
+  error = NULL,
+  updated_at = now()
+WHERE watched_files.size <> EXCLUDED.size OR watched_files.mod_time <> EXCLUDED.mod_time
+RETURNING path;`
+
+	var claimed string
+	err := s.db.QueryRowContext(ctx, q, path, size, modTime.UTC()).Scan(&claimed)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// FinishWatchedFile stores the outcome of an ingestion, see the WatchedFile status constants
+func (s *Worker) FinishWatchedFile(ctx context.Context, path string, status string, audioHash string, errMsg string) error {
+	const q = `
+UPDATE watched_files
+SET status = $2,
+    audiofile_hash = $3,
+    error = $4,
+    updated_at = now()
+WHERE path = $1;`
+
+	_, err := s.db.ExecContext(ctx, q, path, status, nullIfEmpty(audioHash), nullIfEmpty(errMsg))
+	return err
+}
+
+// ResetWatchedFileClaims forgets files whose ingestion was interrupted, so they are picked up again
+func (s *Worker) ResetWatchedFileClaims(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM watched_files WHERE status = 'processing';`)
+	return err
+}
@@ -0,0 +1,412 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"go_audio_search_api_server/globalTypes"
+)
+
+const webhookColumns = `
+  id,
+  workspace_id,
+  url,
+  events::text,
+  categories::text,
+  active,
+  created_at,
+  updated_at`
+
+func scanWebhook(row rowScanner) (*globalTypes.Webhook, error) {
+	var r globalTypes.Webhook
+	var events, categories string
+
+	err := row.Scan(
+		&r.ID,
+		&r.WorkspaceID,
+		&r.Url,
+		&events,
+		&categories,
+		&r.Active,
+		&r.CreatedAt,
+		&r.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	r.Events = nonNilStrings(stringSliceFromJSON(events))
+	r.Categories = nonNilStrings(stringSliceFromJSON(categories))
+	return &r, nil
+}
+
+func nonNilStrings(v []string) []string {
+	if v == nil {
+		return []string{}
+	}
+	return v
+}
+
+func jsonFromStringSlice(v []string) (string, error) {
+	b, err := json.Marshal(nonNilStrings(v))
+	return string(b), err
+}
+
+// CreateWebhook registers a webhook for the events of the workspace, the returned webhook is the only one carrying
+// the secret
+func (s *Worker) CreateWebhook(ctx context.Context, workspaceID string, req globalTypes.WebhookRequest) (*globalTypes.Webhook, error) {
+	events, err := jsonFromStringSlice(req.Events)
+	if err != nil {
+		return nil, err
+	}
+	categories, err := jsonFromStringSlice(req.Categories)
+	if err != nil {
+		return nil, err
+	}
+
+	q := `
+INSERT INTO webhooks (url, secret, events, categories, workspace_id)
+VALUES ($1, $2, $3::jsonb, $4::jsonb, $5)
+RETURNING` + webhookColumns + `;`
+
+	hook, err := scanWebhook(s.db.QueryRowContext(ctx, q, req.Url, req.Secret, events, categories, workspaceOrDefault(workspaceID)))
+	if err != nil {
+		return nil, err
+	}
+
+	hook.Secret = req.Secret
+	return hook, nil
+}
+
+func (s *Worker) ListWebhooks(ctx context.Context, workspaceID string) ([]globalTypes.Webhook, error) {
+	q := `SELECT` + webhookColumns + ` FROM webhooks WHERE workspace_id = $1 ORDER BY id;`
+
+	rows, err := s.db.QueryContext(ctx, q, workspaceOrDefault(workspaceID))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []globalTypes.Webhook{}
+	for rows.Next() {
+		hook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *hook)
+	}
+	return out, rows.Err()
+}
+
+// GetWebhook returns sql.ErrNoRows for unknown ids and webhooks of other workspaces
+func (s *Worker) GetWebhook(ctx context.Context, workspaceID string, id int64) (*globalTypes.Webhook, error) {
+	q := `SELECT` + webhookColumns + ` FROM webhooks WHERE id = $1 AND workspace_id = $2;`
+	return scanWebhook(s.db.QueryRowContext(ctx, q, id, workspaceOrDefault(workspaceID)))
+}
+
+// UpdateWebhook applies the patch and returns sql.ErrNoRows for unknown ids and webhooks of other workspaces
+func (s *Worker) UpdateWebhook(ctx context.Context, workspaceID string, id int64, patch globalTypes.WebhookPatch) (*globalTypes.Webhook, error) {
+	sets := []string{"updated_at = now()"}
+	args := []any{id, workspaceOrDefault(workspaceID)}
+
+	add := func(set string, v any) {
+		args = append(args, v)
+		sets = append(sets, fmt.Sprintf(set, len(args)))
+	}
+
+	if patch.Url != nil {
+		add("url = $%d", *patch.Url)
+	}
+	if patch.Events != nil {
+		events, err := jsonFromStringSlice(*patch.Events)
+		if err != nil {
+			return nil, err
+		}
+		add("events = $%d::jsonb", events)
+	}
+	if patch.Categories != nil {
+		categories, err := jsonFromStringSlice(*patch.Categories)
+		if err != nil {
+			return nil, err
+		}
+		add("categories = $%d::jsonb", categories)
+	}
+	if patch.Active != nil {
+		add("active = $%d", *patch.Active)
+	}
+
+	q := `
+UPDATE webhooks
+SET ` + strings.Join(sets, ",\n    ") + `
+WHERE id = $1 AND workspace_id = $2
+RETURNING` + webhookColumns + `;`
+
+	return scanWebhook(s.db.QueryRowContext(ctx, q, args...))
+}
+
+// DeleteWebhook removes the webhook together with its pending events and delivery log,
+// sql.ErrNoRows for unknown ids and webhooks of other workspaces
+func (s *Worker) DeleteWebhook(ctx context.Context, workspaceID string, id int64) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM webhooks WHERE id = $1 AND workspace_id = $2;`, id, workspaceOrDefault(workspaceID))
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// enqueueWebhookEventTx adds one outbox row for every active webhook of the event's workspace whose event and
+// category filter match
+func enqueueWebhookEventTx(ctx context.Context, tx *sql.Tx, event globalTypes.AudioEvent, payload []byte) error {
+	if !slices.Contains(globalTypes.WebhookEventTypes, event.Type) {
+		return nil
+	}
+
+	const q = `
+INSERT INTO webhook_outbox (webhook_id, event_type, audiofile_hash, payload)
+SELECT id, $1, $2, $3::jsonb
+FROM webhooks
+WHERE active = TRUE
+  AND workspace_id = $5
+  AND (events = '[]'::jsonb OR events ? $1)
+  AND (categories = '[]'::jsonb OR categories ? $4);
+`
+
+	if _, err := tx.ExecContext(ctx, q, event.Type, event.AudiofileHash, string(payload), event.Category, workspaceOrDefault(event.WorkspaceID)); err != nil {
+		return fmt.Errorf("enqueue webhook event: %w", err)
+	}
+	return nil
+}
+
+// ClaimDueWebhookOutbox returns up to limit due outbox items of active webhooks and counts the attempt.
+// The next attempt is pushed back by lease, so an item of a crashed dispatcher is retried after that time.
+// Several API instances can dispatch side by side because locked rows are skipped.
+func (s *Worker) ClaimDueWebhookOutbox(ctx context.Context, limit int, lease time.Duration) ([]globalTypes.WebhookOutboxItem, error) {
+	const q = `
+WITH claimed AS (
+  UPDATE webhook_outbox
+  SET attempts = attempts + 1,
+      next_attempt_at = now() + make_interval(secs => $2),
+      updated_at = now()
+  WHERE id IN (
+    SELECT o.id
+    FROM webhook_outbox o
+    JOIN webhooks w ON w.id = o.webhook_id
+    WHERE o.status = 'pending' AND o.next_attempt_at <= now() AND w.active = TRUE
+    ORDER BY o.next_attempt_at
+    LIMIT $1
+    FOR UPDATE OF o SKIP LOCKED
+  )
+  RETURNING id, webhook_id, event_type, audiofile_hash, payload::text, attempts, created_at
+)
+SELECT c.id, c.webhook_id, c.event_type, c.audiofile_hash, c.payload, c.attempts, c.created_at, w.url, w.secret
+FROM claimed c
+JOIN webhooks w ON w.id = c.webhook_id
+ORDER BY c.id;
+`
+
+	rows, err := s.db.QueryContext(ctx, q, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []globalTypes.WebhookOutboxItem
+	for rows.Next() {
+		var item globalTypes.WebhookOutboxItem
+		var payload string
+
+		err := rows.Scan(
+			&item.ID,
+			&item.WebhookID,
+			&item.EventType,
+			&item.AudiofileHash,
+			&payload,
+			&item.Attempts,
+			&item.CreatedAt,
+			&item.Url,
+			&item.Secret,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		item.Payload = []byte(payload)
+		item.Status = globalTypes.WebhookOutboxPending
+		out = append(out, item)
+	}
+	return out, rows.Err()
+}
+
+// FinishWebhookDelivery logs the attempt and settles the outbox item. A failed attempt is retried at retryAt,
+// a zero retryAt gives up on the item.
+func (s *Worker) FinishWebhookDelivery(ctx context.Context, item globalTypes.WebhookOutboxItem, delivery globalTypes.WebhookDelivery, retryAt time.Time) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var responseStatus any
+	if delivery.ResponseStatus != 0 {
+		responseStatus = delivery.ResponseStatus
+	}
+
+	const logQ = `
+INSERT INTO webhook_deliveries (outbox_id, webhook_id, attempt, success, response_status, error, duration_ms)
+VALUES ($1, $2, $3, $4, $5, $6, $7);
+`
+	_, err = tx.ExecContext(ctx, logQ,
+		item.ID,
+		item.WebhookID,
+		item.Attempts,
+		delivery.Success,
+		responseStatus,
+		nullIfEmpty(delivery.Error),
+		delivery.DurationMs,
+	)
+	if err != nil {
+		return fmt.Errorf("log webhook delivery: %w", err)
+	}
+
+	status := globalTypes.WebhookOutboxPending
+	switch {
+	case delivery.Success:
+		status = globalTypes.WebhookOutboxDelivered
+	case retryAt.IsZero():
+		status = globalTypes.WebhookOutboxFailed
+	}
+
+	var nextAttemptAt any
+	if status == globalTypes.WebhookOutboxPending {
+		nextAttemptAt = retryAt
+	}
+
+	const outboxQ = `
+UPDATE webhook_outbox
+SET status = $2,
+    next_attempt_at = COALESCE($3, next_attempt_at),
+    last_error = $4,
+    updated_at = now()
+WHERE id = $1;
+`
+	_, err = tx.ExecContext(ctx, outboxQ, item.ID, status, nextAttemptAt, nullIfEmpty(delivery.Error))
+	if err != nil {
+		return fmt.Errorf("update webhook outbox: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// ListWebhookOutbox returns the newest events queued for a webhook, optionally only those with the given status
+func (s *Worker) ListWebhookOutbox(ctx context.Context, webhookID int64, status string, limit int) ([]globalTypes.WebhookOutboxItem, error) {
+	const q = `
+SELECT id, webhook_id, event_type, audiofile_hash, status, attempts, next_attempt_at, COALESCE(last_error, ''), created_at
+FROM webhook_outbox
+WHERE webhook_id = $1 AND ($2 = '' OR status = $2)
+ORDER BY id DESC
+LIMIT $3;
+`
+
+	rows, err := s.db.QueryContext(ctx, q, webhookID, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []globalTypes.WebhookOutboxItem{}
+	for rows.Next() {
+		var item globalTypes.WebhookOutboxItem
+		err := rows.Scan(
+			&item.ID,
+			&item.WebhookID,
+			&item.EventType,
+			&item.AudiofileHash,
+			&item.Status,
+			&item.Attempts,
+			&item.NextAttemptAt,
+			&item.LastError,
+			&item.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, item)
+	}
+	return out, rows.Err()
+}
+
+// ListWebhookDeliveries returns the newest entries of the delivery log of a webhook
+func (s *Worker) ListWebhookDeliveries(ctx context.Context, webhookID int64, limit int) ([]globalTypes.WebhookDelivery, error) {
+	const q = `
+SELECT d.id, d.outbox_id, d.webhook_id, o.event_type, o.audiofile_hash, d.attempt, d.success,
+       COALESCE(d.response_status, 0), COALESCE(d.error, ''), d.duration_ms, d.attempted_at
+FROM webhook_deliveries d
+JOIN webhook_outbox o ON o.id = d.outbox_id
+WHERE d.webhook_id = $1
+ORDER BY d.id DESC
+LIMIT $2;
+`
+
+	rows, err := s.db.QueryContext(ctx, q, webhookID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []globalTypes.WebhookDelivery{}
+	for rows.Next() {
+		var d globalTypes.WebhookDelivery
+		err := rows.Scan(
+			&d.ID,
+			&d.OutboxID,
+			&d.WebhookID,
+			&d.EventType,
+			&d.AudiofileHash,
+			&d.Attempt,
+			&d.Success,
+			&d.ResponseStatus,
+			&d.Error,
+			&d.DurationMs,
+			&d.AttemptedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+// RetryWebhookOutbox puts a failed event of a webhook back into the queue, sql.ErrNoRows if there is no such event
+func (s *Worker) RetryWebhookOutbox(ctx context.Context, webhookID int64, outboxID int64) error {
+	const q = `
+UPDATE webhook_outbox
+SET status = 'pending', attempts = 0, next_attempt_at = now(), updated_at = now()
+WHERE id = $1 AND webhook_id = $2 AND status = 'failed';
+`
+
+	res, err := s.db.ExecContext(ctx, q, outboxID, webhookID)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
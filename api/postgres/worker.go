@@ -9,15 +9,19 @@ import (
 	"log/slog"
 	"time"
 
-	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
 )
 
 type Worker struct {
 	db *sql.DB
+	// dsn is kept for connections outside the pool, e.g. to LISTEN for notifications
+	dsn      string
+	counters pendingCounters
 }
 
-func newPostgresWrapper(db *sql.DB) *Worker {
-	store := Worker{db: db}
+func newPostgresWrapper(db *sql.DB, dsn string) *Worker {
+	store := Worker{db: db, dsn: dsn}
 
 	ctx := context.Background()
 	if err := store.CreateTables(ctx); err != nil {
@@ -55,17 +59,17 @@ func Open() (*Worker, error) {
 		postgresDB,
 	)
 
+	cfg, err := pgx.ParseConfig(postgresConnection)
+	if err != nil {
+		return nil, fmt.Errorf("parse postgres config: %w", err)
+	}
+	cfg.Tracer = queryTracer{}
+
 	var db *sql.DB
-	var err error
 
 	for range 10 {
 		time.Sleep(3 * time.Second)
-		db, err = sql.Open("pgx", postgresConnection)
-		if err != nil {
-			slog.Warn("sql.Open failed", "err", err)
-			time.Sleep(3 * time.Second)
-			continue
-		}
+		db = stdlib.OpenDB(*cfg)
 
 		db.SetMaxOpenConns(10)
 		db.SetMaxIdleConns(5)
@@ -75,7 +79,7 @@ func Open() (*Worker, error) {
 		err = db.Ping()
 		if err == nil {
 			slog.Info("postgres connection established")
-			return newPostgresWrapper(db), nil
+			return newPostgresWrapper(db, postgresConnection), nil
 		}
 
 		slog.Warn("db.Ping failed", "err", err, "dsn", postgresConnection)
@@ -90,3 +94,8 @@ func Open() (*Worker, error) {
 func (s *Worker) Close() error {
 	return s.db.Close()
 }
+
+// Ping checks that a connection to Postgres can be used
+func (s *Worker) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
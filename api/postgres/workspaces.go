@@ -0,0 +1,94 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"go_audio_search_api_server/globalTypes"
+)
+
+var ErrWorkspaceExists = errors.New("workspace already exists")
+
+// ErrWorkspaceNotEmpty is returned when a workspace still holds audio files and can not be deleted yet
+var ErrWorkspaceNotEmpty = errors.New("workspace still holds audio files")
+
+const workspaceColumns = `
+  id,
+  name,
+  created_at`
+
+func scanWorkspace(row rowScanner) (*globalTypes.Workspace, error) {
+	var r globalTypes.Workspace
+	if err := row.Scan(&r.ID, &r.Name, &r.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+func (s *Worker) CreateWorkspace(ctx context.Context, req globalTypes.WorkspaceRequest) (*globalTypes.Workspace, error) {
+	q := `
+INSERT INTO workspaces (id, name)
+VALUES ($1, $2)
+ON CONFLICT (id) DO NOTHING
+RETURNING` + workspaceColumns + `;`
+
+	workspace, err := scanWorkspace(s.db.QueryRowContext(ctx, q, req.ID, req.Name))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrWorkspaceExists
+	}
+	return workspace, err
+}
+
+func (s *Worker) ListWorkspaces(ctx context.Context) ([]globalTypes.Workspace, error) {
+	q := `SELECT` + workspaceColumns + ` FROM workspaces ORDER BY id;`
+
+	rows, err := s.db.QueryContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []globalTypes.Workspace{}
+	for rows.Next() {
+		workspace, err := scanWorkspace(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *workspace)
+	}
+	return out, rows.Err()
+}
+
+// GetWorkspace returns sql.ErrNoRows for unknown ids
+func (s *Worker) GetWorkspace(ctx context.Context, id string) (*globalTypes.Workspace, error) {
+	q := `SELECT` + workspaceColumns + ` FROM workspaces WHERE id = $1;`
+	return scanWorkspace(s.db.QueryRowContext(ctx, q, id))
+}
+
+// DeleteWorkspace removes an empty workspace together with its api keys and feed subscriptions.
+// Returns sql.ErrNoRows for unknown ids and ErrWorkspaceNotEmpty while audio files are left.
+func (s *Worker) DeleteWorkspace(ctx context.Context, id string) error {
+	const q = `
+DELETE FROM workspaces
+WHERE id = $1
+  AND NOT EXISTS (SELECT 1 FROM audiofiles WHERE workspace_id = $1);
+`
+
+	res, err := s.db.ExecContext(ctx, q, id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 1 {
+		return nil
+	}
+
+	if _, err := s.GetWorkspace(ctx, id); err != nil {
+		return err
+	}
+	return ErrWorkspaceNotEmpty
+}
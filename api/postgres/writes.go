@@ -10,6 +10,9 @@ import (
 	"go_audio_search_api_server/globalTypes"
 )
 
+// ErrAudioInProcessing is returned when an audio file is currently claimed by a pipeline worker.
+var ErrAudioInProcessing = errors.New("audio file is currently being processed")
+
 func (s *Worker) UpsertBase(ctx context.Context, a *globalTypes.AudioDataElement) error {
 	if a == nil {
 		return errors.New("nil audio element")
@@ -23,6 +26,11 @@ func (s *Worker) UpsertBase(ctx context.Context, a *globalTypes.AudioDataElement
 		return fmt.Errorf("marshal ai keywords: %w", err)
 	}
 
+	aclJSON, err := jsonFromStringSlice(a.Acl)
+	if err != nil {
+		return fmt.Errorf("marshal acl: %w", err)
+	}
+
 	const q = `
 INSERT INTO audiofiles (
   audiofile_hash,
@@ -40,7 +48,14 @@ INSERT INTO audiofiles (
   ai_summary,
   last_successful_stage,
   retry_counter,
-  gets_processed
+  gets_processed,
+  import_id,
+  workspace_id,
+  owner,
+  acl,
+  api_key_id,
+  trace_parent,
+  language
 ) VALUES (
   $1,
   $2,
@@ -57,7 +72,14 @@ INSERT INTO audiofiles (
   $13,
   $14,
   $15,
-  $16
+  $16,
+  $17,
+  $18,
+  $19,
+  $20::jsonb,
+  $21,
+  $22,
+  $23
 )
 ON CONFLICT(audiofile_hash) DO UPDATE SET
   title                = EXCLUDED.title,
@@ -74,7 +96,14 @@ ON CONFLICT(audiofile_hash) DO UPDATE SET
   download_path        = COALESCE(EXCLUDED.download_path, audiofiles.download_path),
   user_summary_text    = COALESCE(EXCLUDED.user_summary_text, audiofiles.user_summary_text),
   ai_keywords          = COALESCE(EXCLUDED.ai_keywords, audiofiles.ai_keywords),
-  ai_summary           = COALESCE(EXCLUDED.ai_summary, audiofiles.ai_summary);
+  ai_summary           = COALESCE(EXCLUDED.ai_summary, audiofiles.ai_summary),
+  import_id            = COALESCE(audiofiles.import_id, EXCLUDED.import_id),
+  owner                = COALESCE(audiofiles.owner, EXCLUDED.owner),
+  api_key_id           = COALESCE(audiofiles.api_key_id, EXCLUDED.api_key_id),
+  trace_parent         = COALESCE(EXCLUDED.trace_parent, audiofiles.trace_parent),
+  language             = COALESCE(EXCLUDED.language, audiofiles.language)
+  -- acl is kept, an existing audio file only changes it through UpdateAudioMetadata
+WHERE audiofiles.workspace_id = EXCLUDED.workspace_id;
 `
 
 	_, err = s.db.ExecContext(ctx, q,
@@ -94,6 +123,13 @@ ON CONFLICT(audiofile_hash) DO UPDATE SET
 		a.LastSuccessfulStage,
 		a.RetryCounter,
 		false, // gets_processed set to false on insert; on update
+		nullIfEmpty(a.ImportID),
+		workspaceOrDefault(a.WorkspaceID),
+		nullIfEmpty(a.Owner),
+		aclJSON,
+		nullIfZero(a.ApiKeyID),
+		nullIfEmpty(a.TraceParent),
+		nullIfEmpty(a.Language),
 	)
 	return err
 }
@@ -103,7 +139,21 @@ func (s *Worker) UpsertBaseBatch(ctx context.Context, items []*globalTypes.Audio
 		return nil
 	}
 
-	const colsPerRow = 16
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := upsertBaseBatchTx(ctx, tx, items); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func upsertBaseBatchTx(ctx context.Context, tx *sql.Tx, items []*globalTypes.AudioDataElement) error {
+	const colsPerRow = 23
 	const chunkSize = 1000
 
 	const head = `
@@ -123,7 +173,14 @@ INSERT INTO audiofiles (
   ai_summary,
   last_successful_stage,
   retry_counter,
-  gets_processed
+  gets_processed,
+  import_id,
+  workspace_id,
+  owner,
+  acl,
+  api_key_id,
+  trace_parent,
+  language
 ) VALUES
 `
 
@@ -143,15 +200,16 @@ ON CONFLICT(audiofile_hash) DO UPDATE SET
   transcript_full      = COALESCE(EXCLUDED.transcript_full, audiofiles.transcript_full),
   user_summary_text    = COALESCE(EXCLUDED.user_summary_text, audiofiles.user_summary_text),
   ai_keywords          = COALESCE(EXCLUDED.ai_keywords, audiofiles.ai_keywords),
-  ai_summary           = COALESCE(EXCLUDED.ai_summary, audiofiles.ai_summary);
+  ai_summary           = COALESCE(EXCLUDED.ai_summary, audiofiles.ai_summary),
+  import_id            = COALESCE(audiofiles.import_id, EXCLUDED.import_id),
+  owner                = COALESCE(audiofiles.owner, EXCLUDED.owner),
+  api_key_id           = COALESCE(audiofiles.api_key_id, EXCLUDED.api_key_id),
+  trace_parent         = COALESCE(EXCLUDED.trace_parent, audiofiles.trace_parent),
+  language             = COALESCE(EXCLUDED.language, audiofiles.language)
+  -- acl is kept, an existing audio file only changes it through UpdateAudioMetadata
+WHERE audiofiles.workspace_id = EXCLUDED.workspace_id;
 `
 
-	tx, err := s.db.BeginTx(ctx, nil)
-	if err != nil {
-		return err
-	}
-	defer func() { _ = tx.Rollback() }()
-
 	for start := 0; start < len(items); start += chunkSize {
 		end := start + chunkSize
 		if end > len(items) {
@@ -183,6 +241,11 @@ ON CONFLICT(audiofile_hash) DO UPDATE SET
 				return fmt.Errorf("marshal ai keywords at index %d: %w", start+i, jerr)
 			}
 
+			aclJSON, jerr := jsonFromStringSlice(a.Acl)
+			if jerr != nil {
+				return fmt.Errorf("marshal acl at index %d: %w", start+i, jerr)
+			}
+
 			off := i*colsPerRow + 1
 
 			if i > 0 {
@@ -190,7 +253,7 @@ ON CONFLICT(audiofile_hash) DO UPDATE SET
 			}
 
 			fmt.Fprintf(&sb,
-				`($%d, $%d, NULLIF($%d, '')::date, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d::jsonb, $%d, $%d, $%d, $%d)`,
+				`($%d, $%d, NULLIF($%d, '')::date, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d::jsonb, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d::jsonb, $%d, $%d, $%d)`,
 				off+0,
 				off+1,
 				off+2,
@@ -207,6 +270,13 @@ ON CONFLICT(audiofile_hash) DO UPDATE SET
 				off+13,
 				off+14,
 				off+15,
+				off+16,
+				off+17,
+				off+18,
+				off+19,
+				off+20,
+				off+21,
+				off+22,
 			)
 
 			args = append(args,
@@ -226,6 +296,13 @@ ON CONFLICT(audiofile_hash) DO UPDATE SET
 				a.LastSuccessfulStage,
 				a.RetryCounter,
 				false, // gets_processed set to false on insert; on update
+				nullIfEmpty(a.ImportID),
+				workspaceOrDefault(a.WorkspaceID),
+				nullIfEmpty(a.Owner),
+				aclJSON,
+				nullIfZero(a.ApiKeyID),
+				nullIfEmpty(a.TraceParent),
+				nullIfEmpty(a.Language),
 			)
 		}
 
@@ -236,7 +313,7 @@ ON CONFLICT(audiofile_hash) DO UPDATE SET
 		}
 	}
 
-	return tx.Commit()
+	return nil
 }
 
 func (s *Worker) UpdateAudiofileHash(ctx context.Context, oldAudioHash string, newAudioHash string) error {
@@ -305,12 +382,16 @@ func (s *Worker) UpsertSegments(ctx context.Context, audioHash string, segs *[]g
 	defer func() { _ = tx.Rollback() }()
 
 	const q = `
-INSERT INTO segments (segment_hash, audiofile_hash, sentence_index, transcript)
-VALUES ($1, $2, $3, $4)
+INSERT INTO segments (segment_hash, audiofile_hash, sentence_index, transcript, start_sec, end_sec, workspace_id)
+SELECT $1, $2, $3, $4, $5, $6, a.workspace_id
+FROM audiofiles a
+WHERE a.audiofile_hash = $2
 ON CONFLICT(segment_hash) DO UPDATE SET
   audiofile_hash = EXCLUDED.audiofile_hash,
   sentence_index      = EXCLUDED.sentence_index,
-  transcript     = EXCLUDED.transcript;
+  transcript     = EXCLUDED.transcript,
+  start_sec      = EXCLUDED.start_sec,
+  end_sec        = EXCLUDED.end_sec;
 `
 
 	stmt, err := tx.PrepareContext(ctx, q)
@@ -332,6 +413,8 @@ ON CONFLICT(segment_hash) DO UPDATE SET
 			sgm.AudiofileHash,
 			sgm.SentenceIndex,
 			sgm.Transcript,
+			sgm.StartSec,
+			sgm.EndSec,
 		); err != nil {
 			return err
 		}
@@ -350,16 +433,177 @@ WHERE gets_processed = TRUE;
 	return err
 }
 
-func (s *Worker) AddToCounter(ctx context.Context, counter Counter, delta int) error {
+// AddToCounter adds delta to the total of the counter of the workspace and to its bucket of the current hour
+func (s *Worker) AddToCounter(ctx context.Context, workspaceID string, counter Counter, delta int) error {
 	const q = `
-		INSERT INTO counters (counter_name, counter_value, updated_at)
-		VALUES ($1, $2, now())
-		ON CONFLICT (counter_name)
-		DO UPDATE SET
-		  counter_value = counters.counter_value + EXCLUDED.counter_value,
-		  updated_at = now();
+		WITH total AS (
+		  INSERT INTO counters (workspace_id, counter_name, counter_value, updated_at)
+		  VALUES ($3, $1, $2, now())
+		  ON CONFLICT (workspace_id, counter_name)
+		  DO UPDATE SET
+		    counter_value = counters.counter_value + EXCLUDED.counter_value,
+		    updated_at = now()
+		)
+		INSERT INTO counter_buckets (workspace_id, counter_name, bucket, counter_value)
+		VALUES ($3, $1, date_trunc('hour', now()), $2)
+		ON CONFLICT (workspace_id, counter_name, bucket)
+		DO UPDATE SET counter_value = counter_buckets.counter_value + EXCLUDED.counter_value;
 	`
 
-	_, err := s.db.ExecContext(ctx, q, counter, delta)
+	_, err := s.db.ExecContext(ctx, q, counter, delta, workspaceOrDefault(workspaceID))
 	return err
 }
+
+// MarkAudiofileForDeletion flags an audio file so the pipeline and the lexical search ignore it while it gets removed.
+// Returns sql.ErrNoRows if the audio file does not exist and ErrAudioInProcessing if a worker currently holds it.
+func (s *Worker) MarkAudiofileForDeletion(ctx context.Context, audioHash string) error {
+	const q = `
+UPDATE audiofiles
+SET delete_requested = TRUE
+WHERE audiofile_hash = $1
+  AND gets_processed = FALSE;
+`
+
+	res, err := s.db.ExecContext(ctx, q, audioHash)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("rows affected: %w", err)
+	}
+	if rows == 1 {
+		return nil
+	}
+
+	var exists bool
+	err = s.db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM audiofiles WHERE audiofile_hash = $1);`, audioHash).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return sql.ErrNoRows
+	}
+
+	return ErrAudioInProcessing
+}
+
+// DeleteAudiofile removes an audio file and its segments and records the deleted event.
+func (s *Worker) DeleteAudiofile(ctx context.Context, audioHash string) error {
+	if audioHash == "" {
+		return errors.New("audioHash required")
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM segments WHERE audiofile_hash = $1;`, audioHash); err != nil {
+		return fmt.Errorf("delete segments: %w", err)
+	}
+
+	const q = `
+DELETE FROM audiofiles
+WHERE audiofile_hash = $1
+RETURNING COALESCE(title, ''), COALESCE(category, ''), COALESCE(audio_type, ''), last_successful_stage, COALESCE(import_id, ''), workspace_id,
+  COALESCE(owner, ''), acl::text;
+`
+
+	deleted := globalTypes.AudioDataElement{AudiofileHash: audioHash}
+	var aclJSON string
+	err = tx.QueryRowContext(ctx, q, audioHash).Scan(
+		&deleted.Title,
+		&deleted.Category,
+		&deleted.AudioType,
+		&deleted.LastSuccessfulStage,
+		&deleted.ImportID,
+		&deleted.WorkspaceID,
+		&deleted.Owner,
+		&aclJSON,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return tx.Commit()
+	}
+	if err != nil {
+		return fmt.Errorf("delete audiofile: %w", err)
+	}
+	deleted.Acl = stringSliceFromJSON(aclJSON)
+
+	if err := recordAudioEventTx(ctx, tx, globalTypes.NewAudioEvent(globalTypes.AudioEventDeleted, &deleted)); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UpdateAudioMetadata changes the user editable metadata of an audio file without touching its processing stage.
+// Returns sql.ErrNoRows if the audio file does not exist and ErrAudioInProcessing if a worker currently holds it,
+// because the worker would overwrite the change with its own copy on the next stage update.
+func (s *Worker) UpdateAudioMetadata(ctx context.Context, audioHash string, patch globalTypes.AudioMetadataPatch) error {
+	sets := make([]string, 0, 4)
+	args := []any{audioHash}
+
+	add := func(set string, v any) {
+		args = append(args, v)
+		sets = append(sets, fmt.Sprintf(set, len(args)))
+	}
+
+	if patch.Title != nil {
+		add("title = $%d", strings.TrimSpace(*patch.Title))
+	}
+	if patch.Category != nil {
+		add("category = $%d", nullIfEmpty(*patch.Category))
+	}
+	if patch.RecordingDate != nil {
+		add("recording_date = NULLIF($%d, '')::date", *patch.RecordingDate)
+	}
+	if patch.UserSummary != nil {
+		add("user_summary_text = $%d", *patch.UserSummary)
+	}
+	if patch.Acl != nil {
+		aclJSON, err := jsonFromStringSlice(*patch.Acl)
+		if err != nil {
+			return fmt.Errorf("marshal acl: %w", err)
+		}
+		add("acl = $%d::jsonb", aclJSON)
+	}
+
+	if len(sets) == 0 {
+		return errors.New("nothing to update")
+	}
+
+	q := `
+UPDATE audiofiles
+SET ` + strings.Join(sets, ",\n    ") + `
+WHERE audiofile_hash = $1
+  AND gets_processed = FALSE
+  AND delete_requested = FALSE;
+`
+
+	res, err := s.db.ExecContext(ctx, q, args...)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("rows affected: %w", err)
+	}
+	if rows == 1 {
+		return nil
+	}
+
+	var exists bool
+	err = s.db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM audiofiles WHERE audiofile_hash = $1 AND delete_requested = FALSE);`, audioHash).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return sql.ErrNoRows
+	}
+
+	return ErrAudioInProcessing
+}
@@ -4,12 +4,14 @@ import (
 	"context"
 	"errors"
 	"go_audio_search_api_server/globalTypes"
+	"go_audio_search_api_server/tracing"
 
 	"github.com/qdrant/go-client/qdrant"
 )
 
 func (w *Worker) RerankCandidatesByHashes(
 	ctx context.Context,
+	viewer globalTypes.Viewer,
 	queryVec []float32,
 	candidateSegmentHashes []string,
 	n uint64,
@@ -17,6 +19,9 @@ func (w *Worker) RerankCandidatesByHashes(
 	if len(queryVec) == 0 {
 		return nil, errors.New("queryVec empty")
 	}
+	if viewer.WorkspaceID == "" {
+		return nil, errors.New("viewer without workspace")
+	}
 
 	if len(candidateSegmentHashes) == 0 {
 		return []globalTypes.SegmentElement{}, nil
@@ -33,7 +38,7 @@ func (w *Worker) RerankCandidatesByHashes(
 	}
 
 	filter := &qdrant.Filter{
-		Must: []*qdrant.Condition{
+		Must: append([]*qdrant.Condition{
 			{
 				ConditionOneOf: &qdrant.Condition_HasId{
 					HasId: &qdrant.HasIdCondition{
@@ -41,16 +46,18 @@ func (w *Worker) RerankCandidatesByHashes(
 					},
 				},
 			},
-		},
+		}, viewerFilter(viewer)...),
 	}
 
-	resp, err := w.client.Query(ctx, &qdrant.QueryPoints{
+	callCtx, done := tracing.StartCall(ctx, "qdrant", "rerank")
+	resp, err := w.client.Query(callCtx, &qdrant.QueryPoints{
 		CollectionName: w.collectionName,
 		Query:          qdrant.NewQuery(queryVec...),
 		Limit:          &n,
 		Filter:         filter,
 		WithPayload:    qdrant.NewWithPayloadInclude("SegmentHash"),
 	})
+	done(err)
 
 	if err != nil {
 		return nil, err
@@ -68,25 +75,44 @@ func (w *Worker) RerankCandidatesByHashes(
 	return out, nil
 }
 
+// QueryCandidates returns the n segments closest to queryVec. The category and date filters are part of the query,
+// so they apply before the limit.
 func (w *Worker) QueryCandidates(
 	ctx context.Context,
+	viewer globalTypes.Viewer,
 	queryVec []float32,
+	category string,
+	startDateISO string,
+	endDateISO string,
 	n uint64,
 ) ([]globalTypes.SegmentElement, error) {
 	if len(queryVec) == 0 {
 		return nil, errors.New("queryVec empty")
 	}
+	if viewer.WorkspaceID == "" {
+		return nil, errors.New("viewer without workspace")
+	}
+
+	conds, err := searchFilter(category, startDateISO, endDateISO)
+	if err != nil {
+		return nil, err
+	}
 
 	if n == 0 {
 		n = 10
 	}
 
-	resp, err := w.client.Query(ctx, &qdrant.QueryPoints{
+	callCtx, done := tracing.StartCall(ctx, "qdrant", "query")
+	resp, err := w.client.Query(callCtx, &qdrant.QueryPoints{
 		CollectionName: w.collectionName,
 		Query:          qdrant.NewQuery(queryVec...),
 		Limit:          &n,
-		WithPayload:    qdrant.NewWithPayloadInclude("SegmentHash"),
+		Filter: &qdrant.Filter{
+			Must: append(viewerFilter(viewer), conds...),
+		},
+		WithPayload: qdrant.NewWithPayloadInclude("SegmentHash"),
 	})
+	done(err)
 
 	if err != nil {
 		return nil, err
@@ -103,3 +129,187 @@ func (w *Worker) QueryCandidates(
 
 	return out, nil
 }
+
+// VectorSize returns the size of the vectors the collection stores, it fails if Qdrant or the collection is gone
+func (w *Worker) VectorSize(ctx context.Context) (uint64, error) {
+	callCtx, done := tracing.StartCall(ctx, "qdrant", "collection_info")
+	info, err := w.client.GetCollectionInfo(callCtx, w.collectionName)
+	done(err)
+
+	if err != nil {
+		return 0, err
+	}
+
+	params := info.GetConfig().GetParams().GetVectorsConfig().GetParams()
+	if params == nil {
+		return 0, errors.New("collection " + w.collectionName + " has no unnamed vector")
+	}
+
+	return params.GetSize(), nil
+}
+
+// CountWorkspacePoints counts the segment vectors stored for a workspace
+func (w *Worker) CountWorkspacePoints(ctx context.Context, workspaceID string) (uint64, error) {
+	callCtx, done := tracing.StartCall(ctx, "qdrant", "count")
+	n, err := w.client.Count(callCtx, &qdrant.CountPoints{
+		CollectionName: w.collectionName,
+		Filter: &qdrant.Filter{
+			Must: []*qdrant.Condition{qdrant.NewMatchKeyword(workspacePayloadKey, workspaceID)},
+		},
+		Exact: qdrant.PtrOf(true),
+	})
+	done(err)
+
+	return n, err
+}
+
+// FusedCandidates runs the hybrid search of the request in one query: the BM25 terms of TsQuery and queryVec
+// prefetch their candidates independently and Qdrant fuses both rankings by weighted reciprocal rank. The
+// returned segments carry the fused score in FusedScore. A ranking with the weight 0 is left out.
+func (w *Worker) FusedCandidates(
+	ctx context.Context,
+	searchQuery globalTypes.SearchRequest,
+	queryVec []float32,
+	candidates uint64,
+	n uint64,
+) ([]globalTypes.SegmentElement, error) {
+	filter, err := w.hybridFilter(searchQuery, queryVec)
+	if err != nil {
+		return nil, err
+	}
+
+	lexicalWeight, semanticWeight := searchQuery.Weights()
+	indices, values := w.encoder.Query(searchQuery.TsQuery)
+
+	var prefetch []*qdrant.PrefetchQuery
+	var weights []float32
+	if lexicalWeight > 0 && len(indices) > 0 {
+		prefetch = append(prefetch, &qdrant.PrefetchQuery{
+			Query:  qdrant.NewQuerySparse(indices, values),
+			Using:  qdrant.PtrOf(bm25VectorName),
+			Filter: filter,
+			Limit:  &candidates,
+		})
+		weights = append(weights, float32(lexicalWeight))
+	}
+	if semanticWeight > 0 {
+		prefetch = append(prefetch, &qdrant.PrefetchQuery{
+			Query:  qdrant.NewQueryDense(queryVec),
+			Filter: filter,
+			Limit:  &candidates,
+		})
+		weights = append(weights, float32(semanticWeight))
+	}
+
+	if len(prefetch) == 0 {
+		return []globalTypes.SegmentElement{}, nil
+	}
+
+	callCtx, done := tracing.StartCall(ctx, "qdrant", "query_fused")
+	resp, err := w.client.Query(callCtx, &qdrant.QueryPoints{
+		CollectionName: w.collectionName,
+		Prefetch:       prefetch,
+		Query: qdrant.NewQueryRRF(&qdrant.Rrf{
+			K:       qdrant.PtrOf(uint32(searchQuery.RrfK)),
+			Weights: weights,
+		}),
+		Filter:      filter,
+		Limit:       &n,
+		WithPayload: qdrant.NewWithPayloadInclude("SegmentHash"),
+	})
+	done(err)
+
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]globalTypes.SegmentElement, 0, len(resp))
+	for _, p := range resp {
+		out = append(out, globalTypes.SegmentElement{
+			SegmentHash: p.Payload["SegmentHash"].GetStringValue(),
+			FusedScore:  float64(p.Score),
+		})
+	}
+
+	return out, nil
+}
+
+// Bm25AndDenseCandidates returns the BM25 and the vector ranking of the request in one round trip, for a fusion
+// Qdrant does not offer. The BM25 score is returned in TsScore.
+func (w *Worker) Bm25AndDenseCandidates(
+	ctx context.Context,
+	searchQuery globalTypes.SearchRequest,
+	queryVec []float32,
+	candidates uint64,
+) (lexical []globalTypes.SegmentElement, semantic []globalTypes.SegmentElement, err error) {
+	filter, err := w.hybridFilter(searchQuery, queryVec)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	indices, values := w.encoder.Query(searchQuery.TsQuery)
+
+	queries := []*qdrant.QueryPoints{{
+		CollectionName: w.collectionName,
+		Query:          qdrant.NewQueryDense(queryVec),
+		Filter:         filter,
+		Limit:          &candidates,
+		WithPayload:    qdrant.NewWithPayloadInclude("SegmentHash"),
+	}}
+	if len(indices) > 0 {
+		queries = append(queries, &qdrant.QueryPoints{
+			CollectionName: w.collectionName,
+			Query:          qdrant.NewQuerySparse(indices, values),
+			Using:          qdrant.PtrOf(bm25VectorName),
+			Filter:         filter,
+			Limit:          &candidates,
+			WithPayload:    qdrant.NewWithPayloadInclude("SegmentHash"),
+		})
+	}
+
+	callCtx, done := tracing.StartCall(ctx, "qdrant", "query_batch")
+	results, err := w.client.QueryBatch(callCtx, &qdrant.QueryBatchPoints{
+		CollectionName: w.collectionName,
+		QueryPoints:    queries,
+	})
+	done(err)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for i, result := range results {
+		for _, p := range result.GetResult() {
+			segment := globalTypes.SegmentElement{SegmentHash: p.Payload["SegmentHash"].GetStringValue()}
+			if i == 0 {
+				segment.QueryScore = p.Score
+				semantic = append(semantic, segment)
+			} else {
+				segment.TsScore = float64(p.Score)
+				lexical = append(lexical, segment)
+			}
+		}
+	}
+
+	return lexical, semantic, nil
+}
+
+// hybridFilter restricts both rankings of a hybrid search to what the viewer may read and the request filters on
+func (w *Worker) hybridFilter(searchQuery globalTypes.SearchRequest, queryVec []float32) (*qdrant.Filter, error) {
+	if !w.hasBm25 {
+		return nil, errors.New("collection " + w.collectionName + " has no bm25 vector")
+	}
+	if len(queryVec) == 0 {
+		return nil, errors.New("queryVec empty")
+	}
+	if searchQuery.Viewer.WorkspaceID == "" {
+		return nil, errors.New("viewer without workspace")
+	}
+
+	conds, err := searchFilter(searchQuery.Category, searchQuery.StartTimePeriodIso, searchQuery.EndTimePeriodIso)
+	if err != nil {
+		return nil, err
+	}
+
+	return &qdrant.Filter{Must: append(viewerFilter(searchQuery.Viewer), conds...)}, nil
+}
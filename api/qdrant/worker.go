@@ -2,19 +2,46 @@ package qdrant
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"go_audio_search_api_server/globalTypes"
 	"go_audio_search_api_server/globalUtils"
+	"go_audio_search_api_server/sparse"
+	"log/slog"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/qdrant/go-client/qdrant"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	// workspacePayloadKey is the payload field holding the workspace of a point
+	workspacePayloadKey = "workspace_id"
+	// readersPayloadKey is the payload field holding the access list entries that may read a point, see AudioReaders
+	readersPayloadKey = "readers"
+
+	// the fields of the audio file a point belongs to, the semantic search filters on them
+	audiofileHashPayloadKey = "audiofile_hash"
+	categoryPayloadKey      = "category"
+	audioTypePayloadKey     = "audio_type"
+	recordingDatePayloadKey = "recording_date"
+	languagePayloadKey      = "language"
+
+	// bm25VectorName is the named sparse vector holding the BM25 terms of a segment, next to the unnamed dense vector
+	bm25VectorName = "bm25"
 )
 
 type Worker struct {
 	collectionName string
 	client         *qdrant.Client
+	encoder        *sparse.Encoder
+	// hasBm25 is false for collections created before the sparse vector existed, Qdrant cannot add it to them
+	hasBm25 bool
 }
 
-func New(collectionName string) (*Worker, error) {
+func New(collectionName string, encoder *sparse.Encoder) (*Worker, error) {
 
 	host := globalUtils.LoadEnvStr("QDRANT_API_HOST")
 
@@ -36,16 +63,194 @@ func New(collectionName string) (*Worker, error) {
 			Size:     globalUtils.LoadEnvUInt64("EMBEDDING_MODEL_DIM"),
 			Distance: qdrant.Distance_Cosine,
 		}),
+		SparseVectorsConfig: qdrant.NewSparseVectorsConfig(map[string]*qdrant.SparseVectorParams{
+			bm25VectorName: {Modifier: qdrant.Modifier_Idf.Enum()},
+		}),
 	})
 
 	if err != nil && !strings.Contains(err.Error(), "already exists") {
 		return nil, err
 	}
 
-	return &Worker{
+	worker := &Worker{
 		collectionName: collectionName,
 		client:         client,
-	}, nil
+		encoder:        encoder,
+	}
+
+	if err := worker.ensurePayloadIndexes(context.Background()); err != nil {
+		return nil, err
+	}
+
+	info, err := client.GetCollectionInfo(context.Background(), collectionName)
+	if err != nil {
+		return nil, err
+	}
+
+	_, worker.hasBm25 = info.GetConfig().GetParams().GetSparseVectorsConfig().GetMap()[bm25VectorName]
+	if !worker.hasBm25 {
+		slog.Warn("Collection has no bm25 sparse vector, the hybrid search takes its full text ranking from Postgres. "+
+			"Recreate the collection to search the sparse vectors in Qdrant, see the README.", "collection", collectionName)
+	}
+
+	return worker, nil
+}
+
+// HasBm25 reports whether the collection stores the BM25 sparse vectors, so a hybrid search can run inside Qdrant
+func (w *Worker) HasBm25() bool {
+	return w.hasBm25
+}
+
+// ensurePayloadIndexes indexes the fields every query filters on. The workspace is a tenant field, so Qdrant keeps
+// the points of a workspace together. Points stored before workspaces and access lists existed are moved to the
+// default workspace and made readable for everyone in it. The audio fields of older points are filled by
+// BackfillAudioPayload.
+func (w *Worker) ensurePayloadIndexes(ctx context.Context) error {
+	keyword := qdrant.FieldType_FieldTypeKeyword
+	indexes := []struct {
+		field     string
+		fieldType qdrant.FieldType
+		params    *qdrant.PayloadIndexParams
+		empty     any
+	}{
+		{workspacePayloadKey, keyword, qdrant.NewPayloadIndexParamsKeyword(&qdrant.KeywordIndexParams{IsTenant: qdrant.PtrOf(true)}), globalTypes.DefaultWorkspaceID},
+		{readersPayloadKey, keyword, qdrant.NewPayloadIndexParamsKeyword(&qdrant.KeywordIndexParams{}), stringList([]string{globalTypes.PublicReader})},
+		{audiofileHashPayloadKey, keyword, nil, nil},
+		{categoryPayloadKey, keyword, nil, nil},
+		{audioTypePayloadKey, keyword, nil, nil},
+		{languagePayloadKey, keyword, nil, nil},
+		{recordingDatePayloadKey, qdrant.FieldType_FieldTypeDatetime, nil, nil},
+	}
+
+	wait := true
+	for _, index := range indexes {
+		_, err := w.client.CreateFieldIndex(ctx, &qdrant.CreateFieldIndexCollection{
+			CollectionName:   w.collectionName,
+			Wait:             &wait,
+			FieldName:        index.field,
+			FieldType:        index.fieldType.Enum(),
+			FieldIndexParams: index.params,
+		})
+		if err != nil {
+			return err
+		}
+
+		if index.empty == nil {
+			continue
+		}
+
+		operationInfo, err := w.client.SetPayload(ctx, &qdrant.SetPayloadPoints{
+			CollectionName: w.collectionName,
+			Wait:           &wait,
+			Payload:        qdrant.NewValueMap(map[string]any{index.field: index.empty}),
+			PointsSelector: qdrant.NewPointsSelectorFilter(&qdrant.Filter{
+				Must: []*qdrant.Condition{qdrant.NewIsEmpty(index.field)},
+			}),
+		})
+		if err != nil {
+			return err
+		}
+
+		slog.Debug("Filled payload field of older points", "field", index.field, "operationInfo", operationInfo)
+	}
+
+	return nil
+}
+
+// audioPayload returns the payload fields a point takes from its audio file. Empty fields are returned in unset, so
+// a refresh removes values that were cleared in Postgres.
+func audioPayload(audio *globalTypes.AudioDataElement) (set map[string]any, unset []string) {
+	set = map[string]any{
+		audiofileHashPayloadKey: audio.AudiofileHash,
+		workspacePayloadKey:     audio.WorkspaceID,
+		readersPayloadKey:       stringList(audio.Readers()),
+	}
+
+	optional := map[string]string{
+		categoryPayloadKey:      audio.Category,
+		audioTypePayloadKey:     audio.AudioType,
+		languagePayloadKey:      audio.Language,
+		recordingDatePayloadKey: "",
+	}
+	if date, err := parseDate(audio.RecordingDate); err == nil {
+		optional[recordingDatePayloadKey] = date.Format(time.RFC3339)
+	}
+
+	for field, value := range optional {
+		if value == "" {
+			unset = append(unset, field)
+			continue
+		}
+		set[field] = value
+	}
+
+	return set, unset
+}
+
+// searchFilter restricts a semantic search to the category and recording dates of the request, like the full text
+// search does in Postgres. The end date is exclusive.
+func searchFilter(category string, startDateISO string, endDateISO string) ([]*qdrant.Condition, error) {
+	var conds []*qdrant.Condition
+	if category != "" {
+		conds = append(conds, qdrant.NewMatchKeyword(categoryPayloadKey, category))
+	}
+
+	dateRange := &qdrant.DatetimeRange{}
+	if startDateISO != "" {
+		start, err := parseDate(startDateISO)
+		if err != nil {
+			return nil, fmt.Errorf("start date: %w", err)
+		}
+		dateRange.Gte = timestamppb.New(start)
+	}
+	if endDateISO != "" {
+		end, err := parseDate(endDateISO)
+		if err != nil {
+			return nil, fmt.Errorf("end date: %w", err)
+		}
+		dateRange.Lt = timestamppb.New(end)
+	}
+	if dateRange.Gte != nil || dateRange.Lt != nil {
+		conds = append(conds, qdrant.NewDatetimeRange(recordingDatePayloadKey, dateRange))
+	}
+
+	return conds, nil
+}
+
+// parseDate reads a date or timestamp and keeps only its day, as the cast to date in Postgres does
+func parseDate(v string) (time.Time, error) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return time.Time{}, errors.New("date is empty")
+	}
+
+	for _, layout := range []string{time.DateOnly, time.RFC3339, "2006-01-02T15:04:05"} {
+		t, err := time.Parse(layout, v)
+		if err == nil {
+			return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC), nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("%q is neither a date like 2026-03-01 nor a RFC3339 timestamp", v)
+}
+
+// viewerFilter restricts a query to the points the viewer may read. It is part of the query, so the restricted
+// points never take a place in the top k.
+func viewerFilter(viewer globalTypes.Viewer) []*qdrant.Condition {
+	conds := []*qdrant.Condition{qdrant.NewMatchKeyword(workspacePayloadKey, viewer.WorkspaceID)}
+	if !viewer.SeesAll {
+		conds = append(conds, qdrant.NewMatchKeywords(readersPayloadKey, viewer.Principals()...))
+	}
+	return conds
+}
+
+// stringList converts strings for qdrant.NewValueMap, which only accepts []any for lists
+func stringList(values []string) []any {
+	out := make([]any, len(values))
+	for i, v := range values {
+		out[i] = v
+	}
+	return out
 }
 
 func segmentHashToPointID(segmentHash string) *qdrant.PointId {
@@ -2,29 +2,54 @@ package qdrant
 
 import (
 	"context"
+	"errors"
 	"go_audio_search_api_server/globalTypes"
+	"go_audio_search_api_server/tracing"
 	"log/slog"
+	"maps"
 
 	"github.com/qdrant/go-client/qdrant"
 )
 
-func (w *Worker) UpsertSegmentEmbeddings(ctx context.Context, elements *[]globalTypes.SegmentElement) error {
+// UpsertSegmentEmbeddings stores the segments of the audio file with their dense and, if the collection has it, their
+// BM25 vector. The payload carries the workspace, readers and the fields the semantic search filters on.
+func (w *Worker) UpsertSegmentEmbeddings(ctx context.Context, audio *globalTypes.AudioDataElement, elements *[]globalTypes.SegmentElement) error {
+	if audio.WorkspaceID == "" {
+		return errors.New("audio file without workspace")
+	}
+
+	fields, _ := audioPayload(audio)
+
 	var points []*qdrant.PointStruct
 
 	for _, element := range *elements {
+		payload := map[string]any{"SegmentHash": element.SegmentHash}
+		maps.Copy(payload, fields)
+
+		vectors := qdrant.NewVectors(element.TranscriptEmbedding...)
+		if w.hasBm25 {
+			indices, values := w.encoder.Document(element.Transcript)
+			vectors = qdrant.NewVectorsMap(map[string]*qdrant.Vector{
+				"":             qdrant.NewVectorDense(element.TranscriptEmbedding),
+				bm25VectorName: qdrant.NewVectorSparse(indices, values),
+			})
+		}
+
 		point := &qdrant.PointStruct{
 			Id:      segmentHashToPointID(element.SegmentHash),
-			Vectors: qdrant.NewVectors(element.TranscriptEmbedding...),
-			Payload: qdrant.NewValueMap(map[string]any{"SegmentHash": element.SegmentHash}),
+			Vectors: vectors,
+			Payload: qdrant.NewValueMap(payload),
 		}
 
 		points = append(points, point)
 	}
 
-	operationInfo, err := w.client.Upsert(ctx, &qdrant.UpsertPoints{
+	callCtx, done := tracing.StartCall(ctx, "qdrant", "upsert")
+	operationInfo, err := w.client.Upsert(callCtx, &qdrant.UpsertPoints{
 		CollectionName: w.collectionName,
 		Points:         points,
 	})
+	done(err)
 
 	if err != nil {
 		return err
@@ -34,3 +59,155 @@ func (w *Worker) UpsertSegmentEmbeddings(ctx context.Context, elements *[]global
 
 	return nil
 }
+
+func (w *Worker) DeleteSegmentEmbeddings(ctx context.Context, segmentHashes []string) error {
+	if len(segmentHashes) == 0 {
+		return nil
+	}
+
+	ids := make([]*qdrant.PointId, 0, len(segmentHashes))
+	for _, h := range segmentHashes {
+		ids = append(ids, segmentHashToPointID(h))
+	}
+
+	wait := true
+	callCtx, done := tracing.StartCall(ctx, "qdrant", "delete")
+	operationInfo, err := w.client.Delete(callCtx, &qdrant.DeletePoints{
+		CollectionName: w.collectionName,
+		Wait:           &wait,
+		Points:         qdrant.NewPointsSelectorIDs(ids),
+	})
+	done(err)
+
+	if err != nil {
+		return err
+	}
+
+	slog.Info("Deleted points from Qdrant", "operationInfo", operationInfo)
+
+	return nil
+}
+
+// RefreshAudioPayload replaces the payload the given segments take from their audio file after its metadata or access
+// list changed. Segments without a point, e.g. of an audio file that is not embedded yet, are skipped.
+func (w *Worker) RefreshAudioPayload(ctx context.Context, segmentHashes []string, audio *globalTypes.AudioDataElement) error {
+	if len(segmentHashes) == 0 {
+		return nil
+	}
+	if audio.WorkspaceID == "" {
+		return errors.New("audio file without workspace")
+	}
+
+	ids := make([]*qdrant.PointId, 0, len(segmentHashes))
+	for _, h := range segmentHashes {
+		ids = append(ids, segmentHashToPointID(h))
+	}
+
+	// a filter selects no point for unknown ids, while a list of ids fails on them
+	selector := qdrant.NewPointsSelectorFilter(&qdrant.Filter{
+		Must: []*qdrant.Condition{qdrant.NewHasID(ids...)},
+	})
+
+	set, unset := audioPayload(audio)
+
+	wait := true
+	callCtx, done := tracing.StartCall(ctx, "qdrant", "set_payload")
+	operationInfo, err := w.client.SetPayload(callCtx, &qdrant.SetPayloadPoints{
+		CollectionName: w.collectionName,
+		Wait:           &wait,
+		Payload:        qdrant.NewValueMap(set),
+		PointsSelector: selector,
+	})
+	done(err)
+
+	if err != nil {
+		return err
+	}
+
+	slog.Info("Updated audio payload of points in Qdrant", "operationInfo", operationInfo)
+
+	if len(unset) == 0 {
+		return nil
+	}
+
+	callCtx, done = tracing.StartCall(ctx, "qdrant", "delete_payload")
+	_, err = w.client.DeletePayload(callCtx, &qdrant.DeletePayloadPoints{
+		CollectionName: w.collectionName,
+		Wait:           &wait,
+		Keys:           unset,
+		PointsSelector: selector,
+	})
+	done(err)
+
+	return err
+}
+
+// BackfillAudioPayload fills the audio fields of points stored before the payload carried them. lookup returns the
+// audio file of every segment hash it knows, points of segments that are gone from Postgres are left unchanged.
+func (w *Worker) BackfillAudioPayload(
+	ctx context.Context,
+	lookup func(ctx context.Context, segmentHashes []string) (map[string]*globalTypes.AudioDataElement, error),
+) error {
+	const pageSize = 256
+
+	filter := &qdrant.Filter{
+		Must: []*qdrant.Condition{qdrant.NewIsEmpty(audiofileHashPayloadKey)},
+	}
+
+	var offset *qdrant.PointId
+	updated, skipped := 0, 0
+
+	for {
+		callCtx, done := tracing.StartCall(ctx, "qdrant", "scroll")
+		points, next, err := w.client.ScrollAndOffset(callCtx, &qdrant.ScrollPoints{
+			CollectionName: w.collectionName,
+			Filter:         filter,
+			Offset:         offset,
+			Limit:          qdrant.PtrOf(uint32(pageSize)),
+			WithPayload:    qdrant.NewWithPayloadInclude("SegmentHash"),
+		})
+		done(err)
+
+		if err != nil {
+			return err
+		}
+
+		segmentHashes := make([]string, 0, len(points))
+		for _, p := range points {
+			segmentHashes = append(segmentHashes, p.Payload["SegmentHash"].GetStringValue())
+		}
+
+		audios, err := lookup(ctx, segmentHashes)
+		if err != nil {
+			return err
+		}
+
+		byAudio := map[*globalTypes.AudioDataElement][]string{}
+		for _, h := range segmentHashes {
+			audio, ok := audios[h]
+			if !ok {
+				skipped++
+				continue
+			}
+			byAudio[audio] = append(byAudio[audio], h)
+		}
+
+		for audio, hashes := range byAudio {
+			if err := w.RefreshAudioPayload(ctx, hashes, audio); err != nil {
+				return err
+			}
+			updated += len(hashes)
+		}
+
+		if next == nil {
+			break
+		}
+		offset = next
+	}
+
+	if updated > 0 || skipped > 0 {
+		slog.Info("Backfilled audio payload of older points", "updated", updated, "skippedWithoutSegment", skipped)
+	}
+
+	return nil
+}
@@ -0,0 +1,238 @@
+package restApi
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go_audio_search_api_server/globalTypes"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const maxListLimit = 500
+
+func (rs *Server) handleListAudio(w http.ResponseWriter, r *http.Request) {
+	slog.Info("Received request to GET /audio")
+
+	query, err := parseAudioListQuery(r)
+	if err != nil {
+		rs.writeJson(w, http.StatusBadRequest, map[string]any{
+			"ok":    false,
+			"code":  "AUDIO_BAD_QUERY",
+			"error": err.Error(),
+		})
+		return
+	}
+
+	query.Filter.Viewer = requestViewer(r)
+
+	// one extra row tells if there is a next page
+	pageSize := query.Limit
+	query.Limit++
+
+	ctx, cancel := rs.opCtx(r)
+	entries, err := rs.postgres.ListAudio(ctx, query)
+	cancel()
+
+	if err != nil {
+		slog.Error("Error while listing audio files: " + err.Error())
+		rs.writeJson(w, http.StatusInternalServerError, map[string]any{
+			"ok":    false,
+			"code":  "AUDIO_LIST_FAILED",
+			"error": "Internal Server Error: " + err.Error(),
+		})
+		return
+	}
+
+	response := map[string]any{
+		"ok":    true,
+		"items": entries,
+	}
+
+	if len(entries) > pageSize {
+		entries = entries[:pageSize]
+		last := entries[len(entries)-1]
+
+		response["items"] = entries
+		response["next_cursor"] = encodeAudioListCursor(globalTypes.AudioListCursor{
+			SortBy:     query.SortBy,
+			Descending: query.Descending,
+			SortValue:  last.SortValue,
+			Hash:       last.AudiofileHash,
+		})
+	}
+
+	rs.writeJson(w, http.StatusOK, response)
+}
+
+func (rs *Server) handleGetAudio(w http.ResponseWriter, r *http.Request) {
+	hash := r.PathValue("hash")
+	slog.Info("Received request to GET /audio/" + hash)
+
+	if !rs.audioVisibleToRequest(w, r, hash) {
+		return
+	}
+
+	ctx, cancel := rs.opCtx(r)
+	audio, err := rs.postgres.GetSearchAudioDataByHash(ctx, hash, requestViewer(r))
+	cancel()
+
+	if errors.Is(err, sql.ErrNoRows) {
+		rs.writeJson(w, http.StatusNotFound, map[string]any{
+			"ok":    false,
+			"code":  "AUDIO_NOT_FOUND",
+			"error": "No audio file with hash " + hash,
+		})
+		return
+	}
+	if err != nil {
+		slog.Error("Error while loading audio file", "audioHash", hash, "err", err)
+		rs.writeJson(w, http.StatusInternalServerError, map[string]any{
+			"ok":    false,
+			"code":  "AUDIO_LOAD_FAILED",
+			"error": "Internal Server Error: " + err.Error(),
+		})
+		return
+	}
+
+	ctx, cancel = rs.opCtx(r)
+	segments, err := rs.postgres.GetAllSegmentsByAudioHash(ctx, hash)
+	cancel()
+
+	if err != nil {
+		slog.Error("Error while loading segments", "audioHash", hash, "err", err)
+		rs.writeJson(w, http.StatusInternalServerError, map[string]any{
+			"ok":    false,
+			"code":  "AUDIO_LOAD_FAILED",
+			"error": "Internal Server Error: " + err.Error(),
+		})
+		return
+	}
+
+	segmentData := make([]globalTypes.SearchSegmentData, 0, len(segments))
+	for _, segment := range segments {
+		segmentData = append(segmentData, globalTypes.SearchSegmentData{
+			SegmentHash:   segment.SegmentHash,
+			AudiofileHash: segment.AudiofileHash,
+			SentenceIndex: float32(segment.SentenceIndex),
+			Transcript:    segment.Transcript,
+		})
+	}
+
+	rs.writeJson(w, http.StatusOK, map[string]any{
+		"ok":       true,
+		"audio":    audio,
+		"segments": segmentData,
+	})
+}
+
+// audioVisibleToRequest answers with 404 unless the audio file belongs to the workspace of the request and its
+// access list lets the caller read it, so nobody else can even learn that a hash exists
+func (rs *Server) audioVisibleToRequest(w http.ResponseWriter, r *http.Request, hash string) bool {
+	ctx, cancel := rs.opCtx(r)
+	exists, err := rs.postgres.CanViewAudio(ctx, hash, requestViewer(r))
+	cancel()
+
+	if err != nil {
+		slog.Error("Error while loading audio file", "audioHash", hash, "err", err)
+		rs.writeJson(w, http.StatusInternalServerError, map[string]any{
+			"ok":    false,
+			"code":  "AUDIO_LOAD_FAILED",
+			"error": "Internal Server Error: " + err.Error(),
+		})
+		return false
+	}
+	if !exists {
+		rs.writeJson(w, http.StatusNotFound, map[string]any{
+			"ok":    false,
+			"code":  "AUDIO_NOT_FOUND",
+			"error": "No audio file with hash " + hash,
+		})
+		return false
+	}
+	return true
+}
+
+// parseAudioListQuery reads the filter, sort, order, limit and cursor query parameters of the listing endpoint.
+func parseAudioListQuery(r *http.Request) (globalTypes.AudioListQuery, error) {
+	var query globalTypes.AudioListQuery
+
+	filter, err := parseAudioFilter(r)
+	if err != nil {
+		return query, err
+	}
+	query.Filter = filter
+
+	params := r.URL.Query()
+
+	switch sortBy := globalTypes.AudioListSort(strings.TrimSpace(params.Get("sort"))); sortBy {
+	case "":
+		query.SortBy = globalTypes.SortByCreatedAt
+	case globalTypes.SortByCreatedAt, globalTypes.SortByRecordingDate:
+		query.SortBy = sortBy
+	default:
+		return query, fmt.Errorf("sort must be created_at or recording_date, got %q", sortBy)
+	}
+
+	switch order := strings.ToLower(strings.TrimSpace(params.Get("order"))); order {
+	case "", "desc":
+		query.Descending = true
+	case "asc":
+		query.Descending = false
+	default:
+		return query, fmt.Errorf("order must be asc or desc, got %q", order)
+	}
+
+	query.Limit = 50
+	if v := strings.TrimSpace(params.Get("limit")); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 || limit > maxListLimit {
+			return query, fmt.Errorf("limit must be between 1 and %d", maxListLimit)
+		}
+		query.Limit = limit
+	}
+
+	if v := strings.TrimSpace(params.Get("cursor")); v != "" {
+		cursor, err := decodeAudioListCursor(v)
+		if err != nil {
+			return query, err
+		}
+		if cursor.SortBy != query.SortBy || cursor.Descending != query.Descending {
+			return query, fmt.Errorf("cursor was created for sort %q order %s, not sort %q order %s",
+				cursor.SortBy, orderName(cursor.Descending), query.SortBy, orderName(query.Descending))
+		}
+		query.After = cursor
+	}
+
+	return query, nil
+}
+
+func orderName(descending bool) string {
+	if descending {
+		return "desc"
+	}
+	return "asc"
+}
+
+func encodeAudioListCursor(cursor globalTypes.AudioListCursor) string {
+	b, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeAudioListCursor(v string) (*globalTypes.AudioListCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(v)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+
+	var cursor globalTypes.AudioListCursor
+	if err := json.Unmarshal(b, &cursor); err != nil || cursor.Hash == "" || cursor.SortValue == "" {
+		return nil, errors.New("invalid cursor")
+	}
+
+	return &cursor, nil
+}
@@ -0,0 +1,229 @@
+package restApi
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"go_audio_search_api_server/postgres"
+	"go_audio_search_api_server/storage"
+	"log/slog"
+	"net/http"
+)
+
+// deleteStoreError marks a delete that stopped in one of the stores, the audio file stays flagged and the delete can be retried.
+type deleteStoreError struct {
+	store string
+	err   error
+}
+
+func (e *deleteStoreError) Error() string {
+	return fmt.Sprintf("deleting from %s failed: %v", e.store, e.err)
+}
+
+func (e *deleteStoreError) Unwrap() error {
+	return e.err
+}
+
+func (rs *Server) handleDeleteAudio(w http.ResponseWriter, r *http.Request) {
+	hash := r.PathValue("hash")
+	slog.Info("Received request to DELETE /audio/" + hash)
+
+	if !rs.audioVisibleToRequest(w, r, hash) {
+		return
+	}
+
+	err := rs.deleteAudio(r, hash)
+
+	var storeErr *deleteStoreError
+	switch {
+	case err == nil:
+		rs.writeJson(w, http.StatusOK, map[string]any{
+			"ok":             true,
+			"audiofile_hash": hash,
+		})
+
+	case errors.Is(err, sql.ErrNoRows):
+		rs.writeJson(w, http.StatusNotFound, map[string]any{
+			"ok":    false,
+			"code":  "AUDIO_NOT_FOUND",
+			"error": "No audio file with hash " + hash,
+		})
+
+	case errors.Is(err, postgres.ErrAudioInProcessing):
+		rs.writeJson(w, http.StatusConflict, map[string]any{
+			"ok":    false,
+			"code":  "AUDIO_IN_PROCESSING",
+			"error": "Audio file is currently processed by a pipeline stage, retry later",
+		})
+
+	case errors.As(err, &storeErr):
+		rs.writeJson(w, http.StatusInternalServerError, map[string]any{
+			"ok":    false,
+			"code":  "AUDIO_DELETE_INCOMPLETE",
+			"error": err.Error() + ", the audio file stays marked for deletion, retry the request",
+			"store": storeErr.store,
+		})
+
+	default:
+		slog.Error("Error while deleting audio file", "audioHash", hash, "err", err)
+		rs.writeJson(w, http.StatusInternalServerError, map[string]any{
+			"ok":    false,
+			"code":  "AUDIO_DELETE_FAILED",
+			"error": "Internal Server Error: " + err.Error(),
+		})
+	}
+}
+
+func (rs *Server) handleBulkDeleteAudio(w http.ResponseWriter, r *http.Request) {
+	slog.Info("Received request to DELETE /audio")
+
+	filter, err := parseAudioFilter(r)
+	if err != nil {
+		rs.writeJson(w, http.StatusBadRequest, map[string]any{
+			"ok":    false,
+			"code":  "AUDIO_BAD_FILTER",
+			"error": err.Error(),
+		})
+		return
+	}
+
+	// a bulk delete without any filter would wipe everything
+	if filter.IsEmpty() {
+		rs.writeJson(w, http.StatusBadRequest, map[string]any{
+			"ok":    false,
+			"code":  "AUDIO_FILTER_REQUIRED",
+			"error": "At least one of category, audio_type, stage, start_date, end_date or title is required",
+		})
+		return
+	}
+
+	filter.Viewer = requestViewer(r)
+
+	ctx, cancel := rs.opCtx(r)
+	hashes, err := rs.postgres.GetAudiofileHashesByFilter(ctx, filter)
+	cancel()
+
+	if err != nil {
+		slog.Error("Error while loading audio files for bulk delete: " + err.Error())
+		rs.writeJson(w, http.StatusInternalServerError, map[string]any{
+			"ok":    false,
+			"code":  "AUDIO_DELETE_FAILED",
+			"error": "Internal Server Error: " + err.Error(),
+		})
+		return
+	}
+
+	var deleted []string
+	var failedHashes []string
+	var failedErrors []string
+	status := http.StatusOK
+
+	for _, hash := range hashes {
+		err := rs.deleteAudio(r, hash)
+		if err == nil || errors.Is(err, sql.ErrNoRows) {
+			deleted = append(deleted, hash)
+			continue
+		}
+
+		failedHashes = append(failedHashes, hash)
+		failedErrors = append(failedErrors, err.Error())
+
+		if errors.Is(err, postgres.ErrAudioInProcessing) {
+			if status == http.StatusOK {
+				status = http.StatusConflict
+			}
+		} else {
+			status = http.StatusInternalServerError
+		}
+	}
+
+	if len(failedHashes) > 0 {
+		slog.Info(fmt.Sprintf("Bulk delete removed %d audio files, %d failed", len(deleted), len(failedHashes)))
+		rs.writeJson(w, status, map[string]any{
+			"ok":    false,
+			"code":  "AUDIO_DELETE_PARTIAL",
+			"error": "Some audio files could not be deleted, retry the request",
+			"deleted": map[string]any{
+				"count":  len(deleted),
+				"hashes": deleted,
+			},
+			"failed": map[string]any{
+				"count":  len(failedHashes),
+				"hashes": failedHashes,
+				"errors": failedErrors,
+			},
+		})
+		return
+	}
+
+	rs.writeJson(w, http.StatusOK, map[string]any{
+		"ok": true,
+		"deleted": map[string]any{
+			"count":  len(deleted),
+			"hashes": deleted,
+		},
+	})
+}
+
+// deleteAudio removes an audio file from Qdrant, the storage and Postgres.
+// The Postgres row is removed last and stays flagged until then, so a failed delete can simply be retried.
+func (rs *Server) deleteAudio(r *http.Request, hash string) error {
+	ctx, cancel := rs.opCtx(r)
+	err := rs.postgres.MarkAudiofileForDeletion(ctx, hash)
+	cancel()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel = rs.opCtx(r)
+	audio, err := rs.postgres.GetAudioDataByHash(ctx, hash)
+	cancel()
+	if err != nil {
+		return &deleteStoreError{store: "postgres", err: err}
+	}
+	if audio == nil {
+		return sql.ErrNoRows
+	}
+
+	ctx, cancel = rs.opCtx(r)
+	segments, err := rs.postgres.GetAllSegmentsByAudioHash(ctx, hash)
+	cancel()
+	if err != nil {
+		return &deleteStoreError{store: "postgres", err: err}
+	}
+
+	segmentHashes := make([]string, len(segments))
+	for idx, segment := range segments {
+		segmentHashes[idx] = segment.SegmentHash
+	}
+
+	ctx, cancel = rs.opCtx(r)
+	err = rs.qdrant.DeleteSegmentEmbeddings(ctx, segmentHashes)
+	cancel()
+	if err != nil {
+		return &deleteStoreError{store: "qdrant", err: err}
+	}
+
+	// referenced files, e.g. from a watch folder, belong to the user and stay where they are
+	if storage.IsManaged(audio.DownloadPath) {
+		store, key := storage.Locate(rs.storage, audio.DownloadPath)
+
+		ctx, cancel = rs.opCtx(r)
+		err = store.Delete(ctx, key)
+		cancel()
+		if err != nil {
+			return &deleteStoreError{store: "storage", err: err}
+		}
+	}
+
+	ctx, cancel = rs.opCtx(r)
+	err = rs.postgres.DeleteAudiofile(ctx, hash)
+	cancel()
+	if err != nil {
+		return &deleteStoreError{store: "postgres", err: err}
+	}
+
+	slog.Info("Deleted audio file", "audioHash", hash, "segments", len(segmentHashes))
+
+	return nil
+}
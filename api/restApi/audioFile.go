@@ -0,0 +1,250 @@
+package restApi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go_audio_search_api_server/storage"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+)
+
+func (rs *Server) handleAudioFile(w http.ResponseWriter, r *http.Request) {
+	hash := r.PathValue("hash")
+	slog.Info("Received request to GET /audio/" + hash + "/file")
+
+	if !rs.audioVisibleToRequest(w, r, hash) {
+		return
+	}
+
+	start, end, clip, err := parseClipRange(r)
+	if err != nil {
+		rs.writeJson(w, http.StatusBadRequest, map[string]any{
+			"ok":    false,
+			"code":  "AUDIO_BAD_CLIP_RANGE",
+			"error": err.Error(),
+		})
+		return
+	}
+
+	ctx, cancel := rs.opCtx(r)
+	audio, err := rs.postgres.GetAudioDataByHash(ctx, hash)
+	cancel()
+
+	if err != nil {
+		slog.Error("Error while loading audio file", "audioHash", hash, "err", err)
+		rs.writeJson(w, http.StatusInternalServerError, map[string]any{
+			"ok":    false,
+			"code":  "AUDIO_LOAD_FAILED",
+			"error": "Internal Server Error: " + err.Error(),
+		})
+		return
+	}
+	if audio == nil {
+		rs.writeJson(w, http.StatusNotFound, map[string]any{
+			"ok":    false,
+			"code":  "AUDIO_NOT_FOUND",
+			"error": "No audio file with hash " + hash,
+		})
+		return
+	}
+	if audio.DownloadPath == "" {
+		rs.writeJson(w, http.StatusConflict, map[string]any{
+			"ok":    false,
+			"code":  "AUDIO_FILE_NOT_PERSISTED",
+			"error": "Audio file has not been persisted yet, current stage: " + audio.LastSuccessfulStage.Name(),
+		})
+		return
+	}
+
+	store, key := storage.Locate(rs.storage, audio.DownloadPath)
+
+	ctx, cancel = rs.opCtx(r)
+	info, err := store.Stat(ctx, key)
+	cancel()
+
+	if errors.Is(err, storage.ErrNotFound) {
+		slog.Error("Stored audio file is missing", "audioHash", hash, "location", audio.DownloadPath)
+		rs.writeJson(w, http.StatusNotFound, map[string]any{
+			"ok":    false,
+			"code":  "AUDIO_FILE_MISSING",
+			"error": "Stored audio file could not be opened",
+		})
+		return
+	}
+	if err != nil {
+		slog.Error("Error while opening audio file", "audioHash", hash, "location", audio.DownloadPath, "err", err)
+		rs.writeJson(w, http.StatusInternalServerError, map[string]any{
+			"ok":    false,
+			"code":  "AUDIO_LOAD_FAILED",
+			"error": "Internal Server Error: " + err.Error(),
+		})
+		return
+	}
+
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		contentType = "audio/mpeg"
+	}
+
+	// ranges are read from the storage only when ServeContent asks for them
+	offset, length := int64(0), info.Size
+	etag := hash
+
+	if clip {
+		duration := float64(audio.DurationInSec)
+		if duration <= 0 {
+			// recordings transcribed before the duration was stored still have their timed segments
+			ctx, cancel = rs.opCtx(r)
+			duration, err = rs.postgres.GetSegmentsEndSec(ctx, hash)
+			cancel()
+
+			if err != nil {
+				slog.Error("Error while loading the duration of the audio file", "audioHash", hash, "err", err)
+				rs.writeJson(w, http.StatusInternalServerError, map[string]any{
+					"ok":    false,
+					"code":  "AUDIO_LOAD_FAILED",
+					"error": "Internal Server Error: " + err.Error(),
+				})
+				return
+			}
+		}
+		if duration <= 0 {
+			rs.writeJson(w, http.StatusUnprocessableEntity, map[string]any{
+				"ok":    false,
+				"code":  "AUDIO_DURATION_UNKNOWN",
+				"error": "Clips need the duration of the audio file, it is known once the audio file is transcribed",
+			})
+			return
+		}
+
+		if end <= 0 || end > duration {
+			end = duration
+		}
+		if start >= end {
+			rs.writeJson(w, http.StatusBadRequest, map[string]any{
+				"ok":    false,
+				"code":  "AUDIO_BAD_CLIP_RANGE",
+				"error": fmt.Sprintf("start %.2f must be before end %.2f", start, end),
+			})
+			return
+		}
+
+		// a leading ID3v2 tag holds no audio, it would shift every offset towards the start of the file
+		var dataStart int64
+		if contentType == "audio/mpeg" {
+			ctx, cancel = rs.opCtx(r)
+			dataStart = readId3v2Size(ctx, store, key)
+			cancel()
+		}
+
+		offset, length = clipByteRange(info.Size, dataStart, start, end, duration)
+
+		etag = fmt.Sprintf("%s-%s-%s", hash, formatSeconds(start), formatSeconds(end))
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("ETag", `"`+etag+`"`)
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+
+	// ServeContent answers Range, If-Range and If-None-Match requests with 206/304 as needed
+	content := storage.NewReadSeeker(r.Context(), store, key, offset, length)
+	defer content.Close()
+
+	http.ServeContent(w, r, path.Base(key), info.ModTime, content)
+}
+
+// clipByteRange maps start and end in seconds onto the audio bytes behind dataStart. The mapping is linear, so the
+// clip is only approximate: exact for constant bitrate MP3s, off by a few seconds for variable bitrate files and
+// unrelated to the time for formats that are not a plain stream of frames. The cut is not on a frame boundary,
+// MP3 decoders resync on the next frame header. The range is clamped to the file, a start at or after the end
+// returns an empty range and an unknown duration the whole file.
+func clipByteRange(size int64, dataStart int64, start float64, end float64, duration float64) (offset int64, length int64) {
+	if size <= 0 {
+		return 0, 0
+	}
+	if duration <= 0 {
+		return 0, size
+	}
+	if dataStart < 0 || dataStart >= size {
+		dataStart = 0
+	}
+
+	start = min(max(start, 0), duration)
+	end = min(max(end, start), duration)
+
+	data := float64(size - dataStart)
+	offset = min(dataStart+int64(data*start/duration), size)
+	endOffset := min(dataStart+int64(data*end/duration), size)
+
+	return offset, endOffset - offset
+}
+
+// readId3v2Size returns the length of the ID3v2 tag at the start of the file, 0 if there is none or it can't be read
+func readId3v2Size(ctx context.Context, store storage.Storage, key string) int64 {
+	rc, err := store.ReadRange(ctx, key, 0, 10)
+	if err != nil {
+		slog.Warn("Could not read the ID3 header, clip offsets include it", "location", key, "err", err)
+		return 0
+	}
+	defer rc.Close()
+
+	header := make([]byte, 10)
+	if _, err := io.ReadFull(rc, header); err != nil {
+		return 0
+	}
+	return id3v2Size(header)
+}
+
+// id3v2Size parses the 10 byte ID3v2 header, the size is stored in four 7 bit bytes and excludes header and footer
+func id3v2Size(header []byte) int64 {
+	if len(header) < 10 || string(header[:3]) != "ID3" {
+		return 0
+	}
+
+	var size int64
+	for _, b := range header[6:10] {
+		if b >= 0x80 {
+			return 0
+		}
+		size = size<<7 | int64(b)
+	}
+
+	size += 10
+	if header[5]&0x10 != 0 {
+		size += 10
+	}
+	return size
+}
+
+// parseClipRange reads the optional start and end query parameters in seconds.
+func parseClipRange(r *http.Request) (start float64, end float64, clip bool, err error) {
+	query := r.URL.Query()
+
+	if v := strings.TrimSpace(query.Get("start")); v != "" {
+		start, err = strconv.ParseFloat(v, 64)
+		if err != nil || start < 0 {
+			return 0, 0, false, fmt.Errorf("start must be a positive number of seconds, got %q", v)
+		}
+		clip = true
+	}
+
+	if v := strings.TrimSpace(query.Get("end")); v != "" {
+		end, err = strconv.ParseFloat(v, 64)
+		if err != nil || end <= 0 {
+			return 0, 0, false, fmt.Errorf("end must be a positive number of seconds, got %q", v)
+		}
+		clip = true
+	}
+
+	return start, end, clip, nil
+}
+
+func formatSeconds(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -0,0 +1,63 @@
+package restApi
+
+import "testing"
+
+func TestClipByteRange(t *testing.T) {
+	tests := []struct {
+		name       string
+		size       int64
+		dataStart  int64
+		start      float64
+		end        float64
+		duration   float64
+		wantOffset int64
+		wantLength int64
+	}{
+		{"middle of the file", 1000, 0, 25, 50, 100, 250, 250},
+		{"whole file", 1000, 0, 0, 100, 100, 0, 1000},
+		{"id3 tag is skipped", 1100, 100, 25, 50, 100, 350, 250},
+		{"end beyond the duration is clamped to the file", 1000, 0, 90, 500, 100, 900, 100},
+		{"start at the duration is empty", 1000, 0, 100, 120, 100, 1000, 0},
+		{"start after the duration is empty", 1000, 0, 150, 200, 100, 1000, 0},
+		{"end before start is empty", 1000, 0, 60, 40, 100, 600, 0},
+		{"negative start begins at the audio", 1100, 100, -5, 10, 100, 100, 100},
+		{"unknown duration returns the whole file", 1000, 0, 10, 20, 0, 0, 1000},
+		{"tag larger than the file is ignored", 1000, 5000, 25, 50, 100, 250, 250},
+		{"empty file", 0, 0, 10, 20, 100, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			offset, length := clipByteRange(tt.size, tt.dataStart, tt.start, tt.end, tt.duration)
+			if offset != tt.wantOffset || length != tt.wantLength {
+				t.Fatalf("got offset %d length %d, want offset %d length %d", offset, length, tt.wantOffset, tt.wantLength)
+			}
+			if offset < 0 || length < 0 || offset+length > max(tt.size, 0) {
+				t.Fatalf("range %d+%d is outside of the %d byte file", offset, length, tt.size)
+			}
+		})
+	}
+}
+
+func TestId3v2Size(t *testing.T) {
+	tests := []struct {
+		name   string
+		header []byte
+		want   int64
+	}{
+		{"no tag", []byte("\xff\xfb\x90\x00\x00\x00\x00\x00\x00\x00"), 0},
+		{"short header", []byte("ID3\x04\x00"), 0},
+		{"empty tag", []byte("ID3\x04\x00\x00\x00\x00\x00\x00"), 10},
+		{"syncsafe size", []byte("ID3\x04\x00\x00\x00\x00\x02\x01"), 10 + 257},
+		{"footer", []byte("ID3\x04\x00\x10\x00\x00\x00\x7f"), 10 + 127 + 10},
+		{"invalid syncsafe byte", []byte("ID3\x04\x00\x00\x00\x00\x80\x00"), 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := id3v2Size(tt.header); got != tt.want {
+				t.Fatalf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
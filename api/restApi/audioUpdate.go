@@ -0,0 +1,186 @@
+package restApi
+
+import (
+	"database/sql"
+	"errors"
+	"go_audio_search_api_server/globalTypes"
+	"go_audio_search_api_server/postgres"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+func (rs *Server) handleUpdateAudio(w http.ResponseWriter, r *http.Request) {
+	hash := r.PathValue("hash")
+	slog.Info("Received request to PATCH /audio/" + hash)
+
+	if !rs.audioVisibleToRequest(w, r, hash) {
+		return
+	}
+
+	ct := r.Header.Get("Content-Type")
+	if ct == "" || !strings.HasPrefix(ct, "application/json") {
+		rs.writeJson(w, http.StatusUnsupportedMediaType, map[string]any{
+			"ok":    false,
+			"code":  "AUDIO_UNSUPPORTED_CONTENT_TYPE",
+			"error": "Content-Type must be application/json",
+			"got":   ct,
+		})
+		return
+	}
+
+	var patch globalTypes.AudioMetadataPatch
+
+	// 1 MiB
+	const maxBody = 1 << 20
+
+	if err := ReadJSON(r, &patch, maxBody); err != nil {
+		rs.writeJson(w, http.StatusBadRequest, map[string]any{
+			"ok":    false,
+			"code":  "AUDIO_BAD_JSON",
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if err := patch.ValidateApiInput(); err != nil {
+		rs.writeJson(w, http.StatusUnprocessableEntity, map[string]any{
+			"ok":    false,
+			"code":  "AUDIO_VALIDATION_FAILED",
+			"error": "Update has a invalid field: " + err.Error(),
+		})
+		return
+	}
+
+	if patch.Acl != nil && !rs.mayChangeAcl(w, r, hash) {
+		return
+	}
+
+	ctx, cancel := rs.opCtx(r)
+	err := rs.postgres.UpdateAudioMetadata(ctx, hash, patch)
+	cancel()
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		rs.writeJson(w, http.StatusNotFound, map[string]any{
+			"ok":    false,
+			"code":  "AUDIO_NOT_FOUND",
+			"error": "No audio file with hash " + hash,
+		})
+		return
+
+	case errors.Is(err, postgres.ErrAudioInProcessing):
+		rs.writeJson(w, http.StatusConflict, map[string]any{
+			"ok":    false,
+			"code":  "AUDIO_IN_PROCESSING",
+			"error": "Audio file is currently processed by a pipeline stage, retry later",
+		})
+		return
+
+	case err != nil:
+		slog.Error("Error while updating audio metadata", "audioHash", hash, "err", err)
+		rs.writeJson(w, http.StatusInternalServerError, map[string]any{
+			"ok":    false,
+			"code":  "AUDIO_UPDATE_FAILED",
+			"error": "Internal Server Error: " + err.Error(),
+		})
+		return
+	}
+
+	// The full text index is built from the segment transcripts only and the search filters join the audiofiles row,
+	// so the changed metadata is picked up by the full text search without reindexing. The vector search filters on
+	// the copy of the metadata stored with every segment in Qdrant.
+
+	ctx, cancel = rs.opCtx(r)
+	audio, err := rs.postgres.GetSearchAudioDataByHash(ctx, hash, requestViewer(r))
+	cancel()
+
+	if err != nil {
+		slog.Error("Error while loading updated audio file", "audioHash", hash, "err", err)
+		rs.writeJson(w, http.StatusInternalServerError, map[string]any{
+			"ok":    false,
+			"code":  "AUDIO_UPDATE_FAILED",
+			"error": "Internal Server Error: audio file was updated but could not be loaded: " + err.Error(),
+		})
+		return
+	}
+
+	if patch.Acl != nil || patch.Category != nil || patch.RecordingDate != nil {
+		if err := rs.syncSegmentPayload(r, hash); err != nil {
+			slog.Error("Error while updating the payload in Qdrant", "audioHash", hash, "err", err)
+			rs.writeJson(w, http.StatusInternalServerError, map[string]any{
+				"ok":    false,
+				"code":  "AUDIO_INDEX_SYNC_FAILED",
+				"error": "Internal Server Error: audio file was updated but the search index could not be updated, retry the request: " + err.Error(),
+			})
+			return
+		}
+	}
+
+	rs.writeJson(w, http.StatusOK, map[string]any{
+		"ok":    true,
+		"audio": audio,
+	})
+}
+
+// mayChangeAcl answers with 403 unless the caller owns the audio file or holds an admin key
+func (rs *Server) mayChangeAcl(w http.ResponseWriter, r *http.Request, hash string) bool {
+	viewer := requestViewer(r)
+	if viewer.SeesAll {
+		return true
+	}
+
+	ctx, cancel := rs.opCtx(r)
+	audio, err := rs.postgres.GetSearchAudioDataByHash(ctx, hash, viewer)
+	cancel()
+
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		slog.Error("Error while loading audio file", "audioHash", hash, "err", err)
+		rs.writeJson(w, http.StatusInternalServerError, map[string]any{
+			"ok":    false,
+			"code":  "AUDIO_LOAD_FAILED",
+			"error": "Internal Server Error: " + err.Error(),
+		})
+		return false
+	}
+	if err != nil || viewer.User == "" || viewer.User != audio.Owner {
+		rs.writeJson(w, http.StatusForbidden, map[string]any{
+			"ok":    false,
+			"code":  "AUDIO_ACL_FORBIDDEN",
+			"error": "Only the owner of the audio file or an admin key can change its acl",
+		})
+		return false
+	}
+	return true
+}
+
+// syncSegmentPayload copies the readers and the filtered metadata of the audio file to its segments in Qdrant
+func (rs *Server) syncSegmentPayload(r *http.Request, hash string) error {
+	ctx, cancel := rs.opCtx(r)
+	audio, err := rs.postgres.GetAudioDataByHash(ctx, hash)
+	cancel()
+
+	if err != nil {
+		return err
+	}
+	if audio == nil {
+		return sql.ErrNoRows
+	}
+
+	ctx, cancel = rs.opCtx(r)
+	segments, err := rs.postgres.GetAllSegmentsByAudioHash(ctx, hash)
+	cancel()
+
+	if err != nil {
+		return err
+	}
+
+	segmentHashes := make([]string, 0, len(segments))
+	for _, segment := range segments {
+		segmentHashes = append(segmentHashes, segment.SegmentHash)
+	}
+
+	ctx, cancel = rs.opCtx(r)
+	defer cancel()
+	return rs.qdrant.RefreshAudioPayload(ctx, segmentHashes, audio)
+}
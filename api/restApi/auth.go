@@ -0,0 +1,395 @@
+package restApi
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"go_audio_search_api_server/globalTypes"
+	"go_audio_search_api_server/globalUtils"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const apiKeyPrefix = "ask_"
+
+// minBootstrapKeyLength keeps guessable admin keys out of the configuration
+const minBootstrapKeyLength = 32
+
+type apiKeyContextKey struct{}
+
+// generateApiKey returns a new random key and its display prefix
+func generateApiKey() (key string, prefix string, err error) {
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+
+	key = apiKeyPrefix + hex.EncodeToString(secret)
+	return key, displayPrefix(key), nil
+}
+
+func displayPrefix(key string) string {
+	return key[:min(len(key), len(apiKeyPrefix)+8)]
+}
+
+// hashApiKey hashes a key for storage. The keys are long random strings, so a fast hash is enough.
+func hashApiKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// bootstrapAdminKey registers API_ADMIN_KEY as admin key, it is needed to create the first keys
+func (rs *Server) bootstrapAdminKey() {
+	key := globalUtils.LoadEnvStrOr("API_ADMIN_KEY", "")
+	if key == "" {
+		slog.Warn("API_ADMIN_KEY is not set, only existing api keys can access the api")
+		return
+	}
+	if len(key) < minBootstrapKeyLength {
+		panic("API_ADMIN_KEY must be at least " + strconv.Itoa(minBootstrapKeyLength) + " characters long")
+	}
+
+	ctx, cancel := context.WithTimeout(rs.StopCtx, opTimeout)
+	defer cancel()
+
+	if err := rs.postgres.EnsureBootstrapApiKey(ctx, hashApiKey(key), displayPrefix(key)); err != nil {
+		panic(err)
+	}
+}
+
+// requestApiKey reads the key from the Authorization bearer token or X-API-Key. GET requests may pass it as
+// api_key query parameter instead, because EventSource and audio elements in browsers cannot set headers.
+func requestApiKey(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		if token, ok := strings.CutPrefix(auth, "Bearer "); ok {
+			return strings.TrimSpace(token)
+		}
+	}
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return strings.TrimSpace(key)
+	}
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return strings.TrimSpace(r.URL.Query().Get("api_key"))
+	}
+	return ""
+}
+
+// requireScope only passes requests with an active api key that grants the scope
+func (rs *Server) requireScope(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := requestApiKey(r)
+		if key == "" {
+			if !rs.allowRequest(w, r, nil, scope) {
+				return
+			}
+			w.Header().Set("WWW-Authenticate", `Bearer realm="audio-search"`)
+			rs.writeJson(w, http.StatusUnauthorized, map[string]any{
+				"ok":    false,
+				"code":  "AUTH_REQUIRED",
+				"error": "An api key is required, send it as Authorization: Bearer <key>",
+			})
+			return
+		}
+
+		ctx, cancel := rs.opCtx(r)
+		apiKey, err := rs.postgres.GetActiveApiKeyByHash(ctx, hashApiKey(key))
+		cancel()
+
+		if errors.Is(err, sql.ErrNoRows) {
+			// guessed keys are limited by ip
+			if !rs.allowRequest(w, r, nil, scope) {
+				return
+			}
+			w.Header().Set("WWW-Authenticate", `Bearer realm="audio-search", error="invalid_token"`)
+			rs.writeJson(w, http.StatusUnauthorized, map[string]any{
+				"ok":    false,
+				"code":  "AUTH_INVALID_KEY",
+				"error": "The api key is unknown or revoked",
+			})
+			return
+		}
+		if err != nil {
+			slog.Error("Error while checking api key", "err", err)
+			rs.writeJson(w, http.StatusInternalServerError, map[string]any{
+				"ok":    false,
+				"code":  "AUTH_FAILED",
+				"error": "Internal Server Error: " + err.Error(),
+			})
+			return
+		}
+
+		if !rs.allowRequest(w, r, apiKey, scope) {
+			return
+		}
+
+		if !apiKey.HasScope(scope) {
+			rs.writeJson(w, http.StatusForbidden, map[string]any{
+				"ok":    false,
+				"code":  "AUTH_MISSING_SCOPE",
+				"error": "The api key lacks the scope " + scope,
+			})
+			return
+		}
+
+		ctx, cancel = rs.opCtx(r)
+		err = rs.postgres.TouchApiKey(ctx, apiKey.ID)
+		cancel()
+
+		if err != nil {
+			slog.Warn("Could not update last use of api key", "apiKeyId", apiKey.ID, "err", err)
+		}
+
+		next(w, r.WithContext(context.WithValue(r.Context(), apiKeyContextKey{}, apiKey)))
+	}
+}
+
+// requestKey returns the api key that authorized the request, nil on public endpoints
+func requestKey(r *http.Request) *globalTypes.ApiKey {
+	key, _ := r.Context().Value(apiKeyContextKey{}).(*globalTypes.ApiKey)
+	return key
+}
+
+// requestWorkspace returns the workspace of the api key that authorized the request,
+// every read and write of recordings is limited to it
+func requestWorkspace(r *http.Request) string {
+	if key := requestKey(r); key != nil {
+		return key.WorkspaceID
+	}
+	return globalTypes.DefaultWorkspaceID
+}
+
+// requestKeyID returns the id of the api key that authorized the request, 0 on public endpoints
+func requestKeyID(r *http.Request) int64 {
+	if key := requestKey(r); key != nil {
+		return key.ID
+	}
+	return 0
+}
+
+// keyWorkspaceFilter returns the workspace whose api keys the request may manage, empty for system keys,
+// which manage the keys of every workspace
+func keyWorkspaceFilter(r *http.Request) string {
+	if key := requestKey(r); key != nil && key.HasScope(globalTypes.ScopeSystem) {
+		return ""
+	}
+	return requestWorkspace(r)
+}
+
+// requestViewer returns the identity recordings are read for, access lists of recordings are checked against it
+func requestViewer(r *http.Request) globalTypes.Viewer {
+	if key := requestKey(r); key != nil {
+		return key.Viewer()
+	}
+	return globalTypes.Viewer{WorkspaceID: globalTypes.DefaultWorkspaceID}
+}
+
+// handleCurrentApiKey tells a client which key it uses and which scopes that key grants
+func (rs *Server) handleCurrentApiKey(w http.ResponseWriter, r *http.Request) {
+	rs.writeJson(w, http.StatusOK, map[string]any{
+		"ok":  true,
+		"key": requestKey(r),
+	})
+}
+
+func (rs *Server) handleListApiKeys(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := rs.opCtx(r)
+	keys, err := rs.postgres.ListApiKeys(ctx, keyWorkspaceFilter(r))
+	cancel()
+
+	if err != nil {
+		slog.Error("Error while listing api keys", "err", err)
+		rs.writeJson(w, http.StatusInternalServerError, map[string]any{
+			"ok":    false,
+			"code":  "AUTH_KEY_LIST_FAILED",
+			"error": "Internal Server Error: " + err.Error(),
+		})
+		return
+	}
+
+	rs.writeJson(w, http.StatusOK, map[string]any{
+		"ok":   true,
+		"keys": keys,
+	})
+}
+
+// handleCreateApiKey creates a key, the key itself is only part of this response
+func (rs *Server) handleCreateApiKey(w http.ResponseWriter, r *http.Request) {
+	slog.Info("Received request to POST /auth/keys")
+
+	var req globalTypes.ApiKeyRequest
+	if err := ReadJSON(r, &req, 1<<20); err != nil {
+		rs.writeJson(w, http.StatusBadRequest, map[string]any{
+			"ok":    false,
+			"code":  "AUTH_BAD_JSON",
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if err := req.ValidateApiInput(); err != nil {
+		rs.writeJson(w, http.StatusUnprocessableEntity, map[string]any{
+			"ok":    false,
+			"code":  "AUTH_VALIDATION_FAILED",
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if req.WorkspaceID == "" {
+		req.WorkspaceID = requestWorkspace(r)
+	}
+
+	if filter := keyWorkspaceFilter(r); filter != "" && req.WorkspaceID != filter {
+		rs.writeJson(w, http.StatusForbidden, map[string]any{
+			"ok":    false,
+			"code":  "AUTH_FOREIGN_WORKSPACE",
+			"error": "Only keys with the system scope create keys for other workspaces",
+		})
+		return
+	}
+
+	// a key can only hand out what it holds itself, so admin keys cannot create system keys
+	for _, scope := range req.Scopes {
+		if !requestKey(r).HasScope(scope) {
+			rs.writeJson(w, http.StatusForbidden, map[string]any{
+				"ok":    false,
+				"code":  "AUTH_SCOPE_NOT_GRANTABLE",
+				"error": "The api key cannot grant the scope " + scope,
+			})
+			return
+		}
+	}
+
+	ctx, cancel := rs.opCtx(r)
+	_, err := rs.postgres.GetWorkspace(ctx, req.WorkspaceID)
+	cancel()
+
+	if errors.Is(err, sql.ErrNoRows) {
+		rs.writeJson(w, http.StatusUnprocessableEntity, map[string]any{
+			"ok":    false,
+			"code":  "AUTH_UNKNOWN_WORKSPACE",
+			"error": "No workspace with id " + req.WorkspaceID,
+		})
+		return
+	}
+	if err != nil {
+		slog.Error("Error while loading workspace", "workspaceId", req.WorkspaceID, "err", err)
+		rs.writeJson(w, http.StatusInternalServerError, map[string]any{
+			"ok":    false,
+			"code":  "AUTH_KEY_CREATE_FAILED",
+			"error": "Internal Server Error: " + err.Error(),
+		})
+		return
+	}
+
+	key, prefix, err := generateApiKey()
+	if err != nil {
+		rs.writeApiKey(w, nil, err)
+		return
+	}
+
+	ctx, cancel = rs.opCtx(r)
+	apiKey, err := rs.postgres.CreateApiKey(ctx, req, hashApiKey(key), prefix)
+	cancel()
+
+	if err != nil {
+		slog.Error("Error while creating api key", "err", err)
+		rs.writeJson(w, http.StatusInternalServerError, map[string]any{
+			"ok":    false,
+			"code":  "AUTH_KEY_CREATE_FAILED",
+			"error": "Internal Server Error: " + err.Error(),
+		})
+		return
+	}
+
+	slog.Info("Created api key", "apiKeyId", apiKey.ID, "name", apiKey.Name, "scopes", apiKey.Scopes, "workspaceId", apiKey.WorkspaceID)
+
+	apiKey.Key = key
+	rs.writeJson(w, http.StatusCreated, map[string]any{
+		"ok":  true,
+		"key": apiKey,
+	})
+}
+
+// handleRotateApiKey replaces the key of an api key and keeps its name and scopes
+func (rs *Server) handleRotateApiKey(w http.ResponseWriter, r *http.Request) {
+	id, ok := rs.apiKeyId(w, r)
+	if !ok {
+		return
+	}
+
+	key, prefix, err := generateApiKey()
+	if err != nil {
+		rs.writeApiKey(w, nil, err)
+		return
+	}
+
+	ctx, cancel := rs.opCtx(r)
+	apiKey, err := rs.postgres.RotateApiKey(ctx, keyWorkspaceFilter(r), id, hashApiKey(key), prefix)
+	cancel()
+
+	if err == nil {
+		slog.Info("Rotated api key", "apiKeyId", id)
+		apiKey.Key = key
+	}
+	rs.writeApiKey(w, apiKey, err)
+}
+
+func (rs *Server) handleRevokeApiKey(w http.ResponseWriter, r *http.Request) {
+	id, ok := rs.apiKeyId(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := rs.opCtx(r)
+	apiKey, err := rs.postgres.RevokeApiKey(ctx, keyWorkspaceFilter(r), id)
+	cancel()
+
+	if err == nil {
+		slog.Info("Revoked api key", "apiKeyId", id)
+	}
+	rs.writeApiKey(w, apiKey, err)
+}
+
+func (rs *Server) apiKeyId(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		rs.writeJson(w, http.StatusBadRequest, map[string]any{
+			"ok":    false,
+			"code":  "AUTH_BAD_KEY_ID",
+			"error": "Api key id must be a positive number",
+		})
+		return 0, false
+	}
+	return id, true
+}
+
+func (rs *Server) writeApiKey(w http.ResponseWriter, apiKey *globalTypes.ApiKey, err error) {
+	if errors.Is(err, sql.ErrNoRows) {
+		rs.writeJson(w, http.StatusNotFound, map[string]any{
+			"ok":    false,
+			"code":  "AUTH_KEY_NOT_FOUND",
+			"error": "No such active api key",
+		})
+		return
+	}
+	if err != nil {
+		slog.Error("Error while updating api key", "err", err)
+		rs.writeJson(w, http.StatusInternalServerError, map[string]any{
+			"ok":    false,
+			"code":  "AUTH_KEY_UPDATE_FAILED",
+			"error": "Internal Server Error: " + err.Error(),
+		})
+		return
+	}
+
+	rs.writeJson(w, http.StatusOK, map[string]any{
+		"ok":  true,
+		"key": apiKey,
+	})
+}
@@ -0,0 +1,75 @@
+package restApi
+
+import (
+	"encoding/json"
+	"fmt"
+	"go_audio_search_api_server/eventStream"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// eventStreamHeartbeat keeps idle connections open through proxies
+const eventStreamHeartbeat = 15 * time.Second
+
+// handleEvents streams the AudioEvents of all instances as Server-Sent Events.
+// Only events of the workspace of the api key that it may read are sent, import_id and category narrow the stream down further.
+// Events are not replayed after a reconnect.
+func (rs *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	filter := eventStream.Filter{
+		Viewer:   requestViewer(r),
+		ImportID: strings.TrimSpace(r.URL.Query().Get("import_id")),
+		Category: strings.TrimSpace(r.URL.Query().Get("category")),
+	}
+
+	slog.Info("Received request to GET /events", "importId", filter.ImportID, "category", filter.Category)
+
+	rc := http.NewResponseController(w)
+
+	events, unsubscribe := rs.events.Subscribe(filter)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	_, _ = fmt.Fprint(w, "retry: 3000\n\n")
+	if err := rc.Flush(); err != nil {
+		slog.Error("Event stream does not support flushing", "err", err)
+		return
+	}
+
+	heartbeat := time.NewTicker(eventStreamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-rs.StopCtx.Done():
+			return
+
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+
+		case event := <-events:
+			data, err := json.Marshal(event)
+			if err != nil {
+				slog.Error("Error while encoding event", "err", err)
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data); err != nil {
+				return
+			}
+		}
+
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
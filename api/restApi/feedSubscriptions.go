@@ -0,0 +1,181 @@
+package restApi
+
+import (
+	"database/sql"
+	"errors"
+	"go_audio_search_api_server/globalTypes"
+	"go_audio_search_api_server/postgres"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+func (rs *Server) handleListFeedSubscriptions(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := rs.opCtx(r)
+	subs, err := rs.postgres.ListFeedSubscriptions(ctx, requestWorkspace(r))
+	cancel()
+
+	if err != nil {
+		slog.Error("Error while listing feed subscriptions", "err", err)
+		rs.writeJson(w, http.StatusInternalServerError, map[string]any{
+			"ok":    false,
+			"code":  "FEED_LIST_FAILED",
+			"error": "Internal Server Error: " + err.Error(),
+		})
+		return
+	}
+
+	rs.writeJson(w, http.StatusOK, map[string]any{
+		"ok":            true,
+		"subscriptions": subs,
+	})
+}
+
+func (rs *Server) handleCreateFeedSubscription(w http.ResponseWriter, r *http.Request) {
+	slog.Info("Received request to POST /feeds/subscriptions")
+
+	var req globalTypes.FeedSubscriptionRequest
+	if err := ReadJSON(r, &req, 1<<20); err != nil {
+		rs.writeJson(w, http.StatusBadRequest, map[string]any{
+			"ok":    false,
+			"code":  "FEED_BAD_JSON",
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if err := req.ValidateApiInput(); err != nil {
+		rs.writeJson(w, http.StatusUnprocessableEntity, map[string]any{
+			"ok":    false,
+			"code":  "FEED_VALIDATION_FAILED",
+			"error": err.Error(),
+		})
+		return
+	}
+
+	ctx, cancel := rs.opCtx(r)
+	sub, err := rs.postgres.CreateFeedSubscription(ctx, requestWorkspace(r), req)
+	cancel()
+
+	if errors.Is(err, postgres.ErrFeedSubscriptionExists) {
+		rs.writeJson(w, http.StatusConflict, map[string]any{
+			"ok":    false,
+			"code":  "FEED_ALREADY_SUBSCRIBED",
+			"error": "The feed " + req.FeedUrl + " is already subscribed",
+		})
+		return
+	}
+	if err != nil {
+		slog.Error("Error while creating feed subscription", "err", err)
+		rs.writeJson(w, http.StatusInternalServerError, map[string]any{
+			"ok":    false,
+			"code":  "FEED_CREATE_FAILED",
+			"error": "Internal Server Error: " + err.Error(),
+		})
+		return
+	}
+
+	rs.FeedPollSignal.Trigger()
+
+	rs.writeJson(w, http.StatusCreated, map[string]any{
+		"ok":           true,
+		"subscription": sub,
+	})
+}
+
+func (rs *Server) handleGetFeedSubscription(w http.ResponseWriter, r *http.Request) {
+	id, ok := rs.feedSubscriptionId(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := rs.opCtx(r)
+	sub, err := rs.postgres.GetFeedSubscription(ctx, requestWorkspace(r), id)
+	cancel()
+
+	rs.writeFeedSubscription(w, sub, err)
+}
+
+func (rs *Server) handlePauseFeedSubscription(w http.ResponseWriter, r *http.Request) {
+	rs.setFeedSubscriptionPaused(w, r, true)
+}
+
+func (rs *Server) handleResumeFeedSubscription(w http.ResponseWriter, r *http.Request) {
+	rs.setFeedSubscriptionPaused(w, r, false)
+}
+
+func (rs *Server) setFeedSubscriptionPaused(w http.ResponseWriter, r *http.Request, paused bool) {
+	id, ok := rs.feedSubscriptionId(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := rs.opCtx(r)
+	sub, err := rs.postgres.SetFeedSubscriptionPaused(ctx, requestWorkspace(r), id, paused)
+	cancel()
+
+	if err == nil && !paused {
+		rs.FeedPollSignal.Trigger()
+	}
+
+	rs.writeFeedSubscription(w, sub, err)
+}
+
+func (rs *Server) handleDeleteFeedSubscription(w http.ResponseWriter, r *http.Request) {
+	id, ok := rs.feedSubscriptionId(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := rs.opCtx(r)
+	err := rs.postgres.DeleteFeedSubscription(ctx, requestWorkspace(r), id)
+	cancel()
+
+	if err != nil {
+		rs.writeFeedSubscription(w, nil, err)
+		return
+	}
+
+	rs.writeJson(w, http.StatusOK, map[string]any{
+		"ok":      true,
+		"deleted": id,
+	})
+}
+
+func (rs *Server) feedSubscriptionId(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		rs.writeJson(w, http.StatusBadRequest, map[string]any{
+			"ok":    false,
+			"code":  "FEED_BAD_ID",
+			"error": "Subscription id must be a positive number",
+		})
+		return 0, false
+	}
+	return id, true
+}
+
+func (rs *Server) writeFeedSubscription(w http.ResponseWriter, sub *globalTypes.FeedSubscription, err error) {
+	if errors.Is(err, sql.ErrNoRows) {
+		rs.writeJson(w, http.StatusNotFound, map[string]any{
+			"ok":    false,
+			"code":  "FEED_NOT_FOUND",
+			"error": "No such feed subscription",
+		})
+		return
+	}
+	if err != nil {
+		slog.Error("Error while updating feed subscription", "err", err)
+		rs.writeJson(w, http.StatusInternalServerError, map[string]any{
+			"ok":    false,
+			"code":  "FEED_UPDATE_FAILED",
+			"error": "Internal Server Error: " + err.Error(),
+		})
+		return
+	}
+
+	rs.writeJson(w, http.StatusOK, map[string]any{
+		"ok":           true,
+		"subscription": sub,
+	})
+}
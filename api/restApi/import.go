@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"go_audio_search_api_server/globalTypes"
 	"go_audio_search_api_server/postgres"
+	"go_audio_search_api_server/tracing"
 	"log/slog"
 	"net/http"
 	"strings"
@@ -14,11 +15,16 @@ func (rs *Server) handleImport(w http.ResponseWriter, r *http.Request) {
 
 	// 1) Content-Type hart prüfen -> 415
 	ct := r.Header.Get("Content-Type")
+	if strings.HasPrefix(ct, "multipart/form-data") {
+		rs.handleMultipartImport(w, r)
+		return
+	}
+
 	if ct == "" || !strings.HasPrefix(ct, "application/json") && !strings.HasPrefix(ct, "application/json; charset=utf-8") {
-		rs.writeJsonWithCounter(w, http.StatusUnsupportedMediaType, postgres.ImportRequestsFailed, map[string]any{
+		rs.writeJsonWithCounter(w, r, http.StatusUnsupportedMediaType, postgres.ImportRequestsFailed, map[string]any{
 			"ok":    false,
 			"code":  "IMPORT_UNSUPPORTED_CONTENT_TYPE",
-			"error": "Content-Type must be application/json",
+			"error": "Content-Type must be application/json or multipart/form-data",
 			"got":   ct,
 		})
 		return
@@ -40,7 +46,7 @@ func (rs *Server) handleImport(w http.ResponseWriter, r *http.Request) {
 		if strings.Contains(strings.ToLower(msg), "too large") ||
 			strings.Contains(strings.ToLower(msg), "request body too large") ||
 			strings.Contains(strings.ToLower(msg), "http: request body too large") {
-			rs.writeJsonWithCounter(w, http.StatusRequestEntityTooLarge, postgres.ImportRequestsFailed, map[string]any{
+			rs.writeJsonWithCounter(w, r, http.StatusRequestEntityTooLarge, postgres.ImportRequestsFailed, map[string]any{
 				"ok":    false,
 				"code":  "IMPORT_PAYLOAD_TOO_LARGE",
 				"error": "Request body too large",
@@ -49,7 +55,7 @@ func (rs *Server) handleImport(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		rs.writeJsonWithCounter(w, http.StatusBadRequest, postgres.ImportRequestsFailed, map[string]any{
+		rs.writeJsonWithCounter(w, r, http.StatusBadRequest, postgres.ImportRequestsFailed, map[string]any{
 			"ok":    false,
 			"code":  "IMPORT_BAD_JSON",
 			"error": msg,
@@ -69,6 +75,9 @@ func (rs *Server) handleImport(w http.ResponseWriter, r *http.Request) {
 			invalidItemsErr = append(invalidItemsErr, err.Error())
 			continue
 		}
+		item.WorkspaceID = requestWorkspace(r)
+		item.Owner = requestViewer(r).User
+		item.ApiKeyID = requestKeyID(r)
 		item.AudiofileHash = item.GetTmpHash()
 		item.LastSuccessfulStage = globalTypes.StageQueued
 		validItems = append(validItems, &item)
@@ -77,7 +86,7 @@ func (rs *Server) handleImport(w http.ResponseWriter, r *http.Request) {
 	// alle invalid -> 422
 	if len(validItems) == 0 {
 		slog.Info("Received an import with no valid items to import")
-		rs.writeJsonWithCounter(w, http.StatusUnprocessableEntity, postgres.ImportRequestsFailed, map[string]any{
+		rs.writeJsonWithCounter(w, r, http.StatusUnprocessableEntity, postgres.ImportRequestsFailed, map[string]any{
 			"ok":    false,
 			"code":  "IMPORT_VALIDATION_FAILED",
 			"error": "No valid items in request",
@@ -99,7 +108,7 @@ func (rs *Server) handleImport(w http.ResponseWriter, r *http.Request) {
 		)
 		slog.Info(errMsg)
 
-		rs.writeJsonWithCounter(w, http.StatusUnprocessableEntity, postgres.ImportRequestsFailed, map[string]any{
+		rs.writeJsonWithCounter(w, r, http.StatusUnprocessableEntity, postgres.ImportRequestsFailed, map[string]any{
 			"ok":    false,
 			"code":  "IMPORT_PARTIAL",
 			"error": "Some items were rejected",
@@ -116,15 +125,26 @@ func (rs *Server) handleImport(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !rs.admitImport(w, r, len(validItems)) {
+		return
+	}
+
 	slog.Info("Queueing " + fmt.Sprintf("%d", len(validItems)) + " item for processing")
 
-	ctx, cancel := rs.opCtx()
+	importID := globalTypes.NewImportID()
+	traceParent := tracing.TraceParent(r.Context())
+	for _, item := range validItems {
+		item.ImportID = importID
+		item.TraceParent = traceParent
+	}
+
+	ctx, cancel := rs.opCtx(r)
 	err := rs.postgres.UpsertBaseBatch(ctx, validItems)
 	cancel()
 
 	if err != nil {
 		slog.Error("Error after Batch inserting audio files into DB: " + err.Error())
-		rs.writeJsonWithCounter(w, http.StatusInternalServerError, postgres.ImportRequestsFailed, map[string]any{
+		rs.writeJsonWithCounter(w, r, http.StatusInternalServerError, postgres.ImportRequestsFailed, map[string]any{
 			"ok":    false,
 			"code":  "COULD_NOT_QUEUE_IMPORT",
 			"error": "Internal Server Error: Failed to queue items for processing",
@@ -139,8 +159,9 @@ func (rs *Server) handleImport(w http.ResponseWriter, r *http.Request) {
 	slog.Debug("Finished handling import request, all items are valid and queued for processing")
 
 	// alles valid -> 200
-	rs.writeJsonWithCounter(w, http.StatusOK, postgres.ImportRequestsSuccessful, map[string]any{
-		"ok": true,
+	rs.writeJsonWithCounter(w, r, http.StatusOK, postgres.ImportRequestsSuccessful, map[string]any{
+		"ok":        true,
+		"import_id": importID,
 		"imported": map[string]any{
 			"count": len(validItems),
 		},
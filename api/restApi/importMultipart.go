@@ -0,0 +1,327 @@
+package restApi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go_audio_search_api_server/globalTypes"
+	"go_audio_search_api_server/postgres"
+	"go_audio_search_api_server/storage"
+	"go_audio_search_api_server/tracing"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"strings"
+)
+
+// 20 GiB, the files are streamed to disk so this only bounds the disk usage of one request
+const maxMultipartBody = 20 * (1 << 30)
+
+// 10 MiB
+const maxMetadataPart = 10 * (1 << 20)
+
+// handleMultipartImport imports uploaded files. The first part is named "metadata" and holds a JSON array with one
+// AudioDataElement per file, the following parts are the files in the same order. Every file is hashed while it is
+// streamed to disk and queued directly at StageFilePersisted, so it never passes through Postgres. Files that are
+// already imported are reported as duplicates and keep their pipeline state.
+func (rs *Server) handleMultipartImport(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxMultipartBody)
+	defer r.Body.Close()
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		rs.writeJsonWithCounter(w, r, http.StatusBadRequest, postgres.ImportRequestsFailed, map[string]any{
+			"ok":    false,
+			"code":  "IMPORT_BAD_MULTIPART",
+			"error": err.Error(),
+		})
+		return
+	}
+
+	// 1) Metadata part muss zuerst kommen, damit vor dem Schreiben der Dateien validiert werden kann
+	part, err := mr.NextPart()
+	if err != nil || part.FormName() != "metadata" {
+		rs.writeJsonWithCounter(w, r, http.StatusBadRequest, postgres.ImportRequestsFailed, map[string]any{
+			"ok":    false,
+			"code":  "IMPORT_METADATA_MISSING",
+			"error": "The first multipart part must be named \"metadata\" and contain a JSON array",
+		})
+		return
+	}
+
+	items, err := readMetadataPart(part)
+	_ = part.Close()
+	if err != nil {
+		rs.writeJsonWithCounter(w, r, http.StatusBadRequest, postgres.ImportRequestsFailed, map[string]any{
+			"ok":    false,
+			"code":  "IMPORT_BAD_JSON",
+			"error": err.Error(),
+		})
+		return
+	}
+
+	var invalidItemsIdx []int
+	var invalidItemsErr []string
+
+	for idx, item := range items {
+		if err := item.ValidateUploadInput(); err != nil {
+			invalidItemsIdx = append(invalidItemsIdx, idx)
+			invalidItemsErr = append(invalidItemsErr, err.Error())
+		}
+	}
+
+	if len(invalidItemsIdx) > 0 {
+		code := "IMPORT_PARTIAL"
+		if len(invalidItemsIdx) == len(items) {
+			code = "IMPORT_VALIDATION_FAILED"
+		}
+
+		rs.writeJsonWithCounter(w, r, http.StatusUnprocessableEntity, postgres.ImportRequestsFailed, map[string]any{
+			"ok":    false,
+			"code":  code,
+			"error": "Some items were rejected, no file was stored",
+			"invalid": map[string]any{
+				"count":   len(invalidItemsIdx),
+				"indexes": invalidItemsIdx,
+				"errors":  invalidItemsErr,
+			},
+		})
+		return
+	}
+
+	// the files are only streamed when they fit into the queue of the api key
+	if !rs.admitImport(w, r, len(items)) {
+		return
+	}
+
+	// 2) Dateien streamen
+	var createdKeys []string
+	cleanup := func() {
+		for _, key := range createdKeys {
+			ctx, cancel := rs.opCtx(r)
+			err := rs.storage.Delete(ctx, key)
+			cancel()
+
+			if err != nil {
+				slog.Error("Error while removing uploaded file after failed import", "key", key, "err", err)
+			}
+		}
+	}
+
+	fileCount := 0
+	for {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			cleanup()
+
+			status, code := http.StatusBadRequest, "IMPORT_BAD_MULTIPART"
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				status, code = http.StatusRequestEntityTooLarge, "IMPORT_PAYLOAD_TOO_LARGE"
+			}
+
+			rs.writeJsonWithCounter(w, r, status, postgres.ImportRequestsFailed, map[string]any{
+				"ok":    false,
+				"code":  code,
+				"error": err.Error(),
+				"limit": maxMultipartBody,
+			})
+			return
+		}
+
+		if part.FileName() == "" {
+			_ = part.Close()
+			continue
+		}
+
+		if fileCount >= len(items) {
+			_ = part.Close()
+			cleanup()
+			rs.writeJsonWithCounter(w, r, http.StatusUnprocessableEntity, postgres.ImportRequestsFailed, map[string]any{
+				"ok":    false,
+				"code":  "IMPORT_FILE_COUNT_MISMATCH",
+				"error": fmt.Sprintf("Received more files than the %d metadata items", len(items)),
+			})
+			return
+		}
+
+		key, hash, created, err := storage.StoreAudio(r.Context(), rs.storage, requestWorkspace(r), part)
+		_ = part.Close()
+
+		if err != nil {
+			cleanup()
+
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				rs.writeJsonWithCounter(w, r, http.StatusRequestEntityTooLarge, postgres.ImportRequestsFailed, map[string]any{
+					"ok":    false,
+					"code":  "IMPORT_PAYLOAD_TOO_LARGE",
+					"error": "Request body too large",
+					"limit": maxMultipartBody,
+				})
+				return
+			}
+
+			slog.Error("Error while storing uploaded file: " + err.Error())
+			rs.writeJsonWithCounter(w, r, http.StatusInternalServerError, postgres.ImportRequestsFailed, map[string]any{
+				"ok":    false,
+				"code":  "IMPORT_FILE_WRITE_FAILED",
+				"error": "Internal Server Error: Failed to store uploaded file " + part.FileName(),
+			})
+			return
+		}
+
+		// files that already existed belong to an earlier import and must survive a failed request
+		if created {
+			createdKeys = append(createdKeys, key)
+		}
+
+		item := items[fileCount]
+		item.WorkspaceID = requestWorkspace(r)
+		item.Owner = requestViewer(r).User
+		item.ApiKeyID = requestKeyID(r)
+		item.AudiofileHash = hash
+		item.DownloadPath = key
+		item.LastSuccessfulStage = globalTypes.StageFilePersisted
+
+		fileCount++
+	}
+
+	if fileCount != len(items) {
+		cleanup()
+		rs.writeJsonWithCounter(w, r, http.StatusUnprocessableEntity, postgres.ImportRequestsFailed, map[string]any{
+			"ok":    false,
+			"code":  "IMPORT_FILE_COUNT_MISMATCH",
+			"error": fmt.Sprintf("Received %d files for %d metadata items", fileCount, len(items)),
+		})
+		return
+	}
+
+	// queueing a stored file again would restart its pipeline, so files that are already imported are only reported
+	var queued []*globalTypes.AudioDataElement
+	duplicates := []string{}
+	seen := map[string]bool{}
+
+	for _, item := range items {
+		if seen[item.AudiofileHash] {
+			duplicates = append(duplicates, item.AudiofileHash)
+			continue
+		}
+		seen[item.AudiofileHash] = true
+
+		ctx, cancel := rs.opCtx(r)
+		existing, lookupErr := rs.postgres.GetAudioDataByHash(ctx, item.AudiofileHash)
+		cancel()
+
+		if lookupErr != nil {
+			cleanup()
+			slog.Error("Error while checking uploaded audio file for duplicates: " + lookupErr.Error())
+			rs.writeJsonWithCounter(w, r, http.StatusInternalServerError, postgres.ImportRequestsFailed, map[string]any{
+				"ok":    false,
+				"code":  "COULD_NOT_QUEUE_IMPORT",
+				"error": "Internal Server Error: Failed to queue items for processing",
+			})
+			return
+		}
+
+		if existing == nil {
+			queued = append(queued, item)
+			continue
+		}
+
+		slog.Info("Uploaded file is already imported", "audioHash", item.AudiofileHash)
+		duplicates = append(duplicates, item.AudiofileHash)
+	}
+
+	slog.Info("Queueing " + fmt.Sprintf("%d", len(queued)) + " uploaded item for processing")
+
+	importID := globalTypes.NewImportID()
+	traceParent := tracing.TraceParent(r.Context())
+	for _, item := range queued {
+		item.ImportID = importID
+		item.TraceParent = traceParent
+	}
+
+	ctx, cancel := rs.opCtx(r)
+	err = rs.postgres.UpsertBaseBatch(ctx, queued)
+	cancel()
+
+	if err != nil {
+		cleanup()
+		slog.Error("Error after Batch inserting uploaded audio files into DB: " + err.Error())
+		rs.writeJsonWithCounter(w, r, http.StatusInternalServerError, postgres.ImportRequestsFailed, map[string]any{
+			"ok":    false,
+			"code":  "COULD_NOT_QUEUE_IMPORT",
+			"error": "Internal Server Error: Failed to queue items for processing",
+		})
+		return
+	}
+
+	rs.PoolRefillSignal.Trigger()
+
+	hashes := make([]string, len(queued))
+	for idx, item := range queued {
+		hashes[idx] = item.AudiofileHash
+	}
+
+	rs.writeJsonWithCounter(w, r, http.StatusOK, postgres.ImportRequestsSuccessful, map[string]any{
+		"ok":        true,
+		"import_id": importID,
+		"imported": map[string]any{
+			"count":  len(queued),
+			"hashes": hashes,
+		},
+		"duplicates": map[string]any{
+			"count":  len(duplicates),
+			"hashes": duplicates,
+		},
+	})
+}
+
+// readMetadataPart decodes the metadata part, a JSON array of AudioDataElement or a single object.
+func readMetadataPart(part *multipart.Part) ([]*globalTypes.AudioDataElement, error) {
+	raw, err := io.ReadAll(io.LimitReader(part, maxMetadataPart+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) > maxMetadataPart {
+		return nil, errors.New("metadata part too large")
+	}
+
+	raw = bytes.TrimSpace(raw)
+
+	var items []*globalTypes.AudioDataElement
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+
+	if strings.HasPrefix(string(raw), "{") {
+		var item globalTypes.AudioDataElement
+		if err := dec.Decode(&item); err != nil {
+			return nil, err
+		}
+		items = append(items, &item)
+	} else if err := dec.Decode(&items); err != nil {
+		return nil, err
+	}
+
+	if dec.More() {
+		return nil, errors.New("invalid json: multiple values")
+	}
+
+	if len(items) == 0 {
+		return nil, errors.New("metadata contains no items")
+	}
+
+	for idx, item := range items {
+		if item == nil {
+			return nil, fmt.Errorf("metadata item %d is null", idx)
+		}
+	}
+
+	return items, nil
+}
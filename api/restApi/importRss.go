@@ -0,0 +1,167 @@
+package restApi
+
+import (
+	"fmt"
+	"go_audio_search_api_server/feeds"
+	"go_audio_search_api_server/globalTypes"
+	"go_audio_search_api_server/postgres"
+	"go_audio_search_api_server/tracing"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// handleRssImport fetches a RSS 2.0 or Atom feed and queues the selected episodes like a JSON import with file_url.
+// Episodes whose enclosure cannot be downloaded are skipped instead of failing the whole feed.
+func (rs *Server) handleRssImport(w http.ResponseWriter, r *http.Request) {
+	slog.Info("Received request to /import/rss")
+
+	ct := r.Header.Get("Content-Type")
+	if !strings.HasPrefix(ct, "application/json") {
+		rs.writeJsonWithCounter(w, r, http.StatusUnsupportedMediaType, postgres.ImportRequestsFailed, map[string]any{
+			"ok":    false,
+			"code":  "IMPORT_UNSUPPORTED_CONTENT_TYPE",
+			"error": "Content-Type must be application/json",
+			"got":   ct,
+		})
+		return
+	}
+
+	var req globalTypes.FeedImportRequest
+	if err := ReadJSON(r, &req, 1<<20); err != nil {
+		rs.writeJsonWithCounter(w, r, http.StatusBadRequest, postgres.ImportRequestsFailed, map[string]any{
+			"ok":    false,
+			"code":  "IMPORT_BAD_JSON",
+			"error": err.Error(),
+		})
+		return
+	}
+
+	start, end, err := req.ValidateApiInput()
+	if err != nil {
+		rs.writeJsonWithCounter(w, r, http.StatusUnprocessableEntity, postgres.ImportRequestsFailed, map[string]any{
+			"ok":    false,
+			"code":  "IMPORT_VALIDATION_FAILED",
+			"error": err.Error(),
+		})
+		return
+	}
+
+	ctx, cancel := rs.opCtx(r)
+	feed, err := feeds.Fetch(ctx, strings.TrimSpace(req.FeedUrl))
+	cancel()
+
+	if err != nil {
+		slog.Info("Could not load feed", "feedUrl", req.FeedUrl, "err", err)
+		rs.writeJsonWithCounter(w, r, http.StatusBadGateway, postgres.ImportRequestsFailed, map[string]any{
+			"ok":    false,
+			"code":  "IMPORT_RSS_FETCH_FAILED",
+			"error": "Couldn't load RSS feed: " + err.Error(),
+		})
+		return
+	}
+
+	episodes := feeds.Select(feed.Episodes, feeds.Selection{Latest: req.Latest, Start: start, End: end})
+	if len(episodes) == 0 {
+		rs.writeJsonWithCounter(w, r, http.StatusUnprocessableEntity, postgres.ImportRequestsFailed, map[string]any{
+			"ok":    false,
+			"code":  "IMPORT_RSS_NO_EPISODES",
+			"error": "No episodes with an audio enclosure match the selection",
+			"feed": map[string]any{
+				"title":    feed.Title,
+				"episodes": len(feed.Episodes),
+			},
+		})
+		return
+	}
+
+	items := make([]*globalTypes.AudioDataElement, len(episodes))
+	for idx, episode := range episodes {
+		items[idx] = feeds.ToAudioData(feed, episode, feeds.Defaults{Category: req.Category, AudioType: req.AudioType})
+	}
+
+	validItems, skipped := rs.validateFeedItems(items, episodes, requestViewer(r), requestKeyID(r))
+
+	if len(validItems) == 0 {
+		rs.writeJsonWithCounter(w, r, http.StatusUnprocessableEntity, postgres.ImportRequestsFailed, map[string]any{
+			"ok":      false,
+			"code":    "IMPORT_VALIDATION_FAILED",
+			"error":   "No episode of the feed could be queued",
+			"skipped": skipped,
+		})
+		return
+	}
+
+	if !rs.admitImport(w, r, len(validItems)) {
+		return
+	}
+
+	slog.Info(fmt.Sprintf("Queueing %d episodes of feed %s for processing", len(validItems), feed.Title))
+
+	importID := globalTypes.NewImportID()
+	traceParent := tracing.TraceParent(r.Context())
+	for _, item := range validItems {
+		item.ImportID = importID
+		item.TraceParent = traceParent
+	}
+
+	ctx, cancel = rs.opCtx(r)
+	err = rs.postgres.UpsertBaseBatch(ctx, validItems)
+	cancel()
+
+	if err != nil {
+		slog.Error("Error after Batch inserting feed episodes into DB: " + err.Error())
+		rs.writeJsonWithCounter(w, r, http.StatusInternalServerError, postgres.ImportRequestsFailed, map[string]any{
+			"ok":    false,
+			"code":  "COULD_NOT_QUEUE_IMPORT",
+			"error": "Internal Server Error: Failed to queue items for processing",
+		})
+		return
+	}
+
+	rs.PoolRefillSignal.Trigger()
+
+	rs.writeJsonWithCounter(w, r, http.StatusOK, postgres.ImportRequestsSuccessful, map[string]any{
+		"ok":        true,
+		"import_id": importID,
+		"feed": map[string]any{
+			"title":    feed.Title,
+			"episodes": len(feed.Episodes),
+		},
+		"imported": map[string]any{
+			"count": len(validItems),
+		},
+		"skipped": skipped,
+	})
+}
+
+// validateFeedItems runs the import validation for every episode and prepares the valid ones for queueing
+func (rs *Server) validateFeedItems(items []*globalTypes.AudioDataElement, episodes []feeds.Episode, viewer globalTypes.Viewer, apiKeyID int64) ([]*globalTypes.AudioDataElement, map[string]any) {
+	errs := feeds.ValidateItems(items)
+
+	var validItems []*globalTypes.AudioDataElement
+	skippedTitles := []string{}
+	skippedErrors := []string{}
+
+	for idx, item := range items {
+		if errs[idx] != nil {
+			slog.Info("Skipping feed episode", "guid", episodes[idx].Guid, "err", errs[idx])
+			skippedTitles = append(skippedTitles, item.Title)
+			skippedErrors = append(skippedErrors, errs[idx].Error())
+			continue
+		}
+
+		item.WorkspaceID = viewer.WorkspaceID
+		item.Owner = viewer.User
+		item.ApiKeyID = apiKeyID
+		item.AudiofileHash = item.GetTmpHash()
+		item.LastSuccessfulStage = globalTypes.StageQueued
+		validItems = append(validItems, item)
+	}
+
+	return validItems, map[string]any{
+		"count":  len(skippedTitles),
+		"titles": skippedTitles,
+		"errors": skippedErrors,
+	}
+}
@@ -0,0 +1,79 @@
+package restApi
+
+import (
+	"go_audio_search_api_server/metrics"
+	"go_audio_search_api_server/tracing"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// statusRecorder remembers the status a handler answered with
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sr *statusRecorder) WriteHeader(status int) {
+	if sr.status == 0 {
+		sr.status = status
+	}
+	sr.ResponseWriter.WriteHeader(status)
+}
+
+func (sr *statusRecorder) Write(b []byte) (int, error) {
+	if sr.status == 0 {
+		sr.status = http.StatusOK
+	}
+	return sr.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach Flush of the underlying writer, the event stream depends on it
+func (sr *statusRecorder) Unwrap() http.ResponseWriter {
+	return sr.ResponseWriter
+}
+
+// instrument traces every request as a server span, continuing the trace of a traceparent header, and counts the
+// requests and their durations. Both use the route pattern the mux matched and not the raw path, so the number of
+// span names and series stays bounded by the routes.
+func instrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		ctx, span := tracing.StartServer(r.Context(), r.Header, r.Method)
+		defer span.End()
+
+		// the mux sets the pattern on the request it is given, so r is read again after ServeHTTP
+		r = r.WithContext(ctx)
+
+		sr := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(sr, r)
+
+		route := "unmatched"
+		if r.Pattern != "" {
+			_, path, _ := strings.Cut(r.Pattern, " ")
+			route = path
+		}
+		if sr.status == 0 {
+			sr.status = http.StatusOK
+		}
+
+		span.SetName(r.Method + " " + route)
+		span.SetAttributes(
+			attribute.String("http.request.method", r.Method),
+			attribute.String("http.route", route),
+			attribute.Int("http.response.status_code", sr.status),
+		)
+		if sr.status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(sr.status))
+		}
+
+		status := strconv.Itoa(sr.status)
+		metrics.HttpRequests.Inc(route, r.Method, status)
+		metrics.HttpRequestDuration.Observe(time.Since(start).Seconds(), route, r.Method, status)
+	})
+}
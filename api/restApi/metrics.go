@@ -0,0 +1,37 @@
+package restApi
+
+import (
+	"go_audio_search_api_server/globalTypes"
+	"go_audio_search_api_server/metrics"
+	"log/slog"
+	"net/http"
+)
+
+// queueStages are the stages an audio file waits in, they are reported even when no audio file is waiting
+func queueStages() []globalTypes.ProcessingStage {
+	var stages []globalTypes.ProcessingStage
+	for stage := globalTypes.StageQueued; stage < globalTypes.FinalStage(); stage = stage.Next() {
+		stages = append(stages, stage)
+	}
+	return stages
+}
+
+// handleMetrics writes the metrics in the Prometheus text format. The queue depth is read from Postgres, if that
+// fails the last known values are written.
+func (rs *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := rs.opCtx(r)
+	counts, err := rs.postgres.CountQueuedByStage(ctx, nil)
+	cancel()
+
+	if err != nil {
+		slog.Error("Error while counting the queue for metrics", "err", err)
+	} else {
+		for _, stage := range queueStages() {
+			metrics.QueueDepth.Set(float64(counts[stage]), stage.Name())
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	metrics.WriteText(w)
+}
@@ -0,0 +1,320 @@
+package restApi
+
+import (
+	_ "embed"
+	"go_audio_search_api_server/globalTypes"
+	"net/http"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//go:embed docs.html
+var docsPage []byte
+
+var pathParamPattern = regexp.MustCompile(`\{([^}]+)\}`)
+
+var (
+	openAPIOnce sync.Once
+	openAPIDoc  map[string]any
+)
+
+// scopeErrors are the answers of requireScope, every scoped route documents them in addition to its own errors
+func scopeErrors(scope string) []apiError {
+	limited := []string{"RATE_LIMITED"}
+	if scope == globalTypes.ScopeSearch {
+		limited = append(limited, "SEARCH_RATE_LIMITED")
+	}
+	return []apiError{
+		errs(http.StatusUnauthorized, "AUTH_REQUIRED", "AUTH_INVALID_KEY"),
+		errs(http.StatusForbidden, "AUTH_MISSING_SCOPE"),
+		errs(http.StatusTooManyRequests, limited...),
+		errs(http.StatusInternalServerError, "AUTH_FAILED"),
+	}
+}
+
+func (rs *Server) handleOpenAPI(w http.ResponseWriter, _ *http.Request) {
+	// the routes do not change at runtime, so the document is built once
+	openAPIOnce.Do(func() {
+		openAPIDoc = buildOpenAPI(rs.routes())
+	})
+	rs.writeJson(w, http.StatusOK, openAPIDoc)
+}
+
+func (rs *Server) handleDocs(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(docsPage)
+}
+
+// buildOpenAPI describes the routes as an OpenAPI 3 document. The schemas of the bodies are derived from the Go types
+// by their json tags, named structs end up in components.schemas.
+func buildOpenAPI(routes []apiRoute) map[string]any {
+	schemas := schemaBuilder{components: map[string]any{}}
+	schemas.components["Error"] = map[string]any{
+		"type":     "object",
+		"required": []string{"ok", "code", "error"},
+		"properties": map[string]any{
+			"ok":    map[string]any{"type": "boolean", "enum": []bool{false}},
+			"code":  map[string]any{"type": "string", "description": "Stable error code, see the codes of the response"},
+			"error": map[string]any{"type": "string", "description": "Human readable message"},
+		},
+	}
+
+	paths := map[string]any{}
+	for _, route := range routes {
+		item, ok := paths[route.Path].(map[string]any)
+		if !ok {
+			item = map[string]any{}
+			paths[route.Path] = item
+		}
+		item[strings.ToLower(route.Method)] = schemas.operation(route)
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":       "Audio Search API",
+			"version":     "1.0.0",
+			"description": "Import, transcribe and search audio files. Errors are answered with {\"ok\": false, \"code\", \"error\"}.",
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": schemas.components,
+			"securitySchemes": map[string]any{
+				"bearer":      map[string]any{"type": "http", "scheme": "bearer"},
+				"apiKey":      map[string]any{"type": "apiKey", "in": "header", "name": "X-API-Key"},
+				"apiKeyQuery": map[string]any{"type": "apiKey", "in": "query", "name": "api_key"},
+			},
+		},
+	}
+}
+
+func (s *schemaBuilder) operation(route apiRoute) map[string]any {
+	op := map[string]any{
+		"tags":        []string{route.Tag},
+		"summary":     route.Summary,
+		"operationId": strings.ToLower(route.Method) + operationName(route.Path),
+	}
+
+	var params []any
+	for _, match := range pathParamPattern.FindAllStringSubmatch(route.Path, -1) {
+		params = append(params, map[string]any{
+			"in":       "path",
+			"name":     match[1],
+			"required": true,
+			"schema":   map[string]any{"type": "string"},
+		})
+	}
+	for _, p := range route.Params {
+		params = append(params, map[string]any{
+			"in":          p.In,
+			"name":        p.Name,
+			"description": p.Description,
+			"required":    p.Required,
+			"schema":      s.schema(reflect.TypeOf(p.Example)),
+		})
+	}
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+
+	if len(route.Body) > 0 {
+		op["requestBody"] = map[string]any{"required": true, "content": s.content(route.Body)}
+	}
+
+	responses := map[string]any{}
+	for _, res := range route.Responses {
+		response := map[string]any{"description": res.Description}
+		if len(res.Content) > 0 {
+			response["content"] = s.content(res.Content)
+		}
+		if len(res.Headers) > 0 {
+			headers := map[string]any{}
+			for _, h := range res.Headers {
+				headers[h] = map[string]any{"schema": map[string]any{"type": "string"}}
+			}
+			response["headers"] = headers
+		}
+		responses[strconv.Itoa(res.Status)] = response
+	}
+
+	errors := route.Errors
+	if route.Scope != "" {
+		errors = append(slices.Clone(errors), scopeErrors(route.Scope)...)
+		op["security"] = []any{
+			map[string]any{"bearer": []string{}},
+			map[string]any{"apiKey": []string{}},
+			map[string]any{"apiKeyQuery": []string{}},
+		}
+		op["x-required-scope"] = route.Scope
+	} else {
+		op["security"] = []any{}
+	}
+
+	for status, codes := range errorCodesByStatus(errors) {
+		key := strconv.Itoa(status)
+		if _, taken := responses[key]; taken {
+			// a status with a success body and error codes, e.g. 409 of /search, keeps the success body
+			continue
+		}
+		responses[key] = map[string]any{
+			"description": strings.Join(codes, ", "),
+			"content": map[string]any{
+				"application/json": map[string]any{
+					"schema": map[string]any{
+						"allOf": []any{
+							map[string]any{"$ref": "#/components/schemas/Error"},
+							map[string]any{"properties": map[string]any{
+								"code": map[string]any{"type": "string", "enum": codes},
+							}},
+						},
+					},
+				},
+			},
+		}
+	}
+	op["responses"] = responses
+
+	return op
+}
+
+func (s *schemaBuilder) content(contents []apiContent) map[string]any {
+	out := map[string]any{}
+	for _, c := range contents {
+		if c.Schema == nil {
+			out[c.ContentType] = map[string]any{"schema": map[string]any{"type": "string", "format": "binary"}}
+			continue
+		}
+		out[c.ContentType] = map[string]any{"schema": s.value(c.Schema)}
+	}
+	return out
+}
+
+// errorCodesByStatus merges the codes of all errors with the same status, sorted for a stable document
+func errorCodesByStatus(errors []apiError) map[int][]string {
+	byStatus := map[int][]string{}
+	for _, e := range errors {
+		for _, code := range e.Codes {
+			if !slices.Contains(byStatus[e.Status], code) {
+				byStatus[e.Status] = append(byStatus[e.Status], code)
+			}
+		}
+	}
+	for _, codes := range byStatus {
+		sort.Strings(codes)
+	}
+	return byStatus
+}
+
+// operationName turns /audio/{hash}/file into AudioHashFile
+func operationName(path string) string {
+	var b strings.Builder
+	for _, part := range strings.FieldsFunc(path, func(r rune) bool { return r == '/' || r == '{' || r == '}' || r == '.' }) {
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
+
+// schemaBuilder derives JSON schemas from Go types and collects the named structs in components
+type schemaBuilder struct {
+	components map[string]any
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// value is the schema of an example value, a jsonObject is described by the types of its values
+func (s *schemaBuilder) value(v any) map[string]any {
+	obj, ok := v.(jsonObject)
+	if !ok {
+		return s.schema(reflect.TypeOf(v))
+	}
+
+	properties := map[string]any{}
+	for name, field := range obj {
+		properties[name] = s.value(field)
+	}
+	return map[string]any{"type": "object", "properties": properties}
+}
+
+func (s *schemaBuilder) schema(t reflect.Type) map[string]any {
+	if t == nil {
+		return map[string]any{}
+	}
+
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.Pointer:
+		inner := s.schema(t.Elem())
+		if _, isRef := inner["$ref"]; isRef {
+			return map[string]any{"allOf": []any{inner}, "nullable": true}
+		}
+		inner["nullable"] = true
+		return inner
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		return map[string]any{"type": "string", "format": "byte"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": s.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": s.schema(t.Elem())}
+	case reflect.Struct:
+		return s.structRef(t)
+	default:
+		return map[string]any{}
+	}
+}
+
+// structRef adds the struct to components once and refers to it, the placeholder ends recursion on nested types
+func (s *schemaBuilder) structRef(t reflect.Type) map[string]any {
+	name := t.Name()
+	if name == "" {
+		return s.structSchema(t)
+	}
+
+	ref := map[string]any{"$ref": "#/components/schemas/" + name}
+	if _, done := s.components[name]; done {
+		return ref
+	}
+
+	s.components[name] = map[string]any{}
+	s.components[name] = s.structSchema(t)
+	return ref
+}
+
+func (s *schemaBuilder) structSchema(t reflect.Type) map[string]any {
+	properties := map[string]any{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = s.schema(field.Type)
+	}
+	return map[string]any{"type": "object", "properties": properties}
+}
@@ -0,0 +1,216 @@
+package restApi
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go_audio_search_api_server/globalTypes"
+	"net/http"
+	"reflect"
+	"regexp"
+	"runtime"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var errorCodePattern = regexp.MustCompile(`^[A-Z]+(_[A-Z0-9]+)+$`)
+
+// funcFacts is what a function of the package writes by itself, without the functions it calls
+type funcFacts struct {
+	codes      []string
+	pathValues []string
+	callees    []string
+}
+
+// the route table and the spec builder mention every code and handler, they are not followed from a handler
+var specFiles = []string{"routes.go", "openapi.go"}
+
+// parsePackage reads the functions of the package source, methods are keyed by their name only
+func parsePackage(t *testing.T) (map[string]*funcFacts, map[string]string) {
+	t.Helper()
+
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, ".", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	facts := map[string]*funcFacts{}
+	fileOf := map[string]string{}
+
+	for _, pkg := range pkgs {
+		for path, file := range pkg.Files {
+			if strings.HasSuffix(path, "_test.go") {
+				continue
+			}
+			for _, decl := range file.Decls {
+				fn, ok := decl.(*ast.FuncDecl)
+				if !ok || fn.Body == nil {
+					continue
+				}
+				name := fn.Name.Name
+				f := facts[name]
+				if f == nil {
+					f = &funcFacts{}
+					facts[name] = f
+				}
+				fileOf[name] = path
+
+				ast.Inspect(fn.Body, func(n ast.Node) bool {
+					switch n := n.(type) {
+					case *ast.BasicLit:
+						if n.Kind == token.STRING {
+							if s, err := strconv.Unquote(n.Value); err == nil && errorCodePattern.MatchString(s) {
+								f.codes = append(f.codes, s)
+							}
+						}
+					case *ast.Ident:
+						f.callees = append(f.callees, n.Name)
+					case *ast.SelectorExpr:
+						f.callees = append(f.callees, n.Sel.Name)
+					case *ast.CallExpr:
+						sel, ok := n.Fun.(*ast.SelectorExpr)
+						if ok && sel.Sel.Name == "PathValue" && len(n.Args) == 1 {
+							if lit, ok := n.Args[0].(*ast.BasicLit); ok {
+								s, _ := strconv.Unquote(lit.Value)
+								f.pathValues = append(f.pathValues, s)
+							}
+						}
+					}
+					return true
+				})
+			}
+		}
+	}
+
+	return facts, fileOf
+}
+
+// reachable collects the codes and path values of the function and everything it calls in the package
+func reachable(root string, facts map[string]*funcFacts, fileOf map[string]string) (codes []string, pathValues []string) {
+	seen := map[string]bool{}
+	var walk func(name string)
+	walk = func(name string) {
+		f := facts[name]
+		if f == nil || seen[name] {
+			return
+		}
+		seen[name] = true
+		if name != root && slices.ContainsFunc(specFiles, func(s string) bool { return strings.HasSuffix(fileOf[name], s) }) {
+			return
+		}
+		codes = append(codes, f.codes...)
+		pathValues = append(pathValues, f.pathValues...)
+		for _, callee := range f.callees {
+			walk(callee)
+		}
+	}
+	walk(root)
+	return unique(codes), unique(pathValues)
+}
+
+func unique(list []string) []string {
+	sort.Strings(list)
+	return slices.Compact(list)
+}
+
+func documentedCodes(errors []apiError) []string {
+	var codes []string
+	for _, e := range errors {
+		codes = append(codes, e.Codes...)
+	}
+	return unique(codes)
+}
+
+// handlerName is the method name of a method value like rs.handleSearch
+func handlerName(h http.HandlerFunc) string {
+	name := runtime.FuncForPC(reflect.ValueOf(h).Pointer()).Name()
+	name = strings.TrimSuffix(name, "-fm")
+	return name[strings.LastIndex(name, ".")+1:]
+}
+
+// TestRoutesMatchHandlers fails when a handler answers with an error code the route does not document, documents
+// a code the handler no longer writes, or reads a path value that is not part of the path.
+func TestRoutesMatchHandlers(t *testing.T) {
+	facts, fileOf := parsePackage(t)
+	rs := &Server{}
+
+	for _, route := range rs.routes() {
+		name := handlerName(route.Handler)
+		if facts[name] == nil {
+			t.Errorf("%s %s: handler %s not found in the package source", route.Method, route.Path, name)
+			continue
+		}
+
+		codes, pathValues := reachable(name, facts, fileOf)
+		documented := documentedCodes(route.Errors)
+
+		for _, code := range codes {
+			if !slices.Contains(documented, code) {
+				t.Errorf("%s %s: %s writes %s, add it to the errors of the route", route.Method, route.Path, name, code)
+			}
+		}
+		for _, code := range documented {
+			if !slices.Contains(codes, code) {
+				t.Errorf("%s %s: %s is documented but never written by %s", route.Method, route.Path, code, name)
+			}
+		}
+
+		for _, value := range pathValues {
+			if !strings.Contains(route.Path, "{"+value+"}") {
+				t.Errorf("%s %s: %s reads the path value %q that is not part of the path", route.Method, route.Path, name, value)
+			}
+		}
+	}
+}
+
+func TestScopeErrorsMatchRequireScope(t *testing.T) {
+	facts, fileOf := parsePackage(t)
+
+	codes, _ := reachable("requireScope", facts, fileOf)
+	documented := documentedCodes(scopeErrors(globalTypes.ScopeSearch))
+
+	if !slices.Equal(codes, documented) {
+		t.Errorf("requireScope writes %v, scopeErrors documents %v", codes, documented)
+	}
+}
+
+// TestOpenAPIDocument checks that every route is part of the document and every schema reference resolves
+func TestOpenAPIDocument(t *testing.T) {
+	routes := (&Server{}).routes()
+
+	raw, err := json.Marshal(buildOpenAPI(routes))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var doc struct {
+		Paths      map[string]map[string]json.RawMessage `json:"paths"`
+		Components struct {
+			Schemas map[string]json.RawMessage `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	for _, route := range routes {
+		// panics on duplicate or conflicting patterns, like NewRestServer would
+		mux.HandleFunc(route.Method+" "+route.Path, route.Handler)
+
+		if _, ok := doc.Paths[route.Path][strings.ToLower(route.Method)]; !ok {
+			t.Errorf("%s %s is missing in the document", route.Method, route.Path)
+		}
+	}
+
+	for _, match := range regexp.MustCompile(`"#/components/schemas/([^"]+)"`).FindAllStringSubmatch(string(raw), -1) {
+		if _, ok := doc.Components.Schemas[match[1]]; !ok {
+			t.Errorf("schema %s is referenced but not defined", match[1])
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package restApi
+
+import (
+	"fmt"
+	"go_audio_search_api_server/globalTypes"
+	"go_audio_search_api_server/postgres"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// idle buckets are full again and can be dropped, this bounds the memory used by clients that went away
+const rateLimitSweepInterval = 10 * time.Minute
+
+// importQueueRetryAfter is a hint in seconds, the queue drains at the pace of the pipeline
+const importQueueRetryAfter = 60
+
+// tokenBucket holds the tokens of one client, it is refilled lazily on every take
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter is a token bucket per client that allows perMinute requests with bursts up to perMinute.
+// The buckets live in memory, so every instance of the api enforces the limit on its own.
+type rateLimiter struct {
+	perMinute int
+
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+// newRateLimiter returns nil for perMinute <= 0, a nil rateLimiter allows everything
+func newRateLimiter(perMinute int) *rateLimiter {
+	if perMinute <= 0 {
+		return nil
+	}
+	return &rateLimiter{
+		perMinute: perMinute,
+		buckets:   map[string]*tokenBucket{},
+		lastSweep: time.Now(),
+	}
+}
+
+// take removes one token of the client. It returns the tokens left and, if the bucket was empty,
+// how long the client has to wait for the next token.
+func (l *rateLimiter) take(client string, now time.Time) (remaining int, retryAfter time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	capacity := float64(l.perMinute)
+	perSecond := capacity / 60
+
+	if now.Sub(l.lastSweep) > rateLimitSweepInterval {
+		for id, b := range l.buckets {
+			if now.Sub(b.last).Seconds()*perSecond+b.tokens >= capacity {
+				delete(l.buckets, id)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	b, ok := l.buckets[client]
+	if !ok {
+		b = &tokenBucket{tokens: capacity, last: now}
+		l.buckets[client] = b
+	}
+
+	b.tokens = math.Min(capacity, b.tokens+now.Sub(b.last).Seconds()*perSecond)
+	b.last = now
+
+	if b.tokens < 1 {
+		return 0, time.Duration((1 - b.tokens) / perSecond * float64(time.Second))
+	}
+
+	b.tokens--
+	return int(b.tokens), 0
+}
+
+// allow takes a token of the client and reports the limit in the X-RateLimit headers.
+// An exhausted bucket is answered with 429 and Retry-After.
+func (l *rateLimiter) allow(rs *Server, w http.ResponseWriter, client string, code string) bool {
+	if l == nil {
+		return true
+	}
+
+	remaining, retryAfter := l.take(client, time.Now())
+
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(l.perMinute))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+
+	if retryAfter == 0 {
+		return true
+	}
+
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	rs.writeJson(w, http.StatusTooManyRequests, map[string]any{
+		"ok":          false,
+		"code":        code,
+		"error":       fmt.Sprintf("Rate limit of %d requests per minute exceeded, retry in %d seconds", l.perMinute, seconds),
+		"retry_after": seconds,
+	})
+	return false
+}
+
+// rateLimitClient identifies the client a request is counted for, the api key if there is one and else the ip
+func rateLimitClient(r *http.Request, apiKey *globalTypes.ApiKey) string {
+	if apiKey != nil {
+		return "key:" + strconv.FormatInt(apiKey.ID, 10)
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// allowRequest applies the request limit and, for searches, the tighter search limit.
+// The headers of the search limit replace those of the request limit, as it is the one that binds first.
+func (rs *Server) allowRequest(w http.ResponseWriter, r *http.Request, apiKey *globalTypes.ApiKey, scope string) bool {
+	client := rateLimitClient(r, apiKey)
+
+	if !rs.requestLimit.allow(rs, w, client, "RATE_LIMITED") {
+		return false
+	}
+	if scope == globalTypes.ScopeSearch && !rs.searchLimit.allow(rs, w, client, "SEARCH_RATE_LIMITED") {
+		return false
+	}
+	return true
+}
+
+// admitImport checks that queueing count more items keeps the api key within maxQueuedPerKey and reports the
+// queue limit in the X-Import-Queue headers. Items count until they completed or failed.
+func (rs *Server) admitImport(w http.ResponseWriter, r *http.Request, count int) bool {
+	key := requestKey(r)
+	if rs.maxQueuedPerKey <= 0 || key == nil {
+		return true
+	}
+
+	ctx, cancel := rs.opCtx(r)
+	queued, err := rs.postgres.CountQueuedByApiKey(ctx, key.ID)
+	cancel()
+
+	if err != nil {
+		slog.Error("Error while counting queued imports", "apiKeyId", key.ID, "err", err)
+		rs.writeJsonWithCounter(w, r, http.StatusInternalServerError, postgres.ImportRequestsFailed, map[string]any{
+			"ok":    false,
+			"code":  "IMPORT_QUEUE_CHECK_FAILED",
+			"error": "Internal Server Error: " + err.Error(),
+		})
+		return false
+	}
+
+	admitted := queued+count <= rs.maxQueuedPerKey
+	remaining := rs.maxQueuedPerKey - queued
+	if admitted {
+		remaining -= count
+	}
+
+	w.Header().Set("X-Import-Queue-Limit", strconv.Itoa(rs.maxQueuedPerKey))
+	w.Header().Set("X-Import-Queue-Remaining", strconv.Itoa(max(0, remaining)))
+
+	if admitted {
+		return true
+	}
+
+	slog.Info("Rejected import above the queue limit", "apiKeyId", key.ID, "queued", queued, "items", count)
+
+	w.Header().Set("Retry-After", strconv.Itoa(importQueueRetryAfter))
+	rs.writeJsonWithCounter(w, r, http.StatusTooManyRequests, postgres.ImportRequestsFailed, map[string]any{
+		"ok":    false,
+		"code":  "IMPORT_QUEUE_FULL",
+		"error": fmt.Sprintf("The api key has %d of at most %d items queued, %d more do not fit, retry once some are processed", queued, rs.maxQueuedPerKey, count),
+		"queue": map[string]any{
+			"queued": queued,
+			"limit":  rs.maxQueuedPerKey,
+		},
+	})
+	return false
+}
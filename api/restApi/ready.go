@@ -0,0 +1,158 @@
+package restApi
+
+import (
+	"context"
+	"fmt"
+	"go_audio_search_api_server/ai"
+	"go_audio_search_api_server/globalTypes"
+	"go_audio_search_api_server/postgres"
+	"go_audio_search_api_server/qdrant"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// readyCacheTTL is how long the results of the dependency checks are reused, so frequent probes of several
+// orchestrators do not turn into load on the dependencies
+const readyCacheTTL = 5 * time.Second
+
+// readyCheckTimeout bounds every single dependency check
+const readyCheckTimeout = 3 * time.Second
+
+// readiness checks the dependencies of the api and keeps the results for readyCacheTTL
+type readiness struct {
+	postgres *postgres.Worker
+	qdrant   *qdrant.Worker
+	embedder *ai.EmbeddingWorker
+	whisper  *ai.WhisperWorker
+	// llm is nil if the AI data stage is deactivated
+	llm *ai.LlmWorker
+
+	mu        sync.Mutex
+	checkedAt time.Time
+	checks    map[string]globalTypes.DependencyCheck
+}
+
+// check returns the cached results or runs all checks concurrently, concurrent callers wait for the same run
+func (rd *readiness) check(ctx context.Context) (map[string]globalTypes.DependencyCheck, time.Time) {
+	rd.mu.Lock()
+	defer rd.mu.Unlock()
+
+	if rd.checks != nil && time.Since(rd.checkedAt) < readyCacheTTL {
+		return rd.checks, rd.checkedAt
+	}
+
+	// each value is only written by its own check and read after all checks finished
+	var vectorSize, modelDimension uint64
+
+	probes := map[string]func(ctx context.Context) (string, error){
+		"postgres": func(ctx context.Context) (string, error) {
+			return "", rd.postgres.Ping(ctx)
+		},
+		"qdrant": func(ctx context.Context) (string, error) {
+			size, err := rd.qdrant.VectorSize(ctx)
+			vectorSize = size
+			return fmt.Sprintf("collection vector size %d", size), err
+		},
+		"ollama_embedding": func(ctx context.Context) (string, error) {
+			dimension, err := rd.embedder.Dimension(ctx)
+			modelDimension = dimension
+			return fmt.Sprintf("model %s, dimension %d", rd.embedder.Model(), dimension), err
+		},
+		"whisper": func(ctx context.Context) (string, error) {
+			return "", rd.whisper.Ping(ctx)
+		},
+	}
+	if rd.llm != nil {
+		probes["ollama_llm"] = func(ctx context.Context) (string, error) {
+			return "model " + rd.llm.Model(), rd.llm.CheckModel(ctx)
+		}
+	}
+
+	checks := make(map[string]globalTypes.DependencyCheck, len(probes)+2)
+	var checksMu sync.Mutex
+	var wg sync.WaitGroup
+
+	for name, probe := range probes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			probeCtx, cancel := context.WithTimeout(ctx, readyCheckTimeout)
+			defer cancel()
+
+			start := time.Now()
+			detail, err := probe(probeCtx)
+			result := globalTypes.DependencyCheck{Ok: err == nil, LatencyMs: time.Since(start).Milliseconds()}
+			if err != nil {
+				result.Error = err.Error()
+				slog.Warn("Readiness check failed", "check", name, "err", err)
+			} else {
+				result.Detail = detail
+			}
+
+			checksMu.Lock()
+			checks[name] = result
+			checksMu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if rd.llm == nil {
+		checks["ollama_llm"] = globalTypes.DependencyCheck{Ok: true, Skipped: true, Detail: "the AI data stage is deactivated"}
+	}
+
+	// the collection is created with EMBEDDING_MODEL_DIM, a different model would fail every upsert and search
+	switch {
+	case !checks["qdrant"].Ok || !checks["ollama_embedding"].Ok:
+		checks["embedding_dimension"] = globalTypes.DependencyCheck{Error: "needs the qdrant and ollama_embedding checks"}
+	case vectorSize != modelDimension:
+		checks["embedding_dimension"] = globalTypes.DependencyCheck{Error: fmt.Sprintf(
+			"the collection stores vectors of size %d but model %s embeds with dimension %d",
+			vectorSize, rd.embedder.Model(), modelDimension,
+		)}
+	default:
+		checks["embedding_dimension"] = globalTypes.DependencyCheck{Ok: true, Detail: fmt.Sprintf("%d", vectorSize)}
+	}
+
+	rd.checks = checks
+	rd.checkedAt = time.Now()
+
+	return rd.checks, rd.checkedAt
+}
+
+// handleReady reports whether the api can serve imports and searches, unlike /health it answers 503 as long as a
+// dependency is unreachable or misconfigured
+func (rs *Server) handleReady(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := rs.opCtx(r)
+	checks, checkedAt := rs.ready.check(ctx)
+	cancel()
+
+	var failed []string
+	for name, check := range checks {
+		if !check.Ok {
+			failed = append(failed, name)
+		}
+	}
+	slices.Sort(failed)
+
+	if len(failed) > 0 {
+		rs.writeJson(w, http.StatusServiceUnavailable, map[string]any{
+			"ok":         false,
+			"code":       "NOT_READY",
+			"error":      "Not ready, failed checks: " + strings.Join(failed, ", "),
+			"checks":     checks,
+			"checked_at": checkedAt,
+		})
+		return
+	}
+
+	rs.writeJson(w, http.StatusOK, map[string]any{
+		"ok":         true,
+		"checks":     checks,
+		"checked_at": checkedAt,
+	})
+}
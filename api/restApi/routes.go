@@ -0,0 +1,630 @@
+package restApi
+
+import (
+	"go_audio_search_api_server/globalTypes"
+	"go_audio_search_api_server/subtitles"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// apiRoute is one endpoint of the api. The routes register the handlers on the mux and describe the endpoints in the
+// OpenAPI document, so both are built from the same list. Scope is empty for public endpoints.
+type apiRoute struct {
+	Method    string
+	Path      string
+	Scope     string
+	Handler   http.HandlerFunc
+	Tag       string
+	Summary   string
+	Params    []apiParam
+	Body      []apiContent
+	Responses []apiResponse
+	Errors    []apiError
+}
+
+// apiParam is a query, header or path parameter, Example is a value of the parameter type
+type apiParam struct {
+	In          string
+	Name        string
+	Description string
+	Required    bool
+	Example     any
+}
+
+// apiContent is a body of the content type, Schema is a value of the Go type that is encoded.
+// A nil Schema is a binary or text body.
+type apiContent struct {
+	ContentType string
+	Schema      any
+}
+
+// apiResponse is a successful response of the endpoint
+type apiResponse struct {
+	Status      int
+	Description string
+	Headers     []string
+	Content     []apiContent
+}
+
+// apiError lists the error codes an endpoint answers with the status
+type apiError struct {
+	Status int
+	Codes  []string
+}
+
+// jsonObject describes an ad-hoc JSON object, the values are examples of the field types
+type jsonObject map[string]any
+
+func query(name string, description string, example any) apiParam {
+	return apiParam{In: "query", Name: name, Description: description, Example: example}
+}
+
+func header(name string, description string, required bool) apiParam {
+	return apiParam{In: "header", Name: name, Description: description, Required: required, Example: ""}
+}
+
+func jsonBody(schema any) []apiContent {
+	return []apiContent{{ContentType: "application/json", Schema: schema}}
+}
+
+// okJson is a 200 response with the fields next to "ok": true
+func okJson(status int, description string, fields jsonObject) apiResponse {
+	body := jsonObject{"ok": true}
+	for k, v := range fields {
+		body[k] = v
+	}
+	return apiResponse{Status: status, Description: description, Content: jsonBody(body)}
+}
+
+func errs(status int, codes ...string) apiError {
+	return apiError{Status: status, Codes: codes}
+}
+
+var audioFilterParams = []apiParam{
+	query("category", "Exact category", ""),
+	query("audio_type", "Exact audio type", ""),
+	query("stage", "Processing stage, e.g. queued, completed or failed", ""),
+	query("start_date", "Recording date from, ISO date", ""),
+	query("end_date", "Recording date before, ISO date", ""),
+	query("title", "Part of the title, case insensitive", ""),
+}
+
+var (
+	uploadErrors = []apiError{
+		errs(http.StatusBadRequest, "UPLOAD_BAD_CHECKSUM", "UPLOAD_BAD_CHECKSUM_ALGORITHM"),
+		errs(http.StatusNotFound, "UPLOAD_NOT_FOUND"),
+		errs(http.StatusConflict, "UPLOAD_OFFSET_MISMATCH"),
+		errs(http.StatusPreconditionFailed, "UPLOAD_UNSUPPORTED_VERSION"),
+		errs(http.StatusRequestEntityTooLarge, "UPLOAD_TOO_LARGE"),
+		errs(statusChecksumMismatch, "UPLOAD_CHECKSUM_MISMATCH"),
+		errs(http.StatusInternalServerError, "UPLOAD_FAILED"),
+	}
+	tusResumable = header("Tus-Resumable", "Protocol version, 1.0.0", true)
+)
+
+func (rs *Server) routes() []apiRoute {
+	return []apiRoute{
+		{
+			Method: "GET", Path: "/health", Handler: rs.handleHealth,
+			Tag: "System", Summary: "Liveness of the api",
+			Responses: []apiResponse{okJson(http.StatusOK, "The api is up", nil)},
+		},
+		{
+			Method: "GET", Path: "/ready", Handler: rs.handleReady,
+			Tag: "System", Summary: "Readiness, checks Postgres, Qdrant, the Ollama models, Whisper and the embedding dimension",
+			Responses: []apiResponse{okJson(http.StatusOK, "Every dependency is ready", jsonObject{
+				"checks":     map[string]globalTypes.DependencyCheck{},
+				"checked_at": time.Time{},
+			})},
+			Errors: []apiError{errs(http.StatusServiceUnavailable, "NOT_READY")},
+		},
+		{
+			Method: "GET", Path: "/openapi.json", Handler: rs.handleOpenAPI,
+			Tag: "System", Summary: "This OpenAPI document",
+			Responses: []apiResponse{{Status: http.StatusOK, Description: "OpenAPI 3 document", Content: jsonBody(jsonObject{})}},
+		},
+		{
+			Method: "GET", Path: "/docs", Handler: rs.handleDocs,
+			Tag: "System", Summary: "Interactive documentation of the api",
+			Responses: []apiResponse{{Status: http.StatusOK, Description: "HTML page", Content: []apiContent{{ContentType: "text/html"}}}},
+		},
+		{
+			Method: "GET", Path: "/stats", Scope: globalTypes.ScopeRead, Handler: rs.handleStats,
+			Tag: "System", Summary: "Request counters, queue, audio files, hours of audio, segments and vectors of the workspace",
+			Responses: []apiResponse{okJson(http.StatusOK, "The statistics", jsonObject{"stats": globalTypes.Stats{}})},
+			Errors:    []apiError{errs(http.StatusInternalServerError, "STATS_LOAD_FAILED")},
+		},
+		{
+			Method: "GET", Path: "/stats/timeseries", Scope: globalTypes.ScopeRead, Handler: rs.handleStatsTimeseries,
+			Tag: "System", Summary: "Imports, completions, failures and requests per hour",
+			Params: []apiParam{query("hours", "Hours to look back, 1 to 720, default 24", 0)},
+			Responses: []apiResponse{okJson(http.StatusOK, "One bucket per hour, oldest first", jsonObject{
+				"hours":   0,
+				"buckets": []globalTypes.StatsBucket{},
+			})},
+			Errors: []apiError{
+				errs(http.StatusBadRequest, "STATS_BAD_QUERY"),
+				errs(http.StatusInternalServerError, "STATS_LOAD_FAILED"),
+			},
+		},
+		{
+			Method: "GET", Path: "/metrics", Scope: globalTypes.ScopeMetrics, Handler: rs.handleMetrics,
+			Tag: "System", Summary: "Request, pipeline and model call metrics in the Prometheus text format",
+			Responses: []apiResponse{{
+				Status:      http.StatusOK,
+				Description: "Prometheus text exposition format 0.0.4",
+				Content:     []apiContent{{ContentType: "text/plain"}},
+			}},
+		},
+		{
+			Method: "GET", Path: "/events", Scope: globalTypes.ScopeRead, Handler: rs.handleEvents,
+			Tag: "Events", Summary: "Stream the lifecycle events of the audio files as Server-Sent Events",
+			Params: []apiParam{
+				query("import_id", "Only events of this import", ""),
+				query("category", "Only events of this category", ""),
+			},
+			Responses: []apiResponse{{
+				Status:      http.StatusOK,
+				Description: "Event stream, every data line is one AudioEvent",
+				Content:     []apiContent{{ContentType: "text/event-stream", Schema: globalTypes.AudioEvent{}}},
+			}},
+		},
+		{
+			Method: "GET", Path: "/auth/keys/current", Scope: globalTypes.ScopeRead, Handler: rs.handleCurrentApiKey,
+			Tag: "Auth", Summary: "The api key of the request",
+			Responses: []apiResponse{okJson(http.StatusOK, "The key", jsonObject{"key": globalTypes.ApiKey{}})},
+		},
+		{
+			Method: "GET", Path: "/auth/keys", Scope: globalTypes.ScopeAdmin, Handler: rs.handleListApiKeys,
+			Tag: "Auth", Summary: "List the api keys of the workspace, system keys list those of all workspaces",
+			Responses: []apiResponse{okJson(http.StatusOK, "The keys", jsonObject{"keys": []globalTypes.ApiKey{}})},
+			Errors:    []apiError{errs(http.StatusInternalServerError, "AUTH_KEY_LIST_FAILED")},
+		},
+		{
+			Method: "POST", Path: "/auth/keys", Scope: globalTypes.ScopeAdmin, Handler: rs.handleCreateApiKey,
+			Tag: "Auth", Summary: "Create an api key, the key is only part of this response",
+			Body:      jsonBody(globalTypes.ApiKeyRequest{}),
+			Responses: []apiResponse{okJson(http.StatusCreated, "The created key", jsonObject{"key": globalTypes.ApiKey{}})},
+			Errors: []apiError{
+				errs(http.StatusBadRequest, "AUTH_BAD_JSON"),
+				errs(http.StatusForbidden, "AUTH_FOREIGN_WORKSPACE", "AUTH_SCOPE_NOT_GRANTABLE"),
+				errs(http.StatusNotFound, "AUTH_KEY_NOT_FOUND"),
+				errs(http.StatusUnprocessableEntity, "AUTH_VALIDATION_FAILED", "AUTH_UNKNOWN_WORKSPACE"),
+				errs(http.StatusInternalServerError, "AUTH_KEY_CREATE_FAILED", "AUTH_KEY_UPDATE_FAILED"),
+			},
+		},
+		{
+			Method: "POST", Path: "/auth/keys/{id}/rotate", Scope: globalTypes.ScopeAdmin, Handler: rs.handleRotateApiKey,
+			Tag: "Auth", Summary: "Replace the key of an api key, the new key is only part of this response",
+			Responses: []apiResponse{okJson(http.StatusOK, "The rotated key", jsonObject{"key": globalTypes.ApiKey{}})},
+			Errors: []apiError{
+				errs(http.StatusBadRequest, "AUTH_BAD_KEY_ID"),
+				errs(http.StatusNotFound, "AUTH_KEY_NOT_FOUND"),
+				errs(http.StatusInternalServerError, "AUTH_KEY_UPDATE_FAILED"),
+			},
+		},
+		{
+			Method: "DELETE", Path: "/auth/keys/{id}", Scope: globalTypes.ScopeAdmin, Handler: rs.handleRevokeApiKey,
+			Tag: "Auth", Summary: "Revoke an api key",
+			Responses: []apiResponse{okJson(http.StatusOK, "The revoked key", jsonObject{"key": globalTypes.ApiKey{}})},
+			Errors: []apiError{
+				errs(http.StatusBadRequest, "AUTH_BAD_KEY_ID"),
+				errs(http.StatusNotFound, "AUTH_KEY_NOT_FOUND"),
+				errs(http.StatusInternalServerError, "AUTH_KEY_UPDATE_FAILED"),
+			},
+		},
+		{
+			Method: "GET", Path: "/workspaces", Scope: globalTypes.ScopeSystem, Handler: rs.handleListWorkspaces,
+			Tag: "Workspaces", Summary: "List all workspaces",
+			Responses: []apiResponse{okJson(http.StatusOK, "The workspaces", jsonObject{"workspaces": []globalTypes.Workspace{}})},
+			Errors:    []apiError{errs(http.StatusInternalServerError, "WORKSPACE_LIST_FAILED")},
+		},
+		{
+			Method: "POST", Path: "/workspaces", Scope: globalTypes.ScopeSystem, Handler: rs.handleCreateWorkspace,
+			Tag: "Workspaces", Summary: "Create an empty workspace",
+			Body:      jsonBody(globalTypes.WorkspaceRequest{}),
+			Responses: []apiResponse{okJson(http.StatusCreated, "The created workspace", jsonObject{"workspace": globalTypes.Workspace{}})},
+			Errors: []apiError{
+				errs(http.StatusBadRequest, "WORKSPACE_BAD_JSON"),
+				errs(http.StatusConflict, "WORKSPACE_EXISTS"),
+				errs(http.StatusUnprocessableEntity, "WORKSPACE_VALIDATION_FAILED"),
+				errs(http.StatusInternalServerError, "WORKSPACE_CREATE_FAILED"),
+			},
+		},
+		{
+			Method: "DELETE", Path: "/workspaces/{id}", Scope: globalTypes.ScopeSystem, Handler: rs.handleDeleteWorkspace,
+			Tag: "Workspaces", Summary: "Delete a workspace with its audio files, api keys, webhooks and feed subscriptions",
+			Responses: []apiResponse{okJson(http.StatusOK, "The workspace is deleted", jsonObject{
+				"workspace_id": "",
+				"deleted":      jsonObject{"count": 0},
+			})},
+			Errors: []apiError{
+				errs(http.StatusNotFound, "WORKSPACE_NOT_FOUND"),
+				errs(http.StatusConflict, "WORKSPACE_PROTECTED", "WORKSPACE_NOT_EMPTY", "WORKSPACE_DELETE_INCOMPLETE"),
+				errs(http.StatusInternalServerError, "WORKSPACE_DELETE_INCOMPLETE", "WORKSPACE_DELETE_FAILED"),
+			},
+		},
+		{
+			Method: "POST", Path: "/import", Scope: globalTypes.ScopeImport, Handler: rs.handleImport,
+			Tag: "Import", Summary: "Queue audio files by url or base64, or upload them as multipart/form-data",
+			Body: []apiContent{
+				{ContentType: "application/json", Schema: []globalTypes.AudioDataElement{}},
+				{ContentType: "multipart/form-data", Schema: jsonObject{
+					"metadata": []globalTypes.AudioDataElement{},
+					"file":     []byte{},
+				}},
+			},
+			Responses: []apiResponse{
+				{
+					Status:      http.StatusOK,
+					Description: "All items are queued, hashes and duplicates are only returned for multipart uploads",
+					Headers:     []string{"X-Import-Queue-Limit", "X-Import-Queue-Remaining"},
+					Content: jsonBody(jsonObject{
+						"ok":         true,
+						"import_id":  "",
+						"imported":   jsonObject{"count": 0, "hashes": []string{}},
+						"duplicates": jsonObject{"count": 0, "hashes": []string{}},
+					}),
+				},
+			},
+			Errors: []apiError{
+				errs(http.StatusBadRequest, "IMPORT_BAD_JSON", "IMPORT_BAD_MULTIPART", "IMPORT_METADATA_MISSING"),
+				errs(http.StatusRequestEntityTooLarge, "IMPORT_PAYLOAD_TOO_LARGE"),
+				errs(http.StatusUnsupportedMediaType, "IMPORT_UNSUPPORTED_CONTENT_TYPE"),
+				errs(http.StatusUnprocessableEntity, "IMPORT_VALIDATION_FAILED", "IMPORT_PARTIAL", "IMPORT_FILE_COUNT_MISMATCH"),
+				errs(http.StatusTooManyRequests, "IMPORT_QUEUE_FULL"),
+				errs(http.StatusInternalServerError, "COULD_NOT_QUEUE_IMPORT", "IMPORT_FILE_WRITE_FAILED", "IMPORT_QUEUE_CHECK_FAILED"),
+			},
+		},
+		{
+			Method: "POST", Path: "/import/rss", Scope: globalTypes.ScopeImport, Handler: rs.handleRssImport,
+			Tag: "Import", Summary: "Queue the episodes of a podcast feed",
+			Body: jsonBody(globalTypes.FeedImportRequest{}),
+			Responses: []apiResponse{
+				{
+					Status:      http.StatusOK,
+					Description: "The valid episodes are queued",
+					Headers:     []string{"X-Import-Queue-Limit", "X-Import-Queue-Remaining"},
+					Content: jsonBody(jsonObject{
+						"ok":        true,
+						"import_id": "",
+						"feed":      jsonObject{"title": "", "episodes": 0},
+						"imported":  jsonObject{"count": 0},
+						"skipped":   jsonObject{"count": 0, "titles": []string{}, "errors": []string{}},
+					}),
+				},
+			},
+			Errors: []apiError{
+				errs(http.StatusBadRequest, "IMPORT_BAD_JSON"),
+				errs(http.StatusUnsupportedMediaType, "IMPORT_UNSUPPORTED_CONTENT_TYPE"),
+				errs(http.StatusUnprocessableEntity, "IMPORT_VALIDATION_FAILED", "IMPORT_RSS_NO_EPISODES"),
+				errs(http.StatusTooManyRequests, "IMPORT_QUEUE_FULL"),
+				errs(http.StatusBadGateway, "IMPORT_RSS_FETCH_FAILED"),
+				errs(http.StatusInternalServerError, "COULD_NOT_QUEUE_IMPORT", "IMPORT_QUEUE_CHECK_FAILED"),
+			},
+		},
+		{
+			Method: "GET", Path: "/feeds/subscriptions", Scope: globalTypes.ScopeRead, Handler: rs.handleListFeedSubscriptions,
+			Tag: "Feeds", Summary: "List the feed subscriptions of the workspace",
+			Responses: []apiResponse{okJson(http.StatusOK, "The subscriptions", jsonObject{"subscriptions": []globalTypes.FeedSubscription{}})},
+			Errors:    []apiError{errs(http.StatusInternalServerError, "FEED_LIST_FAILED")},
+		},
+		{
+			Method: "POST", Path: "/feeds/subscriptions", Scope: globalTypes.ScopeImport, Handler: rs.handleCreateFeedSubscription,
+			Tag: "Feeds", Summary: "Subscribe to a feed, new episodes are imported by the feed poller",
+			Body:      jsonBody(globalTypes.FeedSubscriptionRequest{}),
+			Responses: []apiResponse{okJson(http.StatusCreated, "The subscription", jsonObject{"subscription": globalTypes.FeedSubscription{}})},
+			Errors: []apiError{
+				errs(http.StatusBadRequest, "FEED_BAD_JSON"),
+				errs(http.StatusConflict, "FEED_ALREADY_SUBSCRIBED"),
+				errs(http.StatusUnprocessableEntity, "FEED_VALIDATION_FAILED"),
+				errs(http.StatusInternalServerError, "FEED_CREATE_FAILED"),
+			},
+		},
+		{
+			Method: "GET", Path: "/feeds/subscriptions/{id}", Scope: globalTypes.ScopeRead, Handler: rs.handleGetFeedSubscription,
+			Tag: "Feeds", Summary: "Get a feed subscription",
+			Responses: []apiResponse{okJson(http.StatusOK, "The subscription", jsonObject{"subscription": globalTypes.FeedSubscription{}})},
+			Errors:    feedSubscriptionErrors(),
+		},
+		{
+			Method: "POST", Path: "/feeds/subscriptions/{id}/pause", Scope: globalTypes.ScopeImport, Handler: rs.handlePauseFeedSubscription,
+			Tag: "Feeds", Summary: "Stop polling a feed",
+			Responses: []apiResponse{okJson(http.StatusOK, "The subscription", jsonObject{"subscription": globalTypes.FeedSubscription{}})},
+			Errors:    feedSubscriptionErrors(),
+		},
+		{
+			Method: "POST", Path: "/feeds/subscriptions/{id}/resume", Scope: globalTypes.ScopeImport, Handler: rs.handleResumeFeedSubscription,
+			Tag: "Feeds", Summary: "Poll a paused feed again",
+			Responses: []apiResponse{okJson(http.StatusOK, "The subscription", jsonObject{"subscription": globalTypes.FeedSubscription{}})},
+			Errors:    feedSubscriptionErrors(),
+		},
+		{
+			Method: "DELETE", Path: "/feeds/subscriptions/{id}", Scope: globalTypes.ScopeImport, Handler: rs.handleDeleteFeedSubscription,
+			Tag: "Feeds", Summary: "Unsubscribe from a feed, imported episodes stay",
+			Responses: []apiResponse{okJson(http.StatusOK, "The subscription is deleted", jsonObject{"deleted": int64(0)})},
+			Errors:    feedSubscriptionErrors(),
+		},
+		{
+			Method: "GET", Path: "/webhooks", Scope: globalTypes.ScopeAdmin, Handler: rs.handleListWebhooks,
+			Tag: "Webhooks", Summary: "List the webhooks of the workspace",
+			Responses: []apiResponse{okJson(http.StatusOK, "The webhooks", jsonObject{"webhooks": []globalTypes.Webhook{}})},
+			Errors:    []apiError{errs(http.StatusInternalServerError, "WEBHOOK_LIST_FAILED")},
+		},
+		{
+			Method: "POST", Path: "/webhooks", Scope: globalTypes.ScopeAdmin, Handler: rs.handleCreateWebhook,
+			Tag: "Webhooks", Summary: "Register a webhook, the secret is only part of this response",
+			Body:      jsonBody(globalTypes.WebhookRequest{}),
+			Responses: []apiResponse{okJson(http.StatusCreated, "The webhook", jsonObject{"webhook": globalTypes.Webhook{}})},
+			Errors: []apiError{
+				errs(http.StatusBadRequest, "WEBHOOK_BAD_JSON"),
+				errs(http.StatusNotFound, "WEBHOOK_NOT_FOUND"),
+				errs(http.StatusUnprocessableEntity, "WEBHOOK_VALIDATION_FAILED"),
+				errs(http.StatusInternalServerError, "WEBHOOK_CREATE_FAILED", "WEBHOOK_UPDATE_FAILED"),
+			},
+		},
+		{
+			Method: "GET", Path: "/webhooks/{id}", Scope: globalTypes.ScopeAdmin, Handler: rs.handleGetWebhook,
+			Tag: "Webhooks", Summary: "Get a webhook",
+			Responses: []apiResponse{okJson(http.StatusOK, "The webhook", jsonObject{"webhook": globalTypes.Webhook{}})},
+			Errors:    webhookErrors(),
+		},
+		{
+			Method: "PATCH", Path: "/webhooks/{id}", Scope: globalTypes.ScopeAdmin, Handler: rs.handleUpdateWebhook,
+			Tag: "Webhooks", Summary: "Change the url, events or state of a webhook",
+			Body:      jsonBody(globalTypes.WebhookPatch{}),
+			Responses: []apiResponse{okJson(http.StatusOK, "The webhook", jsonObject{"webhook": globalTypes.Webhook{}})},
+			Errors: append(webhookErrors(),
+				errs(http.StatusBadRequest, "WEBHOOK_BAD_JSON"),
+				errs(http.StatusUnprocessableEntity, "WEBHOOK_VALIDATION_FAILED"),
+			),
+		},
+		{
+			Method: "DELETE", Path: "/webhooks/{id}", Scope: globalTypes.ScopeAdmin, Handler: rs.handleDeleteWebhook,
+			Tag: "Webhooks", Summary: "Delete a webhook with its outbox and delivery log",
+			Responses: []apiResponse{okJson(http.StatusOK, "The webhook is deleted", jsonObject{"deleted": int64(0)})},
+			Errors:    webhookErrors(),
+		},
+		{
+			Method: "GET", Path: "/webhooks/{id}/events", Scope: globalTypes.ScopeAdmin, Handler: rs.handleListWebhookEvents,
+			Tag: "Webhooks", Summary: "List the outbox of a webhook",
+			Params: []apiParam{
+				query("status", "pending, delivered or failed", ""),
+				query("limit", "Number of events, newest first", 0),
+			},
+			Responses: []apiResponse{okJson(http.StatusOK, "The events", jsonObject{"events": []globalTypes.WebhookOutboxItem{}})},
+			Errors:    append(webhookErrors(), errs(http.StatusBadRequest, "WEBHOOK_BAD_QUERY")),
+		},
+		{
+			Method: "POST", Path: "/webhooks/{id}/events/{eventId}/retry", Scope: globalTypes.ScopeAdmin, Handler: rs.handleRetryWebhookEvent,
+			Tag: "Webhooks", Summary: "Deliver a failed event once more",
+			Responses: []apiResponse{okJson(http.StatusOK, "The event is pending again", jsonObject{"retried": int64(0)})},
+			Errors:    append(webhookErrors(), errs(http.StatusNotFound, "WEBHOOK_EVENT_NOT_FOUND")),
+		},
+		{
+			Method: "GET", Path: "/webhooks/{id}/deliveries", Scope: globalTypes.ScopeAdmin, Handler: rs.handleListWebhookDeliveries,
+			Tag: "Webhooks", Summary: "List the delivery attempts of a webhook",
+			Params:    []apiParam{query("limit", "Number of attempts, newest first", 0)},
+			Responses: []apiResponse{okJson(http.StatusOK, "The attempts", jsonObject{"deliveries": []globalTypes.WebhookDelivery{}})},
+			Errors:    append(webhookErrors(), errs(http.StatusBadRequest, "WEBHOOK_BAD_QUERY")),
+		},
+		{
+			Method: "OPTIONS", Path: "/uploads", Handler: rs.handleUploadOptions,
+			Tag: "Uploads", Summary: "tus capabilities of the server",
+			Responses: []apiResponse{{
+				Status:      http.StatusNoContent,
+				Description: "Supported versions, extensions and checksums",
+				Headers:     []string{"Tus-Version", "Tus-Extension", "Tus-Max-Size", "Tus-Checksum-Algorithm"},
+			}},
+		},
+		{
+			Method: "POST", Path: "/uploads", Scope: globalTypes.ScopeImport, Handler: rs.handleCreateUpload,
+			Tag: "Uploads", Summary: "Start a resumable tus upload",
+			Params: []apiParam{
+				tusResumable,
+				header("Upload-Length", "Size of the file in bytes", true),
+				header("Upload-Metadata", "Comma separated key and base64 value pairs of the item fields", false),
+			},
+			Responses: []apiResponse{{
+				Status:      http.StatusCreated,
+				Description: "The upload was created at Location",
+				Headers:     []string{"Location", "Upload-Offset", "Upload-Expires", "X-Import-Queue-Limit", "X-Import-Queue-Remaining"},
+			}},
+			Errors: []apiError{
+				errs(http.StatusBadRequest, "UPLOAD_BAD_LENGTH", "UPLOAD_BAD_METADATA"),
+				errs(http.StatusPreconditionFailed, "UPLOAD_UNSUPPORTED_VERSION"),
+				errs(http.StatusRequestEntityTooLarge, "UPLOAD_TOO_LARGE"),
+				errs(http.StatusUnprocessableEntity, "UPLOAD_VALIDATION_FAILED"),
+				errs(http.StatusTooManyRequests, "IMPORT_QUEUE_FULL"),
+				errs(http.StatusInternalServerError, "UPLOAD_CREATE_FAILED", "IMPORT_QUEUE_CHECK_FAILED"),
+			},
+		},
+		{
+			Method: "HEAD", Path: "/uploads/{id}", Scope: globalTypes.ScopeImport, Handler: rs.handleUploadStatus,
+			Tag: "Uploads", Summary: "Offset of an upload to resume it",
+			Params: []apiParam{tusResumable},
+			Responses: []apiResponse{{
+				Status:      http.StatusOK,
+				Description: "The upload exists",
+				Headers:     []string{"Upload-Offset", "Upload-Length", "Upload-Metadata", "Upload-Expires"},
+			}},
+			Errors: uploadErrors,
+		},
+		{
+			Method: "PATCH", Path: "/uploads/{id}", Scope: globalTypes.ScopeImport, Handler: rs.handleUploadChunk,
+			Tag: "Uploads", Summary: "Append a chunk, the upload is queued after the last one",
+			Params: []apiParam{
+				tusResumable,
+				header("Upload-Offset", "Offset the chunk starts at", true),
+				header("Upload-Checksum", "Algorithm and base64 checksum of the chunk", false),
+			},
+			Body: []apiContent{{ContentType: "application/offset+octet-stream", Schema: []byte{}}},
+			Responses: []apiResponse{{
+				Status:      http.StatusNoContent,
+				Description: "The chunk was stored, Upload-Audiofile-Hash is set once the upload is complete and queued",
+				Headers:     []string{"Upload-Offset", "Upload-Expires", "Upload-Audiofile-Hash"},
+			}},
+			Errors: append(slices.Clone(uploadErrors),
+				errs(http.StatusBadRequest, "UPLOAD_BAD_OFFSET"),
+				errs(http.StatusUnsupportedMediaType, "UPLOAD_UNSUPPORTED_CONTENT_TYPE"),
+				errs(http.StatusInternalServerError, "UPLOAD_STORE_FAILED", "COULD_NOT_QUEUE_IMPORT"),
+			),
+		},
+		{
+			Method: "DELETE", Path: "/uploads/{id}", Scope: globalTypes.ScopeImport, Handler: rs.handleTerminateUpload,
+			Tag: "Uploads", Summary: "Abort an upload",
+			Params:    []apiParam{tusResumable},
+			Responses: []apiResponse{{Status: http.StatusNoContent, Description: "The upload is removed"}},
+			Errors:    uploadErrors,
+		},
+		{
+			Method: "POST", Path: "/search", Scope: globalTypes.ScopeSearch, Handler: rs.handleSearch,
+			Tag: "Search", Summary: "Lexical, semantic, reranked (default with both queries) or hybrid search over the transcripts",
+			Body: jsonBody(globalTypes.SearchRequest{}),
+			Responses: []apiResponse{
+				{Status: http.StatusOK, Description: "The best segments with their audio files", Content: jsonBody(globalTypes.SearchResponse{})},
+				{Status: http.StatusConflict, Description: "The search failed, error tells why", Content: jsonBody(globalTypes.SearchResponse{})},
+			},
+			Errors: []apiError{
+				errs(http.StatusBadRequest, "SEARCH_BAD_JSON"),
+				errs(http.StatusRequestEntityTooLarge, "SEARCH_PAYLOAD_TOO_LARGE"),
+				errs(http.StatusUnsupportedMediaType, "SEARCH_UNSUPPORTED_CONTENT_TYPE"),
+				errs(http.StatusUnprocessableEntity, "SEARCH_VALIDATION_FAILED"),
+			},
+		},
+		{
+			Method: "GET", Path: "/audio", Scope: globalTypes.ScopeRead, Handler: rs.handleListAudio,
+			Tag: "Audio", Summary: "Page through the audio files of the workspace",
+			Params: append(slices.Clone(audioFilterParams),
+				query("sort", "created_at or recording_date", ""),
+				query("order", "asc or desc", ""),
+				query("limit", "Page size", 0),
+				query("cursor", "next_cursor of the previous page, only valid with the same sort and order", ""),
+			),
+			Responses: []apiResponse{okJson(http.StatusOK, "One page, next_cursor is missing on the last one", jsonObject{
+				"items":       []globalTypes.AudioListEntry{},
+				"next_cursor": "",
+			})},
+			Errors: []apiError{
+				errs(http.StatusBadRequest, "AUDIO_BAD_QUERY"),
+				errs(http.StatusInternalServerError, "AUDIO_LIST_FAILED"),
+			},
+		},
+		{
+			Method: "GET", Path: "/audio/{hash}", Scope: globalTypes.ScopeRead, Handler: rs.handleGetAudio,
+			Tag: "Audio", Summary: "An audio file with its segments",
+			Responses: []apiResponse{okJson(http.StatusOK, "The audio file", jsonObject{
+				"audio":    globalTypes.SearchAudioData{},
+				"segments": []globalTypes.SearchSegmentData{},
+			})},
+			Errors: []apiError{
+				errs(http.StatusNotFound, "AUDIO_NOT_FOUND"),
+				errs(http.StatusInternalServerError, "AUDIO_LOAD_FAILED"),
+			},
+		},
+		{
+			Method: "GET", Path: "/audio/{hash}/file", Scope: globalTypes.ScopeRead, Handler: rs.handleAudioFile,
+			Tag: "Audio", Summary: "Play or download the audio, start and end cut out an approximate clip",
+			Params: []apiParam{
+				query("start", "Clip start in seconds", 0.0),
+				query("end", "Clip end in seconds", 0.0),
+				header("Range", "Byte range of the file", false),
+			},
+			Responses: []apiResponse{
+				{Status: http.StatusOK, Description: "The audio", Headers: []string{"ETag"}, Content: []apiContent{{ContentType: "audio/*"}}},
+				{Status: http.StatusPartialContent, Description: "The requested range", Headers: []string{"Content-Range"}, Content: []apiContent{{ContentType: "audio/*"}}},
+			},
+			Errors: []apiError{
+				errs(http.StatusBadRequest, "AUDIO_BAD_CLIP_RANGE"),
+				errs(http.StatusNotFound, "AUDIO_NOT_FOUND", "AUDIO_FILE_MISSING"),
+				errs(http.StatusConflict, "AUDIO_FILE_NOT_PERSISTED"),
+				errs(http.StatusUnprocessableEntity, "AUDIO_DURATION_UNKNOWN"),
+				errs(http.StatusInternalServerError, "AUDIO_LOAD_FAILED"),
+			},
+		},
+		{
+			Method: "GET", Path: "/audio/{hash}/transcript", Scope: globalTypes.ScopeRead, Handler: rs.handleTranscript,
+			Tag: "Audio", Summary: "Export the transcript as srt, vtt, txt or json",
+			Params: []apiParam{query("format", "srt, vtt, txt or json (default)", "")},
+			Responses: []apiResponse{{
+				Status:      http.StatusOK,
+				Description: "The transcript, X-Transcript-Timing tells whether the times are measured or estimated",
+				Headers:     []string{"X-Transcript-Timing"},
+				Content: []apiContent{
+					{ContentType: "application/json", Schema: jsonObject{
+						"ok":             true,
+						"audiofile_hash": "",
+						"title":          "",
+						"timing":         "",
+						"transcript":     "",
+						"cues":           []subtitles.Cue{},
+					}},
+					{ContentType: "application/x-subrip"},
+					{ContentType: "text/vtt"},
+					{ContentType: "text/plain"},
+				},
+			}},
+			Errors: []apiError{
+				errs(http.StatusBadRequest, "TRANSCRIPT_BAD_FORMAT"),
+				errs(http.StatusNotFound, "AUDIO_NOT_FOUND"),
+				errs(http.StatusConflict, "TRANSCRIPT_NOT_AVAILABLE"),
+				errs(http.StatusInternalServerError, "AUDIO_LOAD_FAILED", "TRANSCRIPT_EXPORT_FAILED"),
+			},
+		},
+		{
+			Method: "DELETE", Path: "/audio", Scope: globalTypes.ScopeAdmin, Handler: rs.handleBulkDeleteAudio,
+			Tag: "Audio", Summary: "Delete all audio files matching the filter",
+			Params: audioFilterParams,
+			Responses: []apiResponse{okJson(http.StatusOK, "The audio files are deleted", jsonObject{
+				"deleted": jsonObject{"count": 0, "hashes": []string{}},
+			})},
+			Errors: []apiError{
+				errs(http.StatusBadRequest, "AUDIO_BAD_FILTER", "AUDIO_FILTER_REQUIRED"),
+				errs(http.StatusConflict, "AUDIO_DELETE_PARTIAL"),
+				errs(http.StatusInternalServerError, "AUDIO_DELETE_PARTIAL", "AUDIO_DELETE_FAILED"),
+			},
+		},
+		{
+			Method: "DELETE", Path: "/audio/{hash}", Scope: globalTypes.ScopeAdmin, Handler: rs.handleDeleteAudio,
+			Tag: "Audio", Summary: "Delete an audio file from all stores",
+			Responses: []apiResponse{okJson(http.StatusOK, "The audio file is deleted", jsonObject{"audiofile_hash": ""})},
+			Errors: []apiError{
+				errs(http.StatusNotFound, "AUDIO_NOT_FOUND"),
+				errs(http.StatusConflict, "AUDIO_IN_PROCESSING"),
+				errs(http.StatusInternalServerError, "AUDIO_DELETE_INCOMPLETE", "AUDIO_DELETE_FAILED", "AUDIO_LOAD_FAILED"),
+			},
+		},
+		{
+			Method: "PATCH", Path: "/audio/{hash}", Scope: globalTypes.ScopeImport, Handler: rs.handleUpdateAudio,
+			Tag: "Audio", Summary: "Change the metadata or the acl of an audio file",
+			Body:      jsonBody(globalTypes.AudioMetadataPatch{}),
+			Responses: []apiResponse{okJson(http.StatusOK, "The updated audio file", jsonObject{"audio": globalTypes.SearchAudioData{}})},
+			Errors: []apiError{
+				errs(http.StatusBadRequest, "AUDIO_BAD_JSON"),
+				errs(http.StatusForbidden, "AUDIO_ACL_FORBIDDEN"),
+				errs(http.StatusNotFound, "AUDIO_NOT_FOUND"),
+				errs(http.StatusConflict, "AUDIO_IN_PROCESSING"),
+				errs(http.StatusUnsupportedMediaType, "AUDIO_UNSUPPORTED_CONTENT_TYPE"),
+				errs(http.StatusUnprocessableEntity, "AUDIO_VALIDATION_FAILED"),
+				errs(http.StatusInternalServerError, "AUDIO_UPDATE_FAILED", "AUDIO_INDEX_SYNC_FAILED", "AUDIO_LOAD_FAILED"),
+			},
+		},
+	}
+}
+
+func feedSubscriptionErrors() []apiError {
+	return []apiError{
+		errs(http.StatusBadRequest, "FEED_BAD_ID"),
+		errs(http.StatusNotFound, "FEED_NOT_FOUND"),
+		errs(http.StatusInternalServerError, "FEED_UPDATE_FAILED"),
+	}
+}
+
+func webhookErrors() []apiError {
+	return []apiError{
+		errs(http.StatusBadRequest, "WEBHOOK_BAD_ID"),
+		errs(http.StatusNotFound, "WEBHOOK_NOT_FOUND"),
+		errs(http.StatusInternalServerError, "WEBHOOK_UPDATE_FAILED"),
+	}
+}
@@ -14,7 +14,7 @@ func (rs *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
 	// 1) Content-Type hart prüfen -> 415
 	ct := r.Header.Get("Content-Type")
 	if ct == "" || !strings.HasPrefix(ct, "application/json") && !strings.HasPrefix(ct, "application/json; charset=utf-8") {
-		rs.writeJsonWithCounter(w, http.StatusUnsupportedMediaType, postgres.SearchRequestsFailed, map[string]any{
+		rs.writeJsonWithCounter(w, r, http.StatusUnsupportedMediaType, postgres.SearchRequestsFailed, map[string]any{
 			"ok":    false,
 			"code":  "SEARCH_UNSUPPORTED_CONTENT_TYPE",
 			"error": "Content-Type must be \"application/json\" or \"application/json; charset=utf-8\"",
@@ -35,7 +35,7 @@ func (rs *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
 		if strings.Contains(strings.ToLower(msg), "too large") ||
 			strings.Contains(strings.ToLower(msg), "request body too large") ||
 			strings.Contains(strings.ToLower(msg), "http: request body too large") {
-			rs.writeJsonWithCounter(w, http.StatusRequestEntityTooLarge, postgres.SearchRequestsFailed, map[string]any{
+			rs.writeJsonWithCounter(w, r, http.StatusRequestEntityTooLarge, postgres.SearchRequestsFailed, map[string]any{
 				"ok":    false,
 				"code":  "SEARCH_PAYLOAD_TOO_LARGE",
 				"error": "Request body too large",
@@ -44,7 +44,7 @@ func (rs *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		rs.writeJsonWithCounter(w, http.StatusBadRequest, postgres.SearchRequestsFailed, map[string]any{
+		rs.writeJsonWithCounter(w, r, http.StatusBadRequest, postgres.SearchRequestsFailed, map[string]any{
 			"ok":    false,
 			"code":  "SEARCH_BAD_JSON",
 			"error": msg,
@@ -55,7 +55,7 @@ func (rs *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
 	err := searchRequest.ValidateApiInput()
 	if err != nil {
 		slog.Info("Received an search with invalid parameters")
-		rs.writeJsonWithCounter(w, http.StatusUnprocessableEntity, postgres.SearchRequestsFailed, map[string]any{
+		rs.writeJsonWithCounter(w, r, http.StatusUnprocessableEntity, postgres.SearchRequestsFailed, map[string]any{
 			"ok":    false,
 			"code":  "SEARCH_VALIDATION_FAILED",
 			"error": "Search request has a invalid parameter: " + err.Error(),
@@ -64,9 +64,11 @@ func (rs *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	searchRequest.Viewer = requestViewer(r)
+
 	slog.Info("Start Search for Query: " + searchRequest.SemanticSearchQuery)
 
-	res := rs.searcher.Search(searchRequest)
+	res := rs.searcher.Search(r.Context(), searchRequest)
 
 	var status int
 	if res.Ok {
@@ -76,5 +78,5 @@ func (rs *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Return response
-	rs.writeJsonWithCounter(w, status, postgres.SearchRequestsSuccessful, res)
+	rs.writeJsonWithCounter(w, r, status, postgres.SearchRequestsSuccessful, res)
 }
@@ -3,11 +3,17 @@ package restApi
 import (
 	"context"
 	"errors"
+	"go_audio_search_api_server/ai"
+	"go_audio_search_api_server/eventStream"
 	"go_audio_search_api_server/globalUtils"
 	"go_audio_search_api_server/postgres"
+	"go_audio_search_api_server/qdrant"
 	"go_audio_search_api_server/searcher"
+	"go_audio_search_api_server/storage"
+	"go_audio_search_api_server/uploads"
 	"log/slog"
 	"net/http"
+	"path/filepath"
 	"time"
 )
 
@@ -16,29 +22,70 @@ const opTimeout = 1 * time.Minute
 type Server struct {
 	port             string
 	PoolRefillSignal *globalUtils.NoneStackingEvent
+	FeedPollSignal   *globalUtils.NoneStackingEvent
 	StopCtx          context.Context
 	searcher         *searcher.Worker
 	httpServer       *http.Server
 	postgres         *postgres.Worker
+	qdrant           *qdrant.Worker
+	uploads          *uploads.Store
+	storage          storage.Storage
+	events           *eventStream.Worker
+	requestLimit     *rateLimiter
+	searchLimit      *rateLimiter
+	maxQueuedPerKey  int
+	ready            *readiness
 }
 
-func NewRestServer(ctx context.Context, port string, postgres *postgres.Worker, searcher *searcher.Worker, poolRefillSignal *globalUtils.NoneStackingEvent) *Server {
+func NewRestServer(ctx context.Context, port string, postgres *postgres.Worker, qdrant *qdrant.Worker, searcher *searcher.Worker, embedder *ai.EmbeddingWorker, store storage.Storage, events *eventStream.Worker, poolRefillSignal *globalUtils.NoneStackingEvent, feedPollSignal *globalUtils.NoneStackingEvent) *Server {
 	rs := &Server{
 		port:             port,
 		PoolRefillSignal: poolRefillSignal,
+		FeedPollSignal:   feedPollSignal,
 		StopCtx:          ctx,
 		searcher:         searcher,
 		postgres:         postgres,
+		qdrant:           qdrant,
+		storage:          store,
+		events:           events,
+		requestLimit:     newRateLimiter(globalUtils.LoadEnvIntOr("RATE_LIMIT_PER_MIN", 600)),
+		searchLimit:      newRateLimiter(globalUtils.LoadEnvIntOr("RATE_LIMIT_SEARCH_PER_MIN", 60)),
+		maxQueuedPerKey:  globalUtils.LoadEnvIntOr("IMPORT_MAX_QUEUED_PER_KEY", 1000),
 	}
 
+	rs.ready = &readiness{
+		postgres: postgres,
+		qdrant:   qdrant,
+		embedder: embedder,
+		whisper:  ai.New(0),
+	}
+	if globalUtils.LoadEnvStr("DEACTIVATE_LLM") != "true" {
+		rs.ready.llm = ai.NewLlmWorker()
+	}
+
+	uploadExpiry := time.Duration(globalUtils.LoadEnvIntOr("UPLOAD_EXPIRY_HOURS", 24)) * time.Hour
+	uploadStore, err := uploads.NewStore(filepath.Join(storage.SpoolDir(), "uploads"), uploadExpiry)
+	if err != nil {
+		panic(err)
+	}
+	uploadStore.StartSweep(ctx)
+	rs.uploads = uploadStore
+
+	rs.bootstrapAdminKey()
+
+	// the mux is built from the same route table as the OpenAPI document, see routes.go
 	mux := http.NewServeMux()
-	mux.HandleFunc("GET /health", rs.handleHealth)
-	mux.HandleFunc("POST /import", rs.handleImport)
-	mux.HandleFunc("POST /search", rs.handleSearch)
+	for _, route := range rs.routes() {
+		handler := route.Handler
+		if route.Scope != "" {
+			handler = rs.requireScope(route.Scope, handler)
+		}
+		mux.HandleFunc(route.Method+" "+route.Path, handler)
+	}
 
 	rs.httpServer = &http.Server{
 		Addr:              ":" + rs.port,
-		Handler:           logging(mux),
+		Handler:           logging(instrument(mux)),
 		ReadHeaderTimeout: 5 * time.Second,
 	}
 	return rs
@@ -0,0 +1,116 @@
+package restApi
+
+import (
+	"fmt"
+	"go_audio_search_api_server/globalTypes"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultStatsHours = 24
+	// maxStatsHours stays within the retention of the counter buckets
+	maxStatsHours = 30 * 24
+)
+
+func (rs *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	slog.Info("Received request to GET /stats")
+
+	stats, err := rs.loadStats(r)
+	if err != nil {
+		slog.Error("Error while loading stats", "err", err)
+		rs.writeJson(w, http.StatusInternalServerError, map[string]any{
+			"ok":    false,
+			"code":  "STATS_LOAD_FAILED",
+			"error": "Internal Server Error: " + err.Error(),
+		})
+		return
+	}
+
+	rs.writeJson(w, http.StatusOK, map[string]any{
+		"ok":    true,
+		"stats": stats,
+	})
+}
+
+// loadStats collects the statistics of the workspace of the request. The audio files are the ones the api key may
+// read, a key restricted by acls must not learn about recordings it can not list.
+func (rs *Server) loadStats(r *http.Request) (*globalTypes.Stats, error) {
+	viewer := requestViewer(r)
+
+	ctx, cancel := rs.opCtx(r)
+	defer cancel()
+
+	stats, embeddedSegments, err := rs.postgres.GetWorkspaceStats(ctx, viewer)
+	if err != nil {
+		return nil, err
+	}
+
+	stats.Counters, err = rs.postgres.GetCounters(ctx, viewer.WorkspaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	queued, err := rs.postgres.CountQueuedByStage(ctx, &viewer)
+	if err != nil {
+		return nil, err
+	}
+
+	stats.Queue = map[string]int64{}
+	for _, stage := range queueStages() {
+		stats.Queue[stage.Name()] = int64(queued[stage])
+	}
+
+	// the vectors in Qdrant carry no acl, a restricted key gets the segments of its embedded audio files instead
+	if !viewer.SeesAll {
+		stats.Vectors = uint64(embeddedSegments)
+		return stats, nil
+	}
+
+	stats.Vectors, err = rs.qdrant.CountWorkspacePoints(ctx, viewer.WorkspaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+func (rs *Server) handleStatsTimeseries(w http.ResponseWriter, r *http.Request) {
+	slog.Info("Received request to GET /stats/timeseries")
+
+	hours := defaultStatsHours
+	if v := strings.TrimSpace(r.URL.Query().Get("hours")); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxStatsHours {
+			rs.writeJson(w, http.StatusBadRequest, map[string]any{
+				"ok":    false,
+				"code":  "STATS_BAD_QUERY",
+				"error": fmt.Sprintf("hours must be a number from 1 to %d", maxStatsHours),
+			})
+			return
+		}
+		hours = n
+	}
+
+	ctx, cancel := rs.opCtx(r)
+	buckets, err := rs.postgres.GetStatsTimeseries(ctx, requestViewer(r), hours)
+	cancel()
+
+	if err != nil {
+		slog.Error("Error while loading stats timeseries", "err", err)
+		rs.writeJson(w, http.StatusInternalServerError, map[string]any{
+			"ok":    false,
+			"code":  "STATS_LOAD_FAILED",
+			"error": "Internal Server Error: " + err.Error(),
+		})
+		return
+	}
+
+	rs.writeJson(w, http.StatusOK, map[string]any{
+		"ok":      true,
+		"hours":   hours,
+		"buckets": buckets,
+	})
+}
@@ -0,0 +1,130 @@
+package restApi
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"go_audio_search_api_server/subtitles"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+func (rs *Server) handleTranscript(w http.ResponseWriter, r *http.Request) {
+	hash := r.PathValue("hash")
+	slog.Info("Received request to GET /audio/" + hash + "/transcript")
+
+	if !rs.audioVisibleToRequest(w, r, hash) {
+		return
+	}
+
+	format := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("format")))
+	if format == "" {
+		format = "json"
+	}
+
+	switch format {
+	case "srt", "vtt", "txt", "json":
+	default:
+		rs.writeJson(w, http.StatusBadRequest, map[string]any{
+			"ok":    false,
+			"code":  "TRANSCRIPT_BAD_FORMAT",
+			"error": "format must be one of srt, vtt, txt or json, got " + format,
+		})
+		return
+	}
+
+	ctx, cancel := rs.opCtx(r)
+	audio, err := rs.postgres.GetSearchAudioDataByHash(ctx, hash, requestViewer(r))
+	cancel()
+
+	if errors.Is(err, sql.ErrNoRows) {
+		rs.writeJson(w, http.StatusNotFound, map[string]any{
+			"ok":    false,
+			"code":  "AUDIO_NOT_FOUND",
+			"error": "No audio file with hash " + hash,
+		})
+		return
+	}
+	if err != nil {
+		slog.Error("Error while loading audio file", "audioHash", hash, "err", err)
+		rs.writeJson(w, http.StatusInternalServerError, map[string]any{
+			"ok":    false,
+			"code":  "AUDIO_LOAD_FAILED",
+			"error": "Internal Server Error: " + err.Error(),
+		})
+		return
+	}
+
+	ctx, cancel = rs.opCtx(r)
+	segments, err := rs.postgres.GetAllSegmentsByAudioHash(ctx, hash)
+	cancel()
+
+	if err != nil {
+		slog.Error("Error while loading segments", "audioHash", hash, "err", err)
+		rs.writeJson(w, http.StatusInternalServerError, map[string]any{
+			"ok":    false,
+			"code":  "AUDIO_LOAD_FAILED",
+			"error": "Internal Server Error: " + err.Error(),
+		})
+		return
+	}
+
+	if len(segments) == 0 {
+		rs.writeJson(w, http.StatusConflict, map[string]any{
+			"ok":    false,
+			"code":  "TRANSCRIPT_NOT_AVAILABLE",
+			"error": "Audio file has not been transcribed yet",
+		})
+		return
+	}
+
+	cues, measured := subtitles.BuildCues(segments, float64(audio.DurationInSec))
+
+	timing := "estimated"
+	if measured {
+		timing = "measured"
+	}
+
+	if format == "json" {
+		rs.writeJson(w, http.StatusOK, map[string]any{
+			"ok":             true,
+			"audiofile_hash": audio.AudiofileHash,
+			"title":          audio.Title,
+			"timing":         timing,
+			"transcript":     subtitles.PlainText(cues),
+			"cues":           cues,
+		})
+		return
+	}
+
+	var buf bytes.Buffer
+	var contentType string
+
+	switch format {
+	case "srt":
+		contentType = "application/x-subrip; charset=utf-8"
+		err = subtitles.WriteSrt(&buf, cues)
+	case "vtt":
+		contentType = "text/vtt; charset=utf-8"
+		err = subtitles.WriteVtt(&buf, cues)
+	case "txt":
+		contentType = "text/plain; charset=utf-8"
+		err = subtitles.WriteTxt(&buf, cues)
+	}
+
+	if err != nil {
+		rs.writeJson(w, http.StatusInternalServerError, map[string]any{
+			"ok":    false,
+			"code":  "TRANSCRIPT_EXPORT_FAILED",
+			"error": "Internal Server Error: " + err.Error(),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="`+hash+`.`+format+`"`)
+	w.Header().Set("X-Transcript-Timing", timing)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(buf.Bytes())
+}
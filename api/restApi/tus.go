@@ -0,0 +1,402 @@
+package restApi
+
+import (
+	"context"
+	"errors"
+	"go_audio_search_api_server/globalTypes"
+	"go_audio_search_api_server/postgres"
+	"go_audio_search_api_server/storage"
+	"go_audio_search_api_server/tracing"
+	"go_audio_search_api_server/uploads"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const tusVersion = "1.0.0"
+
+// tus answers a failed checksum with this non standard status
+const statusChecksumMismatch = 460
+
+const tusMaxSize = maxMultipartBody
+
+// tusHeaders sets the headers every tus response carries
+func tusHeaders(w http.ResponseWriter) {
+	w.Header().Set("Tus-Resumable", tusVersion)
+	w.Header().Set("Cache-Control", "no-store")
+}
+
+// uploadExpires tells the client until when an upload without further chunks is kept
+func uploadExpires(w http.ResponseWriter, info *uploads.Info) {
+	w.Header().Set("Upload-Expires", info.ExpiresAt.UTC().Format(http.TimeFormat))
+}
+
+// checkTusResumable rejects requests of clients speaking another protocol version
+func (rs *Server) checkTusResumable(w http.ResponseWriter, r *http.Request) bool {
+	if r.Header.Get("Tus-Resumable") == tusVersion {
+		return true
+	}
+
+	w.Header().Set("Tus-Version", tusVersion)
+	rs.writeJson(w, http.StatusPreconditionFailed, map[string]any{
+		"ok":    false,
+		"code":  "UPLOAD_UNSUPPORTED_VERSION",
+		"error": "Tus-Resumable header must be " + tusVersion,
+	})
+	return false
+}
+
+func (rs *Server) handleUploadOptions(w http.ResponseWriter, r *http.Request) {
+	tusHeaders(w)
+	w.Header().Set("Tus-Version", tusVersion)
+	w.Header().Set("Tus-Extension", "creation,termination,checksum,expiration")
+	w.Header().Set("Tus-Max-Size", strconv.FormatInt(tusMaxSize, 10))
+	w.Header().Set("Tus-Checksum-Algorithm", strings.Join(uploads.ChecksumAlgorithms, ","))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleCreateUpload registers a new upload. The metadata of the audio file is sent in Upload-Metadata, title and
+// user_summary are required just like for the other imports.
+func (rs *Server) handleCreateUpload(w http.ResponseWriter, r *http.Request) {
+	tusHeaders(w)
+	if !rs.checkTusResumable(w, r) {
+		return
+	}
+
+	length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || length <= 0 {
+		rs.writeJsonWithCounter(w, r, http.StatusBadRequest, postgres.ImportRequestsFailed, map[string]any{
+			"ok":    false,
+			"code":  "UPLOAD_BAD_LENGTH",
+			"error": "Upload-Length must be a positive number, deferred lengths are not supported",
+		})
+		return
+	}
+
+	if length > tusMaxSize {
+		rs.writeJsonWithCounter(w, r, http.StatusRequestEntityTooLarge, postgres.ImportRequestsFailed, map[string]any{
+			"ok":    false,
+			"code":  "UPLOAD_TOO_LARGE",
+			"error": "Upload-Length exceeds Tus-Max-Size",
+			"limit": tusMaxSize,
+		})
+		return
+	}
+
+	rawMetadata := r.Header.Get("Upload-Metadata")
+	metadata, err := uploads.ParseMetadata(rawMetadata)
+	if err != nil {
+		rs.writeJsonWithCounter(w, r, http.StatusBadRequest, postgres.ImportRequestsFailed, map[string]any{
+			"ok":    false,
+			"code":  "UPLOAD_BAD_METADATA",
+			"error": err.Error(),
+		})
+		return
+	}
+
+	err = uploadElement(metadata).ValidateUploadInput()
+	if err == nil && metadata["recording_date"] != "" && !isIsoDate(metadata["recording_date"]) {
+		err = errors.New("recording_date must be an ISO date")
+	}
+	if err != nil {
+		rs.writeJsonWithCounter(w, r, http.StatusUnprocessableEntity, postgres.ImportRequestsFailed, map[string]any{
+			"ok":    false,
+			"code":  "UPLOAD_VALIDATION_FAILED",
+			"error": err.Error(),
+		})
+		return
+	}
+
+	// uploads count against the queue once they are complete, starting one is refused while the queue is full
+	if !rs.admitImport(w, r, 1) {
+		return
+	}
+
+	info, err := rs.uploads.Create(length, rawMetadata, metadata, requestWorkspace(r), requestViewer(r).User, requestKeyID(r))
+	if err != nil {
+		slog.Error("Error while creating upload", "err", err)
+		rs.writeJsonWithCounter(w, r, http.StatusInternalServerError, postgres.ImportRequestsFailed, map[string]any{
+			"ok":    false,
+			"code":  "UPLOAD_CREATE_FAILED",
+			"error": "Internal Server Error: " + err.Error(),
+		})
+		return
+	}
+
+	slog.Info("Created upload", "uploadId", info.ID, "length", length)
+
+	w.Header().Set("Location", "/uploads/"+info.ID)
+	w.Header().Set("Upload-Offset", "0")
+	uploadExpires(w, info)
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (rs *Server) handleUploadStatus(w http.ResponseWriter, r *http.Request) {
+	tusHeaders(w)
+	if !rs.checkTusResumable(w, r) {
+		return
+	}
+
+	info, err := rs.getUpload(r, r.PathValue("id"))
+	if err != nil {
+		rs.writeUploadError(w, err)
+		return
+	}
+
+	w.Header().Set("Upload-Offset", strconv.FormatInt(info.Offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(info.Length, 10))
+	if info.RawMetadata != "" {
+		w.Header().Set("Upload-Metadata", info.RawMetadata)
+	}
+	uploadExpires(w, info)
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleUploadChunk appends a chunk. Once the last byte arrived the file is moved into the audio store and queued at
+// StageFilePersisted. Resending the final offset of a queued upload only repeats the answer.
+func (rs *Server) handleUploadChunk(w http.ResponseWriter, r *http.Request) {
+	tusHeaders(w)
+	if !rs.checkTusResumable(w, r) {
+		return
+	}
+
+	if r.Header.Get("Content-Type") != "application/offset+octet-stream" {
+		rs.writeJson(w, http.StatusUnsupportedMediaType, map[string]any{
+			"ok":    false,
+			"code":  "UPLOAD_UNSUPPORTED_CONTENT_TYPE",
+			"error": "Content-Type must be application/offset+octet-stream",
+		})
+		return
+	}
+
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		rs.writeJson(w, http.StatusBadRequest, map[string]any{
+			"ok":    false,
+			"code":  "UPLOAD_BAD_OFFSET",
+			"error": "Upload-Offset must be a non negative number",
+		})
+		return
+	}
+
+	id := r.PathValue("id")
+	defer r.Body.Close()
+
+	info, err := rs.getUpload(r, id)
+	if err != nil {
+		rs.writeUploadError(w, err)
+		return
+	}
+
+	// a finished upload whose queueing failed is completed again by resending the final offset
+	if info.AudiofileHash == "" || offset != info.Length {
+		info, err = rs.uploads.Append(id, offset, r.Body, r.Header.Get("Upload-Checksum"))
+		if err != nil {
+			if info != nil {
+				w.Header().Set("Upload-Offset", strconv.FormatInt(info.Offset, 10))
+			}
+			rs.writeUploadError(w, err)
+			return
+		}
+	}
+
+	if info.Complete() {
+		if !rs.queueUpload(w, r, id) {
+			return
+		}
+	}
+
+	w.Header().Set("Upload-Offset", strconv.FormatInt(info.Offset, 10))
+	uploadExpires(w, info)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (rs *Server) handleTerminateUpload(w http.ResponseWriter, r *http.Request) {
+	tusHeaders(w)
+	if !rs.checkTusResumable(w, r) {
+		return
+	}
+
+	if _, err := rs.getUpload(r, r.PathValue("id")); err != nil {
+		rs.writeUploadError(w, err)
+		return
+	}
+
+	if err := rs.uploads.Remove(r.PathValue("id")); err != nil {
+		rs.writeUploadError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// queueUpload moves the completed upload into the audio store and queues it for processing.
+// An upload is queued once, a content that is already imported keeps its pipeline state.
+func (rs *Server) queueUpload(w http.ResponseWriter, r *http.Request, id string) bool {
+	_, err := rs.uploads.Finalize(id, func(info *uploads.Info, dataPath string) (string, error) {
+		ctx, cancel := context.WithTimeout(r.Context(), storage.StoreTimeout)
+		defer cancel()
+
+		_, hash, _, err := storage.StoreAudioFile(ctx, rs.storage, info.WorkspaceID, dataPath, "")
+		return hash, err
+	})
+	if err != nil {
+		slog.Error("Error while storing completed upload", "uploadId", id, "err", err)
+		rs.writeJsonWithCounter(w, r, http.StatusInternalServerError, postgres.ImportRequestsFailed, map[string]any{
+			"ok":    false,
+			"code":  "UPLOAD_STORE_FAILED",
+			"error": "Internal Server Error: " + err.Error(),
+		})
+		return false
+	}
+
+	info, queued, err := rs.uploads.Queue(id, func(info *uploads.Info) error {
+		ctx, cancel := rs.opCtx(r)
+		existing, err := rs.postgres.GetAudioDataByHash(ctx, info.AudiofileHash)
+		cancel()
+
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			// queueing it again would restart the pipeline of the stored file
+			slog.Info("Completed upload is already imported", "uploadId", info.ID, "audioHash", info.AudiofileHash)
+			return nil
+		}
+
+		item := uploadElement(info.Metadata)
+		item.AudiofileHash = info.AudiofileHash
+		item.DownloadPath = storage.AudioKey(info.AudiofileHash)
+		item.LastSuccessfulStage = globalTypes.StageFilePersisted
+		// the client already knows the upload id, so it doubles as the import id
+		item.ImportID = info.ID
+		item.WorkspaceID = info.WorkspaceID
+		item.Owner = info.Owner
+		item.ApiKeyID = info.ApiKeyID
+		// the request that completed the upload is the one the pipeline stages link to
+		item.TraceParent = tracing.TraceParent(r.Context())
+
+		ctx, cancel = rs.opCtx(r)
+		err = rs.postgres.UpsertBase(ctx, item)
+		cancel()
+
+		if err != nil {
+			return err
+		}
+
+		slog.Info("Queued completed upload", "uploadId", info.ID, "audioHash", info.AudiofileHash)
+		rs.PoolRefillSignal.Trigger()
+		return nil
+	})
+
+	if err != nil {
+		slog.Error("Error while queueing completed upload", "uploadId", id, "err", err)
+		rs.writeJsonWithCounter(w, r, http.StatusInternalServerError, postgres.ImportRequestsFailed, map[string]any{
+			"ok":    false,
+			"code":  "COULD_NOT_QUEUE_IMPORT",
+			"error": "Internal Server Error: Failed to queue upload for processing, resend the final Upload-Offset to retry",
+		})
+		return false
+	}
+
+	if queued {
+		rs.postgres.IncCounter(requestWorkspace(r), postgres.ImportRequestsSuccessful)
+	}
+
+	w.Header().Set("Upload-Audiofile-Hash", info.AudiofileHash)
+	return true
+}
+
+// getUpload loads an upload of the requesting workspace, uploads of other workspaces are reported as not found
+func (rs *Server) getUpload(r *http.Request, id string) (*uploads.Info, error) {
+	info, err := rs.uploads.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	workspaceID := info.WorkspaceID
+	if workspaceID == "" {
+		workspaceID = globalTypes.DefaultWorkspaceID
+	}
+	if workspaceID != requestWorkspace(r) {
+		return nil, uploads.ErrNotFound
+	}
+	return info, nil
+}
+
+func (rs *Server) writeUploadError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, uploads.ErrNotFound):
+		rs.writeJson(w, http.StatusNotFound, map[string]any{
+			"ok":    false,
+			"code":  "UPLOAD_NOT_FOUND",
+			"error": err.Error(),
+		})
+	case errors.Is(err, uploads.ErrOffsetMismatch):
+		rs.writeJson(w, http.StatusConflict, map[string]any{
+			"ok":    false,
+			"code":  "UPLOAD_OFFSET_MISMATCH",
+			"error": "Upload-Offset does not match the current offset, send HEAD to resume",
+		})
+	case errors.Is(err, uploads.ErrChecksumMismatch):
+		rs.writeJson(w, statusChecksumMismatch, map[string]any{
+			"ok":    false,
+			"code":  "UPLOAD_CHECKSUM_MISMATCH",
+			"error": "Chunk checksum does not match, the chunk was discarded",
+		})
+	case errors.Is(err, uploads.ErrInvalidChecksum):
+		rs.writeJson(w, http.StatusBadRequest, map[string]any{
+			"ok":    false,
+			"code":  "UPLOAD_BAD_CHECKSUM",
+			"error": err.Error(),
+		})
+	case errors.Is(err, uploads.ErrUnknownAlgorithm):
+		rs.writeJson(w, http.StatusBadRequest, map[string]any{
+			"ok":    false,
+			"code":  "UPLOAD_BAD_CHECKSUM_ALGORITHM",
+			"error": "Supported checksum algorithms: " + strings.Join(uploads.ChecksumAlgorithms, ","),
+		})
+	case errors.Is(err, uploads.ErrTooLarge):
+		rs.writeJson(w, http.StatusRequestEntityTooLarge, map[string]any{
+			"ok":    false,
+			"code":  "UPLOAD_TOO_LARGE",
+			"error": "Chunk exceeds Upload-Length, the chunk was discarded",
+		})
+	default:
+		slog.Error("Error while handling upload", "err", err)
+		rs.writeJson(w, http.StatusInternalServerError, map[string]any{
+			"ok":    false,
+			"code":  "UPLOAD_FAILED",
+			"error": "Internal Server Error: " + err.Error(),
+		})
+	}
+}
+
+// uploadElement maps the tus metadata onto the import fields, the file name is the fallback title
+func uploadElement(metadata map[string]string) *globalTypes.AudioDataElement {
+	title := metadata["title"]
+	if title == "" {
+		title = metadata["filename"]
+	}
+
+	return &globalTypes.AudioDataElement{
+		Title:         title,
+		UserSummary:   metadata["user_summary"],
+		Category:      metadata["category"],
+		AudioType:     metadata["audio_type"],
+		RecordingDate: metadata["recording_date"],
+		Acl:           uploadAcl(metadata["acl"]),
+	}
+}
+
+// uploadAcl splits the comma separated acl entry of the Upload-Metadata
+func uploadAcl(raw string) []string {
+	var acl []string
+	for _, entry := range strings.Split(raw, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			acl = append(acl, entry)
+		}
+	}
+	return acl
+}
@@ -4,10 +4,15 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"go_audio_search_api_server/globalTypes"
 	"go_audio_search_api_server/postgres"
 	"log/slog"
 	"net/http"
+	"strings"
 	"time"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
 func logging(next http.Handler) http.Handler {
@@ -18,20 +23,15 @@ func logging(next http.Handler) http.Handler {
 	})
 }
 
-func (rs *Server) writeJsonWithCounter(w http.ResponseWriter, status int, counter postgres.Counter, v any) {
+// writeJsonWithCounter writes the response and counts the request in the workspace of its api key
+func (rs *Server) writeJsonWithCounter(w http.ResponseWriter, r *http.Request, status int, counter postgres.Counter, v any) {
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	w.WriteHeader(status)
 	enc := json.NewEncoder(w)
 	enc.SetEscapeHTML(true)
 	_ = enc.Encode(v)
 
-	ctx, cancel := rs.opCtx()
-	err := rs.postgres.AddToCounter(ctx, counter, 1)
-	cancel()
-
-	if err != nil {
-		slog.Error("Error while updating " + string(counter) + " counter after api call: " + err.Error())
-	}
+	rs.postgres.IncCounter(requestWorkspace(r), counter)
 }
 
 func (rs *Server) writeJson(w http.ResponseWriter, status int, v any) {
@@ -59,6 +59,50 @@ func ReadJSON(r *http.Request, dst any, maxBytes int64) error {
 	return nil
 }
 
-func (rs *Server) opCtx() (context.Context, context.CancelFunc) {
-	return context.WithTimeout(rs.StopCtx, opTimeout)
+// opCtx bounds a backend call made for the request. It ends with the server and not with the request, so a client that
+// goes away does not abort a half done write, but it carries the span of the request for tracing.
+func (rs *Server) opCtx(r *http.Request) (context.Context, context.CancelFunc) {
+	ctx := trace.ContextWithSpan(rs.StopCtx, trace.SpanFromContext(r.Context()))
+	return context.WithTimeout(ctx, opTimeout)
+}
+
+// parseAudioFilter reads an AudioFilter from the query parameters category, audio_type, stage, start_date, end_date and title.
+func parseAudioFilter(r *http.Request) (globalTypes.AudioFilter, error) {
+	query := r.URL.Query()
+
+	filter := globalTypes.AudioFilter{
+		Category:      strings.TrimSpace(query.Get("category")),
+		AudioType:     strings.TrimSpace(query.Get("audio_type")),
+		StartDateIso:  strings.TrimSpace(query.Get("start_date")),
+		EndDateIso:    strings.TrimSpace(query.Get("end_date")),
+		TitleContains: strings.TrimSpace(query.Get("title")),
+	}
+
+	if v := strings.TrimSpace(query.Get("stage")); v != "" {
+		stage, err := globalTypes.ParseProcessingStage(v)
+		if err != nil {
+			return filter, err
+		}
+		filter.Stage = &stage
+	}
+
+	if filter.StartDateIso != "" && !isIsoDate(filter.StartDateIso) {
+		return filter, fmt.Errorf("start_date %q is not an iso date", filter.StartDateIso)
+	}
+	if filter.EndDateIso != "" && !isIsoDate(filter.EndDateIso) {
+		return filter, fmt.Errorf("end_date %q is not an iso date", filter.EndDateIso)
+	}
+
+	return filter, nil
+}
+
+// isIsoDate accepts dates like "2026-03-01" and timestamps like "2026-03-01T09:00:00Z"
+func isIsoDate(v string) bool {
+	if _, err := time.Parse(time.DateOnly, v); err == nil {
+		return true
+	}
+	if _, err := time.Parse(time.RFC3339, v); err == nil {
+		return true
+	}
+	return false
 }
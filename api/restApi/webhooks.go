@@ -0,0 +1,343 @@
+package restApi
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go_audio_search_api_server/globalTypes"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultWebhookLogLimit = 50
+	maxWebhookLogLimit     = 500
+)
+
+func (rs *Server) handleListWebhooks(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := rs.opCtx(r)
+	hooks, err := rs.postgres.ListWebhooks(ctx, requestWorkspace(r))
+	cancel()
+
+	if err != nil {
+		slog.Error("Error while listing webhooks", "err", err)
+		rs.writeJson(w, http.StatusInternalServerError, map[string]any{
+			"ok":    false,
+			"code":  "WEBHOOK_LIST_FAILED",
+			"error": "Internal Server Error: " + err.Error(),
+		})
+		return
+	}
+
+	rs.writeJson(w, http.StatusOK, map[string]any{
+		"ok":       true,
+		"webhooks": hooks,
+	})
+}
+
+// handleCreateWebhook registers a webhook for the events of the requesting workspace. The secret is only part of this response, a generated one has to be
+// stored by the caller right away.
+func (rs *Server) handleCreateWebhook(w http.ResponseWriter, r *http.Request) {
+	slog.Info("Received request to POST /webhooks")
+
+	var req globalTypes.WebhookRequest
+	if err := ReadJSON(r, &req, 1<<20); err != nil {
+		rs.writeJson(w, http.StatusBadRequest, map[string]any{
+			"ok":    false,
+			"code":  "WEBHOOK_BAD_JSON",
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if err := req.ValidateApiInput(); err != nil {
+		rs.writeJson(w, http.StatusUnprocessableEntity, map[string]any{
+			"ok":    false,
+			"code":  "WEBHOOK_VALIDATION_FAILED",
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if req.Secret == "" {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			rs.writeWebhookError(w, err)
+			return
+		}
+		req.Secret = hex.EncodeToString(secret)
+	}
+
+	ctx, cancel := rs.opCtx(r)
+	hook, err := rs.postgres.CreateWebhook(ctx, requestWorkspace(r), req)
+	cancel()
+
+	if err != nil {
+		slog.Error("Error while creating webhook", "err", err)
+		rs.writeJson(w, http.StatusInternalServerError, map[string]any{
+			"ok":    false,
+			"code":  "WEBHOOK_CREATE_FAILED",
+			"error": "Internal Server Error: " + err.Error(),
+		})
+		return
+	}
+
+	rs.writeJson(w, http.StatusCreated, map[string]any{
+		"ok":      true,
+		"webhook": hook,
+	})
+}
+
+func (rs *Server) handleGetWebhook(w http.ResponseWriter, r *http.Request) {
+	id, ok := rs.webhookId(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := rs.opCtx(r)
+	hook, err := rs.postgres.GetWebhook(ctx, requestWorkspace(r), id)
+	cancel()
+
+	rs.writeWebhook(w, hook, err)
+}
+
+func (rs *Server) handleUpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	id, ok := rs.webhookId(w, r)
+	if !ok {
+		return
+	}
+
+	var patch globalTypes.WebhookPatch
+	if err := ReadJSON(r, &patch, 1<<20); err != nil {
+		rs.writeJson(w, http.StatusBadRequest, map[string]any{
+			"ok":    false,
+			"code":  "WEBHOOK_BAD_JSON",
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if err := patch.ValidateApiInput(); err != nil {
+		rs.writeJson(w, http.StatusUnprocessableEntity, map[string]any{
+			"ok":    false,
+			"code":  "WEBHOOK_VALIDATION_FAILED",
+			"error": err.Error(),
+		})
+		return
+	}
+
+	ctx, cancel := rs.opCtx(r)
+	hook, err := rs.postgres.UpdateWebhook(ctx, requestWorkspace(r), id, patch)
+	cancel()
+
+	rs.writeWebhook(w, hook, err)
+}
+
+func (rs *Server) handleDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id, ok := rs.webhookId(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := rs.opCtx(r)
+	err := rs.postgres.DeleteWebhook(ctx, requestWorkspace(r), id)
+	cancel()
+
+	if err != nil {
+		rs.writeWebhookError(w, err)
+		return
+	}
+
+	rs.writeJson(w, http.StatusOK, map[string]any{
+		"ok":      true,
+		"deleted": id,
+	})
+}
+
+// handleListWebhookEvents lists the outbox of a webhook, status filters for pending, delivered or failed events
+func (rs *Server) handleListWebhookEvents(w http.ResponseWriter, r *http.Request) {
+	id, ok := rs.webhookId(w, r)
+	if !ok {
+		return
+	}
+
+	status := strings.TrimSpace(r.URL.Query().Get("status"))
+	switch status {
+	case "", globalTypes.WebhookOutboxPending, globalTypes.WebhookOutboxDelivered, globalTypes.WebhookOutboxFailed:
+	default:
+		rs.writeJson(w, http.StatusBadRequest, map[string]any{
+			"ok":    false,
+			"code":  "WEBHOOK_BAD_QUERY",
+			"error": "status must be pending, delivered or failed",
+		})
+		return
+	}
+
+	limit, ok := rs.webhookLogLimit(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := rs.opCtx(r)
+	_, err := rs.postgres.GetWebhook(ctx, requestWorkspace(r), id)
+	var events []globalTypes.WebhookOutboxItem
+	if err == nil {
+		events, err = rs.postgres.ListWebhookOutbox(ctx, id, status, limit)
+	}
+	cancel()
+
+	if err != nil {
+		rs.writeWebhookError(w, err)
+		return
+	}
+
+	rs.writeJson(w, http.StatusOK, map[string]any{
+		"ok":     true,
+		"events": events,
+	})
+}
+
+// handleListWebhookDeliveries returns the delivery log of a webhook, one entry per attempt
+func (rs *Server) handleListWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	id, ok := rs.webhookId(w, r)
+	if !ok {
+		return
+	}
+
+	limit, ok := rs.webhookLogLimit(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := rs.opCtx(r)
+	_, err := rs.postgres.GetWebhook(ctx, requestWorkspace(r), id)
+	var deliveries []globalTypes.WebhookDelivery
+	if err == nil {
+		deliveries, err = rs.postgres.ListWebhookDeliveries(ctx, id, limit)
+	}
+	cancel()
+
+	if err != nil {
+		rs.writeWebhookError(w, err)
+		return
+	}
+
+	rs.writeJson(w, http.StatusOK, map[string]any{
+		"ok":         true,
+		"deliveries": deliveries,
+	})
+}
+
+// handleRetryWebhookEvent queues an event that was given up after all attempts once more
+func (rs *Server) handleRetryWebhookEvent(w http.ResponseWriter, r *http.Request) {
+	id, ok := rs.webhookId(w, r)
+	if !ok {
+		return
+	}
+
+	eventId, err := strconv.ParseInt(r.PathValue("eventId"), 10, 64)
+	if err != nil || eventId <= 0 {
+		rs.writeJson(w, http.StatusBadRequest, map[string]any{
+			"ok":    false,
+			"code":  "WEBHOOK_BAD_ID",
+			"error": "Event id must be a positive number",
+		})
+		return
+	}
+
+	ctx, cancel := rs.opCtx(r)
+	_, err = rs.postgres.GetWebhook(ctx, requestWorkspace(r), id)
+	cancel()
+
+	if err != nil {
+		rs.writeWebhookError(w, err)
+		return
+	}
+
+	ctx, cancel = rs.opCtx(r)
+	err = rs.postgres.RetryWebhookOutbox(ctx, id, eventId)
+	cancel()
+
+	if errors.Is(err, sql.ErrNoRows) {
+		rs.writeJson(w, http.StatusNotFound, map[string]any{
+			"ok":    false,
+			"code":  "WEBHOOK_EVENT_NOT_FOUND",
+			"error": "No failed event with this id for this webhook",
+		})
+		return
+	}
+	if err != nil {
+		rs.writeWebhookError(w, err)
+		return
+	}
+
+	rs.writeJson(w, http.StatusOK, map[string]any{
+		"ok":      true,
+		"retried": eventId,
+	})
+}
+
+func (rs *Server) webhookId(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		rs.writeJson(w, http.StatusBadRequest, map[string]any{
+			"ok":    false,
+			"code":  "WEBHOOK_BAD_ID",
+			"error": "Webhook id must be a positive number",
+		})
+		return 0, false
+	}
+	return id, true
+}
+
+func (rs *Server) webhookLogLimit(w http.ResponseWriter, r *http.Request) (int, bool) {
+	v := strings.TrimSpace(r.URL.Query().Get("limit"))
+	if v == "" {
+		return defaultWebhookLogLimit, true
+	}
+
+	limit, err := strconv.Atoi(v)
+	if err != nil || limit <= 0 || limit > maxWebhookLogLimit {
+		rs.writeJson(w, http.StatusBadRequest, map[string]any{
+			"ok":    false,
+			"code":  "WEBHOOK_BAD_QUERY",
+			"error": fmt.Sprintf("limit must be between 1 and %d", maxWebhookLogLimit),
+		})
+		return 0, false
+	}
+	return limit, true
+}
+
+func (rs *Server) writeWebhookError(w http.ResponseWriter, err error) {
+	if errors.Is(err, sql.ErrNoRows) {
+		rs.writeJson(w, http.StatusNotFound, map[string]any{
+			"ok":    false,
+			"code":  "WEBHOOK_NOT_FOUND",
+			"error": "No such webhook",
+		})
+		return
+	}
+
+	slog.Error("Error while handling webhook request", "err", err)
+	rs.writeJson(w, http.StatusInternalServerError, map[string]any{
+		"ok":    false,
+		"code":  "WEBHOOK_UPDATE_FAILED",
+		"error": "Internal Server Error: " + err.Error(),
+	})
+}
+
+func (rs *Server) writeWebhook(w http.ResponseWriter, hook *globalTypes.Webhook, err error) {
+	if err != nil {
+		rs.writeWebhookError(w, err)
+		return
+	}
+
+	rs.writeJson(w, http.StatusOK, map[string]any{
+		"ok":      true,
+		"webhook": hook,
+	})
+}
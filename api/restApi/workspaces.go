@@ -0,0 +1,203 @@
+package restApi
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"go_audio_search_api_server/globalTypes"
+	"go_audio_search_api_server/postgres"
+	"log/slog"
+	"net/http"
+)
+
+func (rs *Server) handleListWorkspaces(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := rs.opCtx(r)
+	workspaces, err := rs.postgres.ListWorkspaces(ctx)
+	cancel()
+
+	if err != nil {
+		slog.Error("Error while listing workspaces", "err", err)
+		rs.writeJson(w, http.StatusInternalServerError, map[string]any{
+			"ok":    false,
+			"code":  "WORKSPACE_LIST_FAILED",
+			"error": "Internal Server Error: " + err.Error(),
+		})
+		return
+	}
+
+	rs.writeJson(w, http.StatusOK, map[string]any{
+		"ok":         true,
+		"workspaces": workspaces,
+	})
+}
+
+// handleCreateWorkspace creates an empty workspace, api keys for it are created with POST /auth/keys
+func (rs *Server) handleCreateWorkspace(w http.ResponseWriter, r *http.Request) {
+	slog.Info("Received request to POST /workspaces")
+
+	var req globalTypes.WorkspaceRequest
+	if err := ReadJSON(r, &req, 1<<20); err != nil {
+		rs.writeJson(w, http.StatusBadRequest, map[string]any{
+			"ok":    false,
+			"code":  "WORKSPACE_BAD_JSON",
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if err := req.ValidateApiInput(); err != nil {
+		rs.writeJson(w, http.StatusUnprocessableEntity, map[string]any{
+			"ok":    false,
+			"code":  "WORKSPACE_VALIDATION_FAILED",
+			"error": err.Error(),
+		})
+		return
+	}
+
+	ctx, cancel := rs.opCtx(r)
+	workspace, err := rs.postgres.CreateWorkspace(ctx, req)
+	cancel()
+
+	if errors.Is(err, postgres.ErrWorkspaceExists) {
+		rs.writeJson(w, http.StatusConflict, map[string]any{
+			"ok":    false,
+			"code":  "WORKSPACE_EXISTS",
+			"error": "A workspace with id " + req.ID + " already exists",
+		})
+		return
+	}
+	if err != nil {
+		slog.Error("Error while creating workspace", "err", err)
+		rs.writeJson(w, http.StatusInternalServerError, map[string]any{
+			"ok":    false,
+			"code":  "WORKSPACE_CREATE_FAILED",
+			"error": "Internal Server Error: " + err.Error(),
+		})
+		return
+	}
+
+	slog.Info("Created workspace", "workspaceId", workspace.ID)
+
+	rs.writeJson(w, http.StatusCreated, map[string]any{
+		"ok":        true,
+		"workspace": workspace,
+	})
+}
+
+// handleDeleteWorkspace deletes every audio file of the workspace from all stores and then the workspace with its
+// api keys, webhooks and feed subscriptions. A failed delete leaves the workspace in place and can be retried.
+func (rs *Server) handleDeleteWorkspace(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	slog.Info("Received request to DELETE /workspaces/" + id)
+
+	if id == globalTypes.DefaultWorkspaceID || id == requestWorkspace(r) {
+		rs.writeJson(w, http.StatusConflict, map[string]any{
+			"ok":    false,
+			"code":  "WORKSPACE_PROTECTED",
+			"error": "The default workspace and the workspace of the requesting api key cannot be deleted",
+		})
+		return
+	}
+
+	ctx, cancel := rs.opCtx(r)
+	_, err := rs.postgres.GetWorkspace(ctx, id)
+	cancel()
+
+	if err != nil {
+		rs.writeWorkspaceDeleteError(w, id, err)
+		return
+	}
+
+	ctx, cancel = rs.opCtx(r)
+	hashes, err := rs.postgres.GetAudiofileHashesByFilter(ctx, globalTypes.AudioFilter{
+		Viewer: globalTypes.Viewer{WorkspaceID: id, SeesAll: true},
+	})
+	cancel()
+
+	if err != nil {
+		rs.writeWorkspaceDeleteError(w, id, err)
+		return
+	}
+
+	deleted := 0
+	var failedHashes []string
+	var failedErrors []string
+	status := http.StatusConflict
+
+	for _, hash := range hashes {
+		err := rs.deleteAudio(r, hash)
+		if err == nil || errors.Is(err, sql.ErrNoRows) {
+			deleted++
+			continue
+		}
+
+		failedHashes = append(failedHashes, hash)
+		failedErrors = append(failedErrors, err.Error())
+		if !errors.Is(err, postgres.ErrAudioInProcessing) {
+			status = http.StatusInternalServerError
+		}
+	}
+
+	if len(failedHashes) > 0 {
+		slog.Info(fmt.Sprintf("Workspace delete removed %d audio files, %d failed", deleted, len(failedHashes)), "workspaceId", id)
+		rs.writeJson(w, status, map[string]any{
+			"ok":    false,
+			"code":  "WORKSPACE_DELETE_INCOMPLETE",
+			"error": "Some audio files of the workspace could not be deleted, retry the request",
+			"deleted": map[string]any{
+				"count": deleted,
+			},
+			"failed": map[string]any{
+				"count":  len(failedHashes),
+				"hashes": failedHashes,
+				"errors": failedErrors,
+			},
+		})
+		return
+	}
+
+	ctx, cancel = rs.opCtx(r)
+	err = rs.postgres.DeleteWorkspace(ctx, id)
+	cancel()
+
+	if err != nil {
+		rs.writeWorkspaceDeleteError(w, id, err)
+		return
+	}
+
+	slog.Info("Deleted workspace", "workspaceId", id, "audioFiles", deleted)
+
+	rs.writeJson(w, http.StatusOK, map[string]any{
+		"ok":           true,
+		"workspace_id": id,
+		"deleted": map[string]any{
+			"count": deleted,
+		},
+	})
+}
+
+func (rs *Server) writeWorkspaceDeleteError(w http.ResponseWriter, id string, err error) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		rs.writeJson(w, http.StatusNotFound, map[string]any{
+			"ok":    false,
+			"code":  "WORKSPACE_NOT_FOUND",
+			"error": "No workspace with id " + id,
+		})
+
+	case errors.Is(err, postgres.ErrWorkspaceNotEmpty):
+		rs.writeJson(w, http.StatusConflict, map[string]any{
+			"ok":    false,
+			"code":  "WORKSPACE_NOT_EMPTY",
+			"error": "New audio files were imported into the workspace while it was deleted, retry the request",
+		})
+
+	default:
+		slog.Error("Error while deleting workspace", "workspaceId", id, "err", err)
+		rs.writeJson(w, http.StatusInternalServerError, map[string]any{
+			"ok":    false,
+			"code":  "WORKSPACE_DELETE_FAILED",
+			"error": "Internal Server Error: " + err.Error(),
+		})
+	}
+}
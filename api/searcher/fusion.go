@@ -0,0 +1,93 @@
+package searcher
+
+import (
+	"go_audio_search_api_server/globalTypes"
+	"sort"
+)
+
+// fuseRankings merges the lexical and the semantic ranking of a hybrid search into one list ordered by FusedScore.
+// A segment found by both lists keeps both scores, the first list decides the order of equal fused scores. A list with
+// the weight 0 is left out, so its segments do not fill up the top k.
+func fuseRankings(
+	lexical []globalTypes.SegmentElement,
+	semantic []globalTypes.SegmentElement,
+	fusion globalTypes.SearchFusion,
+	lexicalWeight float64,
+	semanticWeight float64,
+	rrfK uint64,
+) []globalTypes.SegmentElement {
+	if lexicalWeight == 0 {
+		lexical = nil
+	}
+	if semanticWeight == 0 {
+		semantic = nil
+	}
+
+	var lexicalScores, semanticScores []float64
+	if fusion == globalTypes.FusionWeighted {
+		lexicalScores = normalizedScores(lexical, func(s globalTypes.SegmentElement) float64 { return s.TsScore })
+		semanticScores = normalizedScores(semantic, func(s globalTypes.SegmentElement) float64 { return float64(s.QueryScore) })
+	}
+
+	var out []globalTypes.SegmentElement
+	index := map[string]int{}
+
+	add := func(segment globalTypes.SegmentElement, score float64) *globalTypes.SegmentElement {
+		idx, ok := index[segment.SegmentHash]
+		if !ok {
+			idx = len(out)
+			index[segment.SegmentHash] = idx
+			out = append(out, segment)
+		}
+		out[idx].FusedScore += score
+		return &out[idx]
+	}
+
+	for rank, segment := range lexical {
+		score := lexicalWeight / float64(rrfK+uint64(rank)+1)
+		if fusion == globalTypes.FusionWeighted {
+			score = lexicalWeight * lexicalScores[rank]
+		}
+		add(segment, score)
+	}
+
+	for rank, segment := range semantic {
+		score := semanticWeight / float64(rrfK+uint64(rank)+1)
+		if fusion == globalTypes.FusionWeighted {
+			score = semanticWeight * semanticScores[rank]
+		}
+		fused := add(segment, score)
+		fused.QueryScore = segment.QueryScore
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].FusedScore > out[j].FusedScore
+	})
+
+	return out
+}
+
+// normalizedScores scales the scores of a ranking to 0..1, so full text ranks and cosine similarities can be added.
+// If all scores are equal every segment gets 1.
+func normalizedScores(segments []globalTypes.SegmentElement, score func(globalTypes.SegmentElement) float64) []float64 {
+	out := make([]float64, len(segments))
+	if len(segments) == 0 {
+		return out
+	}
+
+	lowest, highest := score(segments[0]), score(segments[0])
+	for _, segment := range segments {
+		lowest = min(lowest, score(segment))
+		highest = max(highest, score(segment))
+	}
+
+	for i, segment := range segments {
+		if highest == lowest {
+			out[i] = 1
+			continue
+		}
+		out[i] = (score(segment) - lowest) / (highest - lowest)
+	}
+
+	return out
+}
@@ -0,0 +1,228 @@
+package searcher
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"go_audio_search_api_server/globalTypes"
+	"go_audio_search_api_server/tracing"
+	"log/slog"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// hybridCandidates is the least number of candidates taken from each ranking before the fusion
+const hybridCandidates = 100
+
+func (w *Worker) hybridSearch(ctx context.Context, searchQuery globalTypes.SearchRequest) *globalTypes.SearchResponse {
+	slog.Debug(fmt.Sprintf("Starting hybrid search for TsQuery=%s, SemanticQuery=%s", searchQuery.TsQuery, searchQuery.SemanticSearchQuery))
+	var response = globalTypes.SearchResponse{}
+
+	limit := searchQuery.MaxSegmentReturn
+	if limit == 0 {
+		limit = 10
+	}
+	candidateCount := max(limit, hybridCandidates)
+
+	// Creating Query Embedding
+	embedding, err := w.embedder.CreateEmbedding(ctx, searchQuery.SemanticSearchQuery)
+
+	if err != nil {
+		response.Err = "Error creating embedding for semantic search for query \"" + searchQuery.SemanticSearchQuery + "\": " + err.Error()
+		response.Ok = false
+		slog.Error(response.Err)
+		return &response
+	}
+
+	var segments []globalTypes.SegmentElement
+	if w.qdrant.HasBm25() {
+		segments, err = w.bm25Candidates(ctx, searchQuery, embedding, candidateCount, limit)
+	} else {
+		segments, err = w.postgresCandidates(ctx, searchQuery, embedding, candidateCount)
+	}
+
+	if err != nil {
+		response.Err = "Error finding hybrid candidates for query \"" + searchQuery.TsQuery + "\" / \"" + searchQuery.SemanticSearchQuery + "\": " + err.Error()
+		response.Ok = false
+		slog.Error(response.Err)
+		return &response
+	}
+
+	if len(segments) == 0 {
+		response.Err = "No candidates found for query: " + searchQuery.TsQuery + " / " + searchQuery.SemanticSearchQuery
+		response.Ok = false
+		slog.Error(response.Err)
+		return &response
+	}
+
+	if uint64(len(segments)) > limit {
+		segments = segments[:limit]
+	}
+
+	// Load data for Top K
+	stepCtx, span := tracing.Start(ctx, "search.load_segments", attribute.Int("segments", len(segments)))
+	var fullSegmentElements []globalTypes.SearchSegmentData
+	for _, segment := range segments {
+		callCtx, cancel := w.opCtx(stepCtx)
+		fullSegmentData, err := w.postgres.GetSegmentByHash(callCtx, segment.SegmentHash, searchQuery.Viewer)
+		cancel()
+
+		if err != nil {
+			slog.Error("Error loading segment: " + segment.SegmentHash + ", error: " + err.Error())
+			fullSegmentElements = append(fullSegmentElements, globalTypes.SearchSegmentData{
+				SegmentHash:   segment.SegmentHash,
+				AudiofileHash: segment.AudiofileHash,
+				Error:         "Error loading full segment data: " + err.Error(),
+				TsScore:       segment.TsScore,
+				QueryScore:    segment.QueryScore,
+				FusedScore:    segment.FusedScore,
+			})
+			continue
+		}
+
+		if fullSegmentData == nil {
+			// deleted meanwhile or not visible to the viewer, the hash must not show up in the response
+			slog.Warn("No full segment data found for segment hash: " + segment.SegmentHash)
+			continue
+		}
+
+		fullSegmentData.TsScore = segment.TsScore
+		fullSegmentData.QueryScore = segment.QueryScore
+		fullSegmentData.FusedScore = segment.FusedScore
+		fullSegmentElements = append(fullSegmentElements, *fullSegmentData)
+	}
+
+	span.End()
+
+	// Load connected audio data
+	var audioFileHashes []string
+	for _, segment := range fullSegmentElements {
+		contains := false
+		for _, savedHash := range audioFileHashes {
+			if savedHash == segment.AudiofileHash {
+				contains = true
+				break
+			}
+		}
+		if !contains {
+			audioFileHashes = append(audioFileHashes, segment.AudiofileHash)
+		}
+	}
+
+	stepCtx, span = tracing.Start(ctx, "search.load_audio", attribute.Int("audio_files", len(audioFileHashes)))
+	var relatedAudioElements []globalTypes.SearchAudioData
+	for _, audioFileHash := range audioFileHashes {
+		callCtx, cancel := w.opCtx(stepCtx)
+		audioData, err := w.postgres.GetSearchAudioDataByHash(callCtx, audioFileHash, searchQuery.Viewer)
+		cancel()
+
+		if errors.Is(err, sql.ErrNoRows) {
+			// restricted audio files never appear in RelatedAudioData
+			continue
+		}
+		if err != nil {
+			slog.Error("Error loading audio file data for hash: " + audioFileHash + ", error: " + err.Error())
+			relatedAudioElements = append(relatedAudioElements, globalTypes.SearchAudioData{
+				AudiofileHash: audioFileHash,
+				Error:         "Error loading audio file data for this audio hash: " + err.Error(),
+			})
+			continue
+		}
+		relatedAudioElements = append(relatedAudioElements, *audioData)
+	}
+
+	span.End()
+
+	response.Ok = true
+	response.RelatedAudioData = relatedAudioElements
+	response.TopKSegments = fullSegmentElements
+
+	slog.Info(fmt.Sprintf("Completed hybrid search for TsQuery=%s, SemanticQuery=%s", searchQuery.TsQuery, searchQuery.SemanticSearchQuery))
+
+	return &response
+}
+
+// bm25Candidates takes both rankings from Qdrant, the BM25 terms of the full text query rank the lexical side.
+// Reciprocal rank fusion runs inside Qdrant, the weighted fusion on the two rankings of one batch query.
+func (w *Worker) bm25Candidates(
+	ctx context.Context,
+	searchQuery globalTypes.SearchRequest,
+	embedding []float32,
+	candidateCount uint64,
+	limit uint64,
+) ([]globalTypes.SegmentElement, error) {
+	callCtx, cancel := w.opCtx(ctx)
+	defer cancel()
+
+	if searchQuery.Fusion == globalTypes.FusionRrf {
+		return w.qdrant.FusedCandidates(callCtx, searchQuery, embedding, candidateCount, limit)
+	}
+
+	lexical, semantic, err := w.qdrant.Bm25AndDenseCandidates(callCtx, searchQuery, embedding, candidateCount)
+	if err != nil {
+		return nil, err
+	}
+
+	return w.fuse(ctx, searchQuery, lexical, semantic), nil
+}
+
+// postgresCandidates ranks the lexical side by the full text search of Postgres, for collections without BM25 vectors
+func (w *Worker) postgresCandidates(
+	ctx context.Context,
+	searchQuery globalTypes.SearchRequest,
+	embedding []float32,
+	candidateCount uint64,
+) ([]globalTypes.SegmentElement, error) {
+	stepCtx, span := tracing.Start(ctx, "search.lexical_candidates")
+	callCtx, cancel := w.opCtx(stepCtx)
+	lexical, err := w.postgres.GetPostgresCandidates(
+		callCtx,
+		searchQuery.Viewer,
+		searchQuery.TsQuery,
+		int(candidateCount),
+		searchQuery.Category,
+		searchQuery.StartTimePeriodIso,
+		searchQuery.EndTimePeriodIso,
+	)
+	cancel()
+	tracing.End(span, err)
+
+	if err != nil {
+		return nil, err
+	}
+
+	callCtx, cancel = w.opCtx(ctx)
+	semantic, err := w.qdrant.QueryCandidates(
+		callCtx,
+		searchQuery.Viewer,
+		embedding,
+		searchQuery.Category,
+		searchQuery.StartTimePeriodIso,
+		searchQuery.EndTimePeriodIso,
+		candidateCount,
+	)
+	cancel()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return w.fuse(ctx, searchQuery, lexical, semantic), nil
+}
+
+func (w *Worker) fuse(
+	ctx context.Context,
+	searchQuery globalTypes.SearchRequest,
+	lexical []globalTypes.SegmentElement,
+	semantic []globalTypes.SegmentElement,
+) []globalTypes.SegmentElement {
+	lexicalWeight, semanticWeight := searchQuery.Weights()
+	_, span := tracing.Start(ctx, "search.fusion",
+		attribute.Int("lexical_candidates", len(lexical)),
+		attribute.Int("semantic_candidates", len(semantic)),
+	)
+	defer span.End()
+
+	return fuseRankings(lexical, semantic, searchQuery.Fusion, lexicalWeight, semanticWeight, searchQuery.RrfK)
+}
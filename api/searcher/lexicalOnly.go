@@ -1,19 +1,27 @@
 package searcher
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"go_audio_search_api_server/globalTypes"
+	"go_audio_search_api_server/tracing"
 	"log/slog"
+
+	"go.opentelemetry.io/otel/attribute"
 )
 
-func (w *Worker) lexicalSearch(searchQuery globalTypes.SearchRequest) *globalTypes.SearchResponse {
+func (w *Worker) lexicalSearch(ctx context.Context, searchQuery globalTypes.SearchRequest) *globalTypes.SearchResponse {
 	slog.Debug(fmt.Sprintf("Starting lexical only search for TsQuery=%s", searchQuery.TsQuery))
 	var response = globalTypes.SearchResponse{}
 
 	// Find Ts Candidates
-	ctx, cancel := w.opCtx()
+	stepCtx, span := tracing.Start(ctx, "search.lexical_candidates")
+	callCtx, cancel := w.opCtx(stepCtx)
 	candidates, err := w.postgres.GetPostgresCandidates(
-		ctx,
+		callCtx,
+		searchQuery.Viewer,
 		searchQuery.TsQuery,
 		int(searchQuery.MaxSegmentReturn),
 		searchQuery.Category,
@@ -21,6 +29,7 @@ func (w *Worker) lexicalSearch(searchQuery globalTypes.SearchRequest) *globalTyp
 		searchQuery.EndTimePeriodIso,
 	)
 	cancel()
+	tracing.End(span, err)
 
 	if err != nil {
 		response.Err = "Error finding FTS5 candidates for query \"" + searchQuery.TsQuery + "\": " + err.Error()
@@ -34,10 +43,11 @@ func (w *Worker) lexicalSearch(searchQuery globalTypes.SearchRequest) *globalTyp
 	}
 
 	// Load data for Top K
+	stepCtx, span = tracing.Start(ctx, "search.load_segments", attribute.Int("segments", len(candidates)))
 	var fullSegmentElements []globalTypes.SearchSegmentData
 	for _, segment := range candidates {
-		ctx, cancel = w.opCtx()
-		fullSegmentData, err := w.postgres.GetSegmentByHash(ctx, segment.SegmentHash)
+		callCtx, cancel := w.opCtx(stepCtx)
+		fullSegmentData, err := w.postgres.GetSegmentByHash(callCtx, segment.SegmentHash, searchQuery.Viewer)
 		cancel()
 
 		if err != nil {
@@ -62,17 +72,13 @@ func (w *Worker) lexicalSearch(searchQuery globalTypes.SearchRequest) *globalTyp
 			fullSegmentData.QueryScore = segment.QueryScore
 			fullSegmentElements = append(fullSegmentElements, *fullSegmentData)
 		} else {
+			// deleted meanwhile or not visible to the viewer, the hash must not show up in the response
 			slog.Warn("No full segment data found for segment hash: " + segment.SegmentHash)
-			fullSegmentElements = append(fullSegmentElements, globalTypes.SearchSegmentData{
-				SegmentHash:   segment.SegmentHash,
-				AudiofileHash: segment.AudiofileHash,
-				Error:         "No full segment data found for this segment",
-				TsScore:       segment.TsScore,
-				QueryScore:    segment.QueryScore,
-			})
 		}
 	}
 
+	span.End()
+
 	// Load connected audio data
 	var audioFileHashes []string
 	for _, segment := range fullSegmentElements {
@@ -88,12 +94,17 @@ func (w *Worker) lexicalSearch(searchQuery globalTypes.SearchRequest) *globalTyp
 		}
 	}
 
+	stepCtx, span = tracing.Start(ctx, "search.load_audio", attribute.Int("audio_files", len(audioFileHashes)))
 	var relatedAudioElements []globalTypes.SearchAudioData
 	for _, audioFileHash := range audioFileHashes {
-		ctx, cancel = w.opCtx()
-		audioData, err := w.postgres.GetSearchAudioDataByHash(ctx, audioFileHash)
+		callCtx, cancel := w.opCtx(stepCtx)
+		audioData, err := w.postgres.GetSearchAudioDataByHash(callCtx, audioFileHash, searchQuery.Viewer)
 		cancel()
 
+		if errors.Is(err, sql.ErrNoRows) {
+			// restricted audio files never appear in RelatedAudioData
+			continue
+		}
 		if err != nil {
 			slog.Error("Error loading audio file data for hash: " + audioFileHash + ", error: " + err.Error())
 			relatedAudioElements = append(relatedAudioElements, globalTypes.SearchAudioData{
@@ -105,6 +116,8 @@ func (w *Worker) lexicalSearch(searchQuery globalTypes.SearchRequest) *globalTyp
 		relatedAudioElements = append(relatedAudioElements, *audioData)
 	}
 
+	span.End()
+
 	response.Ok = true
 	response.RelatedAudioData = relatedAudioElements
 	response.TopKSegments = fullSegmentElements
@@ -1,19 +1,27 @@
 package searcher
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"go_audio_search_api_server/globalTypes"
+	"go_audio_search_api_server/tracing"
 	"log/slog"
+
+	"go.opentelemetry.io/otel/attribute"
 )
 
-func (w *Worker) normalSearch(searchQuery globalTypes.SearchRequest) *globalTypes.SearchResponse {
+func (w *Worker) normalSearch(ctx context.Context, searchQuery globalTypes.SearchRequest) *globalTypes.SearchResponse {
 	slog.Debug(fmt.Sprintf("Starting standard search for TsQuery=%s, SemanticQuery=%s", searchQuery.TsQuery, searchQuery.SemanticSearchQuery))
 	var response = globalTypes.SearchResponse{}
 
 	// Find Ts Candidates
-	ctx, cancel := w.opCtx()
+	stepCtx, span := tracing.Start(ctx, "search.lexical_candidates")
+	callCtx, cancel := w.opCtx(stepCtx)
 	candidates, err := w.postgres.GetPostgresCandidates(
-		ctx,
+		callCtx,
+		searchQuery.Viewer,
 		searchQuery.TsQuery,
 		100,
 		searchQuery.Category,
@@ -21,6 +29,7 @@ func (w *Worker) normalSearch(searchQuery globalTypes.SearchRequest) *globalType
 		searchQuery.EndTimePeriodIso,
 	)
 	cancel()
+	tracing.End(span, err)
 
 	if err != nil {
 		response.Err = "Error finding FTS5 candidates for query \"" + searchQuery.TsQuery + "\": " + err.Error()
@@ -39,7 +48,7 @@ func (w *Worker) normalSearch(searchQuery globalTypes.SearchRequest) *globalType
 	}
 
 	// Creating Query Embedding
-	embedding, err := w.embedder.CreateEmbedding(searchQuery.SemanticSearchQuery)
+	embedding, err := w.embedder.CreateEmbedding(ctx, searchQuery.SemanticSearchQuery)
 
 	if err != nil {
 		response.Err = "Error creating embedding for semantic search for query \"" + searchQuery.SemanticSearchQuery + "\": " + err.Error()
@@ -49,9 +58,10 @@ func (w *Worker) normalSearch(searchQuery globalTypes.SearchRequest) *globalType
 	}
 
 	// Qdrant Reranking
-	ctx, cancel = w.opCtx()
+	callCtx, cancel = w.opCtx(ctx)
 	segments, err := w.qdrant.RerankCandidatesByHashes(
-		ctx,
+		callCtx,
+		searchQuery.Viewer,
 		embedding,
 		segmentIds,
 		searchQuery.MaxSegmentReturn,
@@ -74,10 +84,11 @@ func (w *Worker) normalSearch(searchQuery globalTypes.SearchRequest) *globalType
 	}
 
 	// Load data for Top K
+	stepCtx, span = tracing.Start(ctx, "search.load_segments", attribute.Int("segments", len(segments)))
 	var fullSegmentElements []globalTypes.SearchSegmentData
 	for _, segment := range segments {
-		ctx, cancel = w.opCtx()
-		fullSegmentData, err := w.postgres.GetSegmentByHash(ctx, segment.SegmentHash)
+		callCtx, cancel := w.opCtx(stepCtx)
+		fullSegmentData, err := w.postgres.GetSegmentByHash(callCtx, segment.SegmentHash, searchQuery.Viewer)
 		cancel()
 
 		if err != nil {
@@ -102,17 +113,13 @@ func (w *Worker) normalSearch(searchQuery globalTypes.SearchRequest) *globalType
 			fullSegmentData.QueryScore = segment.QueryScore
 			fullSegmentElements = append(fullSegmentElements, *fullSegmentData)
 		} else {
+			// deleted meanwhile or not visible to the viewer, the hash must not show up in the response
 			slog.Warn("No full segment data found for segment hash: " + segment.SegmentHash)
-			fullSegmentElements = append(fullSegmentElements, globalTypes.SearchSegmentData{
-				SegmentHash:   segment.SegmentHash,
-				AudiofileHash: segment.AudiofileHash,
-				Error:         "No full segment data found for this segment",
-				TsScore:       segment.TsScore,
-				QueryScore:    segment.QueryScore,
-			})
 		}
 	}
 
+	span.End()
+
 	// Load connected audio data
 	var audioFileHashes []string
 	for _, segment := range fullSegmentElements {
@@ -128,12 +135,17 @@ func (w *Worker) normalSearch(searchQuery globalTypes.SearchRequest) *globalType
 		}
 	}
 
+	stepCtx, span = tracing.Start(ctx, "search.load_audio", attribute.Int("audio_files", len(audioFileHashes)))
 	var relatedAudioElements []globalTypes.SearchAudioData
 	for _, audioFileHash := range audioFileHashes {
-		ctx, cancel = w.opCtx()
-		audioData, err := w.postgres.GetSearchAudioDataByHash(ctx, audioFileHash)
+		callCtx, cancel := w.opCtx(stepCtx)
+		audioData, err := w.postgres.GetSearchAudioDataByHash(callCtx, audioFileHash, searchQuery.Viewer)
 		cancel()
 
+		if errors.Is(err, sql.ErrNoRows) {
+			// restricted audio files never appear in RelatedAudioData
+			continue
+		}
 		if err != nil {
 			slog.Error("Error loading audio file data for hash: " + audioFileHash + ", error: " + err.Error())
 			relatedAudioElements = append(relatedAudioElements, globalTypes.SearchAudioData{
@@ -145,6 +157,8 @@ func (w *Worker) normalSearch(searchQuery globalTypes.SearchRequest) *globalType
 		relatedAudioElements = append(relatedAudioElements, *audioData)
 	}
 
+	span.End()
+
 	response.Ok = true
 	response.RelatedAudioData = relatedAudioElements
 	response.TopKSegments = fullSegmentElements
@@ -1,17 +1,23 @@
 package searcher
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"go_audio_search_api_server/globalTypes"
+	"go_audio_search_api_server/tracing"
 	"log/slog"
+
+	"go.opentelemetry.io/otel/attribute"
 )
 
-func (w *Worker) semanticSearch(searchQuery globalTypes.SearchRequest) *globalTypes.SearchResponse {
+func (w *Worker) semanticSearch(ctx context.Context, searchQuery globalTypes.SearchRequest) *globalTypes.SearchResponse {
 	slog.Debug(fmt.Sprintf("Starting semantic search for SemanticQuery=%s", searchQuery.SemanticSearchQuery))
 	var response = globalTypes.SearchResponse{}
 
 	// Creating Query Embedding
-	embedding, err := w.embedder.CreateEmbedding(searchQuery.SemanticSearchQuery)
+	embedding, err := w.embedder.CreateEmbedding(ctx, searchQuery.SemanticSearchQuery)
 
 	if err != nil {
 		response.Err = "Error creating embedding for semantic search for query \"" + searchQuery.SemanticSearchQuery + "\": " + err.Error()
@@ -21,10 +27,14 @@ func (w *Worker) semanticSearch(searchQuery globalTypes.SearchRequest) *globalTy
 	}
 
 	// Qdrant Reranking
-	ctx, cancel := w.opCtx()
+	callCtx, cancel := w.opCtx(ctx)
 	segments, err := w.qdrant.QueryCandidates(
-		ctx,
+		callCtx,
+		searchQuery.Viewer,
 		embedding,
+		searchQuery.Category,
+		searchQuery.StartTimePeriodIso,
+		searchQuery.EndTimePeriodIso,
 		searchQuery.MaxSegmentReturn,
 	)
 
@@ -45,10 +55,11 @@ func (w *Worker) semanticSearch(searchQuery globalTypes.SearchRequest) *globalTy
 	}
 
 	// Load data for Top K
+	stepCtx, span := tracing.Start(ctx, "search.load_segments", attribute.Int("segments", len(segments)))
 	var fullSegmentElements []globalTypes.SearchSegmentData
 	for _, segment := range segments {
-		ctx, cancel = w.opCtx()
-		fullSegmentData, err := w.postgres.GetSegmentByHash(ctx, segment.SegmentHash)
+		callCtx, cancel := w.opCtx(stepCtx)
+		fullSegmentData, err := w.postgres.GetSegmentByHash(callCtx, segment.SegmentHash, searchQuery.Viewer)
 		cancel()
 
 		if err != nil {
@@ -63,9 +74,17 @@ func (w *Worker) semanticSearch(searchQuery globalTypes.SearchRequest) *globalTy
 			continue
 		}
 
+		if fullSegmentData == nil {
+			// deleted meanwhile or not visible to the viewer, the hash must not show up in the response
+			slog.Warn("No full segment data found for segment hash: " + segment.SegmentHash)
+			continue
+		}
+
 		fullSegmentElements = append(fullSegmentElements, *fullSegmentData)
 	}
 
+	span.End()
+
 	// Load connected audio data
 	var audioFileHashes []string
 	for _, segment := range fullSegmentElements {
@@ -81,12 +100,17 @@ func (w *Worker) semanticSearch(searchQuery globalTypes.SearchRequest) *globalTy
 		}
 	}
 
+	stepCtx, span = tracing.Start(ctx, "search.load_audio", attribute.Int("audio_files", len(audioFileHashes)))
 	var relatedAudioElements []globalTypes.SearchAudioData
 	for _, audioFileHash := range audioFileHashes {
-		ctx, cancel = w.opCtx()
-		audioData, err := w.postgres.GetSearchAudioDataByHash(ctx, audioFileHash)
+		callCtx, cancel := w.opCtx(stepCtx)
+		audioData, err := w.postgres.GetSearchAudioDataByHash(callCtx, audioFileHash, searchQuery.Viewer)
 		cancel()
 
+		if errors.Is(err, sql.ErrNoRows) {
+			// restricted audio files never appear in RelatedAudioData
+			continue
+		}
 		if err != nil {
 			slog.Error("Error loading audio file data for hash: " + audioFileHash + ", error: " + err.Error())
 			relatedAudioElements = append(relatedAudioElements, globalTypes.SearchAudioData{
@@ -98,6 +122,8 @@ func (w *Worker) semanticSearch(searchQuery globalTypes.SearchRequest) *globalTy
 		relatedAudioElements = append(relatedAudioElements, *audioData)
 	}
 
+	span.End()
+
 	response.Ok = true
 	response.RelatedAudioData = relatedAudioElements
 	response.TopKSegments = fullSegmentElements
@@ -2,8 +2,11 @@ package searcher
 
 import (
 	"context"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
-func (w *Worker) opCtx() (context.Context, context.CancelFunc) {
-	return context.WithTimeout(w.stopCtx, opTimeout)
+// opCtx bounds a backend call by opTimeout and the shutdown of the worker, the call is traced under the span in parent
+func (w *Worker) opCtx(parent context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(trace.ContextWithSpan(w.stopCtx, trace.SpanFromContext(parent)), opTimeout)
 }
@@ -6,9 +6,12 @@ import (
 	"go_audio_search_api_server/globalTypes"
 	"go_audio_search_api_server/postgres"
 	"go_audio_search_api_server/qdrant"
+	"go_audio_search_api_server/tracing"
 	"log/slog"
 	"sync"
 	"time"
+
+	"go.opentelemetry.io/otel/attribute"
 )
 
 const opTimeout = 5 * time.Minute
@@ -34,22 +37,27 @@ func NewWorker(ctx context.Context, wg *sync.WaitGroup, qdrant *qdrant.Worker, p
 	return &worker
 }
 
-func (w *Worker) Search(searchQuery globalTypes.SearchRequest) *globalTypes.SearchResponse {
-	if searchQuery.TsQuery != "" && searchQuery.SemanticSearchQuery != "" {
-		return w.normalSearch(searchQuery)
-	}
-
-	if searchQuery.TsQuery != "" && searchQuery.SemanticSearchQuery == "" {
-		return w.lexicalSearch(searchQuery)
-	}
-
-	if searchQuery.SemanticSearchQuery != "" && searchQuery.TsQuery == "" {
-		return w.semanticSearch(searchQuery)
+// Search runs the search mode the query asks for, its steps are traced as children of the span in ctx. The query
+// must have passed ValidateApiInput, which fills in the mode.
+func (w *Worker) Search(ctx context.Context, searchQuery globalTypes.SearchRequest) *globalTypes.SearchResponse {
+	ctx, span := tracing.Start(ctx, "search", attribute.String("search.mode", string(searchQuery.Mode)))
+	defer span.End()
+
+	switch searchQuery.Mode {
+	case globalTypes.SearchModeHybrid:
+		span.SetAttributes(attribute.String("search.fusion", string(searchQuery.Fusion)))
+		return w.hybridSearch(ctx, searchQuery)
+	case globalTypes.SearchModeRerank:
+		return w.normalSearch(ctx, searchQuery)
+	case globalTypes.SearchModeLexical:
+		return w.lexicalSearch(ctx, searchQuery)
+	case globalTypes.SearchModeSemantic:
+		return w.semanticSearch(ctx, searchQuery)
 	}
 
-	slog.Error("Received invalid")
+	slog.Error("Received invalid search mode", "mode", searchQuery.Mode)
 	return &globalTypes.SearchResponse{
-		Err: "Internal server error: Received invalid query, both TsQuery and SemanticSearchQuery are empty",
+		Err: "Internal server error: Received invalid query, unknown search mode \"" + string(searchQuery.Mode) + "\"",
 		Ok:  false,
 	}
 }
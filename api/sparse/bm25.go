@@ -0,0 +1,125 @@
+package sparse
+
+import (
+	"hash/fnv"
+	"log/slog"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/blevesearch/snowballstem"
+	"github.com/blevesearch/snowballstem/dutch"
+	"github.com/blevesearch/snowballstem/english"
+	"github.com/blevesearch/snowballstem/french"
+	"github.com/blevesearch/snowballstem/german"
+	"github.com/blevesearch/snowballstem/italian"
+	"github.com/blevesearch/snowballstem/portuguese"
+	"github.com/blevesearch/snowballstem/spanish"
+)
+
+const (
+	// k1 and b are the usual BM25 parameters
+	k1 = 1.2
+	b  = 0.75
+	// avgSegmentTokens is the assumed average length of a segment. Qdrant only knows the document frequencies, so
+	// the length normalization uses a fixed average instead of the one of the collection.
+	avgSegmentTokens = 20
+)
+
+var stemmers = map[string]func(*snowballstem.Env) bool{
+	"de": german.Stem,
+	"en": english.Stem,
+	"es": spanish.Stem,
+	"fr": french.Stem,
+	"it": italian.Stem,
+	"nl": dutch.Stem,
+	"pt": portuguese.Stem,
+}
+
+// Encoder turns text into sparse BM25 vectors. A document vector holds the term frequency part of BM25 for every
+// stemmed term, the inverse document frequency is applied by Qdrant with the idf modifier of the sparse vector.
+type Encoder struct {
+	stem func(*snowballstem.Env) bool
+}
+
+// NewEncoder stems in the given ISO 639-1 language, for languages without a stemmer the terms are only lowercased
+func NewEncoder(language string) *Encoder {
+	stem, ok := stemmers[strings.ToLower(language)]
+	if !ok {
+		slog.Warn("No stemmer for language, sparse vectors are built from unstemmed terms", "language", language)
+	}
+	return &Encoder{stem: stem}
+}
+
+// Document returns the sparse vector of a segment transcript, its indices are sorted
+func (e *Encoder) Document(text string) (indices []uint32, values []float32) {
+	terms := e.terms(text)
+
+	tf := map[uint32]float64{}
+	for _, term := range terms {
+		tf[termIndex(term)]++
+	}
+
+	norm := k1 * (1 - b + b*float64(len(terms))/avgSegmentTokens)
+	return sortedVector(tf, func(freq float64) float64 {
+		return freq * (k1 + 1) / (freq + norm)
+	})
+}
+
+// Query returns the sparse vector of a full text query in the websearch syntax of Postgres. Every term weighs 1, the
+// OR operator and negated words are dropped, quotes are ignored.
+func (e *Encoder) Query(query string) (indices []uint32, values []float32) {
+	var words []string
+	for _, word := range strings.Fields(query) {
+		if strings.EqualFold(word, "or") || strings.HasPrefix(word, "-") {
+			continue
+		}
+		words = append(words, word)
+	}
+
+	tf := map[uint32]float64{}
+	for _, term := range e.terms(strings.Join(words, " ")) {
+		tf[termIndex(term)] = 1
+	}
+
+	return sortedVector(tf, func(weight float64) float64 { return weight })
+}
+
+// terms splits text at everything that is not a letter or digit and stems the lowercased words
+func (e *Encoder) terms(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+
+	if e.stem == nil {
+		return words
+	}
+
+	for i, word := range words {
+		env := snowballstem.NewEnv(word)
+		e.stem(env)
+		words[i] = env.Current()
+	}
+	return words
+}
+
+// termIndex maps a term to its dimension, there is no vocabulary, so different terms may share one in rare cases
+func termIndex(term string) uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(term))
+	return h.Sum32()
+}
+
+func sortedVector(weights map[uint32]float64, value func(float64) float64) ([]uint32, []float32) {
+	indices := make([]uint32, 0, len(weights))
+	for index := range weights {
+		indices = append(indices, index)
+	}
+	slices.Sort(indices)
+
+	values := make([]float32, len(indices))
+	for i, index := range indices {
+		values[i] = float32(value(weights[index]))
+	}
+	return indices, values
+}
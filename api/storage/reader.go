@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// rangeReader is an io.ReadSeeker over a window of an object. The object is streamed from the current position
+// and only requested again after a seek, so http.ServeContent costs one range request per served range.
+type rangeReader struct {
+	ctx    context.Context
+	s      Storage
+	key    string
+	base   int64
+	size   int64
+	pos    int64
+	body   io.ReadCloser
+	bodyAt int64
+}
+
+// NewReadSeeker returns a reader over length bytes of key starting at offset. Close releases the open stream.
+func NewReadSeeker(ctx context.Context, s Storage, key string, offset int64, length int64) io.ReadSeekCloser {
+	return &rangeReader{ctx: ctx, s: s, key: key, base: offset, size: length}
+}
+
+func (r *rangeReader) Read(p []byte) (int, error) {
+	if r.pos >= r.size {
+		return 0, io.EOF
+	}
+
+	if r.body != nil && r.bodyAt != r.pos {
+		_ = r.body.Close()
+		r.body = nil
+	}
+
+	if r.body == nil {
+		body, err := r.s.ReadRange(r.ctx, r.key, r.base+r.pos, r.size-r.pos)
+		if err != nil {
+			return 0, err
+		}
+		r.body = body
+		r.bodyAt = r.pos
+	}
+
+	if remaining := r.size - r.pos; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+
+	n, err := r.body.Read(p)
+	r.pos += int64(n)
+	r.bodyAt = r.pos
+
+	if errors.Is(err, io.EOF) && r.pos < r.size {
+		return n, io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func (r *rangeReader) Seek(offset int64, whence int) (int64, error) {
+	var pos int64
+	switch whence {
+	case io.SeekStart:
+		pos = offset
+	case io.SeekCurrent:
+		pos = r.pos + offset
+	case io.SeekEnd:
+		pos = r.size + offset
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if pos < 0 {
+		return 0, errors.New("negative position")
+	}
+
+	r.pos = pos
+	return pos, nil
+}
+
+func (r *rangeReader) Close() error {
+	if r.body == nil {
+		return nil
+	}
+	err := r.body.Close()
+	r.body = nil
+	return err
+}
@@ -0,0 +1,513 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"go_audio_search_api_server/globalUtils"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// unsignedPayload lets the body be streamed instead of being hashed upfront
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+// s3PartSize is the part size of multipart uploads. A single PUT is capped at 5 GiB, larger objects are sent in
+// parts of this size, at most 10000 of them.
+const s3PartSize = 64 << 20
+
+// s3AbortTimeout bounds the abort of a failed multipart upload, it runs after the context of the upload is done
+const s3AbortTimeout = 30 * time.Second
+
+// S3 stores the objects in a bucket of an S3 compatible service like AWS S3 or MinIO.
+// Requests are signed with AWS Signature Version 4.
+type S3 struct {
+	endpoint  *url.URL
+	region    string
+	bucket    string
+	accessKey string
+	secretKey string
+	pathStyle bool
+	client    *http.Client
+	now       func() time.Time
+}
+
+// NewS3FromEnv reads S3_ENDPOINT, S3_BUCKET, S3_ACCESS_KEY_ID, S3_SECRET_ACCESS_KEY and the optional S3_REGION
+// and S3_PATH_STYLE. The bucket is created if it does not exist.
+func NewS3FromEnv() (*S3, error) {
+	endpoint, err := url.Parse(globalUtils.LoadEnvStr("S3_ENDPOINT"))
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid S3_ENDPOINT")
+	}
+
+	pathStyle, err := strconv.ParseBool(globalUtils.LoadEnvStrOr("S3_PATH_STYLE", "true"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid S3_PATH_STYLE: %w", err)
+	}
+
+	s := &S3{
+		endpoint:  endpoint,
+		region:    globalUtils.LoadEnvStrOr("S3_REGION", "us-east-1"),
+		bucket:    globalUtils.LoadEnvStr("S3_BUCKET"),
+		accessKey: globalUtils.LoadEnvStr("S3_ACCESS_KEY_ID"),
+		secretKey: globalUtils.LoadEnvStr("S3_SECRET_ACCESS_KEY"),
+		pathStyle: pathStyle,
+		client:    &http.Client{},
+		now:       time.Now,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := s.ensureBucket(ctx); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *S3) ensureBucket(ctx context.Context) error {
+	resp, err := s.do(ctx, http.MethodHead, "", nil, -1, nil)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	if resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("s3 bucket %s: unexpected status %s", s.bucket, resp.Status)
+	}
+
+	var body io.Reader
+	size := int64(0)
+	if s.region != "us-east-1" {
+		config := `<CreateBucketConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><LocationConstraint>` +
+			s.region + `</LocationConstraint></CreateBucketConfiguration>`
+		body = strings.NewReader(config)
+		size = int64(len(config))
+	}
+
+	resp, err = s.do(ctx, http.MethodPut, "", body, size, nil)
+	if err != nil {
+		return err
+	}
+	return checkResponse(resp, http.StatusOK)
+}
+
+func (s *S3) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	if err := validKey(key); err != nil {
+		return err
+	}
+
+	if size < 0 {
+		// a PUT needs the content length, spool unknown streams first
+		path, _, err := Spool(func(w io.Writer) error {
+			_, err := io.Copy(w, r)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		defer os.Remove(path)
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		stat, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		r, size = f, stat.Size()
+	}
+
+	if size > s3PartSize {
+		return s.putMultipart(ctx, key, r, size)
+	}
+
+	resp, err := s.do(ctx, http.MethodPut, key, r, size, http.Header{"Content-Type": {"audio/mpeg"}})
+	if err != nil {
+		return err
+	}
+	return checkResponse(resp, http.StatusOK)
+}
+
+// putMultipart uploads r in parts of s3PartSize. A failed upload is aborted, so its parts do not keep using space.
+func (s *S3) putMultipart(ctx context.Context, key string, r io.Reader, size int64) error {
+	if parts := (size + s3PartSize - 1) / s3PartSize; parts > 10000 {
+		return fmt.Errorf("s3 object %s is too large: %d bytes", key, size)
+	}
+
+	resp, err := s.doQuery(ctx, http.MethodPost, key, url.Values{"uploads": {""}}, nil, -1, http.Header{"Content-Type": {"audio/mpeg"}})
+	if err != nil {
+		return err
+	}
+
+	var initiated struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err := decodeResponse(resp, &initiated); err != nil {
+		return err
+	}
+	if initiated.UploadID == "" {
+		return fmt.Errorf("s3 multipart upload of %s: no upload id", key)
+	}
+
+	if err := s.uploadParts(ctx, key, initiated.UploadID, r, size); err != nil {
+		abortCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), s3AbortTimeout)
+		defer cancel()
+
+		resp, abortErr := s.doQuery(abortCtx, http.MethodDelete, key, url.Values{"uploadId": {initiated.UploadID}}, nil, -1, nil)
+		if abortErr == nil {
+			abortErr = checkResponse(resp, http.StatusNoContent, http.StatusOK)
+		}
+		if abortErr != nil && !errors.Is(abortErr, ErrNotFound) {
+			slog.Warn("Could not abort s3 multipart upload", "key", key, "uploadId", initiated.UploadID, "err", abortErr)
+		}
+		return err
+	}
+	return nil
+}
+
+type completedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+func (s *S3) uploadParts(ctx context.Context, key string, uploadID string, r io.Reader, size int64) error {
+	var parts []completedPart
+
+	for offset, number := int64(0), 1; offset < size; offset, number = offset+s3PartSize, number+1 {
+		length := min(s3PartSize, size-offset)
+
+		query := url.Values{"partNumber": {strconv.Itoa(number)}, "uploadId": {uploadID}}
+		resp, err := s.doQuery(ctx, http.MethodPut, key, query, io.LimitReader(r, length), length, nil)
+		if err != nil {
+			return err
+		}
+
+		etag := resp.Header.Get("ETag")
+		if err := checkResponse(resp, http.StatusOK); err != nil {
+			return err
+		}
+		if etag == "" {
+			return fmt.Errorf("s3 part %d of %s: no etag", number, key)
+		}
+
+		parts = append(parts, completedPart{PartNumber: number, ETag: etag})
+	}
+
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+		Parts   []completedPart `xml:"Part"`
+	}{Parts: parts})
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.doQuery(ctx, http.MethodPost, key, url.Values{"uploadId": {uploadID}}, bytes.NewReader(body), int64(len(body)), nil)
+	if err != nil {
+		return err
+	}
+
+	// the completion can fail after the 200 has been sent, the error is then the body
+	var completed struct {
+		XMLName xml.Name
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if err := decodeResponse(resp, &completed); err != nil {
+		return err
+	}
+	if completed.XMLName.Local == "Error" {
+		return fmt.Errorf("s3 complete multipart upload of %s: %s: %s", key, completed.Code, completed.Message)
+	}
+	return nil
+}
+
+func (s *S3) Get(ctx context.Context, key string) (io.ReadCloser, *ObjectInfo, error) {
+	if err := validKey(key); err != nil {
+		return nil, nil, err
+	}
+
+	resp, err := s.do(ctx, http.MethodGet, key, nil, -1, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, checkResponse(resp, http.StatusOK)
+	}
+
+	return resp.Body, objectInfo(key, resp), nil
+}
+
+func (s *S3) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	if err := validKey(key); err != nil {
+		return nil, err
+	}
+
+	resp, err := s.do(ctx, http.MethodHead, key, nil, -1, nil)
+	if err != nil {
+		return nil, err
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("s3 HEAD %s: unexpected status %s", key, resp.Status)
+	}
+
+	return objectInfo(key, resp), nil
+}
+
+func (s *S3) ReadRange(ctx context.Context, key string, offset int64, length int64) (io.ReadCloser, error) {
+	if err := validKey(key); err != nil {
+		return nil, err
+	}
+	if length == 0 {
+		return io.NopCloser(strings.NewReader("")), nil
+	}
+
+	rangeHeader := fmt.Sprintf("bytes=%d-", offset)
+	if length > 0 {
+		rangeHeader = fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)
+	}
+
+	resp, err := s.do(ctx, http.MethodGet, key, nil, -1, http.Header{"Range": {rangeHeader}})
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusPartialContent && resp.StatusCode != http.StatusOK {
+		return nil, checkResponse(resp, http.StatusPartialContent)
+	}
+
+	if resp.StatusCode == http.StatusOK {
+		// the service ignored the range, skip to the offset ourselves
+		if _, err := io.CopyN(io.Discard, resp.Body, offset); err != nil {
+			_ = resp.Body.Close()
+			return nil, err
+		}
+		if length > 0 {
+			return struct {
+				io.Reader
+				io.Closer
+			}{io.LimitReader(resp.Body, length), resp.Body}, nil
+		}
+	}
+
+	return resp.Body, nil
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	if err := validKey(key); err != nil {
+		return err
+	}
+
+	resp, err := s.do(ctx, http.MethodDelete, key, nil, -1, nil)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		_ = resp.Body.Close()
+		return nil
+	}
+	return checkResponse(resp, http.StatusNoContent, http.StatusOK)
+}
+
+// do sends a signed request for key, an empty key addresses the bucket itself
+func (s *S3) do(ctx context.Context, method string, key string, body io.Reader, size int64, header http.Header) (*http.Response, error) {
+	return s.doQuery(ctx, method, key, nil, body, size, header)
+}
+
+// doQuery is do with query parameters, the sub resources of the multipart upload are addressed by them
+func (s *S3) doQuery(ctx context.Context, method string, key string, query url.Values, body io.Reader, size int64, header http.Header) (*http.Response, error) {
+	u := *s.endpoint
+	if s.pathStyle {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + s.bucket
+	} else {
+		u.Host = s.bucket + "." + u.Host
+	}
+	if key != "" {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + key
+	}
+	u.RawPath = escapePath(u.Path)
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	s.sign(req, unsignedPayload)
+
+	return s.client.Do(req)
+}
+
+// sign adds the AWS Signature Version 4 Authorization header. Host, x-amz-* and Range are signed.
+func (s *S3) sign(req *http.Request, payloadHash string) {
+	now := s.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-amz-") || lower == "range" || lower == "content-type" {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		escapePath(req.URL.Path),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	signingKey := hmacSha256([]byte("AWS4"+s.secretKey), date)
+	signingKey = hmacSha256(signingKey, s.region)
+	signingKey = hmacSha256(signingKey, "s3")
+	signingKey = hmacSha256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSha256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey, scope, signedHeaders, signature,
+	))
+}
+
+func objectInfo(key string, resp *http.Response) *ObjectInfo {
+	info := &ObjectInfo{Key: key, Size: resp.ContentLength}
+	if modTime, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		info.ModTime = modTime
+	}
+	return info
+}
+
+// decodeResponse decodes the XML body of a 200 response into v
+func decodeResponse(resp *http.Response, v any) error {
+	if resp.StatusCode != http.StatusOK {
+		return checkResponse(resp, http.StatusOK)
+	}
+	defer resp.Body.Close()
+
+	if err := xml.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("s3 %s %s: %w", resp.Request.Method, resp.Request.URL.Path, err)
+	}
+	return nil
+}
+
+func checkResponse(resp *http.Response, expected ...int) error {
+	defer resp.Body.Close()
+
+	for _, status := range expected {
+		if resp.StatusCode == status {
+			return nil
+		}
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("s3 %s %s: %s: %s", resp.Request.Method, resp.Request.URL.Path, resp.Status, string(body))
+}
+
+// escapePath encodes every byte except the unreserved characters and slashes, as SigV4 requires
+func escapePath(path string) string {
+	var sb strings.Builder
+	for _, b := range []byte(path) {
+		if b == '/' || isUnreserved(b) {
+			sb.WriteByte(b)
+			continue
+		}
+		fmt.Fprintf(&sb, "%%%02X", b)
+	}
+	return sb.String()
+}
+
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, key := range keys {
+		values := query[key]
+		sort.Strings(values)
+		for _, value := range values {
+			parts = append(parts, escapeQuery(key)+"="+escapeQuery(value))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func escapeQuery(v string) string {
+	return strings.ReplaceAll(escapePath(v), "/", "%2F")
+}
+
+func isUnreserved(b byte) bool {
+	return 'A' <= b && b <= 'Z' || 'a' <= b && b <= 'z' || '0' <= b && b <= '9' ||
+		b == '-' || b == '_' || b == '.' || b == '~'
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSha256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
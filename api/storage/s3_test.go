@@ -0,0 +1,155 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+)
+
+// newTestS3 connects to the S3 service in S3_TEST_ENDPOINT, e.g. a local MinIO started with
+// docker run -p 9000:9000 minio/minio server /data. The tests are skipped without it.
+func newTestS3(t *testing.T) *S3 {
+	t.Helper()
+
+	endpoint := os.Getenv("S3_TEST_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("S3_TEST_ENDPOINT is not set")
+	}
+
+	accessKey := os.Getenv("S3_TEST_ACCESS_KEY_ID")
+	if accessKey == "" {
+		accessKey = "minioadmin"
+	}
+	secretKey := os.Getenv("S3_TEST_SECRET_ACCESS_KEY")
+	if secretKey == "" {
+		secretKey = "minioadmin"
+	}
+
+	t.Setenv("S3_ENDPOINT", endpoint)
+	t.Setenv("S3_BUCKET", "audio-search-test")
+	t.Setenv("S3_ACCESS_KEY_ID", accessKey)
+	t.Setenv("S3_SECRET_ACCESS_KEY", secretKey)
+
+	s, err := NewS3FromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// testData returns a reproducible stream of size bytes and the sha256 it has
+func testData(size int64) (io.Reader, []byte) {
+	h := sha256.New()
+	_, _ = io.CopyN(h, rand.NewChaCha8([32]byte{1}), size)
+	return io.LimitReader(rand.NewChaCha8([32]byte{1}), size), h.Sum(nil)
+}
+
+func TestS3PutMultipart(t *testing.T) {
+	s := newTestS3(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	// two full parts and a short last one
+	size := int64(2*s3PartSize + 1<<20)
+	data, sum := testData(size)
+	key := "test/multipart.mp3"
+
+	if err := s.Put(ctx, key, data, size); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = s.Delete(context.Background(), key) })
+
+	info, err := s.Stat(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size != size {
+		t.Fatalf("stored %d bytes, want %d", info.Size, size)
+	}
+
+	body, _, err := s.Get(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, body); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(h.Sum(nil), sum) {
+		t.Fatal("stored object differs from the uploaded data")
+	}
+}
+
+func TestS3PutMultipartAbortsOnShortBody(t *testing.T) {
+	s := newTestS3(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	size := int64(s3PartSize + 1<<20)
+	data, _ := testData(size - 1)
+	key := "test/aborted.mp3"
+
+	if err := s.Put(ctx, key, data, size); err == nil {
+		t.Fatal("put of a short body succeeded")
+	}
+
+	if _, err := s.Stat(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("stat after failed put: %v, want ErrNotFound", err)
+	}
+
+	resp, err := s.doQuery(ctx, http.MethodGet, "", url.Values{"uploads": {""}, "prefix": {key}}, nil, -1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var listed struct {
+		Uploads []struct {
+			Key string `xml:"Key"`
+		} `xml:"Upload"`
+	}
+	if err := decodeResponse(resp, &listed); err != nil {
+		t.Fatal(err)
+	}
+	if len(listed.Uploads) > 0 {
+		t.Fatalf("%d multipart uploads of %s were not aborted", len(listed.Uploads), key)
+	}
+}
+
+func TestS3PutSmallObject(t *testing.T) {
+	s := newTestS3(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	size := int64(1 << 20)
+	data, sum := testData(size)
+	key := "test/small.mp3"
+
+	if err := s.Put(ctx, key, data, size); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = s.Delete(context.Background(), key) })
+
+	body, _, err := s.Get(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, body); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(h.Sum(nil), sum) {
+		t.Fatal("stored object differs from the uploaded data")
+	}
+}
@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"go_audio_search_api_server/globalTypes"
+	"go_audio_search_api_server/globalUtils"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// SpoolDir is the local directory for files that are still being received, configured by STORAGE_SPOOL_DIR
+func SpoolDir() string {
+	return globalUtils.LoadEnvStrOr("STORAGE_SPOOL_DIR", filepath.Join(legacyAudioDir, "spool"))
+}
+
+// Spool writes into a temp file of the spool dir and hashes the content on the way
+func Spool(write func(w io.Writer) error) (path string, hash string, err error) {
+	dir := SpoolDir()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", "", err
+	}
+
+	tmp, err := os.CreateTemp(dir, ".spool-*")
+	if err != nil {
+		return "", "", err
+	}
+	tmpName := tmp.Name()
+
+	// cleanup only on error
+	defer func() {
+		if err != nil {
+			_ = tmp.Close()
+			_ = os.Remove(tmpName)
+		}
+	}()
+
+	h := sha256.New()
+	if err := write(io.MultiWriter(tmp, h)); err != nil {
+		return "", "", err
+	}
+	if err := tmp.Sync(); err != nil {
+		return "", "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", "", err
+	}
+
+	return tmpName, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// StoreAudio spools r and stores it under the AudioKey of its hash in the workspace, see globalTypes.WorkspaceAudioHash.
+// created is false if the storage already held a file with the same content in the workspace.
+func StoreAudio(ctx context.Context, s Storage, workspaceID string, r io.Reader) (key string, hash string, created bool, err error) {
+	path, hash, err := Spool(func(w io.Writer) error {
+		_, err := io.Copy(w, r)
+		return err
+	})
+	if err != nil {
+		return "", "", false, err
+	}
+
+	return StoreAudioFile(ctx, s, workspaceID, path, hash)
+}
+
+// StoreAudioFile moves a local file into the storage under the AudioKey of its hash in the workspace, the file is
+// consumed. contentHash may be empty if it is not known yet, the returned hash is the one of the workspace.
+func StoreAudioFile(ctx context.Context, s Storage, workspaceID string, path string, contentHash string) (key string, hash string, created bool, err error) {
+	// after a rename into a local storage the file is already gone
+	defer func() { _ = os.Remove(path) }()
+
+	if contentHash == "" {
+		contentHash, err = globalUtils.FileSha256Hex(path)
+		if err != nil {
+			return "", "", false, err
+		}
+	}
+	// a file per workspace, deleting the recording in one workspace must not remove it from another
+	hash = globalTypes.WorkspaceAudioHash(workspaceID, contentHash)
+	key = AudioKey(hash)
+
+	_, err = s.Stat(ctx, key)
+	if err == nil {
+		return key, hash, false, nil
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return "", "", false, err
+	}
+
+	if local, ok := s.(*Local); ok {
+		if err := local.putFile(ctx, key, path); err != nil {
+			return "", "", false, err
+		}
+		return key, hash, true, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", "", false, err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return "", "", false, err
+	}
+
+	if err := s.Put(ctx, key, f, stat.Size()); err != nil {
+		return "", "", false, err
+	}
+	return key, hash, true, nil
+}
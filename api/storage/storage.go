@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go_audio_search_api_server/globalUtils"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var ErrNotFound = errors.New("object not found")
+
+// StoreTimeout bounds storing one audio file. Uploads may be 20 GiB, which takes far longer than a backend call.
+const StoreTimeout = 2 * time.Hour
+
+// legacyAudioDir is where audio files were stored before the storage layer, rows may still point into it
+const legacyAudioDir = "/app/downloaded_audios"
+
+// ObjectInfo describes a stored object
+type ObjectInfo struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// Storage holds the audio files. Keys are slash separated relative paths like "marked/<hash>.mp3".
+type Storage interface {
+	// Put stores r under key, size is the length of r or -1 if unknown
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+	Get(ctx context.Context, key string) (io.ReadCloser, *ObjectInfo, error)
+	Stat(ctx context.Context, key string) (*ObjectInfo, error)
+	// ReadRange reads length bytes starting at offset, a negative length reads to the end
+	ReadRange(ctx context.Context, key string, offset int64, length int64) (io.ReadCloser, error)
+	// Delete removes key, a missing key is not an error
+	Delete(ctx context.Context, key string) error
+}
+
+// New creates the storage configured by STORAGE_BACKEND, "local" (default) or "s3"
+func New() (Storage, error) {
+	switch backend := globalUtils.LoadEnvStrOr("STORAGE_BACKEND", "local"); backend {
+	case "local":
+		return NewLocal(globalUtils.LoadEnvStrOr("STORAGE_LOCAL_ROOT", legacyAudioDir))
+	case "s3":
+		return NewS3FromEnv()
+	default:
+		return nil, fmt.Errorf("unknown STORAGE_BACKEND %q, must be local or s3", backend)
+	}
+}
+
+// AudioKey is the key of the audio file with the given content hash
+func AudioKey(hash string) string {
+	return "marked/" + hash + ".mp3"
+}
+
+// referenced reads files outside of the storage, see Locate
+var referenced = &Local{root: "/"}
+
+// Locate returns the storage and key of an audiofiles.download_path. Relative values are keys of s, absolute paths
+// are files on the local disk that are only referenced: watch folders in reference mode and rows that were written
+// before the storage layer existed.
+func Locate(s Storage, location string) (Storage, string) {
+	if !filepath.IsAbs(location) {
+		return s, location
+	}
+	return referenced, strings.TrimPrefix(filepath.Clean(location), "/")
+}
+
+// IsManaged reports whether the server owns the file at location and may delete it
+func IsManaged(location string) bool {
+	if !filepath.IsAbs(location) {
+		return location != ""
+	}
+	return isInside(legacyAudioDir, location) ||
+		isInside(globalUtils.LoadEnvStrOr("STORAGE_LOCAL_ROOT", legacyAudioDir), location) ||
+		isInside(SpoolDir(), location)
+}
+
+func isInside(root string, path string) bool {
+	rel, err := filepath.Rel(root, filepath.Clean(path))
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, "../")
+}
+
+// validKey rejects keys that could leave the storage root
+func validKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") {
+		return fmt.Errorf("invalid storage key %q", key)
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return fmt.Errorf("invalid storage key %q", key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package subtitles
+
+import (
+	"go_audio_search_api_server/ai"
+	"go_audio_search_api_server/globalTypes"
+	"sort"
+	"strings"
+)
+
+// charsPerSecond is the speaking rate used to estimate timestamps when neither timestamps nor the duration are known
+const charsPerSecond = 15.0
+
+// Cue is one sentence of the transcript with its time range
+type Cue struct {
+	Index    int     `json:"index"`
+	StartSec float64 `json:"start_sec"`
+	EndSec   float64 `json:"end_sec"`
+	Text     string  `json:"text"`
+}
+
+// BuildCues turns the stored segments back into one cue per sentence.
+// The segments overlap by several sentences, so every sentence is only taken from the first segment containing it.
+// Stored segment timestamps are used as anchors, the remaining sentence boundaries are interpolated by text length
+// over the duration. measured reports whether at least one real timestamp was available.
+func BuildCues(segments []globalTypes.SegmentElement, durationSec float64) (cues []Cue, measured bool) {
+	ordered := make([]globalTypes.SegmentElement, len(segments))
+	copy(ordered, segments)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].SentenceIndex < ordered[j].SentenceIndex
+	})
+
+	var sentences []string
+	// boundaries[k] is the start of sentence k, boundaries[len(sentences)] the end of the last one
+	known := map[int]float64{}
+
+	for _, segment := range ordered {
+		segmentSentences := ai.SplitIntoSentences(segment.Transcript)
+
+		for offset, sentence := range segmentSentences {
+			idx := segment.SentenceIndex + offset
+			if idx < len(sentences) {
+				continue
+			}
+			// a gap in the sentence indexes would mean missing segments, keep the order anyway
+			sentences = append(sentences, sentence)
+		}
+
+		if segment.StartSec != nil {
+			if _, ok := known[segment.SentenceIndex]; !ok {
+				known[segment.SentenceIndex] = *segment.StartSec
+			}
+			measured = true
+		}
+		if segment.EndSec != nil && len(segmentSentences) > 0 {
+			known[segment.SentenceIndex+len(segmentSentences)] = *segment.EndSec
+			measured = true
+		}
+	}
+
+	if len(sentences) == 0 {
+		return nil, measured
+	}
+
+	boundaries := interpolateBoundaries(sentences, known, durationSec)
+
+	cues = make([]Cue, 0, len(sentences))
+	for idx, sentence := range sentences {
+		cues = append(cues, Cue{
+			Index:    idx + 1,
+			StartSec: boundaries[idx],
+			EndSec:   boundaries[idx+1],
+			Text:     sentence,
+		})
+	}
+
+	return cues, measured
+}
+
+// interpolateBoundaries fills the sentence boundaries between the known anchors proportional to the text length.
+func interpolateBoundaries(sentences []string, known map[int]float64, durationSec float64) []float64 {
+	n := len(sentences)
+
+	// cumulative character count up to each boundary
+	chars := make([]float64, n+1)
+	for idx, sentence := range sentences {
+		chars[idx+1] = chars[idx] + float64(len(sentence)) + 1
+	}
+
+	if _, ok := known[0]; !ok {
+		known[0] = 0
+	}
+
+	if _, ok := known[n]; !ok {
+		switch {
+		case durationSec > 0:
+			known[n] = durationSec
+		default:
+			// extrapolate with the rate of the last known anchor or the default speaking rate
+			last := 0
+			for idx := range known {
+				if idx <= n && idx > last {
+					last = idx
+				}
+			}
+
+			rate := charsPerSecond
+			if last > 0 && known[last] > known[0] {
+				rate = (chars[last] - chars[0]) / (known[last] - known[0])
+			}
+			known[n] = known[last] + (chars[n]-chars[last])/rate
+		}
+	}
+
+	anchors := make([]int, 0, len(known))
+	for idx := range known {
+		if idx >= 0 && idx <= n {
+			anchors = append(anchors, idx)
+		}
+	}
+	sort.Ints(anchors)
+
+	boundaries := make([]float64, n+1)
+	for a := 0; a+1 < len(anchors); a++ {
+		from, to := anchors[a], anchors[a+1]
+		startSec, endSec := known[from], known[to]
+		if endSec < startSec {
+			endSec = startSec
+		}
+
+		span := chars[to] - chars[from]
+		for idx := from; idx <= to; idx++ {
+			if span == 0 {
+				boundaries[idx] = startSec
+				continue
+			}
+			boundaries[idx] = startSec + (endSec-startSec)*(chars[idx]-chars[from])/span
+		}
+	}
+
+	// keep the cues monotonic even if anchors disagree
+	for idx := 1; idx <= n; idx++ {
+		if boundaries[idx] < boundaries[idx-1] {
+			boundaries[idx] = boundaries[idx-1]
+		}
+	}
+
+	return boundaries
+}
+
+// PlainText joins the cues into the deduplicated transcript
+func PlainText(cues []Cue) string {
+	texts := make([]string, len(cues))
+	for idx, cue := range cues {
+		texts[idx] = cue.Text
+	}
+	return strings.Join(texts, " ")
+}
@@ -0,0 +1,155 @@
+package subtitles
+
+import (
+	"go_audio_search_api_server/globalTypes"
+	"math"
+	"testing"
+)
+
+func sec(v float64) *float64 {
+	return &v
+}
+
+// segment builds a stored segment the way the importer does, three sentences that overlap the next segment by two
+func segment(sentenceIndex int, transcript string, startSec *float64, endSec *float64) globalTypes.SegmentElement {
+	return globalTypes.SegmentElement{
+		SentenceIndex: sentenceIndex,
+		Transcript:    transcript,
+		StartSec:      startSec,
+		EndSec:        endSec,
+	}
+}
+
+// the sentences have the same length, so interpolated boundaries are evenly spaced
+var fourSentences = []globalTypes.SegmentElement{
+	segment(0, "Eins. Zwei. Drei.", nil, nil),
+	segment(1, "Zwei. Drei. Vier.", nil, nil),
+}
+
+func TestBuildCues(t *testing.T) {
+	tests := []struct {
+		name         string
+		segments     []globalTypes.SegmentElement
+		durationSec  float64
+		wantBounds   []float64
+		wantMeasured bool
+	}{
+		{
+			name:        "without times spread over the duration",
+			segments:    fourSentences,
+			durationSec: 24,
+			wantBounds:  []float64{0, 6, 12, 18, 24},
+		},
+		{
+			name:       "without times and duration at the default speaking rate",
+			segments:   fourSentences,
+			wantBounds: []float64{0, 6 / charsPerSecond, 12 / charsPerSecond, 18 / charsPerSecond, 24 / charsPerSecond},
+		},
+		{
+			name: "unsorted segments",
+			segments: []globalTypes.SegmentElement{
+				fourSentences[1],
+				fourSentences[0],
+			},
+			durationSec: 24,
+			wantBounds:  []float64{0, 6, 12, 18, 24},
+		},
+		{
+			name: "timed segments are anchors",
+			segments: []globalTypes.SegmentElement{
+				segment(0, "Eins. Zwei. Drei.", sec(10), sec(19)),
+				segment(1, "Zwei. Drei. Vier.", sec(13), sec(25)),
+			},
+			wantBounds:   []float64{10, 13, 16, 19, 25},
+			wantMeasured: true,
+		},
+		{
+			name: "partly timed segments are interpolated between the anchors",
+			segments: []globalTypes.SegmentElement{
+				segment(0, "Eins. Zwei. Drei.", nil, nil),
+				segment(1, "Zwei. Drei. Vier.", sec(4), sec(10)),
+			},
+			durationSec:  40,
+			wantBounds:   []float64{0, 4, 6, 8, 10},
+			wantMeasured: true,
+		},
+		{
+			name: "the end is extrapolated at the rate of the anchors",
+			segments: []globalTypes.SegmentElement{
+				segment(0, "Eins. Zwei. Drei.", sec(0), sec(9)),
+				segment(1, "Zwei. Drei. Vier.", nil, nil),
+			},
+			wantBounds:   []float64{0, 3, 6, 9, 12},
+			wantMeasured: true,
+		},
+		{
+			name: "overlapping times stay monotonic",
+			segments: []globalTypes.SegmentElement{
+				segment(0, "Eins. Zwei. Drei.", sec(10), sec(19)),
+				segment(1, "Zwei. Drei. Vier.", sec(5), sec(25)),
+			},
+			wantBounds:   []float64{10, 10, 12, 19, 25},
+			wantMeasured: true,
+		},
+		{
+			name: "an end before the start collapses the cue",
+			segments: []globalTypes.SegmentElement{
+				segment(0, "Eins. Zwei. Drei.", sec(8), sec(2)),
+			},
+			wantBounds:   []float64{8, 8, 8, 8},
+			wantMeasured: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cues, measured := BuildCues(tt.segments, tt.durationSec)
+
+			if measured != tt.wantMeasured {
+				t.Fatalf("measured = %v, want %v", measured, tt.wantMeasured)
+			}
+			if len(cues) != len(tt.wantBounds)-1 {
+				t.Fatalf("got %d cues, want %d: %+v", len(cues), len(tt.wantBounds)-1, cues)
+			}
+
+			for idx, cue := range cues {
+				if cue.Index != idx+1 {
+					t.Errorf("cue %d has index %d", idx, cue.Index)
+				}
+				if !near(cue.StartSec, tt.wantBounds[idx]) || !near(cue.EndSec, tt.wantBounds[idx+1]) {
+					t.Errorf("cue %d %q is %.3f-%.3f, want %.3f-%.3f",
+						idx, cue.Text, cue.StartSec, cue.EndSec, tt.wantBounds[idx], tt.wantBounds[idx+1])
+				}
+			}
+		})
+	}
+}
+
+func TestBuildCuesTakesEverySentenceOnce(t *testing.T) {
+	cues, _ := BuildCues(fourSentences, 0)
+
+	want := "Eins. Zwei. Drei. Vier."
+	if got := PlainText(cues); got != want {
+		t.Fatalf("PlainText = %q, want %q", got, want)
+	}
+}
+
+func TestBuildCuesWithoutSentences(t *testing.T) {
+	tests := map[string][]globalTypes.SegmentElement{
+		"no segments":          nil,
+		"no sentence endings":  {segment(0, "ohne Satzende", nil, nil)},
+		"only whitespace text": {segment(0, "  ", sec(1), sec(2))},
+	}
+
+	for name, segments := range tests {
+		t.Run(name, func(t *testing.T) {
+			if cues, _ := BuildCues(segments, 10); cues != nil {
+				t.Fatalf("got cues %+v, want none", cues)
+			}
+		})
+	}
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
@@ -0,0 +1,70 @@
+package subtitles
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// WriteSrt writes the cues in the SubRip format
+func WriteSrt(w io.Writer, cues []Cue) error {
+	for _, cue := range cues {
+		_, err := fmt.Fprintf(w, "%d\n%s --> %s\n%s\n\n",
+			cue.Index,
+			formatTimestamp(cue.StartSec, ","),
+			formatTimestamp(cue.EndSec, ","),
+			cue.Text,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteVtt writes the cues in the WebVTT format
+func WriteVtt(w io.Writer, cues []Cue) error {
+	if _, err := io.WriteString(w, "WEBVTT\n\n"); err != nil {
+		return err
+	}
+
+	for _, cue := range cues {
+		_, err := fmt.Fprintf(w, "%d\n%s --> %s\n%s\n\n",
+			cue.Index,
+			formatTimestamp(cue.StartSec, "."),
+			formatTimestamp(cue.EndSec, "."),
+			// "-->" would end the cue timing line early
+			strings.ReplaceAll(cue.Text, "-->", "->"),
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteTxt writes one sentence per line
+func WriteTxt(w io.Writer, cues []Cue) error {
+	for _, cue := range cues {
+		if _, err := fmt.Fprintln(w, cue.Text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formatTimestamp formats seconds as hh:mm:ss,mmm (SRT) or hh:mm:ss.mmm (WebVTT)
+func formatTimestamp(sec float64, msSeparator string) string {
+	if sec < 0 || math.IsNaN(sec) {
+		sec = 0
+	}
+
+	total := int64(math.Round(sec * 1000))
+	ms := total % 1000
+	s := (total / 1000) % 60
+	m := (total / 60000) % 60
+	h := total / 3600000
+
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", h, m, s, msSeparator, ms)
+}
@@ -0,0 +1,108 @@
+package subtitles
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestFormatTimestamp(t *testing.T) {
+	tests := []struct {
+		name string
+		sec  float64
+		srt  string
+		vtt  string
+	}{
+		{"zero", 0, "00:00:00,000", "00:00:00.000"},
+		{"milliseconds", 1.5, "00:00:01,500", "00:00:01.500"},
+		{"minutes", 61.25, "00:01:01,250", "00:01:01.250"},
+		{"rounding carries into the minute", 59.9996, "00:01:00,000", "00:01:00.000"},
+		{"last millisecond of the first hour", 3599.999, "00:59:59,999", "00:59:59.999"},
+		{"rounding carries into the hour", 3599.9996, "01:00:00,000", "01:00:00.000"},
+		{"hour rollover", 3600, "01:00:00,000", "01:00:00.000"},
+		{"hours, minutes and seconds", 3723.004, "01:02:03,004", "01:02:03.004"},
+		{"more than two digits of hours", 100 * 3600, "100:00:00,000", "100:00:00.000"},
+		{"negative", -3, "00:00:00,000", "00:00:00.000"},
+		{"not a number", math.NaN(), "00:00:00,000", "00:00:00.000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatTimestamp(tt.sec, ","); got != tt.srt {
+				t.Errorf("srt: got %s, want %s", got, tt.srt)
+			}
+			if got := formatTimestamp(tt.sec, "."); got != tt.vtt {
+				t.Errorf("vtt: got %s, want %s", got, tt.vtt)
+			}
+		})
+	}
+}
+
+var testCues = []Cue{
+	{Index: 1, StartSec: 0, EndSec: 2.5, Text: "Guten Morgen."},
+	{Index: 2, StartSec: 3599.5, EndSec: 3601, Text: "Der Pfeil --> zeigt weiter."},
+}
+
+func TestWriteSrt(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteSrt(&buf, testCues); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `1
+00:00:00,000 --> 00:00:02,500
+Guten Morgen.
+
+2
+00:59:59,500 --> 01:00:01,000
+Der Pfeil --> zeigt weiter.
+
+`
+	if got := buf.String(); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWriteVtt(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteVtt(&buf, testCues); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `WEBVTT
+
+1
+00:00:00.000 --> 00:00:02.500
+Guten Morgen.
+
+2
+00:59:59.500 --> 01:00:01.000
+Der Pfeil -> zeigt weiter.
+
+`
+	if got := buf.String(); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWriteVttWithoutCues(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteVtt(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != "WEBVTT\n\n" {
+		t.Fatalf("got %q, want only the header", got)
+	}
+}
+
+func TestWriteTxt(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteTxt(&buf, testCues); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "Guten Morgen.\nDer Pfeil --> zeigt weiter.\n"
+	if got := buf.String(); got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,139 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"go_audio_search_api_server/globalUtils"
+	"go_audio_search_api_server/metrics"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "go_audio_search_api_server"
+
+var tracer = otel.Tracer(tracerName)
+
+var propagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+// Init exports spans over OTLP/HTTP when OTEL_EXPORTER_OTLP_ENDPOINT or OTEL_EXPORTER_OTLP_TRACES_ENDPOINT is set,
+// the exporter reads the other OTEL_EXPORTER_OTLP_* variables itself. Without an endpoint spans are not recorded.
+// The returned function flushes the spans that are not exported yet.
+func Init(ctx context.Context) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagator)
+
+	if globalUtils.LoadEnvStrOr("OTEL_EXPORTER_OTLP_ENDPOINT", "") == "" &&
+		globalUtils.LoadEnvStrOr("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "") == "" {
+		slog.Info("Tracing disabled, OTEL_EXPORTER_OTLP_ENDPOINT is not set")
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(globalUtils.LoadEnvStrOr("OTEL_SERVICE_NAME", "audio-search-api")),
+	))
+	if err != nil && !errors.Is(err, resource.ErrSchemaURLConflict) {
+		return nil, err
+	}
+
+	// the sampler follows OTEL_TRACES_SAMPLER, by default a trace is sampled unless the caller did not sample it
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+
+	slog.Info("Tracing enabled, exporting spans over OTLP")
+	return provider.Shutdown, nil
+}
+
+// Start starts an internal span as child of the span in ctx
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records err on the span, if there is one, and ends it
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// StartCall starts a client span for a call to Whisper, Ollama or Qdrant. The returned function ends the span and
+// records the latency and the outcome of the call as metrics.
+func StartCall(ctx context.Context, service string, operation string) (context.Context, func(err error)) {
+	start := time.Now()
+	ctx, span := tracer.Start(ctx, service+" "+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("peer.service", service),
+			attribute.String("operation", operation),
+		),
+	)
+
+	return ctx, func(err error) {
+		metrics.ObserveCall(service, operation, start, &err)
+		End(span, err)
+	}
+}
+
+// InjectHeaders adds the traceparent of the span in ctx to an outgoing request
+func InjectHeaders(ctx context.Context, header http.Header) {
+	propagator.Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+// StartServer starts the span of an incoming request, continuing the trace of its traceparent header if it sent one
+func StartServer(ctx context.Context, header http.Header, name string) (context.Context, trace.Span) {
+	ctx = propagator.Extract(ctx, propagation.HeaderCarrier(header))
+	return tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindServer))
+}
+
+// TraceParent returns the W3C traceparent of the span in ctx, empty without a span. It is stored with
+// imported items so the pipeline stages can link to the request that queued them.
+func TraceParent(ctx context.Context) string {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return ""
+	}
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(ctx, carrier)
+	return carrier.Get("traceparent")
+}
+
+// LinkTo returns a link to the span of a stored traceparent, ok is false if traceParent is empty or invalid
+func LinkTo(traceParent string) (link trace.Link, ok bool) {
+	if traceParent == "" {
+		return trace.Link{}, false
+	}
+	ctx := propagation.TraceContext{}.Extract(context.Background(), propagation.MapCarrier{"traceparent": traceParent})
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return trace.Link{}, false
+	}
+	return trace.Link{SpanContext: sc}, true
+}
+
+// StartLinked starts a root span for asynchronous work that links to the span of the stored traceparent
+func StartLinked(ctx context.Context, name string, traceParent string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	opts := []trace.SpanStartOption{trace.WithNewRoot(), trace.WithAttributes(attrs...)}
+	if link, ok := LinkTo(traceParent); ok {
+		opts = append(opts, trace.WithLinks(link))
+	}
+	return tracer.Start(ctx, name, opts...)
+}
@@ -0,0 +1,451 @@
+package uploads
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrNotFound         = errors.New("upload not found")
+	ErrOffsetMismatch   = errors.New("upload offset does not match")
+	ErrTooLarge         = errors.New("upload exceeds its declared length")
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+	ErrUnknownAlgorithm = errors.New("unsupported checksum algorithm")
+	ErrInvalidChecksum  = errors.New("invalid Upload-Checksum")
+)
+
+// ChecksumAlgorithms lists the algorithms accepted in the Upload-Checksum header
+var ChecksumAlgorithms = []string{"md5", "sha1", "sha256"}
+
+// Info describes one resumable upload, it is stored as JSON next to the partial file
+type Info struct {
+	ID            string            `json:"id"`
+	Length        int64             `json:"length"`
+	Offset        int64             `json:"-"`
+	Metadata      map[string]string `json:"metadata"`
+	RawMetadata   string            `json:"raw_metadata"`
+	CreatedAt     time.Time         `json:"created_at"`
+	AudiofileHash string            `json:"audiofile_hash,omitempty"`
+	WorkspaceID   string            `json:"workspace_id,omitempty"`
+	Owner         string            `json:"owner,omitempty"`
+	ApiKeyID      int64             `json:"api_key_id,omitempty"`
+	// Queued is set once the finished upload is queued for processing, a repeated final PATCH does not queue it again
+	Queued    bool      `json:"queued,omitempty"`
+	ExpiresAt time.Time `json:"-"`
+}
+
+// Complete reports whether all bytes have been received
+func (i *Info) Complete() bool {
+	return i.Offset >= i.Length
+}
+
+// sweepInterval is how often expired uploads are looked for
+const sweepInterval = 15 * time.Minute
+
+// Store keeps partial uploads on disk, the offset of an upload is the size of its partial file.
+// Uploads without a write for longer than expiry are removed by the sweep.
+type Store struct {
+	dir    string
+	expiry time.Duration
+	locks  sync.Map
+}
+
+func NewStore(dir string, expiry time.Duration) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Store{dir: dir, expiry: expiry}, nil
+}
+
+// Create registers a new upload with its final length and tus Upload-Metadata
+func (s *Store) Create(length int64, rawMetadata string, metadata map[string]string, workspaceID string, owner string, apiKeyID int64) (*Info, error) {
+	info := &Info{
+		ID:          uuid.NewString(),
+		Length:      length,
+		Metadata:    metadata,
+		RawMetadata: rawMetadata,
+		CreatedAt:   time.Now().UTC(),
+		WorkspaceID: workspaceID,
+		Owner:       owner,
+		ApiKeyID:    apiKeyID,
+	}
+	info.ExpiresAt = info.CreatedAt.Add(s.expiry)
+
+	f, err := os.OpenFile(s.dataPath(info.ID), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+
+	if err := s.writeInfo(info); err != nil {
+		_ = os.Remove(s.dataPath(info.ID))
+		return nil, err
+	}
+
+	return info, nil
+}
+
+// Get loads an upload and its current offset
+func (s *Store) Get(id string) (*Info, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrNotFound
+	}
+
+	raw, err := os.ReadFile(s.infoPath(id))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var info Info
+	if err := json.Unmarshal(raw, &info); err != nil {
+		return nil, fmt.Errorf("corrupt upload info %s: %w", id, err)
+	}
+
+	info.ExpiresAt = s.lastWrite(id).Add(s.expiry)
+
+	if info.AudiofileHash != "" {
+		// the data file was moved into the audio store on completion
+		info.Offset = info.Length
+		return &info, nil
+	}
+
+	stat, err := os.Stat(s.dataPath(id))
+	if err != nil {
+		return nil, err
+	}
+	info.Offset = stat.Size()
+
+	return &info, nil
+}
+
+// Append writes a chunk at offset. With a checksum header value like "sha1 <base64>" the chunk is verified and
+// discarded again on a mismatch, so the client can resend it.
+func (s *Store) Append(id string, offset int64, r io.Reader, checksum string) (*Info, error) {
+	unlock := s.lock(id)
+	defer unlock()
+
+	info, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if info.AudiofileHash != "" || offset != info.Offset {
+		return info, ErrOffsetMismatch
+	}
+
+	var h hash.Hash
+	var expected []byte
+	if checksum != "" {
+		h, expected, err = parseChecksum(checksum)
+		if err != nil {
+			return info, err
+		}
+	}
+
+	f, err := os.OpenFile(s.dataPath(id), os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	var dst io.Writer = f
+	if h != nil {
+		dst = io.MultiWriter(f, h)
+	}
+
+	// one byte more than allowed tells that the client sent too much
+	remaining := info.Length - info.Offset
+	written, copyErr := io.Copy(dst, io.LimitReader(r, remaining+1))
+
+	if copyErr == nil && written > remaining {
+		copyErr = ErrTooLarge
+	}
+	if copyErr == nil && h != nil && !bytes.Equal(h.Sum(nil), expected) {
+		copyErr = ErrChecksumMismatch
+	}
+
+	// a broken connection keeps what was received so far, invalid chunks are removed again
+	if errors.Is(copyErr, ErrTooLarge) || errors.Is(copyErr, ErrChecksumMismatch) || (copyErr != nil && h != nil) {
+		_ = f.Truncate(info.Offset)
+		written = 0
+	}
+
+	if err := f.Sync(); err != nil && copyErr == nil {
+		copyErr = err
+	}
+	if err := f.Close(); err != nil && copyErr == nil {
+		copyErr = err
+	}
+
+	info.Offset += written
+	if written > 0 {
+		info.ExpiresAt = time.Now().Add(s.expiry)
+	}
+	return info, copyErr
+}
+
+// Finalize hands the upload and its completed data file to move, which returns the hash of the stored audio file.
+// The upload info keeps the hash, so a retried completion does not store the file twice.
+func (s *Store) Finalize(id string, move func(info *Info, dataPath string) (hash string, err error)) (*Info, error) {
+	unlock := s.lock(id)
+	defer unlock()
+
+	info, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if info.AudiofileHash != "" {
+		return info, nil
+	}
+	if !info.Complete() {
+		return info, fmt.Errorf("upload %s is incomplete: %d of %d bytes", id, info.Offset, info.Length)
+	}
+
+	hash, err := move(info, s.dataPath(id))
+	if err != nil {
+		return info, err
+	}
+
+	info.AudiofileHash = hash
+	if err := s.writeInfo(info); err != nil {
+		return info, err
+	}
+
+	return info, nil
+}
+
+// Queue hands a finalized upload to queue unless an earlier call succeeded, the info remembers the success.
+// It reports whether queue ran, repeated final PATCHes of a client that missed the answer only get the info.
+func (s *Store) Queue(id string, queue func(info *Info) error) (*Info, bool, error) {
+	unlock := s.lock(id)
+	defer unlock()
+
+	info, err := s.Get(id)
+	if err != nil {
+		return nil, false, err
+	}
+	if info.Queued {
+		return info, false, nil
+	}
+	if info.AudiofileHash == "" {
+		return info, false, fmt.Errorf("upload %s is not finalized", id)
+	}
+
+	if err := queue(info); err != nil {
+		return info, true, err
+	}
+
+	info.Queued = true
+	if err := s.writeInfo(info); err != nil {
+		return info, true, err
+	}
+
+	return info, true, nil
+}
+
+// Remove deletes an upload and its partial data
+func (s *Store) Remove(id string) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return ErrNotFound
+	}
+
+	unlock := s.lock(id)
+	defer unlock()
+
+	if _, err := os.Stat(s.infoPath(id)); os.IsNotExist(err) {
+		return ErrNotFound
+	}
+
+	if err := os.Remove(s.dataPath(id)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return os.Remove(s.infoPath(id))
+}
+
+// StartSweep removes expired uploads every sweepInterval until ctx is done
+func (s *Store) StartSweep(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(sweepInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				removed, err := s.Sweep(time.Now())
+				if err != nil {
+					slog.Error("Error while removing expired uploads", "err", err)
+				}
+				if removed > 0 {
+					slog.Info("Removed expired uploads", "count", removed)
+				}
+			}
+		}
+	}()
+}
+
+// Sweep removes the uploads whose last write is older than the expiry: abandoned partial uploads as well as the
+// info files of finished ones. Data files without an info file are left over by a crash and removed as well.
+func (s *Store) Sweep(now time.Time) (int, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return 0, err
+	}
+
+	ids := map[string]bool{}
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), ".tmp")
+		id := strings.TrimSuffix(strings.TrimSuffix(name, ".json"), ".bin")
+		if _, err := uuid.Parse(id); err == nil {
+			ids[id] = true
+		}
+	}
+
+	removed := 0
+	for id := range ids {
+		if s.sweepUpload(id, now) {
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+func (s *Store) sweepUpload(id string, now time.Time) bool {
+	unlock := s.lock(id)
+	defer unlock()
+
+	if now.Sub(s.lastWrite(id)) < s.expiry {
+		return false
+	}
+
+	for _, path := range []string{s.dataPath(id), s.infoPath(id), s.infoPath(id) + ".tmp"} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			slog.Warn("Could not remove expired upload file", "path", path, "err", err)
+		}
+	}
+	s.locks.Delete(id)
+	return true
+}
+
+// lastWrite is the latest modification of the files of an upload, appends touch the data file and state changes
+// the info file
+func (s *Store) lastWrite(id string) time.Time {
+	var last time.Time
+	for _, path := range []string{s.dataPath(id), s.infoPath(id), s.infoPath(id) + ".tmp"} {
+		if stat, err := os.Stat(path); err == nil && stat.ModTime().After(last) {
+			last = stat.ModTime()
+		}
+	}
+	return last
+}
+
+func (s *Store) lock(id string) func() {
+	m, _ := s.locks.LoadOrStore(id, &sync.Mutex{})
+	mu := m.(*sync.Mutex)
+	mu.Lock()
+	return mu.Unlock
+}
+
+func (s *Store) writeInfo(info *Info) error {
+	raw, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+
+	tmp := s.infoPath(info.ID) + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.infoPath(info.ID))
+}
+
+func (s *Store) dataPath(id string) string {
+	return filepath.Join(s.dir, id+".bin")
+}
+
+func (s *Store) infoPath(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
+
+// ParseMetadata decodes the tus Upload-Metadata header: comma separated pairs of key and base64 value.
+func ParseMetadata(header string) (map[string]string, error) {
+	out := map[string]string{}
+	if strings.TrimSpace(header) == "" {
+		return out, nil
+	}
+
+	for _, pair := range strings.Split(header, ",") {
+		fields := strings.Fields(pair)
+		if len(fields) == 0 || len(fields) > 2 {
+			return nil, fmt.Errorf("invalid Upload-Metadata pair %q", pair)
+		}
+
+		value := ""
+		if len(fields) == 2 {
+			decoded, err := base64.StdEncoding.DecodeString(fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid base64 for Upload-Metadata key %q", fields[0])
+			}
+			value = string(decoded)
+		}
+
+		if _, exists := out[fields[0]]; exists {
+			return nil, fmt.Errorf("duplicate Upload-Metadata key %q", fields[0])
+		}
+		out[fields[0]] = value
+	}
+
+	return out, nil
+}
+
+// parseChecksum parses "<algorithm> <base64 digest>", a malformed header is ErrInvalidChecksum
+func parseChecksum(header string) (hash.Hash, []byte, error) {
+	fields := strings.Fields(header)
+	if len(fields) != 2 {
+		return nil, nil, fmt.Errorf("%w: expected an algorithm and a base64 digest, got %q", ErrInvalidChecksum, header)
+	}
+
+	expected, err := base64.StdEncoding.DecodeString(fields[1])
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: digest is not base64", ErrInvalidChecksum)
+	}
+
+	var h hash.Hash
+	switch strings.ToLower(fields[0]) {
+	case "md5":
+		h = md5.New()
+	case "sha1":
+		h = sha1.New()
+	case "sha256":
+		h = sha256.New()
+	default:
+		return nil, nil, ErrUnknownAlgorithm
+	}
+
+	if len(expected) != h.Size() {
+		return nil, nil, fmt.Errorf("%w: %s digest has %d bytes, expected %d", ErrInvalidChecksum, fields[0], len(expected), h.Size())
+	}
+
+	return h, expected, nil
+}
@@ -0,0 +1,106 @@
+package watchFolder
+
+import (
+	"encoding/json"
+	"fmt"
+	"go_audio_search_api_server/globalTypes"
+	"go_audio_search_api_server/globalUtils"
+	"go_audio_search_api_server/storage"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// ModeMove copies the file into the managed store and removes it from the watch folder
+	ModeMove = "move"
+	// ModeReference leaves the file where it is, the watch folder must stay mounted
+	ModeReference = "reference"
+)
+
+// Folder maps a watched directory to the import defaults of the files found in it
+type Folder struct {
+	Path      string `json:"path"`
+	Category  string `json:"category"`
+	AudioType string `json:"audio_type"`
+	Mode      string `json:"mode"`
+	Recursive bool   `json:"recursive"`
+	Workspace string `json:"workspace"`
+}
+
+// LoadFolders reads WATCH_FOLDERS, a JSON array of Folder. Every folder has to lie inside one of the
+// comma separated WATCH_FOLDER_ROOTS, so a config change cannot expose arbitrary paths of the container.
+func LoadFolders() ([]Folder, error) {
+	raw := strings.TrimSpace(globalUtils.LoadEnvStrOr("WATCH_FOLDERS", ""))
+	if raw == "" {
+		return nil, nil
+	}
+
+	var folders []Folder
+	if err := json.Unmarshal([]byte(raw), &folders); err != nil {
+		return nil, fmt.Errorf("invalid WATCH_FOLDERS: %w", err)
+	}
+
+	var roots []string
+	for _, root := range strings.Split(globalUtils.LoadEnvStrOr("WATCH_FOLDER_ROOTS", ""), ",") {
+		root = strings.TrimSpace(root)
+		if root == "" {
+			continue
+		}
+		resolved, err := resolve(root)
+		if err != nil {
+			return nil, fmt.Errorf("invalid WATCH_FOLDER_ROOTS entry %s: %w", root, err)
+		}
+		roots = append(roots, resolved)
+	}
+
+	if len(folders) > 0 && len(roots) == 0 {
+		return nil, fmt.Errorf("WATCH_FOLDERS is set but WATCH_FOLDER_ROOTS is empty")
+	}
+
+	for idx := range folders {
+		folder := &folders[idx]
+
+		if folder.Mode == "" {
+			folder.Mode = ModeMove
+		}
+		if folder.Workspace == "" {
+			folder.Workspace = globalTypes.DefaultWorkspaceID
+		}
+		if folder.Mode != ModeMove && folder.Mode != ModeReference {
+			return nil, fmt.Errorf("watch folder %s: mode must be %s or %s", folder.Path, ModeMove, ModeReference)
+		}
+
+		resolved, err := resolve(folder.Path)
+		if err != nil {
+			return nil, fmt.Errorf("watch folder %s: %w", folder.Path, err)
+		}
+		if !insideAny(resolved, roots) {
+			return nil, fmt.Errorf("watch folder %s is not inside WATCH_FOLDER_ROOTS", folder.Path)
+		}
+		if storage.IsManaged(resolved) {
+			return nil, fmt.Errorf("watch folder %s is inside the managed audio store", folder.Path)
+		}
+		folder.Path = resolved
+	}
+
+	return folders, nil
+}
+
+// resolve makes the path absolute and follows symlinks, so a link cannot lead out of the roots
+func resolve(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(abs)
+}
+
+func insideAny(path string, roots []string) bool {
+	for _, root := range roots {
+		rel, err := filepath.Rel(root, path)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, "../") {
+			return true
+		}
+	}
+	return false
+}
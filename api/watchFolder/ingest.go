@@ -0,0 +1,174 @@
+package watchFolder
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go_audio_search_api_server/globalTypes"
+	"go_audio_search_api_server/globalUtils"
+	"go_audio_search_api_server/postgres"
+	"go_audio_search_api_server/storage"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Sidecar is the optional <file>.json or <name>.json next to an audio file, set fields override the folder defaults
+type Sidecar struct {
+	Title         string   `json:"title"`
+	RecordingDate string   `json:"recording_date"`
+	UserSummary   string   `json:"user_summary"`
+	Category      string   `json:"category"`
+	AudioType     string   `json:"audio_type"`
+	Owner         string   `json:"owner"`
+	Acl           []string `json:"acl"`
+}
+
+// ingest stores the file according to the folder mode and queues it at StageFilePersisted
+func (w *Worker) ingest(folder Folder, path string, state fileState) (status string, hash string, err error) {
+	sidecarPath, sidecar, err := readSidecar(path)
+	if err != nil {
+		return postgres.WatchedFileFailed, "", err
+	}
+
+	item := &globalTypes.AudioDataElement{
+		Title:         strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)),
+		RecordingDate: time.Unix(0, state.modTime).UTC().Format(time.RFC3339),
+		UserSummary:   "Recording from the watch folder " + folder.Path,
+		Category:      folder.Category,
+		AudioType:     folder.AudioType,
+	}
+	sidecar.apply(item)
+
+	if item.RecordingDate != "" && !isIsoDate(item.RecordingDate) {
+		return postgres.WatchedFileFailed, "", fmt.Errorf("sidecar recording_date %q is not an iso date", item.RecordingDate)
+	}
+	if item.Acl, err = globalTypes.NormalizeAcl(item.Acl); err != nil {
+		return postgres.WatchedFileFailed, "", fmt.Errorf("sidecar %w", err)
+	}
+
+	switch folder.Mode {
+	case ModeReference:
+		contentHash, err := globalUtils.FileSha256Hex(path)
+		if err != nil {
+			return postgres.WatchedFileFailed, "", err
+		}
+		hash = globalTypes.WorkspaceAudioHash(folder.Workspace, contentHash)
+		item.DownloadPath = path
+	default:
+		hash, item.DownloadPath, err = w.copyIntoStore(folder, path)
+		if err != nil {
+			return postgres.WatchedFileFailed, "", err
+		}
+	}
+	item.AudiofileHash = hash
+
+	ctx, cancel := w.opCtx()
+	existing, err := w.postgres.GetAudioDataByHash(ctx, hash)
+	cancel()
+
+	if err != nil {
+		return postgres.WatchedFileFailed, hash, err
+	}
+
+	if existing != nil {
+		// queueing it again would restart the pipeline of the stored file
+		slog.Info("Watched file is already imported", "path", path, "audioHash", hash)
+		status = postgres.WatchedFileDuplicate
+	} else {
+		item.LastSuccessfulStage = globalTypes.StageFilePersisted
+		item.ImportID = globalTypes.NewImportID()
+		item.WorkspaceID = folder.Workspace
+
+		ctx, cancel = w.opCtx()
+		err = w.postgres.UpsertBase(ctx, item)
+		cancel()
+
+		if err != nil {
+			return postgres.WatchedFileFailed, hash, err
+		}
+
+		slog.Info("Queued watched file", "path", path, "audioHash", hash, "mode", folder.Mode)
+		w.PoolRefillSignal.Trigger()
+		status = postgres.WatchedFileQueued
+	}
+
+	if folder.Mode == ModeMove {
+		removeIfExists(path)
+		if sidecarPath != "" {
+			removeIfExists(sidecarPath)
+		}
+	}
+
+	return status, hash, nil
+}
+
+// copyIntoStore streams the file into the storage, a rename is not possible across mounts
+func (w *Worker) copyIntoStore(folder Folder, path string) (hash string, key string, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", "", err
+	}
+	defer f.Close()
+
+	ctx, cancel := context.WithTimeout(w.stopCtx, storage.StoreTimeout)
+	defer cancel()
+
+	key, hash, _, err = storage.StoreAudio(ctx, w.storage, folder.Workspace, f)
+	return hash, key, err
+}
+
+func readSidecar(path string) (string, *Sidecar, error) {
+	candidates := []string{
+		path + ".json",
+		strings.TrimSuffix(path, filepath.Ext(path)) + ".json",
+	}
+
+	for _, candidate := range candidates {
+		raw, err := os.ReadFile(candidate)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", nil, err
+		}
+
+		var sidecar Sidecar
+		if err := json.Unmarshal(raw, &sidecar); err != nil {
+			return "", nil, fmt.Errorf("invalid sidecar %s: %w", candidate, err)
+		}
+		return candidate, &sidecar, nil
+	}
+
+	return "", &Sidecar{}, nil
+}
+
+func (s *Sidecar) apply(item *globalTypes.AudioDataElement) {
+	if s.Title != "" {
+		item.Title = s.Title
+	}
+	if s.RecordingDate != "" {
+		item.RecordingDate = s.RecordingDate
+	}
+	if s.UserSummary != "" {
+		item.UserSummary = s.UserSummary
+	}
+	if s.Category != "" {
+		item.Category = s.Category
+	}
+	if s.AudioType != "" {
+		item.AudioType = s.AudioType
+	}
+	item.Owner = s.Owner
+	item.Acl = s.Acl
+}
+
+func isIsoDate(v string) bool {
+	if _, err := time.Parse(time.DateOnly, v); err == nil {
+		return true
+	}
+	_, err := time.Parse(time.RFC3339, v)
+	return err == nil
+}
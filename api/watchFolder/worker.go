@@ -0,0 +1,210 @@
+package watchFolder
+
+import (
+	"context"
+	"go_audio_search_api_server/globalUtils"
+	"go_audio_search_api_server/postgres"
+	"go_audio_search_api_server/storage"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const opTimeout = 10 * time.Minute
+
+var audioExtensions = map[string]bool{
+	".mp3":  true,
+	".wav":  true,
+	".m4a":  true,
+	".aac":  true,
+	".flac": true,
+	".ogg":  true,
+	".opus": true,
+	".webm": true,
+}
+
+// fileState is what a scan saw of a file, a file is stable once two scans in a row saw the same state
+type fileState struct {
+	size    int64
+	modTime int64
+}
+
+type Worker struct {
+	PoolRefillSignal *globalUtils.NoneStackingEvent
+
+	workerWG     *sync.WaitGroup
+	stopCtx      context.Context
+	postgres     *postgres.Worker
+	storage      storage.Storage
+	folders      []Folder
+	scanInterval time.Duration
+
+	// lastSeen holds the state of every file of the previous scan, handled the state that was already claimed
+	lastSeen map[string]fileState
+	handled  map[string]fileState
+}
+
+// NewWorker starts scanning the configured watch folders, it returns nil if none are configured
+func NewWorker(ctx context.Context, wg *sync.WaitGroup, postgres *postgres.Worker, store storage.Storage, poolRefillSignal *globalUtils.NoneStackingEvent) *Worker {
+	folders, err := LoadFolders()
+	if err != nil {
+		panic(err)
+	}
+	if len(folders) == 0 {
+		return nil
+	}
+
+	worker := Worker{
+		PoolRefillSignal: poolRefillSignal,
+		workerWG:         wg,
+		stopCtx:          ctx,
+		postgres:         postgres,
+		storage:          store,
+		folders:          folders,
+		scanInterval:     time.Duration(globalUtils.LoadEnvIntOr("WATCH_FOLDER_SCAN_INTERVAL_SEC", 30)) * time.Second,
+		lastSeen:         map[string]fileState{},
+		handled:          map[string]fileState{},
+	}
+
+	ctx, cancel := worker.opCtx()
+	err = worker.postgres.ResetWatchedFileClaims(ctx)
+	cancel()
+
+	if err != nil {
+		slog.Error("Error resetting watched file claims in DB: " + err.Error())
+	}
+
+	for _, folder := range folders {
+		slog.Info("Watching folder", "path", folder.Path, "mode", folder.Mode, "category", folder.Category)
+	}
+
+	worker.workerWG.Add(1)
+	go worker.run()
+
+	return &worker
+}
+
+func (w *Worker) run() {
+	defer w.workerWG.Done()
+
+	ticker := time.NewTicker(w.scanInterval)
+	defer ticker.Stop()
+
+	for {
+		w.scan()
+
+		select {
+		case <-w.stopCtx.Done():
+			slog.Debug("Watch folder worker stopped", "reason", "stop_ctx_done")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// scan walks all folders and ingests the files that did not change since the previous scan
+func (w *Worker) scan() {
+	seen := map[string]fileState{}
+
+	for _, folder := range w.folders {
+		err := filepath.WalkDir(folder.Path, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				slog.Warn("Error while scanning watch folder", "path", path, "err", err)
+				return nil
+			}
+			if w.stopCtx.Err() != nil {
+				return filepath.SkipAll
+			}
+
+			if d.IsDir() {
+				if path != folder.Path && (!folder.Recursive || strings.HasPrefix(d.Name(), ".")) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			if !isAudioFile(d.Name()) {
+				return nil
+			}
+
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+
+			state := fileState{size: info.Size(), modTime: info.ModTime().UnixNano()}
+			seen[path] = state
+
+			if w.handled[path] == state || w.lastSeen[path] != state || state.size == 0 {
+				return nil
+			}
+
+			w.ingestIfNew(folder, path, state)
+			return nil
+		})
+		if err != nil {
+			slog.Error("Error while scanning watch folder", "path", folder.Path, "err", err)
+		}
+	}
+
+	w.lastSeen = seen
+
+	// forget files that are gone, e.g. after they were moved into the store
+	for path := range w.handled {
+		if _, ok := seen[path]; !ok {
+			delete(w.handled, path)
+		}
+	}
+}
+
+func (w *Worker) ingestIfNew(folder Folder, path string, state fileState) {
+	ctx, cancel := w.opCtx()
+	claimed, err := w.postgres.ClaimWatchedFile(ctx, path, state.size, time.Unix(0, state.modTime))
+	cancel()
+
+	if err != nil {
+		slog.Error("Error while claiming watched file", "path", path, "err", err)
+		return
+	}
+
+	w.handled[path] = state
+	if !claimed {
+		return
+	}
+
+	status, hash, ingestErr := w.ingest(folder, path, state)
+	errMsg := ""
+	if ingestErr != nil {
+		slog.Error("Error while ingesting watched file", "path", path, "err", ingestErr)
+		errMsg = ingestErr.Error()
+	}
+
+	ctx, cancel = w.opCtx()
+	err = w.postgres.FinishWatchedFile(ctx, path, status, hash, errMsg)
+	cancel()
+
+	if err != nil {
+		slog.Error("Error while storing watched file result", "path", path, "err", err)
+	}
+}
+
+func isAudioFile(name string) bool {
+	if strings.HasPrefix(name, ".") {
+		return false
+	}
+	return audioExtensions[strings.ToLower(filepath.Ext(name))]
+}
+
+func (w *Worker) opCtx() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(w.stopCtx, opTimeout)
+}
+
+func removeIfExists(path string) {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		slog.Warn("Could not remove ingested file from watch folder", "path", path, "err", err)
+	}
+}
@@ -0,0 +1,117 @@
+package webhooks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"go_audio_search_api_server/globalTypes"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const deliveryTimeout = 15 * time.Second
+
+// maxAttempts is the number of deliveries before an event is given up, the backoff spreads them over most of a day
+const maxAttempts = 12
+
+const (
+	minBackoff = 30 * time.Second
+	maxBackoff = 6 * time.Hour
+)
+
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// Sign returns the signature header value of a payload: the hex HMAC-SHA256 of "<timestamp>.<payload>" keyed with
+// the webhook secret. The timestamp is part of the signature, so receivers can reject replayed deliveries.
+func Sign(secret string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// backoff doubles the wait after every failed attempt
+func backoff(attempt int) time.Duration {
+	wait := minBackoff
+	for i := 1; i < attempt && wait < maxBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, maxBackoff)
+}
+
+func (w *Worker) deliver(item globalTypes.WebhookOutboxItem) {
+	start := time.Now()
+	status, err := w.post(item)
+
+	delivery := globalTypes.WebhookDelivery{
+		Success:        err == nil,
+		ResponseStatus: status,
+		DurationMs:     time.Since(start).Milliseconds(),
+	}
+
+	var retryAt time.Time
+	if err != nil {
+		delivery.Error = err.Error()
+		if item.Attempts < maxAttempts {
+			retryAt = time.Now().Add(backoff(item.Attempts))
+		}
+
+		slog.Warn("Webhook delivery failed",
+			"webhookId", item.WebhookID,
+			"outboxId", item.ID,
+			"event", item.EventType,
+			"attempt", item.Attempts,
+			"givingUp", retryAt.IsZero(),
+			"err", err,
+		)
+	}
+
+	ctx, cancel := w.opCtx()
+	err = w.postgres.FinishWebhookDelivery(ctx, item, delivery, retryAt)
+	cancel()
+
+	if err != nil {
+		slog.Error("Error while recording webhook delivery", "outboxId", item.ID, "err", err)
+	}
+}
+
+// post sends the event and returns the response status, every status outside 2xx is an error
+func (w *Worker) post(item globalTypes.WebhookOutboxItem) (int, error) {
+	req, err := http.NewRequestWithContext(w.stopCtx, http.MethodPost, item.Url, bytes.NewReader(item.Payload))
+	if err != nil {
+		return 0, fmt.Errorf("new request: %w", err)
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "go-audio-search-webhooks/1.0")
+	req.Header.Set(HeaderEvent, item.EventType)
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(item.ID, 10))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(item.Secret, timestamp, item.Payload))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return resp.StatusCode, fmt.Errorf("receiver answered %s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	return resp.StatusCode, nil
+}
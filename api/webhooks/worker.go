@@ -0,0 +1,103 @@
+package webhooks
+
+import (
+	"context"
+	"go_audio_search_api_server/postgres"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+const opTimeout = 1 * time.Minute
+
+// checkInterval is how often the dispatcher looks for due events
+const checkInterval = 5 * time.Second
+
+// batchSize is the number of events claimed at once, they are delivered in parallel
+const batchSize = 32
+
+// claimLease must be longer than a delivery takes, an unfinished claim is retried after it
+const claimLease = 2 * time.Minute
+
+type Worker struct {
+	workerWG *sync.WaitGroup
+	stopCtx  context.Context
+	postgres *postgres.Worker
+	client   *http.Client
+}
+
+func NewWorker(ctx context.Context, wg *sync.WaitGroup, postgres *postgres.Worker) *Worker {
+	worker := Worker{
+		workerWG: wg,
+		stopCtx:  ctx,
+		postgres: postgres,
+		client: &http.Client{
+			Timeout: deliveryTimeout,
+			// redirects are not followed, the signature was made for the registered url
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+
+	worker.workerWG.Add(1)
+	go worker.run()
+
+	return &worker
+}
+
+func (w *Worker) run() {
+	defer w.workerWG.Done()
+	slog.Debug("Started webhook dispatcher")
+
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stopCtx.Done():
+			slog.Debug("Webhook dispatcher stopped", "reason", "stop_ctx_done")
+			return
+		case <-ticker.C:
+			w.dispatchDueEvents()
+		}
+	}
+}
+
+// dispatchDueEvents delivers batches until no event is due anymore
+func (w *Worker) dispatchDueEvents() {
+	for w.stopCtx.Err() == nil {
+		ctx, cancel := w.opCtx()
+		items, err := w.postgres.ClaimDueWebhookOutbox(ctx, batchSize, claimLease)
+		cancel()
+
+		if err != nil {
+			slog.Error("Error while claiming due webhook events", "err", err)
+			return
+		}
+		if len(items) == 0 {
+			return
+		}
+
+		var g errgroup.Group
+		g.SetLimit(8)
+		for _, item := range items {
+			g.Go(func() error {
+				w.deliver(item)
+				return nil
+			})
+		}
+		_ = g.Wait()
+
+		if len(items) < batchSize {
+			return
+		}
+	}
+}
+
+func (w *Worker) opCtx() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(w.stopCtx, opTimeout)
+}
@@ -13,10 +13,15 @@ class API:
         self.import_url = f"{self.base_url}/import"
         self.health_url = f"{self.base_url}/health"
         self.search_url = f"{self.base_url}/search"
+        self.stats_url = f"{self.base_url}/stats"
+        self.stats_timeseries_url = f"{self.base_url}/stats/timeseries"
+
+        api_key = config_manager.ConfigManager().get_api_key()
+        self.headers = {"Authorization": f"Bearer {api_key}"} if api_key else {}
 
     def import_request(self, payload_list: list[ImportPayload]) -> None | list[dict] | str:
         request_payloads = [p.to_dict() for p in payload_list]
-        r = requests.post(self.import_url, json=request_payloads, timeout=300)
+        r = requests.post(self.import_url, json=request_payloads, headers=self.headers, timeout=300)
 
         ct = (r.headers.get("content-type") or "").lower()
         if "application/json" in ct:
@@ -46,6 +51,8 @@ class API:
                              f"Got {response_json.get('got')}")
             case 400:
                 error_str = f"Payload Json is invalid. Response: {response_json}"
+            case 401 | 403:
+                error_str = f"Access denied, check AUDIO_TRANSCRIPT_API_KEY: {response_json.get('error')}"
             case 422:
                 warning_str = "Every audio file in the payload is invalid."
                 all_invalid = True
@@ -84,9 +91,19 @@ class API:
         r = requests.get(self.health_url, timeout=10)
         return r.status_code == 200
 
+    def stats(self) -> dict:
+        r = requests.get(self.stats_url, headers=self.headers, timeout=30)
+        r.raise_for_status()
+        return r.json().get("stats", {})
+
+    def stats_timeseries(self, hours: int = 24) -> list[dict]:
+        r = requests.get(self.stats_timeseries_url, params={"hours": hours}, headers=self.headers, timeout=30)
+        r.raise_for_status()
+        return r.json().get("buckets", [])
+
     def search_request(self, payload: SearchPayload):
         request_payloads = payload.to_dict()
-        r = requests.post(self.search_url, json=request_payloads, timeout=600)
+        r = requests.post(self.search_url, json=request_payloads, headers=self.headers, timeout=600)
 
         ct = (r.headers.get("content-type") or "").lower()
         if "application/json" in ct:
@@ -112,6 +129,8 @@ class API:
                     f"Got {response_json.get('got')}")
             case 400:
                 error_str = f"Payload Json is invalid. Response: {response_json}"
+            case 401 | 403:
+                error_str = f"Access denied, check AUDIO_TRANSCRIPT_API_KEY: {response_json.get('error')}"
             case 422:
                 error_str = f"{response_json.get('error')}"
             case _:
@@ -2,7 +2,8 @@ import json
 import logging
 import os
 from pathlib import Path
-from sqlalchemy import text, create_engine
+
+import requests
 
 
 class ConfigManager:
@@ -14,21 +15,19 @@ class ConfigManager:
         self.load_config()
         logging.debug(f"Loaded Config: {self._config}")
 
-    def load_postgres_url(self):
-        return os.environ.get(
-            "POSTGRES_URL",
-            "user:password@localhost:5432/audio_transcript_db"
-        )
-
     def fetch_categories(self) -> list[str]:
-        engine = create_engine("postgresql+psycopg://" + self.load_postgres_url(), pool_pre_ping=True)
+        api_key = self.get_api_key()
+        headers = {"Authorization": f"Bearer {api_key}"} if api_key else {}
 
-        with engine.connect() as conn:
-            return list(conn.execute(text("""
-                                          SELECT DISTINCT category
-                                          FROM audiofiles
-                                          ORDER BY category
-                                          """)).scalars().all())
+        try:
+            r = requests.get(f"{self.get_api_base_url()}/stats", headers=headers, timeout=10)
+            r.raise_for_status()
+        except requests.RequestException as e:
+            logging.error(f"Could not load the categories from the backend: {e}")
+            return []
+
+        categories = r.json().get("stats", {}).get("categories", {})
+        return sorted(c for c in categories if c)
 
     def _resolve_base_dir(self) -> Path:
         env_data_dir = os.environ.get("DATA_DIR")
@@ -62,6 +61,10 @@ class ConfigManager:
         except Exception as e:
             logging.error(f"Error saving config: {e}")
 
+    def get_api_key(self) -> str:
+        # the key stays in the environment so it never ends up in the config file
+        return os.environ.get("AUDIO_TRANSCRIPT_API_KEY", "")
+
     def get_api_base_url(self):
         self.load_config()
         return self._config.get("api_base_url", "http://localhost:8880")
@@ -1,19 +1,7 @@
-import os
-
 import gradio as gr
 import pandas as pd
-from sqlalchemy import create_engine, text
-
-
-def load_postgres_url():
-    return os.environ.get("POSTGRES_URL", "user:password@localhost:5432/audio_transcript_db")
-
-
-engine = create_engine(
-    "postgresql+psycopg://" + load_postgres_url(),
-    pool_pre_ping=True,
-)
 
+from src.api.api import API
 
 def make_card(title: str, value: str, subtext: str = "", tone: str = "neutral") -> str:
     return f"""
@@ -45,154 +33,59 @@ def make_stage_strip(stages: list[tuple[str, int]]) -> str:
     return f'<div class="stage-strip">{"".join(parts)}</div>'
 
 
-def fetch_scalar(sql: str) -> int:
-    with engine.connect() as conn:
-        value = conn.execute(text(sql)).scalar_one()
-        return int(value)
-
+# the labels name the stage an audio file waits for, the keys are the last successful stage reported by the api
+STAGE_LABELS = [
+    ("queued", "Import Stage Persisting"),
+    ("file_persisted", "Import Stage Transcribing"),
+    ("transcribed", "Import Stage Embedding"),
+    ("embedded", "Import Stage AI Generation"),
+]
 
-def fetch_timeseries(ts_column: str, value_name: str, hours: int = 24) -> pd.DataFrame:
-    allowed_columns = {"created_at", "updated_at"}
-    if ts_column not in allowed_columns:
-        raise ValueError(f"unsupported timestamp column: {ts_column}")
 
-    sql = text(
-        f"""
-        WITH series AS (
-            SELECT generate_series(
-                date_trunc('hour', now() - make_interval(hours => :hours)),
-                date_trunc('hour', now()),
-                interval '1 hour'
-            ) AS bucket
-        ),
-        counts AS (
-            SELECT
-                date_trunc('hour', {ts_column}) AS bucket,
-                COUNT(*)::int AS count
-            FROM audiofiles
-            WHERE {ts_column} IS NOT NULL
-              AND {ts_column} >= now() - make_interval(hours => :hours)
-            GROUP BY 1
-        )
-        SELECT
-            series.bucket AS "Time",
-            COALESCE(counts.count, 0) AS "{value_name}"
-        FROM series
-        LEFT JOIN counts
-            ON series.bucket = counts.bucket
-        ORDER BY series.bucket
-        """
+def timeseries_frame(buckets: list[dict], key: str, value_name: str) -> pd.DataFrame:
+    df = pd.DataFrame(
+        [(b["time"], b.get(key, 0)) for b in buckets],
+        columns=["Time", value_name],
     )
-
-    df = pd.read_sql_query(sql, engine, params={"hours": hours})
     df["Time"] = pd.to_datetime(df["Time"])
     return df
 
 
 def load_stats():
-    # -----------------------------
-    # echte KPIs aus audiofiles
-    # -----------------------------
-    audio_amount = fetch_scalar("SELECT COUNT(*) FROM audiofiles")
-    segment_amount = fetch_scalar("SELECT COUNT(*) FROM segments")
-    search_requests = fetch_scalar("""
-                                   SELECT SUM(counter_value) AS total
-                                   FROM counters
-                                   WHERE counter_name IN ('search_requests_failed', 'search_requests_successful');
-                                   """)
+    api = API()
+    stats = api.stats()
+    buckets = api.stats_timeseries(hours=24)
+
+    counters = stats.get("counters", {})
+    audio_files = stats.get("audio_files", {})
+    queue = stats.get("queue", {})
 
-    import_requests = fetch_scalar("""
-                                   SELECT SUM(counter_value) AS total
-                                   FROM counters
-                                   WHERE counter_name IN ('import_requests_failed', 'import_requests_successful');
-                                   """)
+    search_requests = counters.get("search_requests_failed", 0) + counters.get("search_requests_successful", 0)
+    import_requests = counters.get("import_requests_failed", 0) + counters.get("import_requests_successful", 0)
 
-    audio_files_card = make_card("Audio Files", f"{audio_amount:,}", "rows in audiofiles")
-    audio_segments_card = make_card("Segment Amount", f"{segment_amount:,}", "rows in segments")
+    audio_files_card = make_card("Audio Files", f"{audio_files.get('total', 0):,}", "in this workspace")
+    audio_segments_card = make_card("Segment Amount", f"{stats.get('segments', 0):,}",
+                                    f"{stats.get('vectors', 0):,} vectors in qdrant")
+    audio_hours_card = make_card("Audio Hours", f"{stats.get('audio_hours', 0):,.1f}", "total duration")
     search_requests_card = make_card("Search Requests", f"{search_requests:,}", "search requests send")
     import_requests_card = make_card("Import Requests", f"{import_requests:,}", "import requests send")
 
-    # -----------------------------
-    # grobe Statussicht aus aktuellem Zustand
-    # -----------------------------
-    successful_imports = fetch_scalar(
-        "SELECT COUNT(*) FROM audiofiles WHERE last_successful_stage = 5"
-    )
-    in_processing_queue = fetch_scalar(
-        """
-        SELECT COUNT(*)
-        FROM audiofiles
-        WHERE gets_processed = true
-        """
-    )
-    awaits_processing = fetch_scalar(
-        """
-        SELECT COUNT(*)
-        FROM audiofiles
-        WHERE COALESCE(gets_processed, false) = false
-          AND last_successful_stage != 5
-        """
-    )
-    failed_imports = fetch_scalar(
-        """
-        SELECT COUNT(*)
-        FROM audiofiles
-        WHERE last_successful_stage = -1
-        """
-    )
-
-    imported_card = make_card("Successful Audio Imports", str(successful_imports), "All Import steps Completed", "ok")
-    processed_card = make_card("Audio In Queue", str(in_processing_queue), "Processing Item", "neutral")
-    waiting_card = make_card("Audio Waiting", str(awaits_processing), "Waiting for Processing", "warn")
-    errors_card = make_card("Audio Failed", str(failed_imports), "Steps failed 10 times", "err")
-
-    # -----------------------------
-    # Pipeline aus realen Feldern
-    # -----------------------------
-    stage_queued = fetch_scalar(
-        """
-        SELECT COUNT(*)
-        FROM audiofiles
-        WHERE last_successful_stage = 1
-        """
-    )
-    stage_persisted = fetch_scalar(
-        """
-        SELECT COUNT(*)
-        FROM audiofiles
-        WHERE last_successful_stage = 2
-        """
-    )
-    stage_transcribed = fetch_scalar(
-        """
-        SELECT COUNT(*)
-        FROM audiofiles
-        WHERE last_successful_stage = 3
-        """
-    )
-    stage_embedded = fetch_scalar(
-        """
-        SELECT COUNT(*)
-        FROM audiofiles
-        WHERE last_successful_stage = 4
-        """
-    )
+    imported_card = make_card("Successful Audio Imports", str(audio_files.get("completed", 0)),
+                              "All Import steps Completed", "ok")
+    processed_card = make_card("Audio In Queue", str(audio_files.get("processing", 0)), "Processing Item", "neutral")
+    waiting_card = make_card("Audio Waiting", str(audio_files.get("waiting", 0)), "Waiting for Processing", "warn")
+    errors_card = make_card("Audio Failed", str(audio_files.get("failed", 0)), "Steps failed 10 times", "err")
 
-    stage_strip = make_stage_strip([
-        ("Import Stage Persisting", stage_queued),
-        ("Import Stage Transcribing", stage_persisted),
-        ("Import Stage Embedding", stage_transcribed),
-        ("Import Stage AI Generation", stage_embedded),
-    ])
+    # stages after the final stage of the pipeline are not part of the queue, e.g. the AI stage without an LLM
+    stage_strip = make_stage_strip([(label, queue[key]) for key, label in STAGE_LABELS if key in queue])
 
-    # -----------------------------
-    # echte DB-Zeitreihen
-    # -----------------------------
-    created_df = fetch_timeseries("created_at", "Created", hours=24)
+    created_df = timeseries_frame(buckets, "audio_files_imported", "Created")
+    searches_df = timeseries_frame(buckets, "search_requests", "Searches")
 
     return (
         audio_files_card,
         audio_segments_card,
+        audio_hours_card,
         search_requests_card,
         import_requests_card,
         imported_card,
@@ -201,6 +94,7 @@ def load_stats():
         errors_card,
         stage_strip,
         created_df,
+        searches_df,
     )
 
 
@@ -210,6 +104,7 @@ def mount_statistics_routes(app: gr.Blocks):
         with gr.Row():
             audio_files_display = gr.HTML()
             audio_segments_display = gr.HTML()
+            audio_hours_display = gr.HTML()
             search_requests_display = gr.HTML()
             import_requests_display = gr.HTML()
 
@@ -228,14 +123,20 @@ def mount_statistics_routes(app: gr.Blocks):
                 insertions_plot = gr.LinePlot(
                     x="Time",
                     y="Created",
-                    title="Created rows (last 24h)",
+                    title="Imported audio files (last 24h)",
+                )
+                searches_plot = gr.LinePlot(
+                    x="Time",
+                    y="Searches",
+                    title="Search requests (last 24h)",
                 )
 
-        timer = gr.Timer(value=1.0, active=True)
+        timer = gr.Timer(value=5.0, active=True)
 
         outputs = [
             audio_files_display,
             audio_segments_display,
+            audio_hours_display,
             search_requests_display,
             import_requests_display,
             imported_display,
@@ -244,6 +145,7 @@ def mount_statistics_routes(app: gr.Blocks):
             errors_display,
             stage_strip_display,
             insertions_plot,
+            searches_plot,
         ]
 
         timer.tick(
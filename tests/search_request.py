@@ -1,3 +1,4 @@
+import os
 import time
 from typing import Any
 
@@ -7,8 +8,11 @@ import requests
 def search_request(payload: SearchPayload, url: str) -> tuple[bool, Any, float]:
     request_payload = payload.to_dict()
 
+    api_key = os.environ.get("AUDIO_TRANSCRIPT_API_KEY", "")
+    headers = {"Authorization": f"Bearer {api_key}"} if api_key else {}
+
     start = time.perf_counter()
-    r = requests.post(url, json=request_payload, timeout=600)
+    r = requests.post(url, json=request_payload, headers=headers, timeout=600)
     end = time.perf_counter()
 
     respond_time = end - start